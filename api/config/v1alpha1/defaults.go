@@ -0,0 +1,111 @@
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetDefaults fills in the unset fields of a configuration
+func SetDefaults(config *LabelerConfiguration) {
+	if config.Nautobot.Client == "" {
+		config.Nautobot.Client = NautobotClientREST
+	}
+	if config.ThermalZone != nil && config.ThermalZone.Label == "" {
+		config.ThermalZone.Label = ThermalZoneLabel
+	}
+	if config.Mappings == nil {
+		// Topology domains, location types, rack groups and the thermal zone take over the default
+		// labels they map to
+		claimed := map[string]bool{}
+		for _, domain := range config.TopologyDomains {
+			claimed[TopologyDomainLabel(domain)] = true
+		}
+		for _, locationType := range config.LocationTypes {
+			claimed[locationType.Label] = true
+		}
+		for _, rackGroup := range config.RackGroups {
+			claimed[rackGroup.Label] = true
+		}
+		if config.ThermalZone != nil {
+			claimed[config.ThermalZone.Label] = true
+		}
+		config.Mappings = []LabelMapping{}
+		for _, mapping := range []LabelMapping{
+			{Label: "topology.kubernetes.io/zone", Value: "{{ .SiteName }}"},
+			{Label: "topology.kubernetes.io/rack", Value: "{{ .RackName }}"},
+		} {
+			if !claimed[mapping.Label] {
+				config.Mappings = append(config.Mappings, mapping)
+			}
+		}
+	}
+	for _, mappings := range [][]InventoryItemMapping{config.Accelerators, config.NICs} {
+		for i := range mappings {
+			if mappings[i].ModelField == "" {
+				mappings[i].ModelField = "part_id"
+			}
+		}
+	}
+	for i := range config.Firmware {
+		if config.Firmware[i].Label == "" {
+			config.Firmware[i].Label = FirmwareLabelPrefix + config.Firmware[i].Component
+		}
+	}
+	if power := config.PowerRedundancy; power != nil {
+		if power.TaintKey == "" {
+			power.TaintKey = PowerRedundancyTaint
+		}
+		if power.TaintEffect == "" {
+			power.TaintEffect = "PreferNoSchedule"
+		}
+		if power.FailedStatuses == nil {
+			power.FailedStatuses = []string{"failed", "offline"}
+		}
+	}
+	if circuits := config.CircuitProviders; circuits != nil && circuits.Statuses == nil {
+		circuits.Statuses = []string{"active"}
+	}
+	if vms := config.VirtualMachines; vms != nil && vms.ClusterLabel == "" {
+		vms.ClusterLabel = VirtualizationClusterLabel
+	}
+	if serviceNow := config.ServiceNow; serviceNow != nil {
+		if serviceNow.Table == "" {
+			serviceNow.Table = "cmdb_ci_server"
+		}
+		if serviceNow.SiteField == "" {
+			serviceNow.SiteField = "location"
+		}
+		if serviceNow.RackField == "" {
+			serviceNow.RackField = "u_rack"
+		}
+	}
+	if config.CiliumBGP != nil && config.CiliumBGP.RouterID == "" {
+		config.CiliumBGP.RouterID = "{{ with .PrimaryIP4 }}{{ ip .Address }}{{ end }}"
+	}
+	if precedence := config.SourcePrecedence; precedence != nil {
+		if precedence.Sources == nil {
+			precedence.Sources = []DeviceSourceName{DeviceSourceNautobot, DeviceSourceStatic, DeviceSourceCloud}
+		}
+		if precedence.Conflict == "" {
+			precedence.Conflict = SourceConflictFirstWins
+		}
+	}
+	if config.Rollout != nil {
+		setDefaultDuration(&config.Rollout.Interval, 10*time.Minute)
+	}
+	setDefaultDuration(&config.Intervals.Resync, 12*time.Hour)
+	setDefaultDuration(&config.Intervals.Unchanged, 6*time.Hour)
+	setDefaultDuration(&config.Intervals.Updated, time.Hour)
+	setDefaultDuration(&config.Intervals.Retry, 5*time.Minute)
+	setDefaultDuration(&config.Intervals.Partial, 24*time.Hour)
+	setDefaultDuration(&config.Intervals.NotFound, time.Hour)
+	setDefaultDuration(&config.Intervals.AdaptiveMin, 15*time.Minute)
+	setDefaultDuration(&config.Intervals.AdaptiveMax, 24*time.Hour)
+}
+
+func setDefaultDuration(duration *metav1.Duration, value time.Duration) {
+	if duration.Duration == 0 {
+		duration.Duration = value
+	}
+}
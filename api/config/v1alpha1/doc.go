@@ -0,0 +1,35 @@
+// Package v1alpha1 contains the versioned configuration file format of the
+// nautobot-node-labeler
+// +kubebuilder:object:generate=true
+// +groupName=config.nautobot.io
+package v1alpha1
+
+//go:generate go run sigs.k8s.io/controller-tools/cmd/controller-gen@v0.17.2 object paths=./
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	// GroupVersion is the group and version of the configuration format
+	GroupVersion = schema.GroupVersion{Group: "config.nautobot.io", Version: "v1alpha1"}
+
+	// SchemeBuilder registers the configuration types and their defaulting
+	SchemeBuilder = runtime.NewSchemeBuilder(addKnownTypes, addDefaultingFuncs)
+
+	// AddToScheme adds the configuration types to a scheme
+	AddToScheme = SchemeBuilder.AddToScheme
+)
+
+func addKnownTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(GroupVersion, &LabelerConfiguration{})
+	return nil
+}
+
+func addDefaultingFuncs(scheme *runtime.Scheme) error {
+	scheme.AddTypeDefaultingFunc(&LabelerConfiguration{}, func(obj interface{}) {
+		SetDefaults(obj.(*LabelerConfiguration))
+	})
+	return nil
+}
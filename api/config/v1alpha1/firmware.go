@@ -0,0 +1,88 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FirmwareLabelPrefix is the prefix of the default label keys of firmware versions
+const FirmwareLabelPrefix = "nautobot.io/firmware."
+
+// FirmwareOutdatedLabel returns the label key of whether a firmware is older than its target
+func FirmwareOutdatedLabel(firmware FirmwareMapping) string {
+	return firmware.Label + "-outdated"
+}
+
+// LabelVersion turns a version into a label value, keeping its case: every run of characters
+// other than ASCII letters, digits, dots, dashes and underscores becomes a dash, with at most 63
+// characters starting and ending with a letter or digit. Values that are not strings, e.g.
+// numbers, are formatted first; nil is "".
+func LabelVersion(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	var version strings.Builder
+	replaced := false
+	for _, r := range fmt.Sprint(value) {
+		if isAlphanumeric(r) || r == '.' || r == '-' || r == '_' {
+			if replaced && version.Len() > 0 {
+				version.WriteByte('-')
+			}
+			version.WriteRune(r)
+			replaced = false
+		} else {
+			replaced = true
+		}
+	}
+	result := strings.TrimLeft(version.String(), ".-_")
+	if len(result) > 63 {
+		result = result[:63]
+	}
+	return strings.TrimRight(result, ".-_")
+}
+
+// VersionBefore reports whether version is older than target. Versions are compared part by
+// part, runs of digits as numbers and runs of letters as text, ignoring separators, so 2.9 is
+// before 2.10 and 1.2 before 1.2.1.
+func VersionBefore(version, target string) bool {
+	versionParts, targetParts := versionParts(version), versionParts(target)
+	for i := 0; i < len(versionParts) && i < len(targetParts); i++ {
+		a, b := versionParts[i], targetParts[i]
+		aNumber, aErr := strconv.ParseUint(a, 10, 64)
+		bNumber, bErr := strconv.ParseUint(b, 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNumber != bNumber {
+				return aNumber < bNumber
+			}
+		case a != b:
+			return a < b
+		}
+	}
+	return len(versionParts) < len(targetParts)
+}
+
+// versionParts splits a version into its runs of digits and of letters
+func versionParts(version string) []string {
+	var parts []string
+	start := -1
+	for i, r := range version + " " {
+		if start >= 0 && (!isAlphanumeric(r) || isDigit(r) != isDigit(rune(version[start]))) {
+			parts = append(parts, version[start:i])
+			start = -1
+		}
+		if start < 0 && isAlphanumeric(r) {
+			start = i
+		}
+	}
+	return parts
+}
+
+func isAlphanumeric(r rune) bool {
+	return isDigit(r) || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
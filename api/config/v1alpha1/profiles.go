@@ -0,0 +1,236 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Profiles are predefined sets of mappings for common consumers of node labels, added to the
+// mappings of a configuration by name. Their values are slugs, so clusters labeling nodes of
+// the same site get the same values whatever the site is called in Nautobot.
+var Profiles = map[string][]LabelMapping{
+	// metallb labels nodes by site and by rack for the node selectors of MetalLB
+	// L2Advertisements, BGPAdvertisements and BGPPeers. Rack names are only unique within a
+	// site, so the rack value includes the site.
+	"metallb": {
+		{Label: "nautobot.io/site", Value: "{{ slug .SiteName }}"},
+		{Label: "nautobot.io/rack", Value: `{{ if .RackName }}{{ slug (printf "%s-%s" .SiteName .RackName) }}{{ end }}`},
+	},
+}
+
+// Labels of the BGP settings of CiliumBGP
+const (
+	BGPLocalASNLabel    = "nautobot.io/bgp-local-asn"
+	BGPPeerAddressLabel = "nautobot.io/bgp-peer-address"
+)
+
+// AllMappings returns the mappings followed by those of the profiles and the BGP labels, with
+// the default normalization filled in
+func (c *LabelerConfiguration) AllMappings() []LabelMapping {
+	mappings := append([]LabelMapping{}, c.Mappings...)
+	for _, domain := range c.TopologyDomains {
+		mappings = append(mappings, LabelMapping{Label: TopologyDomainLabel(domain), Value: TopologyDomainLabelValue(domain)})
+	}
+	for _, locationType := range c.LocationTypes {
+		mappings = append(mappings, LabelMapping{Label: locationType.Label, Value: LocationTypeLabelValue(locationType.LocationType)})
+	}
+	for _, rackGroup := range c.RackGroups {
+		mappings = append(mappings, LabelMapping{Label: rackGroup.Label, Value: RackGroupLabelValue(rackGroup.Level)})
+	}
+	if c.ThermalZone != nil {
+		mappings = append(mappings, LabelMapping{Label: c.ThermalZone.Label, Value: ThermalZoneLabelValue(*c.ThermalZone)})
+	}
+	for _, inventoryItems := range c.InventoryItemMappings() {
+		mappings = append(mappings,
+			LabelMapping{Label: InventoryItemLabel(inventoryItems.Name, "model"), Value: fmt.Sprintf(`{{ with index .Inventory %q }}{{ slug .Model }}{{ end }}`, inventoryItems.Name)},
+			LabelMapping{Label: InventoryItemLabel(inventoryItems.Name, "count"), Value: fmt.Sprintf(`{{ with index .Inventory %q }}{{ .Count }}{{ end }}`, inventoryItems.Name)},
+		)
+		suffixes := make([]string, 0, len(inventoryItems.Fields))
+		for suffix := range inventoryItems.Fields {
+			suffixes = append(suffixes, suffix)
+		}
+		sort.Strings(suffixes)
+		for _, suffix := range suffixes {
+			mappings = append(mappings, LabelMapping{
+				Label: InventoryItemLabel(inventoryItems.Name, suffix),
+				Value: fmt.Sprintf(`{{ with index .Inventory %q }}{{ slug (index .Fields %q) }}{{ end }}`, inventoryItems.Name, suffix),
+			})
+		}
+	}
+	for _, firmware := range c.Firmware {
+		mappings = append(mappings, LabelMapping{Label: firmware.Label, Value: fmt.Sprintf(`{{ version (index .CustomFields %q) }}`, firmware.CustomField)})
+		if firmware.Target != "" {
+			mappings = append(mappings, LabelMapping{
+				Label: FirmwareOutdatedLabel(firmware),
+				Value: fmt.Sprintf(`{{ with version (index .CustomFields %q) }}{{ versionBefore . %q }}{{ end }}`, firmware.CustomField, firmware.Target),
+			})
+		}
+	}
+	if vms := c.VirtualMachines; vms != nil {
+		mappings = append(mappings, LabelMapping{Label: vms.ClusterLabel, Value: "{{ slug .VirtualizationCluster }}"})
+		if vms.HostLabel != "" {
+			mappings = append(mappings, LabelMapping{Label: vms.HostLabel, Value: "{{ slug .Hypervisor }}"})
+		}
+	}
+	for _, profile := range c.Profiles {
+		mappings = append(mappings, Profiles[profile]...)
+	}
+	for i := range mappings {
+		if mappings[i].Normalize == nil {
+			mappings[i].Normalize = c.Normalization
+		}
+	}
+	for _, relationship := range c.Relationships {
+		if relationship.Label != "" {
+			mappings = append(mappings, LabelMapping{Label: relationship.Label, Value: RelationshipLabelValue(relationship.Relationship)})
+		}
+	}
+	if c.CircuitProviders != nil && c.CircuitProviders.Label != "" {
+		mappings = append(mappings, LabelMapping{Label: c.CircuitProviders.Label, Value: CircuitProviderLabelValue})
+	}
+	if c.CiliumBGP != nil {
+		mappings = append(mappings, LabelMapping{Label: BGPLocalASNLabel, Value: c.CiliumBGP.LocalASN})
+		if c.CiliumBGP.PeerAddress != "" {
+			mappings = append(mappings, LabelMapping{Label: BGPPeerAddressLabel, Value: c.CiliumBGP.PeerAddress})
+		}
+	}
+	return mappings
+}
+
+// LocationTypeLabelValue returns the value template of the label of a location type: the slug of
+// the name of the device's location of that type
+func LocationTypeLabelValue(locationType string) string {
+	return fmt.Sprintf(`{{ with index .Locations %q }}{{ slug . }}{{ end }}`, locationType)
+}
+
+// RackGroupLevels returns the rack group levels the rack groups and the thermal zone read
+func (c *LabelerConfiguration) RackGroupLevels() []int {
+	var levels []int
+	for _, rackGroup := range c.RackGroups {
+		levels = append(levels, rackGroup.Level)
+	}
+	if c.ThermalZone != nil && c.ThermalZone.RackGroupLevel != nil {
+		levels = append(levels, *c.ThermalZone.RackGroupLevel)
+	}
+	return levels
+}
+
+// ThermalZoneLabelValue returns the value template of the thermal zone label: the slug of the
+// custom field of the device, else of its rack, else of the name of the rack group of the level
+func ThermalZoneLabelValue(thermalZone ThermalZoneConfig) string {
+	var rackGroup string
+	if thermalZone.RackGroupLevel != nil {
+		rackGroup = RackGroupLabelValue(*thermalZone.RackGroupLevel)
+	}
+	if thermalZone.CustomField == "" {
+		return rackGroup
+	}
+	return fmt.Sprintf(`{{ with or (field .Raw %q) (field .Raw %q) }}{{ slug . }}{{ else }}%s{{ end }}`,
+		"custom_fields."+thermalZone.CustomField, "rack.custom_fields."+thermalZone.CustomField, rackGroup)
+}
+
+// RackGroupLabelValue returns the value template of the label of a rack group level: the slug of
+// the name of the rack group of the device's rack at that level
+func RackGroupLabelValue(level int) string {
+	return fmt.Sprintf(`{{ if gt (len .RackGroups) %d }}{{ slug (index .RackGroups %d) }}{{ end }}`, level, level)
+}
+
+// InventoryItemMappings returns the inventory item mappings of Accelerators and NICs
+func (c *LabelerConfiguration) InventoryItemMappings() []InventoryItemMapping {
+	return append(append([]InventoryItemMapping{}, c.Accelerators...), c.NICs...)
+}
+
+// InventoryItemLabel returns the label key of a property of the inventory items of a mapping,
+// e.g. nautobot.io/gpu.model
+func InventoryItemLabel(name, property string) string {
+	return "nautobot.io/" + name + "." + property
+}
+
+// RelationshipLabelValue returns the value template of the label of a relationship: the slug of
+// the name of the only object related to the device, if there is just one
+func RelationshipLabelValue(relationship string) string {
+	return fmt.Sprintf(`{{ with index .Relationships %q }}{{ if eq (len .) 1 }}{{ slug (index . 0) }}{{ end }}{{ end }}`, relationship)
+}
+
+// CircuitProviderLabelValue is the value template of the circuit provider label: the slug of the
+// name of the only provider of the device's circuits, if there is just one
+const CircuitProviderLabelValue = `{{ if eq (len .CircuitProviders) 1 }}{{ slug (index .CircuitProviders 0) }}{{ end }}`
+
+// profileNames returns the names of all profiles, sorted
+func profileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TemplateFuncs are the functions available to the value templates of mappings besides the
+// builtin ones
+var TemplateFuncs = template.FuncMap{
+	"slug": Slug,
+	// field reads a dotted path of a Nautobot object, e.g. '{{ field .Raw "rack.rack_group" }}'
+	"field": Field,
+	// version makes a version, e.g. of a custom field, a label value
+	"version": LabelVersion,
+	// versionBefore reports whether a version is older than another, e.g. 2.9 than 2.10
+	"versionBefore": VersionBefore,
+	// ip drops the prefix length of an address, e.g. of Nautobot's "10.0.0.5/24"
+	"ip": func(address string) string {
+		ip, _, _ := strings.Cut(address, "/")
+		return ip
+	},
+}
+
+// Slug turns a value into a label value: lowercase, with every run of other characters than
+// ASCII letters and digits replaced by a dash and at most 63 characters, e.g. "NYC 01 / Hall A"
+// into "nyc-01-hall-a"
+func Slug(value string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(value) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	result := slug.String()
+	if len(result) > 63 {
+		result = strings.TrimRight(result[:63], "-")
+	}
+	return result
+}
+
+// Apply normalizes a value; a nil Normalization keeps it as is
+func (n *Normalization) Apply(value string) string {
+	if n == nil {
+		return value
+	}
+	if n.StripDiacritics {
+		// Decomposed, accented letters are their base letter followed by combining marks
+		value, _, _ = transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), value)
+	}
+	if n.Lowercase {
+		value = strings.ToLower(value)
+	}
+	if n.Separator != "" {
+		words := strings.FieldsFunc(value, func(r rune) bool {
+			return unicode.IsSpace(r) || r == '_' || strings.ContainsRune(n.Separator, r)
+		})
+		value = strings.Join(words, n.Separator)
+	}
+	return value
+}
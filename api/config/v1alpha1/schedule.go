@@ -0,0 +1,121 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron schedule of five fields: minute, hour, day of month, month and day of
+// week. Fields are *, values, ranges (1-5) and lists of them (1,3,5), each with an optional
+// step (*/15). Like cron, a time matches when the day of month or the day of week matches if
+// both are restricted.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+// scheduleFields are the names and bounds of the schedule fields
+var scheduleFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseSchedule parses a cron schedule. Sunday is 0 or 7 in the day of week.
+func ParseSchedule(spec string) (*Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(scheduleFields) {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(fields))
+	}
+	var bits [5]uint64
+	for i, value := range fields {
+		set, err := parseScheduleField(value, scheduleFields[i].min, scheduleFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", scheduleFields[i].name, value, err)
+		}
+		bits[i] = set
+	}
+	// Sunday is both 0 and 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &Schedule{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}, nil
+}
+
+// parseScheduleField returns the values of a schedule field as a bit set
+func parseScheduleField(value string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(value, ",") {
+		rangePart, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			var err error
+			if step, err = strconv.Atoi(after); err != nil || step <= 0 {
+				return 0, fmt.Errorf("step %q must be a positive number", after)
+			}
+			rangePart = before
+		}
+		low, high := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("%q is not a number", from)
+			}
+			// A single value with a step, e.g. 5/15, is a range up to the maximum
+			high = low
+			if step > 1 && !isRange {
+				high = max
+			}
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("%q is not a number", to)
+				}
+			}
+		}
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%s is out of range %d-%d", rangePart, min, max)
+		}
+		for v := low; v <= high; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// Matches reports whether the minute of t is part of the schedule
+func (s *Schedule) Matches(t time.Time) bool {
+	if s.minute&(1<<t.Minute()) == 0 || s.hour&(1<<t.Hour()) == 0 || s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	domMatch, dowMatch := s.dom&(1<<t.Day()) != 0, s.dow&(1<<int(t.Weekday())) != 0
+	if !s.domStar && !s.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Last returns the latest start of the schedule at or before now, but not before since, and
+// whether there is one
+func (s *Schedule) Last(now, since time.Time) (time.Time, bool) {
+	for t := now.Truncate(time.Minute); !t.Before(since); t = t.Add(-time.Minute) {
+		if s.Matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
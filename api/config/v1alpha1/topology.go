@@ -0,0 +1,92 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TopologyDomainLabelPrefix is the prefix of the default label keys of topology domains
+const TopologyDomainLabelPrefix = "topology.nautobot.io/"
+
+// VirtualizationClusterLabel is the default label of the virtualization cluster of virtual
+// machines
+const VirtualizationClusterLabel = TopologyDomainLabelPrefix + "virtualization-cluster"
+
+// ThermalZoneLabel is the default label of the thermal zone
+const ThermalZoneLabel = TopologyDomainLabelPrefix + "thermal-zone"
+
+// TopologyDomainLabel returns the label key of a topology domain, TopologyDomainLabelPrefix and
+// its name unless it sets its own
+func TopologyDomainLabel(domain TopologyDomain) string {
+	if domain.Label != "" {
+		return domain.Label
+	}
+	return TopologyDomainLabelPrefix + domain.Name
+}
+
+// TopologyDomainLabelValue returns the value template of the label of a topology domain: the
+// slug of its field of the device object
+func TopologyDomainLabelValue(domain TopologyDomain) string {
+	return fmt.Sprintf(`{{ slug (field .Raw %q) }}`, domain.Field)
+}
+
+// MaxNautobotDepth is the deepest nesting of objects Nautobot 2.x serializes
+const MaxNautobotDepth = 10
+
+// MaxRackGroupLevels bounds the levels of rack groups walked up from a rack
+const MaxRackGroupLevels = 16
+
+// FieldDepth returns the depth a REST lookup needs for the nested objects of a field path to
+// come with their names, e.g. 2 for rack.rack_group: the rack and its rack group. Custom fields
+// are plain values and need none.
+func FieldDepth(path string) int {
+	segments := strings.Split(path, ".")
+	if segments[0] == "custom_fields" {
+		return 0
+	}
+	if last := segments[len(segments)-1]; len(segments) > 1 && (last == "name" || last == "display") {
+		segments = segments[:len(segments)-1]
+	}
+	return min(len(segments), MaxNautobotDepth)
+}
+
+// Field returns the value at a dotted path of a Nautobot object, e.g. "rack.rack_group.name" or
+// "custom_fields.row". Numbers index lists, and a path ending at a nested object returns its
+// name, or else its display name. Missing values and nulls are "".
+func Field(raw json.RawMessage, path string) string {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			value = current[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(current) {
+				return ""
+			}
+			value = current[i]
+		default:
+			return ""
+		}
+	}
+	switch value := value.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case map[string]interface{}:
+		for _, key := range []string{"name", "display"} {
+			if name, ok := value[key].(string); ok && name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,426 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// LabelerConfiguration is the configuration file of the nautobot-node-labeler, loaded with
+// --config
+// +kubebuilder:object:root=true
+type LabelerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// Nautobot is the Nautobot endpoint. --nautobot-url ($NAUTOBOT_URL), $NAUTOBOT_TOKEN and
+	// --nautobot-token-file ($NAUTOBOT_TOKEN_FILE) take precedence.
+	Nautobot NautobotConfig `json:"nautobot,omitempty"`
+	// ServiceNow, if set, looks devices up in a ServiceNow CMDB table instead of Nautobot. The
+	// Nautobot endpoint is then optional, but required by the features writing to Nautobot or
+	// reading it in bulk.
+	ServiceNow *ServiceNowConfig `json:"serviceNow,omitempty"`
+	// Mappings derive the node labels from Nautobot device data. Defaults to the zone and rack
+	// labels.
+	Mappings []LabelMapping `json:"mappings,omitempty"`
+	// TopologyDomains label nodes with further levels of their topology beyond zone and rack,
+	// e.g. row, pod, cage or suite, each from a field of the device
+	TopologyDomains []TopologyDomain `json:"topologyDomains,omitempty"`
+	// LocationTypes map the location types of nested Nautobot locations to labels, e.g. Building
+	// to topology.kubernetes.io/zone, filled from the ancestry of the device's location
+	LocationTypes []LocationTypeMapping `json:"locationTypes,omitempty"`
+	// RackGroups map the levels of nested Nautobot rack groups to labels, e.g. the row, pod and
+	// room of the device's rack, for failure domains like aisles sharing a cooling loop
+	RackGroups []RackGroupMapping `json:"rackGroups,omitempty"`
+	// ThermalZone, if set, labels nodes with the cooling zone of their device, so heat-dense
+	// workloads such as GPU training can be spread across zones
+	ThermalZone *ThermalZoneConfig `json:"thermalZone,omitempty"`
+	// Accelerators label nodes with the model and count of the GPUs and other accelerators of
+	// their device, as modeled by its Nautobot inventory items
+	Accelerators []InventoryItemMapping `json:"accelerators,omitempty"`
+	// NICs label nodes with the model and count of the network interface cards of their device,
+	// and e.g. their speed, as modeled by its Nautobot inventory items, for DPDK or SR-IOV
+	// workloads
+	NICs []InventoryItemMapping `json:"nics,omitempty"`
+	// Firmware labels nodes with the firmware versions of their device, e.g. of the BIOS or BMC,
+	// from custom fields, optionally with whether they are older than a target version
+	Firmware []FirmwareMapping `json:"firmware,omitempty"`
+	// Lifecycle, if set, annotates nodes with the warranty expiry and end of life of their
+	// hardware, for decommissioning automation and reports
+	Lifecycle *LifecycleConfig `json:"lifecycle,omitempty"`
+	// PowerRedundancy, if set, taints the nodes of racks that lost redundant power, with a power
+	// feed failed or offline, until redundancy is restored
+	PowerRedundancy *PowerRedundancyConfig `json:"powerRedundancy,omitempty"`
+	// CircuitProviders, if set, exposes the providers of the circuits terminating at the site of
+	// devices, e.g. the upstream carriers of edge sites, as a node label and annotation
+	CircuitProviders *CircuitProvidersConfig `json:"circuitProviders,omitempty"`
+	// VirtualMachines, if set, matches nodes without a device to Nautobot virtual machines and
+	// labels them with their virtualization cluster, and optionally their hypervisor, so
+	// replicas can be spread across hypervisors
+	VirtualMachines *VirtualMachinesConfig `json:"virtualMachines,omitempty"`
+	// Profiles add the mappings of predefined profiles to Mappings, e.g. "metallb"
+	Profiles []string `json:"profiles,omitempty"`
+	// Normalization, if set, normalizes the values of all mappings, those of profiles included,
+	// that do not set their own
+	Normalization *Normalization `json:"normalization,omitempty"`
+	// CiliumBGP, if set, derives the BGP settings of nodes for Cilium's BGP control plane
+	CiliumBGP *CiliumBGPConfig `json:"ciliumBGP,omitempty"`
+	// Relationships expose the objects devices are associated with by Nautobot relationships,
+	// e.g. their storage shelf or Kubernetes cluster, as node labels and annotations
+	Relationships []RelationshipMapping `json:"relationships,omitempty"`
+	// SourcePrecedence, if set, merges the labels of every device source a node has a device in,
+	// with precedence per label, instead of labeling it from the first source with a device
+	SourcePrecedence *SourcePrecedence `json:"sourcePrecedence,omitempty"`
+	// Intervals control how often nodes are reconciled again
+	Intervals Intervals `json:"intervals,omitempty"`
+	// SiteRegions maps site names to regions, for devices whose site has no region in Nautobot,
+	// e.g. for a topology.kubernetes.io/region mapping of "{{ .RegionName }}"
+	SiteRegions map[string]string `json:"siteRegions,omitempty"`
+	// NodeSelector is a label selector restricting the nodes that are labeled, e.g.
+	// "node-role.kubernetes.io/worker". All nodes are labeled when empty.
+	NodeSelector string `json:"nodeSelector,omitempty"`
+	// FreezeWindows are the times node changes are deferred, e.g. outside approved change
+	// windows. Nodes are still looked up and their pending changes reported.
+	FreezeWindows []FreezeWindow `json:"freezeWindows,omitempty"`
+	// Rollout, if set, limits how many nodes per interval get an existing label value changed
+	Rollout *RolloutConfig `json:"rollout,omitempty"`
+
+	// Flags sets command line flags by name, without the leading dashes, e.g.
+	// "metrics-bind-address": ":8080". Flags given on the command line or in the environment
+	// take precedence. They are read at startup only and do not change on reload. Flags
+	// overriding fields of the configuration, e.g. node-selector, cannot be set here.
+	Flags map[string]string `json:"flags,omitempty"`
+}
+
+// NautobotConfig is the Nautobot endpoint
+type NautobotConfig struct {
+	URL   string `json:"url,omitempty"`
+	Token string `json:"token,omitempty"`
+	// TokenFile is a file holding the token, e.g. a projected Secret. It is re-read when it
+	// changes, so the token can be rotated without a restart. Mutually exclusive with Token.
+	TokenFile string `json:"tokenFile,omitempty"`
+	// SecondaryToken is tried when Nautobot rejects Token, so the token can be rotated without
+	// downtime: add the new token here, swap it into Token, then remove the old one
+	SecondaryToken string `json:"secondaryToken,omitempty"`
+	// SecondaryTokenFile is a file holding SecondaryToken. Mutually exclusive with SecondaryToken.
+	SecondaryTokenFile string `json:"secondaryTokenFile,omitempty"`
+	// Client looks devices up: rest, the built-in client, is the only one. Defaults to rest.
+	Client NautobotClientKind `json:"client,omitempty"`
+	// DeviceFilters are extra filters of every device query, by filter name of the device list,
+	// e.g. {location: dc1, status: active}, so same-named devices elsewhere never match
+	DeviceFilters map[string]string `json:"deviceFilters,omitempty"`
+	// Depth is the depth of the nested objects of REST device lookups on Nautobot 2.x, at most
+	// 10: nested objects within it, e.g. rack.rack_group at 2 or location parents, come with the
+	// device instead of taking a request each. Defaults to 1, or to the depth topologyDomains
+	// and locationTypes read if deeper. Nautobot 1.x ignores it.
+	Depth int `json:"depth,omitempty"`
+}
+
+// NautobotClientKind is the client devices are looked up with
+type NautobotClientKind string
+
+// Supported Nautobot clients
+const (
+	NautobotClientREST NautobotClientKind = "rest"
+)
+
+// ServiceNowConfig is a ServiceNow CMDB table read with the Table API. The device of a node is
+// the record named like the node's short hostname; its fields are read as display values.
+type ServiceNowConfig struct {
+	// URL is the instance, e.g. https://example.service-now.com
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	// PasswordFile is a file holding the password, e.g. a projected Secret. It is re-read when
+	// it changes. Mutually exclusive with Password.
+	PasswordFile string `json:"passwordFile,omitempty"`
+	// Table is the CMDB table of the devices. Defaults to cmdb_ci_server.
+	Table string `json:"table,omitempty"`
+	// SiteField is the field holding the site of a device. Defaults to location.
+	SiteField string `json:"siteField,omitempty"`
+	// RackField is the field holding the rack of a device. Defaults to u_rack.
+	RackField string `json:"rackField,omitempty"`
+}
+
+// LabelMapping derives a node label from Nautobot device data. Value is a text/template
+// evaluated against the device data and the controller's --cluster-name (.ClusterName), e.g.
+// "{{ .SiteName }}". Besides the builtin functions, slug turns a value into a lowercase label
+// value, e.g. "{{ slug .SiteName }}".
+type LabelMapping struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+	// Normalize normalizes the rendered value. Defaults to the Normalization of the configuration.
+	Normalize *Normalization `json:"normalize,omitempty"`
+	// Default, if set, is the value of the label when the device has no data for it, e.g.
+	// "unracked", so topology spread constraints count such nodes in a domain of their own
+	Default string `json:"default,omitempty"`
+	// RefreshInterval, if set, checks the label against Nautobot at least this often, e.g. 5m
+	// for labels derived from the device status, also on nodes that carry all labels. Nodes are
+	// checked at the smallest interval of their labels.
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// TopologyDomain labels nodes with the slug of a field of their device, handled like the mapped
+// labels
+type TopologyDomain struct {
+	// Name names the domain, e.g. "row"
+	Name string `json:"name"`
+	// Label is the label key. Defaults to topology.nautobot.io/<name>.
+	Label string `json:"label,omitempty"`
+	// Field is the dotted path of the value in the Nautobot device object, e.g.
+	// "custom_fields.row" or "rack.rack_group.name"; nested objects give their name
+	Field string `json:"field"`
+}
+
+// LocationTypeMapping labels nodes with the slug of the name of the location of a type among
+// their device's location and its ancestors, the nearest one if several are of the type
+type LocationTypeMapping struct {
+	// LocationType is the name of the location type, e.g. "Building"
+	LocationType string `json:"locationType"`
+	Label        string `json:"label"`
+}
+
+// RackGroupMapping labels nodes with the slug of the name of a rack group their device's rack is
+// nested in, counted from the rack: level 0 is the rack's own group, e.g. the row, 1 the group
+// containing it, e.g. the pod, and so on
+type RackGroupMapping struct {
+	Level int    `json:"level"`
+	Label string `json:"label"`
+}
+
+// ThermalZoneConfig labels nodes with the thermal or airflow zone of their device, from a custom
+// field of the device or its rack, or else from the rack group of a level. At least one source
+// must be set.
+type ThermalZoneConfig struct {
+	// Label defaults to topology.nautobot.io/thermal-zone
+	Label string `json:"label,omitempty"`
+	// CustomField is the custom field holding the zone, e.g. thermal_zone, read from the device
+	// and else from its rack
+	CustomField string `json:"customField,omitempty"`
+	// RackGroupLevel, if set, is the level of the rack group naming the zone of devices without
+	// the custom field, counted as in rackGroups, e.g. 0 for a row of racks sharing a hot aisle
+	RackGroupLevel *int `json:"rackGroupLevel,omitempty"`
+}
+
+// Normalization turns rendered values into canonical label values, so that e.g. "São Paulo DC"
+// and "sao-paulo-dc" become the same value. The steps apply in field order.
+type Normalization struct {
+	// StripDiacritics replaces accented letters by their base letters, e.g. "ã" by "a"
+	StripDiacritics bool `json:"stripDiacritics,omitempty"`
+	// Lowercase lowercases values
+	Lowercase bool `json:"lowercase,omitempty"`
+	// Separator, if set, replaces every run of spaces, underscores and separators, which are
+	// trimmed from both ends of values: "-", "_" or "."
+	Separator string `json:"separator,omitempty"`
+}
+
+// CiliumBGPConfig derives the BGP settings of a node from Nautobot device data, with templates
+// like the values of mappings. The local ASN and peer address are added as the
+// nautobot.io/bgp-local-asn and nautobot.io/bgp-peer-address labels, for the node selectors of
+// peering policies, and the local ASN and router ID make up the
+// cilium.io/bgp-virtual-router.<asn> annotation of Cilium's BGP control plane.
+type CiliumBGPConfig struct {
+	// LocalASN is the ASN of the node, e.g. '{{ index .CustomFields "bgp_asn" }}'
+	LocalASN string `json:"localASN"`
+	// PeerAddress is the address of the node's BGP peer, e.g. its top-of-rack switch
+	PeerAddress string `json:"peerAddress,omitempty"`
+	// RouterID is the BGP router ID of the node. Defaults to the primary IPv4 address of the
+	// device.
+	RouterID string `json:"routerID,omitempty"`
+}
+
+// InventoryItemMapping selects the inventory items of a kind of hardware, e.g. GPUs or NICs, and
+// labels nodes with their model, as nautobot.io/<name>.model, and number, as
+// nautobot.io/<name>.count. Items match all selectors that are set, and at least one must be.
+type InventoryItemMapping struct {
+	// Name is the kind of hardware in the label keys, e.g. gpu
+	Name string `json:"name"`
+	// Manufacturer selects the items of a manufacturer, e.g. NVIDIA
+	Manufacturer string `json:"manufacturer,omitempty"`
+	// Tag selects the items with a tag
+	Tag string `json:"tag,omitempty"`
+	// NamePattern is a regular expression selecting items by name, e.g. "^GPU"
+	NamePattern string `json:"namePattern,omitempty"`
+	// ModelField is the field of the inventory item objects holding their model, a dotted path
+	// like the fields of topology domains. Defaults to part_id.
+	ModelField string `json:"modelField,omitempty"`
+	// Fields label nodes with further fields of the items, like ModelField, by the suffix of
+	// their nautobot.io/<name>.<suffix> label, e.g. {speed: custom_fields.speed}. Like the model,
+	// a field is only labeled when all items share its value.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// FirmwareMapping labels nodes with the version of a firmware of their device, made a valid label
+// value, e.g. "U46 v2.42 (03/15/2023)" becomes "U46-v2.42-03-15-2023"
+type FirmwareMapping struct {
+	// Component names the firmware, e.g. bios or bmc
+	Component string `json:"component"`
+	// CustomField is the device custom field holding the version, e.g. bios_version
+	CustomField string `json:"customField"`
+	// Label is the label holding the version. Defaults to nautobot.io/firmware.<component>.
+	Label string `json:"label,omitempty"`
+	// Target, if set, is the version devices should run. Nodes are then also labeled
+	// <label>-outdated, "true" if their version is older and else "false", with versions
+	// compared number by number, e.g. 2.9 before 2.10.
+	Target string `json:"target,omitempty"`
+}
+
+// LifecycleConfig annotates nodes with the lifecycle dates of their device, from its custom
+// fields or the hardware notice of its device type in the Device Lifecycle Management plugin. At
+// least one source must be set.
+type LifecycleConfig struct {
+	// WarrantyCustomField is the device custom field holding the warranty expiry, annotated as
+	// nautobot.io/warranty-expiry
+	WarrantyCustomField string `json:"warrantyCustomField,omitempty"`
+	// EndOfLifeCustomField is the device custom field holding the end of life, annotated as
+	// nautobot.io/end-of-life
+	EndOfLifeCustomField string `json:"endOfLifeCustomField,omitempty"`
+	// DeviceLifecyclePlugin reads the hardware notices of the Device Lifecycle Management plugin:
+	// their end of support is the end of life of devices without EndOfLifeCustomField, and their
+	// end of sale is annotated as nautobot.io/end-of-sale
+	DeviceLifecyclePlugin bool `json:"deviceLifecyclePlugin,omitempty"`
+}
+
+// PowerRedundancyTaint is the default taint of nodes in racks without redundant power
+const PowerRedundancyTaint = "nautobot.io/power-redundancy-lost"
+
+// PowerRedundancyConfig taints the nodes of racks with a power feed in a failed status, so
+// workloads prefer racks that would survive the loss of another feed
+type PowerRedundancyConfig struct {
+	// TaintKey is the key of the taint. Defaults to nautobot.io/power-redundancy-lost.
+	TaintKey string `json:"taintKey,omitempty"`
+	// TaintEffect is the effect of the taint: PreferNoSchedule, NoSchedule or NoExecute.
+	// Defaults to PreferNoSchedule.
+	TaintEffect string `json:"taintEffect,omitempty"`
+	// FailedStatuses are the power feed statuses that lose redundancy. Defaults to failed and
+	// offline.
+	FailedStatuses []string `json:"failedStatuses,omitempty"`
+}
+
+// CircuitProvidersConfig exposes the providers of the circuits of Nautobot's circuits app that
+// terminate at the site of a node's device. Label and Annotation may both be set, but not neither.
+type CircuitProvidersConfig struct {
+	// Label, if set, is the label holding the slug of the provider's name. Devices whose site has
+	// no circuits, or circuits of several providers, get no label.
+	Label string `json:"label,omitempty"`
+	// Annotation, if set, is the annotation holding the slugs of the names of all providers,
+	// sorted and comma separated
+	Annotation string `json:"annotation,omitempty"`
+	// LocationType, if set, is the location type of the ancestor of a Nautobot 2.x device's
+	// location the circuits terminate at or below, e.g. Site; by default the device's location
+	LocationType string `json:"locationType,omitempty"`
+	// Statuses are the circuit statuses counted. Defaults to active.
+	Statuses []string `json:"statuses,omitempty"`
+}
+
+// VirtualMachinesConfig labels the nodes of Nautobot virtual machines with where they run
+type VirtualMachinesConfig struct {
+	// ClusterLabel is the label holding the slug of the virtualization cluster. Defaults to
+	// topology.nautobot.io/virtualization-cluster.
+	ClusterLabel string `json:"clusterLabel,omitempty"`
+	// HostLabel, if set, is the label holding the slug of the hypervisor device, e.g.
+	// topology.nautobot.io/hypervisor
+	HostLabel string `json:"hostLabel,omitempty"`
+	// HostCustomField is the custom field of virtual machines naming their hypervisor device,
+	// which Nautobot does not record itself. Required with HostLabel.
+	HostCustomField string `json:"hostCustomField,omitempty"`
+}
+
+// RelationshipMapping exposes the names of the objects a device is associated with by a Nautobot
+// relationship. Label and Annotation may both be set, but not neither.
+type RelationshipMapping struct {
+	// Relationship is the key of the relationship, its slug in Nautobot 1.x, e.g.
+	// "compute-to-storage-shelf"
+	Relationship string `json:"relationship"`
+	// Label, if set, is the label holding the slug of the related object's name. Devices with
+	// none or several related objects get no label.
+	Label string `json:"label,omitempty"`
+	// Annotation, if set, is the annotation holding the names of all related objects, sorted and
+	// comma separated
+	Annotation string `json:"annotation,omitempty"`
+}
+
+// DeviceSourceName names a device source of the source precedence
+type DeviceSourceName string
+
+// Device sources of the source precedence
+const (
+	// DeviceSourceNautobot is the inventory devices are looked up in, Nautobot or ServiceNow
+	DeviceSourceNautobot DeviceSourceName = "nautobot"
+	// DeviceSourceStatic is the static devices file
+	DeviceSourceStatic DeviceSourceName = "static"
+	// DeviceSourceCloud is the topology cloud providers report for their instances
+	DeviceSourceCloud DeviceSourceName = "cloud"
+)
+
+// SourceConflictPolicy decides the value of a label the device sources disagree about
+type SourceConflictPolicy string
+
+// Supported source conflict policies
+const (
+	// SourceConflictFirstWins takes the value of the first source with one
+	SourceConflictFirstWins SourceConflictPolicy = "first-wins"
+	// SourceConflictNautobotWins takes the Nautobot value if there is one, else the first
+	SourceConflictNautobotWins SourceConflictPolicy = "nautobot-wins"
+	// SourceConflictError applies no value while the sources disagree, keeping the node's
+	SourceConflictError SourceConflictPolicy = "error"
+)
+
+// SourcePrecedence orders the device sources by precedence, for all labels and per label
+type SourcePrecedence struct {
+	// Sources are the sources by precedence. Defaults to nautobot, static, cloud.
+	Sources []DeviceSourceName `json:"sources,omitempty"`
+	// Conflict decides values the sources disagree about. Defaults to first-wins.
+	Conflict SourceConflictPolicy `json:"conflict,omitempty"`
+	// Labels override Sources and Conflict for single labels, by label key; unset fields are
+	// taken from above
+	Labels map[string]LabelPrecedence `json:"labels,omitempty"`
+}
+
+// LabelPrecedence is the source precedence of a label
+type LabelPrecedence struct {
+	Sources  []DeviceSourceName   `json:"sources,omitempty"`
+	Conflict SourceConflictPolicy `json:"conflict,omitempty"`
+}
+
+// FreezeWindow is a recurring time span without node changes
+type FreezeWindow struct {
+	// Schedule is the cron schedule of the window starts, e.g. "0 18 * * 5" for Fridays at 18:00
+	Schedule string `json:"schedule"`
+	// Duration is how long each window lasts, at most 7 days
+	Duration metav1.Duration `json:"duration"`
+	// TimeZone is the IANA time zone of Schedule, e.g. "Europe/Berlin". Defaults to UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// RolloutConfig rolls changes of existing label values, e.g. after a site rename, out a few
+// nodes at a time. Labels of new nodes are not limited.
+type RolloutConfig struct {
+	// MaxNodes is how many nodes may be relabeled per interval, a count or a percentage of all
+	// nodes, e.g. "10%"
+	MaxNodes intstr.IntOrString `json:"maxNodes"`
+	// Interval is the length of a rollout step. Defaults to 10m.
+	Interval metav1.Duration `json:"interval,omitempty"`
+	// MaxErrors is how many relabels may fail before the rollout is aborted, until the
+	// configuration changes. Defaults to 0, aborting at the first failure.
+	MaxErrors int `json:"maxErrors,omitempty"`
+}
+
+// Intervals are the requeue intervals of the node reconciler
+type Intervals struct {
+	// Resync is the delay for nodes that already have all labels. Defaults to 12h.
+	Resync metav1.Duration `json:"resync,omitempty"`
+	// Unchanged is the delay after a lookup that required no changes. Defaults to 6h.
+	Unchanged metav1.Duration `json:"unchanged,omitempty"`
+	// Updated is the delay after the node was updated. Defaults to 1h.
+	Updated metav1.Duration `json:"updated,omitempty"`
+	// Retry is the delay after a failed lookup. Defaults to 5m.
+	Retry metav1.Duration `json:"retry,omitempty"`
+	// Partial is the delay after a lookup whose device lacked data for some labels, e.g. a
+	// device without a rack. Defaults to 24h.
+	Partial metav1.Duration `json:"partial,omitempty"`
+	// NotFound is the delay after a lookup that found no device for the node. Defaults to 1h.
+	NotFound metav1.Duration `json:"notFound,omitempty"`
+	// AdaptiveMin and AdaptiveMax bound the per-node intervals of the AdaptiveRequeue feature
+	// gate. Default to 15m and 24h.
+	AdaptiveMin metav1.Duration `json:"adaptiveMin,omitempty"`
+	AdaptiveMax metav1.Duration `json:"adaptiveMax,omitempty"`
+}
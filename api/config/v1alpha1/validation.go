@@ -0,0 +1,546 @@
+package v1alpha1
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// deviceFilterName matches the filter names of the device list, which are GraphQL arguments too
+var deviceFilterName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedDeviceFilters are the query parameters set by the lookups themselves
+var reservedDeviceFilters = map[string]bool{"id": true, "name": true, "limit": true, "offset": true, "depth": true}
+
+// Validate checks a defaulted configuration and returns all problems found
+func Validate(config *LabelerConfiguration) field.ErrorList {
+	var errs field.ErrorList
+
+	nautobotPath := field.NewPath("nautobot")
+	// With ServiceNow as the device source, Nautobot is only validated when it is configured
+	nautobotRequired := config.ServiceNow == nil || config.Nautobot.URL != ""
+	if config.Nautobot.URL == "" && nautobotRequired {
+		errs = append(errs, field.Required(nautobotPath.Child("url"), "set it here, with --nautobot-url or in $NAUTOBOT_URL"))
+	} else if config.Nautobot.URL != "" && !isHTTPURL(config.Nautobot.URL) {
+		errs = append(errs, field.Invalid(nautobotPath.Child("url"), config.Nautobot.URL, "must be an absolute http or https URL"))
+	}
+	switch {
+	case config.Nautobot.Token == "" && config.Nautobot.TokenFile == "" && nautobotRequired:
+		errs = append(errs, field.Required(nautobotPath.Child("token"),
+			"set token or tokenFile, $NAUTOBOT_TOKEN or --nautobot-token-file"))
+	case config.Nautobot.Token != "" && config.Nautobot.TokenFile != "":
+		errs = append(errs, field.Forbidden(nautobotPath.Child("tokenFile"), "token and tokenFile are mutually exclusive"))
+	}
+	if config.Nautobot.SecondaryToken != "" && config.Nautobot.SecondaryTokenFile != "" {
+		errs = append(errs, field.Forbidden(nautobotPath.Child("secondaryTokenFile"),
+			"secondaryToken and secondaryTokenFile are mutually exclusive"))
+	}
+	if config.Nautobot.Client != NautobotClientREST {
+		errs = append(errs, field.NotSupported(nautobotPath.Child("client"), config.Nautobot.Client,
+			[]NautobotClientKind{NautobotClientREST}))
+	}
+	if config.Nautobot.Depth < 0 || config.Nautobot.Depth > MaxNautobotDepth {
+		errs = append(errs, field.Invalid(nautobotPath.Child("depth"), config.Nautobot.Depth, "must be between 0 and "+strconv.Itoa(MaxNautobotDepth)))
+	}
+	if len(config.Nautobot.DeviceFilters) > 0 {
+		filtersPath := nautobotPath.Child("deviceFilters")
+		if config.ServiceNow != nil {
+			errs = append(errs, field.Forbidden(filtersPath, "devices are looked up in ServiceNow"))
+		}
+		filterNames := make([]string, 0, len(config.Nautobot.DeviceFilters))
+		for filter := range config.Nautobot.DeviceFilters {
+			filterNames = append(filterNames, filter)
+		}
+		sort.Strings(filterNames)
+		for _, filter := range filterNames {
+			switch {
+			case reservedDeviceFilters[filter]:
+				errs = append(errs, field.Forbidden(filtersPath.Key(filter), "set by the lookups themselves"))
+			case !deviceFilterName.MatchString(filter):
+				errs = append(errs, field.Invalid(filtersPath.Key(filter), filter, "must be a filter name of the device list, e.g. location"))
+			case config.Nautobot.DeviceFilters[filter] == "":
+				errs = append(errs, field.Required(filtersPath.Key(filter), ""))
+			}
+		}
+	}
+
+	if serviceNow := config.ServiceNow; serviceNow != nil {
+		serviceNowPath := field.NewPath("serviceNow")
+		if serviceNow.URL == "" {
+			errs = append(errs, field.Required(serviceNowPath.Child("url"), ""))
+		} else if !isHTTPURL(serviceNow.URL) {
+			errs = append(errs, field.Invalid(serviceNowPath.Child("url"), serviceNow.URL, "must be an absolute http or https URL"))
+		}
+		if serviceNow.Username == "" {
+			errs = append(errs, field.Required(serviceNowPath.Child("username"), ""))
+		}
+		switch {
+		case serviceNow.Password == "" && serviceNow.PasswordFile == "":
+			errs = append(errs, field.Required(serviceNowPath.Child("password"), "set password or passwordFile"))
+		case serviceNow.Password != "" && serviceNow.PasswordFile != "":
+			errs = append(errs, field.Forbidden(serviceNowPath.Child("passwordFile"), "password and passwordFile are mutually exclusive"))
+		}
+	}
+
+	mappingsPath := field.NewPath("mappings")
+	seen := map[string]bool{}
+	for i, mapping := range config.Mappings {
+		path := mappingsPath.Index(i)
+		for _, msg := range validation.IsQualifiedName(mapping.Label) {
+			errs = append(errs, field.Invalid(path.Child("label"), mapping.Label, msg))
+		}
+		if seen[mapping.Label] {
+			errs = append(errs, field.Duplicate(path.Child("label"), mapping.Label))
+		}
+		seen[mapping.Label] = true
+		if _, err := template.New(mapping.Label).Funcs(TemplateFuncs).Parse(mapping.Value); err != nil {
+			errs = append(errs, field.Invalid(path.Child("value"), mapping.Value, err.Error()))
+		}
+		errs = append(errs, validateNormalization(mapping.Normalize, path.Child("normalize"))...)
+		for _, msg := range validation.IsValidLabelValue(mapping.Default) {
+			errs = append(errs, field.Invalid(path.Child("default"), mapping.Default, msg))
+		}
+		if mapping.RefreshInterval != nil && mapping.RefreshInterval.Duration <= 0 {
+			errs = append(errs, field.Invalid(path.Child("refreshInterval"), mapping.RefreshInterval.Duration.String(), "must be positive"))
+		}
+	}
+	domains := map[string]bool{}
+	for i, domain := range config.TopologyDomains {
+		path := field.NewPath("topologyDomains").Index(i)
+		if domain.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		} else if domains[domain.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), domain.Name))
+		}
+		domains[domain.Name] = true
+		label := TopologyDomainLabel(domain)
+		for _, msg := range validation.IsQualifiedName(label) {
+			errs = append(errs, field.Invalid(path.Child("label"), label, msg))
+		}
+		if seen[label] {
+			errs = append(errs, field.Duplicate(path.Child("label"), label))
+		}
+		seen[label] = true
+		if domain.Field == "" {
+			errs = append(errs, field.Required(path.Child("field"), ""))
+		}
+	}
+	for i, locationType := range config.LocationTypes {
+		path := field.NewPath("locationTypes").Index(i)
+		if locationType.LocationType == "" {
+			errs = append(errs, field.Required(path.Child("locationType"), ""))
+		}
+		for _, msg := range validation.IsQualifiedName(locationType.Label) {
+			errs = append(errs, field.Invalid(path.Child("label"), locationType.Label, msg))
+		}
+		if seen[locationType.Label] {
+			errs = append(errs, field.Duplicate(path.Child("label"), locationType.Label))
+		}
+		seen[locationType.Label] = true
+	}
+	for i, rackGroup := range config.RackGroups {
+		path := field.NewPath("rackGroups").Index(i)
+		if rackGroup.Level < 0 || rackGroup.Level >= MaxRackGroupLevels {
+			errs = append(errs, field.Invalid(path.Child("level"), rackGroup.Level, "must be between 0 and "+strconv.Itoa(MaxRackGroupLevels-1)))
+		}
+		for _, msg := range validation.IsQualifiedName(rackGroup.Label) {
+			errs = append(errs, field.Invalid(path.Child("label"), rackGroup.Label, msg))
+		}
+		if seen[rackGroup.Label] {
+			errs = append(errs, field.Duplicate(path.Child("label"), rackGroup.Label))
+		}
+		seen[rackGroup.Label] = true
+	}
+	if thermalZone := config.ThermalZone; thermalZone != nil {
+		path := field.NewPath("thermalZone")
+		if thermalZone.CustomField == "" && thermalZone.RackGroupLevel == nil {
+			errs = append(errs, field.Required(path, "customField or rackGroupLevel must be set"))
+		}
+		if level := thermalZone.RackGroupLevel; level != nil && (*level < 0 || *level >= MaxRackGroupLevels) {
+			errs = append(errs, field.Invalid(path.Child("rackGroupLevel"), *level, "must be between 0 and "+strconv.Itoa(MaxRackGroupLevels-1)))
+		}
+		for _, msg := range validation.IsQualifiedName(thermalZone.Label) {
+			errs = append(errs, field.Invalid(path.Child("label"), thermalZone.Label, msg))
+		}
+		if seen[thermalZone.Label] {
+			errs = append(errs, field.Duplicate(path.Child("label"), thermalZone.Label))
+		}
+		seen[thermalZone.Label] = true
+	}
+	// Accelerators and NICs share the inventory summaries of devices, keyed by name
+	inventoryNames := map[string]bool{}
+	for _, list := range []struct {
+		name     string
+		mappings []InventoryItemMapping
+	}{
+		{"accelerators", config.Accelerators},
+		{"nics", config.NICs},
+	} {
+		for i, mapping := range list.mappings {
+			errs = append(errs, validateInventoryItemMapping(mapping, field.NewPath(list.name).Index(i), inventoryNames, seen)...)
+		}
+	}
+	components := map[string]bool{}
+	for i, firmware := range config.Firmware {
+		path := field.NewPath("firmware").Index(i)
+		if firmware.Component == "" {
+			errs = append(errs, field.Required(path.Child("component"), ""))
+		} else if components[firmware.Component] {
+			errs = append(errs, field.Duplicate(path.Child("component"), firmware.Component))
+		}
+		components[firmware.Component] = true
+		if firmware.CustomField == "" {
+			errs = append(errs, field.Required(path.Child("customField"), ""))
+		}
+		labels := []string{firmware.Label}
+		if firmware.Target != "" {
+			labels = append(labels, FirmwareOutdatedLabel(firmware))
+		}
+		for _, label := range labels {
+			for _, msg := range validation.IsQualifiedName(label) {
+				errs = append(errs, field.Invalid(path.Child("label"), label, msg))
+			}
+			if seen[label] {
+				errs = append(errs, field.Duplicate(path.Child("label"), label))
+			}
+			seen[label] = true
+		}
+	}
+	if lifecycle := config.Lifecycle; lifecycle != nil && lifecycle.WarrantyCustomField == "" && lifecycle.EndOfLifeCustomField == "" && !lifecycle.DeviceLifecyclePlugin {
+		errs = append(errs, field.Required(field.NewPath("lifecycle"), "at least one of warrantyCustomField, endOfLifeCustomField and deviceLifecyclePlugin is required"))
+	}
+	if power := config.PowerRedundancy; power != nil {
+		path := field.NewPath("powerRedundancy")
+		for _, msg := range validation.IsQualifiedName(power.TaintKey) {
+			errs = append(errs, field.Invalid(path.Child("taintKey"), power.TaintKey, msg))
+		}
+		switch power.TaintEffect {
+		case "PreferNoSchedule", "NoSchedule", "NoExecute":
+		default:
+			errs = append(errs, field.NotSupported(path.Child("taintEffect"), power.TaintEffect,
+				[]string{"PreferNoSchedule", "NoSchedule", "NoExecute"}))
+		}
+		if len(power.FailedStatuses) == 0 {
+			errs = append(errs, field.Required(path.Child("failedStatuses"), ""))
+		}
+		if !nautobotRequired {
+			errs = append(errs, field.Forbidden(path, "power feeds are read from Nautobot, which needs its URL also with ServiceNow as the device source"))
+		}
+	}
+	if vms := config.VirtualMachines; vms != nil {
+		path := field.NewPath("virtualMachines")
+		for _, label := range []struct {
+			name, key string
+		}{
+			{"clusterLabel", vms.ClusterLabel},
+			{"hostLabel", vms.HostLabel},
+		} {
+			if label.key == "" {
+				continue
+			}
+			for _, msg := range validation.IsQualifiedName(label.key) {
+				errs = append(errs, field.Invalid(path.Child(label.name), label.key, msg))
+			}
+			if seen[label.key] {
+				errs = append(errs, field.Duplicate(path.Child(label.name), label.key))
+			}
+			seen[label.key] = true
+		}
+		if vms.HostLabel != "" && vms.HostCustomField == "" {
+			errs = append(errs, field.Required(path.Child("hostCustomField"), "required with hostLabel"))
+		}
+		if !nautobotRequired {
+			errs = append(errs, field.Forbidden(path, "virtual machines are looked up in Nautobot, which needs its URL also with ServiceNow as the device source"))
+		}
+	}
+	errs = append(errs, validateNormalization(config.Normalization, field.NewPath("normalization"))...)
+	for i, profile := range config.Profiles {
+		path := field.NewPath("profiles").Index(i)
+		if _, ok := Profiles[profile]; !ok {
+			errs = append(errs, field.NotSupported(path, profile, profileNames()))
+		}
+		for _, mapping := range Profiles[profile] {
+			if seen[mapping.Label] {
+				errs = append(errs, field.Duplicate(path, mapping.Label))
+			}
+			seen[mapping.Label] = true
+		}
+	}
+
+	annotations := map[string]bool{}
+	for i, relationship := range config.Relationships {
+		path := field.NewPath("relationships").Index(i)
+		if relationship.Relationship == "" {
+			errs = append(errs, field.Required(path.Child("relationship"), ""))
+		}
+		if relationship.Label == "" && relationship.Annotation == "" {
+			errs = append(errs, field.Required(path, "set label, annotation or both"))
+		}
+		if relationship.Label != "" {
+			for _, msg := range validation.IsQualifiedName(relationship.Label) {
+				errs = append(errs, field.Invalid(path.Child("label"), relationship.Label, msg))
+			}
+			if seen[relationship.Label] {
+				errs = append(errs, field.Duplicate(path.Child("label"), relationship.Label))
+			}
+			seen[relationship.Label] = true
+		}
+		if relationship.Annotation != "" {
+			for _, msg := range validation.IsQualifiedName(relationship.Annotation) {
+				errs = append(errs, field.Invalid(path.Child("annotation"), relationship.Annotation, msg))
+			}
+			if annotations[relationship.Annotation] {
+				errs = append(errs, field.Duplicate(path.Child("annotation"), relationship.Annotation))
+			}
+			annotations[relationship.Annotation] = true
+		}
+	}
+	if circuits := config.CircuitProviders; circuits != nil {
+		path := field.NewPath("circuitProviders")
+		if circuits.Label == "" && circuits.Annotation == "" {
+			errs = append(errs, field.Required(path, "set label, annotation or both"))
+		}
+		if circuits.Label != "" {
+			for _, msg := range validation.IsQualifiedName(circuits.Label) {
+				errs = append(errs, field.Invalid(path.Child("label"), circuits.Label, msg))
+			}
+			if seen[circuits.Label] {
+				errs = append(errs, field.Duplicate(path.Child("label"), circuits.Label))
+			}
+			seen[circuits.Label] = true
+		}
+		if circuits.Annotation != "" {
+			for _, msg := range validation.IsQualifiedName(circuits.Annotation) {
+				errs = append(errs, field.Invalid(path.Child("annotation"), circuits.Annotation, msg))
+			}
+			if annotations[circuits.Annotation] {
+				errs = append(errs, field.Duplicate(path.Child("annotation"), circuits.Annotation))
+			}
+			annotations[circuits.Annotation] = true
+		}
+	}
+
+	if bgp := config.CiliumBGP; bgp != nil {
+		bgpPath := field.NewPath("ciliumBGP")
+		if bgp.LocalASN == "" {
+			errs = append(errs, field.Required(bgpPath.Child("localASN"), ""))
+		}
+		for _, value := range []struct {
+			name, template string
+		}{
+			{"localASN", bgp.LocalASN},
+			{"peerAddress", bgp.PeerAddress},
+			{"routerID", bgp.RouterID},
+		} {
+			if _, err := template.New(value.name).Funcs(TemplateFuncs).Parse(value.template); err != nil {
+				errs = append(errs, field.Invalid(bgpPath.Child(value.name), value.template, err.Error()))
+			}
+		}
+		for _, label := range []string{BGPLocalASNLabel, BGPPeerAddressLabel} {
+			if seen[label] {
+				errs = append(errs, field.Duplicate(bgpPath, label))
+			}
+		}
+	}
+
+	for site, region := range config.SiteRegions {
+		if site == "" {
+			errs = append(errs, field.Invalid(field.NewPath("siteRegions"), site, "site names must not be empty"))
+		}
+		if region == "" {
+			errs = append(errs, field.Required(field.NewPath("siteRegions").Key(site), ""))
+		}
+	}
+
+	if precedence := config.SourcePrecedence; precedence != nil {
+		path := field.NewPath("sourcePrecedence")
+		errs = append(errs, validateLabelPrecedence(LabelPrecedence{Sources: precedence.Sources, Conflict: precedence.Conflict}, path)...)
+		if len(precedence.Sources) == 0 {
+			errs = append(errs, field.Required(path.Child("sources"), ""))
+		}
+		for label, labelPrecedence := range precedence.Labels {
+			labelPath := path.Child("labels").Key(label)
+			for _, msg := range validation.IsQualifiedName(label) {
+				errs = append(errs, field.Invalid(labelPath, label, msg))
+			}
+			errs = append(errs, validateLabelPrecedence(labelPrecedence, labelPath)...)
+		}
+	}
+
+	intervalsPath := field.NewPath("intervals")
+	for _, interval := range []struct {
+		name     string
+		duration metav1.Duration
+	}{
+		{"resync", config.Intervals.Resync},
+		{"unchanged", config.Intervals.Unchanged},
+		{"updated", config.Intervals.Updated},
+		{"retry", config.Intervals.Retry},
+		{"partial", config.Intervals.Partial},
+		{"notFound", config.Intervals.NotFound},
+		{"adaptiveMin", config.Intervals.AdaptiveMin},
+		{"adaptiveMax", config.Intervals.AdaptiveMax},
+	} {
+		if interval.duration.Duration <= 0 {
+			errs = append(errs, field.Invalid(intervalsPath.Child(interval.name), interval.duration.Duration.String(), "must be positive"))
+		}
+	}
+	if config.Intervals.AdaptiveMax.Duration < config.Intervals.AdaptiveMin.Duration {
+		errs = append(errs, field.Invalid(intervalsPath.Child("adaptiveMax"), config.Intervals.AdaptiveMax.Duration.String(),
+			"must not be shorter than adaptiveMin"))
+	}
+
+	if _, err := labels.Parse(config.NodeSelector); err != nil {
+		errs = append(errs, field.Invalid(field.NewPath("nodeSelector"), config.NodeSelector, err.Error()))
+	}
+	for i, window := range config.FreezeWindows {
+		errs = append(errs, validateFreezeWindow(window, field.NewPath("freezeWindows").Index(i))...)
+	}
+	if config.Rollout != nil {
+		errs = append(errs, validateRollout(config.Rollout, field.NewPath("rollout"))...)
+	}
+	return errs
+}
+
+// maxFreezeWindow bounds freeze windows, so finding the current one stays cheap
+const maxFreezeWindow = 7 * 24 * time.Hour
+
+// validateFreezeWindow checks a freeze window
+func validateFreezeWindow(window FreezeWindow, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if window.Schedule == "" {
+		errs = append(errs, field.Required(path.Child("schedule"), ""))
+	} else if _, err := ParseSchedule(window.Schedule); err != nil {
+		errs = append(errs, field.Invalid(path.Child("schedule"), window.Schedule, err.Error()))
+	}
+	if window.Duration.Duration <= 0 || window.Duration.Duration > maxFreezeWindow {
+		errs = append(errs, field.Invalid(path.Child("duration"), window.Duration.Duration.String(), "must be positive and at most 168h"))
+	}
+	if _, err := time.LoadLocation(window.TimeZone); err != nil {
+		errs = append(errs, field.Invalid(path.Child("timeZone"), window.TimeZone, err.Error()))
+	}
+	return errs
+}
+
+// validateRollout checks the limits of a rollout
+func validateRollout(rollout *RolloutConfig, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	maxNodesPath := path.Child("maxNodes")
+	if rollout.MaxNodes.Type == intstr.String {
+		percent, err := strconv.Atoi(strings.TrimSuffix(rollout.MaxNodes.StrVal, "%"))
+		if err != nil || !strings.HasSuffix(rollout.MaxNodes.StrVal, "%") || percent < 1 || percent > 100 {
+			errs = append(errs, field.Invalid(maxNodesPath, rollout.MaxNodes.StrVal, "must be a count or a percentage between 1% and 100%"))
+		}
+	} else if rollout.MaxNodes.IntVal < 1 {
+		errs = append(errs, field.Invalid(maxNodesPath, rollout.MaxNodes.IntVal, "must be positive"))
+	}
+	if rollout.Interval.Duration <= 0 {
+		errs = append(errs, field.Invalid(path.Child("interval"), rollout.Interval.Duration.String(), "must be positive"))
+	}
+	if rollout.MaxErrors < 0 {
+		errs = append(errs, field.Invalid(path.Child("maxErrors"), rollout.MaxErrors, "must not be negative"))
+	}
+	return errs
+}
+
+// isHTTPURL reports whether value is an absolute http or https URL
+func isHTTPURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// normalizationSeparators are the separators of normalized values allowed in label values
+var normalizationSeparators = []string{"-", "_", "."}
+
+// validateNormalization checks an optional normalization
+func validateNormalization(normalization *Normalization, path *field.Path) field.ErrorList {
+	if normalization == nil || normalization.Separator == "" {
+		return nil
+	}
+	for _, separator := range normalizationSeparators {
+		if normalization.Separator == separator {
+			return nil
+		}
+	}
+	return field.ErrorList{field.NotSupported(path.Child("separator"), normalization.Separator, normalizationSeparators)}
+}
+
+// validateLabelPrecedence checks the sources and conflict policy of a source precedence
+// validateInventoryItemMapping validates an inventory item mapping, adding its name to names and
+// its labels to seen
+func validateInventoryItemMapping(mapping InventoryItemMapping, path *field.Path, names, seen map[string]bool) field.ErrorList {
+	var errs field.ErrorList
+	if mapping.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), ""))
+	} else if names[mapping.Name] {
+		errs = append(errs, field.Duplicate(path.Child("name"), mapping.Name))
+	}
+	names[mapping.Name] = true
+	// The other labels are valid if the model label is
+	for _, msg := range validation.IsQualifiedName(InventoryItemLabel(mapping.Name, "model")) {
+		errs = append(errs, field.Invalid(path.Child("name"), mapping.Name, msg))
+	}
+	suffixes := []string{"model", "count"}
+	for suffix, itemField := range mapping.Fields {
+		fieldPath := path.Child("fields").Key(suffix)
+		if suffix == "model" || suffix == "count" {
+			errs = append(errs, field.Invalid(fieldPath, suffix, "model and count are labeled already"))
+			continue
+		}
+		for _, msg := range validation.IsQualifiedName(InventoryItemLabel(mapping.Name, suffix)) {
+			errs = append(errs, field.Invalid(fieldPath, suffix, msg))
+		}
+		if itemField == "" {
+			errs = append(errs, field.Required(fieldPath, ""))
+		}
+		suffixes = append(suffixes, suffix)
+	}
+	for _, suffix := range suffixes {
+		label := InventoryItemLabel(mapping.Name, suffix)
+		if seen[label] {
+			errs = append(errs, field.Duplicate(path.Child("name"), label))
+		}
+		seen[label] = true
+	}
+	if mapping.Manufacturer == "" && mapping.Tag == "" && mapping.NamePattern == "" {
+		errs = append(errs, field.Required(path, "at least one of manufacturer, tag and namePattern is required"))
+	}
+	if _, err := regexp.Compile(mapping.NamePattern); err != nil {
+		errs = append(errs, field.Invalid(path.Child("namePattern"), mapping.NamePattern, err.Error()))
+	}
+	return errs
+}
+
+func validateLabelPrecedence(precedence LabelPrecedence, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	seen := map[DeviceSourceName]bool{}
+	for i, source := range precedence.Sources {
+		switch source {
+		case DeviceSourceNautobot, DeviceSourceStatic, DeviceSourceCloud:
+		default:
+			errs = append(errs, field.NotSupported(path.Child("sources").Index(i), source,
+				[]DeviceSourceName{DeviceSourceNautobot, DeviceSourceStatic, DeviceSourceCloud}))
+		}
+		if seen[source] {
+			errs = append(errs, field.Duplicate(path.Child("sources").Index(i), source))
+		}
+		seen[source] = true
+	}
+	switch precedence.Conflict {
+	case "", SourceConflictFirstWins, SourceConflictNautobotWins, SourceConflictError:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("conflict"), precedence.Conflict,
+			[]SourceConflictPolicy{SourceConflictFirstWins, SourceConflictNautobotWins, SourceConflictError}))
+	}
+	return errs
+}
@@ -0,0 +1,22 @@
+// Package v1alpha1 contains the API types of the nautobot-node-labeler
+// +kubebuilder:object:generate=true
+// +groupName=nautobot.io
+package v1alpha1
+
+//go:generate go run sigs.k8s.io/controller-tools/cmd/controller-gen@v0.17.2 object crd:crdVersions=v1 paths=./ output:crd:artifacts:config=../../chart/nautobot-node-labeler/crds
+
+import (
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+var (
+	// GroupVersion is the group and version of these API types
+	GroupVersion = schema.GroupVersion{Group: "nautobot.io", Version: "v1alpha1"}
+
+	// SchemeBuilder registers the types of this group version
+	SchemeBuilder = &scheme.Builder{GroupVersion: GroupVersion}
+
+	// AddToScheme adds the types of this group version to a scheme
+	AddToScheme = SchemeBuilder.AddToScheme
+)
@@ -0,0 +1,66 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Condition types of a NautobotLabelerStatus
+const (
+	// ConditionNautobotReachable is true when the Nautobot API answers authenticated requests
+	ConditionNautobotReachable = "NautobotReachable"
+	// ConditionAllNodesSynced is true when every node was reconciled and none is failing
+	ConditionAllNodesSynced = "AllNodesSynced"
+)
+
+// NautobotLabelerStatusStatus is the aggregate sync state of the cluster
+type NautobotLabelerStatusStatus struct {
+	// Conditions are NautobotReachable and AllNodesSynced
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Nodes is the number of nodes in the cluster
+	Nodes int32 `json:"nodes"`
+	// Synced is the number of nodes whose last reconcile succeeded
+	Synced int32 `json:"synced"`
+	// Pending is the number of nodes that have not been reconciled yet
+	Pending int32 `json:"pending"`
+	// Failed is the number of nodes whose last reconcile failed
+	Failed int32 `json:"failed"`
+	// NotFound is the number of nodes without a Nautobot device
+	NotFound int32 `json:"notFound"`
+
+	// LastResyncTime is the oldest last reconcile among all nodes, i.e. every node has been
+	// synced since
+	// +optional
+	LastResyncTime *metav1.Time `json:"lastResyncTime,omitempty"`
+}
+
+// NautobotLabelerStatus is a singleton published by the leading controller replica, so GitOps
+// health checks can gate on the sync state of the cluster
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=nlstatus
+// +kubebuilder:printcolumn:name="Synced",type=integer,JSONPath=`.status.synced`
+// +kubebuilder:printcolumn:name="Failed",type=integer,JSONPath=`.status.failed`
+// +kubebuilder:printcolumn:name="Not Found",type=integer,JSONPath=`.status.notFound`
+// +kubebuilder:printcolumn:name="Last Resync",type=date,JSONPath=`.status.lastResyncTime`
+type NautobotLabelerStatus struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status NautobotLabelerStatusStatus `json:"status,omitempty"`
+}
+
+// NautobotLabelerStatusList is a list of NautobotLabelerStatus
+// +kubebuilder:object:root=true
+type NautobotLabelerStatusList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NautobotLabelerStatus `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NautobotLabelerStatus{}, &NautobotLabelerStatusList{})
+}
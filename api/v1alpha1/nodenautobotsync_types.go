@@ -0,0 +1,69 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Lookup results of a NodeNautobotSync
+const (
+	LookupResultFound    = "Found"
+	LookupResultNotFound = "NotFound"
+	LookupResultError    = "Error"
+)
+
+// SyncError is a failed sync of a node
+type SyncError struct {
+	// Time is when the sync failed
+	Time metav1.Time `json:"time"`
+	// Message is the error message
+	Message string `json:"message"`
+}
+
+// NodeNautobotSyncStatus is the sync record of a node
+type NodeNautobotSyncStatus struct {
+	// LookupResult is the outcome of the last Nautobot lookup: Found, NotFound or Error
+	// +optional
+	LookupResult string `json:"lookupResult,omitempty"`
+	// DeviceName is the name of the matched Nautobot device
+	// +optional
+	DeviceName string `json:"deviceName,omitempty"`
+	// DeviceURL links to the matched device in the Nautobot UI
+	// +optional
+	DeviceURL string `json:"deviceURL,omitempty"`
+	// AppliedLabels are the labels the controller manages on the node
+	// +optional
+	AppliedLabels map[string]string `json:"appliedLabels,omitempty"`
+	// LastSyncTime is when the node was last synced
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+	// Errors are the most recent failed syncs, newest first
+	// +optional
+	Errors []SyncError `json:"errors,omitempty"`
+}
+
+// NodeNautobotSync records the Nautobot sync of the node of the same name. It is owned by the
+// node and garbage collected with it.
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=nsync
+// +kubebuilder:printcolumn:name="Result",type=string,JSONPath=`.status.lookupResult`
+// +kubebuilder:printcolumn:name="Device",type=string,JSONPath=`.status.deviceName`
+// +kubebuilder:printcolumn:name="Last Sync",type=date,JSONPath=`.status.lastSyncTime`
+type NodeNautobotSync struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Status NodeNautobotSyncStatus `json:"status,omitempty"`
+}
+
+// NodeNautobotSyncList is a list of NodeNautobotSync
+// +kubebuilder:object:root=true
+type NodeNautobotSyncList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NodeNautobotSync `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NodeNautobotSync{}, &NodeNautobotSyncList{})
+}
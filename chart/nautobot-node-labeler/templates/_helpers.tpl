@@ -57,4 +57,21 @@ Create the name of the service account to use
 {{- else }}
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
-{{- end }} 
\ No newline at end of file
+{{- end }} 
+{{/*
+Name of the Secret holding the serving certificate of the admission webhooks
+*/}}
+{{- define "nautobot-node-labeler.webhookCertSecret" -}}
+{{- if .Values.webhook.certManager }}
+{{- include "nautobot-node-labeler.fullname" . }}-webhook-certs
+{{- else }}
+{{- required "webhook.certSecret is required without webhook.certManager" .Values.webhook.certSecret }}
+{{- end }}
+{{- end }}
+
+{{/*
+Whether any admission webhook is enabled
+*/}}
+{{- define "nautobot-node-labeler.webhooksEnabled" -}}
+{{- if or .Values.nodeWebhook.enabled .Values.podTopologyWebhook.enabled }}true{{ end }}
+{{- end }}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/controller"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+const (
+	// benchmarkNodesPerRack and benchmarkRacksPerSite shape the generated inventory
+	benchmarkNodesPerRack = 40
+	benchmarkRacksPerSite = 25
+)
+
+// benchmarkCommand reconciles every node of a generated cluster against a generated Nautobot,
+// both in-process, and reports throughput and memory use. Nautobot latency and the Kubernetes
+// API rate limits are simulated; the node parallelism is --max-concurrent-reconciles.
+type benchmarkCommand struct {
+	nodes           int
+	nautobotLatency time.Duration
+	output          string
+
+	mock *nautobot.MockServer
+}
+
+// benchmarkRound is the outcome of reconciling every node once
+type benchmarkRound struct {
+	Name             string  `json:"name"`
+	Seconds          float64 `json:"seconds"`
+	ReconcilesPerSec float64 `json:"reconcilesPerSecond"`
+	NautobotRequests int64   `json:"nautobotRequests"`
+	KubeWrites       int64   `json:"kubeWrites"`
+	Errors           int64   `json:"errors"`
+}
+
+// benchmarkResult is the JSON output of the benchmark subcommand
+type benchmarkResult struct {
+	Nodes             int              `json:"nodes"`
+	Workers           int              `json:"workers"`
+	NautobotLatency   string           `json:"nautobotLatency"`
+	KubeAPIQPS        float32          `json:"kubeAPIQPS"`
+	KubeAPIBurst      int              `json:"kubeAPIBurst"`
+	Rounds            []benchmarkRound `json:"rounds"`
+	HeapGrowthMiB     float64          `json:"heapGrowthMiB"`
+	BytesPerNode      int64            `json:"bytesPerNode"`
+	GoVersion         string           `json:"goVersion"`
+	Platform          string           `json:"platform"`
+	LogicalProcessors int              `json:"logicalProcessors"`
+}
+
+// Summary implements subcommand
+func (c *benchmarkCommand) Summary() string {
+	return "Measure reconcile throughput and memory for a generated cluster and Nautobot"
+}
+
+// BindFlags implements subcommand
+func (c *benchmarkCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.IntVar(&c.nodes, "nodes", 5000, "Number of nodes and devices to generate")
+	fs.DurationVar(&c.nautobotLatency, "nautobot-latency", 20*time.Millisecond, "Simulated latency of every Nautobot request")
+	fs.StringVarP(&c.output, "output", "o", "table", "Output format: table or json")
+}
+
+// nautobot.MockServer implements mockingSubcommand with one device per node, racks of
+// benchmarkNodesPerRack devices and sites of benchmarkRacksPerSite racks
+func (c *benchmarkCommand) MockNautobot() (*nautobot.MockServer, error) {
+	if c.nodes < 1 {
+		return nil, fmt.Errorf("--nodes must be at least 1, got %d", c.nodes)
+	}
+	if c.nautobotLatency < 0 {
+		return nil, fmt.Errorf("--nautobot-latency must not be negative")
+	}
+
+	var fixtures nautobot.MockFixtures
+	for i := 0; i < c.nodes; i++ {
+		rack := i / benchmarkNodesPerRack
+		site := fmt.Sprintf("site-%03d", rack/benchmarkRacksPerSite)
+		if rack%benchmarkRacksPerSite == 0 && i%benchmarkNodesPerRack == 0 {
+			fixtures.Sites = append(fixtures.Sites, map[string]interface{}{
+				"id":     site,
+				"name":   site,
+				"region": map[string]interface{}{"name": fmt.Sprintf("region-%d", rack/benchmarkRacksPerSite%4)},
+			})
+		}
+		fixtures.Devices = append(fixtures.Devices, map[string]interface{}{
+			"name":          benchmarkNodeName(i),
+			"serial":        fmt.Sprintf("SN%07d", i),
+			"site":          map[string]interface{}{"id": site, "name": site},
+			"rack":          map[string]interface{}{"name": fmt.Sprintf("rack-%04d", rack)},
+			"status":        map[string]interface{}{"value": "active"},
+			"custom_fields": map[string]interface{}{},
+		})
+	}
+	c.mock = nautobot.NewMockServer(fixtures)
+	c.mock.Latency = c.nautobotLatency
+	return c.mock, nil
+}
+
+// Run implements subcommand
+func (c *benchmarkCommand) Run(ctx context.Context, env *commandEnv, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: benchmark [--nodes <count>] [--nautobot-latency <duration>]")
+	}
+	if c.mock == nil {
+		return fmt.Errorf("benchmark generates its own devices and cannot be combined with --mock-nautobot")
+	}
+	if err := validateOutput(c.output); err != nil {
+		return err
+	}
+	// Per-node log lines would dominate the measurement
+	ctx = log.IntoContext(ctx, logr.Discard())
+
+	heapBefore := heapInUse()
+	nodes := make([]client.Object, c.nodes)
+	names := make([]string, c.nodes)
+	for i := range nodes {
+		nodes[i] = benchmarkNode(i)
+		names[i] = nodes[i].GetName()
+	}
+	kubeClient := &rateLimitedClient{
+		Client:  fake.NewClientBuilder().WithObjects(nodes...).Build(),
+		limiter: flowcontrol.NewTokenBucketRateLimiter(env.KubeAPIQPS, env.KubeAPIBurst),
+	}
+	syncRecords := controller.NewSyncRecords()
+	syncRecords.SkipResponses = true
+	reconciler := &controller.NodeReconciler{
+		Client:         kubeClient,
+		NautobotClient: env.NautobotClient,
+		Recorder:       &record.FakeRecorder{},
+		ConflictPolicy: env.ConflictPolicy,
+		Config:         env.Config,
+		MissingNodes:   controller.NewMissingNodes(),
+		SyncRecords:    syncRecords,
+		ClusterName:    env.ClusterName,
+		MetadataOnly:   env.MetadataOnly,
+	}
+
+	result := benchmarkResult{
+		Nodes:             c.nodes,
+		Workers:           env.MaxConcurrentReconciles,
+		NautobotLatency:   c.nautobotLatency.String(),
+		KubeAPIQPS:        env.KubeAPIQPS,
+		KubeAPIBurst:      env.KubeAPIBurst,
+		GoVersion:         runtime.Version(),
+		Platform:          runtime.GOOS + "/" + runtime.GOARCH,
+		LogicalProcessors: runtime.NumCPU(),
+	}
+	// A fresh cluster, a forced check of every node against Nautobot, then the periodic
+	// requeue of nodes that already carry their labels
+	for _, round := range []struct {
+		name        string
+		forceLookup bool
+	}{
+		{"initial", false},
+		{"recheck", true},
+		{"steady", false},
+	} {
+		reconciler.ForceLookup = round.forceLookup
+		result.Rounds = append(result.Rounds, c.round(ctx, round.name, reconciler, kubeClient, names, env.MaxConcurrentReconciles))
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	heapGrowth := heapInUse() - heapBefore
+	result.HeapGrowthMiB = float64(heapGrowth) / (1 << 20)
+	result.BytesPerNode = heapGrowth / int64(c.nodes)
+	runtime.KeepAlive(kubeClient)
+
+	if c.output == "json" {
+		writeJSONTo(env.Out, result)
+		return nil
+	}
+	fmt.Fprintf(env.Out, "%d nodes, %d workers, Nautobot latency %s, Kubernetes API %v QPS (burst %d), %s %s with %d CPUs\n\n",
+		result.Nodes, result.Workers, result.NautobotLatency, result.KubeAPIQPS, result.KubeAPIBurst,
+		result.GoVersion, result.Platform, result.LogicalProcessors)
+	w := tabwriter.NewWriter(env.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ROUND\tDURATION\tRECONCILES/S\tNAUTOBOT REQUESTS\tKUBE WRITES\tERRORS")
+	for _, round := range result.Rounds {
+		fmt.Fprintf(w, "%s\t%.1fs\t%.0f\t%d\t%d\t%d\n", round.Name, round.Seconds, round.ReconcilesPerSec,
+			round.NautobotRequests, round.KubeWrites, round.Errors)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Out, "\nHeap growth: %.1f MiB (%d bytes per node, including the node objects)\n",
+		result.HeapGrowthMiB, result.BytesPerNode)
+	return nil
+}
+
+// round reconciles every node once with the given number of workers
+func (c *benchmarkCommand) round(ctx context.Context, name string, reconciler *controller.NodeReconciler, kubeClient *rateLimitedClient,
+	names []string, workers int) benchmarkRound {
+	requestsBefore, writesBefore := c.mock.Requests(), kubeClient.writes.Load()
+	var errs atomic.Int64
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	started := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for nodeName := range queue {
+				_, err := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: nodeName}})
+				if record, _ := reconciler.SyncRecords.Get(nodeName); err != nil || record.Result == controller.ResultError {
+					errs.Add(1)
+				}
+			}
+		}()
+	}
+	for _, nodeName := range names {
+		select {
+		case queue <- nodeName:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	elapsed := time.Since(started)
+	return benchmarkRound{
+		Name:             name,
+		Seconds:          elapsed.Seconds(),
+		ReconcilesPerSec: float64(len(names)) / elapsed.Seconds(),
+		NautobotRequests: c.mock.Requests() - requestsBefore,
+		KubeWrites:       kubeClient.writes.Load() - writesBefore,
+		Errors:           errs.Load(),
+	}
+}
+
+// benchmarkNodeName is the name of the i-th generated device, the short hostname of its node
+func benchmarkNodeName(i int) string {
+	return fmt.Sprintf("node-%05d", i)
+}
+
+// benchmarkNode returns the i-th generated node, shaped like a typical worker node
+func benchmarkNode(i int) *corev1.Node {
+	name := benchmarkNodeName(i) + ".example.com"
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"kubernetes.io/hostname":           name,
+				"kubernetes.io/os":                 "linux",
+				"kubernetes.io/arch":               "amd64",
+				"node.kubernetes.io/instance-type": "bare-metal",
+			},
+			Annotations: map[string]string{
+				"node.alpha.kubernetes.io/ttl":                           "0",
+				"volumes.kubernetes.io/controller-managed-attach-detach": "true",
+			},
+		},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeInternalIP, Address: fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)},
+				{Type: corev1.NodeHostName, Address: name},
+			},
+			Capacity: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("64"),
+				corev1.ResourceMemory: resource.MustParse("512Gi"),
+				corev1.ResourcePods:   resource.MustParse("110"),
+			},
+		},
+	}
+}
+
+// heapInUse returns the bytes of live heap objects after a garbage collection
+func heapInUse() int64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.HeapAlloc)
+}
+
+// rateLimitedClient limits the writes of a client to the rate the controller's Kubernetes client
+// is allowed. Reads are not limited, the controller serves them from its cache.
+type rateLimitedClient struct {
+	client.Client
+	limiter flowcontrol.RateLimiter
+	writes  atomic.Int64
+}
+
+// Update implements client.Writer
+func (c *rateLimitedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// Patch implements client.Writer
+func (c *rateLimitedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+// wait blocks until the rate limits allow another write
+func (c *rateLimitedClient) wait(ctx context.Context) error {
+	c.writes.Add(1)
+	return c.limiter.Wait(ctx)
+}
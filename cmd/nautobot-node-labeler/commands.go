@@ -0,0 +1,1013 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/controller"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// subcommand is a one-off operation run instead of the controller, e.g. from an operator's
+// laptop. Subcommands accept the controller's flags and configuration, so they see the same
+// Nautobot endpoint, mappings and policies.
+type subcommand interface {
+	// Summary is a one-line description for the usage message
+	Summary() string
+	// BindFlags registers the subcommand's own flags
+	BindFlags(fs *pflag.FlagSet)
+	// Run executes the subcommand with its positional arguments
+	Run(ctx context.Context, env *commandEnv, args []string) error
+}
+
+// mockingSubcommand is implemented by subcommands that bring their own mock Nautobot, which
+// the Nautobot client then talks to as with --mock-nautobot
+type mockingSubcommand interface {
+	subcommand
+	// nautobot.MockServer returns the mock to serve, after the flags were parsed
+	MockNautobot() (*nautobot.MockServer, error)
+}
+
+// nodeListPageSize is how many nodes a list request of a subcommand returns at once
+const nodeListPageSize = 500
+
+// subcommands are the known subcommands by name
+var subcommands = map[string]subcommand{
+	"benchmark":       &benchmarkCommand{},
+	"cleanup":         &cleanupCommand{},
+	"diff":            &diffCommand{},
+	"export":          &exportCommand{},
+	"lookup":          &lookupCommand{},
+	"report":          &reportCommand{},
+	"simulate":        &simulateCommand{},
+	"sync":            &syncCommand{},
+	"validate-config": &validateConfigCommand{},
+	"version":         &versionCommand{},
+}
+
+// commandEnv is what subcommands share with the controller: the validated configuration and
+// the clients built from the same flags
+type commandEnv struct {
+	NautobotClient nautobot.Interface
+	Config         *controller.ConfigStore
+	ConflictPolicy controller.ConflictPolicy
+	ClusterName    string
+	MetadataOnly   bool
+	AuditSink      controller.AuditSink
+	// KubeContext selects the kubeconfig context of KubeClient, KubeAPIQPS and KubeAPIBurst
+	// its rate limits
+	KubeContext  string
+	KubeAPIQPS   float32
+	KubeAPIBurst int
+	// MaxConcurrentReconciles is the parallelism of the controllers
+	MaxConcurrentReconciles int
+	// MockNautobot is set when NautobotClient talks to --mock-nautobot fixtures
+	MockNautobot bool
+	// AllowZoneChanges lets Nautobot change zone labels, see --allow-zone-changes
+	AllowZoneChanges bool
+	// LookupKey selects the names nodes are matched to devices by, see --lookup-key
+	LookupKey controller.LookupKey
+	// Out receives the subcommand's output
+	Out io.Writer
+
+	kubeClient client.Client
+}
+
+// KubeClient returns an uncached Kubernetes client. It is only created on demand, so
+// subcommands that only talk to Nautobot work without cluster access.
+func (e *commandEnv) KubeClient() (client.Client, error) {
+	if e.kubeClient != nil {
+		return e.kubeClient, nil
+	}
+	restConfig, err := ctrlconfig.GetConfigWithContext(e.KubeContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kubernetes client configuration: %w", err)
+	}
+	restConfig.QPS, restConfig.Burst = e.KubeAPIQPS, e.KubeAPIBurst
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	kubeClient, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+	e.kubeClient = kubeClient
+	return kubeClient, nil
+}
+
+// parseSubcommand splits a leading subcommand name off the command line arguments. It returns
+// a nil subcommand when the controller should run.
+func parseSubcommand(args []string) (subcommand, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return nil, args, nil
+	}
+	cmd, ok := subcommands[args[0]]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown command %q, expected one of: %s", args[0], strings.Join(subcommandNames(), ", "))
+	}
+	return cmd, args[1:], nil
+}
+
+// subcommandNames returns the sorted names of all subcommands
+func subcommandNames() []string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// kubectlPluginName is the binary name under which kubectl finds the binary as the
+// "kubectl nautobot-labels" plugin
+const kubectlPluginName = "kubectl-nautobot_labels"
+
+// runningAsKubectlPlugin reports whether the binary was installed as the kubectl plugin. The
+// plugin only runs subcommands, never the controller.
+func runningAsKubectlPlugin() bool {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe") == kubectlPluginName
+}
+
+// usage prints how to run the controller and the subcommands, followed by the flags
+func usage() {
+	if runningAsKubectlPlugin() {
+		fmt.Fprintf(os.Stderr, "Usage: kubectl nautobot-labels <command> [flags]\n\nCommands:\n")
+	} else {
+		fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nWithout a command, the controller runs. Commands:\n", os.Args[0])
+	}
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, name := range subcommandNames() {
+		fmt.Fprintf(w, "  %s\t%s\n", name, subcommands[name].Summary())
+	}
+	_ = w.Flush()
+	fmt.Fprintf(os.Stderr, "\nFlags:\n%s", pflag.CommandLine.FlagUsages())
+}
+
+// validateOutput checks an --output value
+func validateOutput(output string) error {
+	if output != "table" && output != "json" {
+		return fmt.Errorf("invalid --output %q (expected table or json)", output)
+	}
+	return nil
+}
+
+// syncCommand reconciles a single node once and prints the outcome
+type syncCommand struct {
+	node   string
+	output string
+}
+
+// Summary implements subcommand
+func (c *syncCommand) Summary() string {
+	return "Look up a node in Nautobot and apply its labels once (--node <name>)"
+}
+
+// BindFlags implements subcommand
+func (c *syncCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.node, "node", "", "Name of the node to sync")
+	fs.StringVarP(&c.output, "output", "o", "table", "Output format: table or json")
+}
+
+// Run implements subcommand
+func (c *syncCommand) Run(ctx context.Context, env *commandEnv, args []string) error {
+	if c.node == "" || len(args) > 0 {
+		return fmt.Errorf("usage: sync --node <name>")
+	}
+	if err := validateOutput(c.output); err != nil {
+		return err
+	}
+	kubeClient, err := env.KubeClient()
+	if err != nil {
+		return err
+	}
+
+	// The controller's reconciler does the work, so the result is exactly what the controller
+	// would do, except that nodes with all labels present are looked up too
+	syncRecords := controller.NewSyncRecords()
+	reconciler := &controller.NodeReconciler{
+		Client:         kubeClient,
+		NautobotClient: env.NautobotClient,
+		ConflictPolicy: env.ConflictPolicy,
+		MissingNodes:   controller.NewMissingNodes(),
+		AuditSink:      env.AuditSink,
+		Config:         env.Config,
+		SyncRecords:    syncRecords,
+		ClusterName:    env.ClusterName,
+		MetadataOnly:   env.MetadataOnly,
+		ForceLookup:    true,
+
+		AllowZoneChanges: env.AllowZoneChanges,
+		LookupKey:        env.LookupKey,
+	}
+	_, reconcileErr := reconciler.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: c.node}})
+	record, ok := syncRecords.Get(c.node)
+	if !ok {
+		if reconcileErr != nil {
+			return reconcileErr
+		}
+		return fmt.Errorf("node %q not found", c.node)
+	}
+
+	if c.output == "json" {
+		writeJSONTo(env.Out, record)
+	} else {
+		printSyncRecord(env.Out, record)
+	}
+	if record.Result == controller.ResultError {
+		return fmt.Errorf("sync of node %q failed", c.node)
+	}
+	return nil
+}
+
+// printSyncRecord prints a sync record for humans
+func printSyncRecord(out io.Writer, record controller.NodeSyncRecord) {
+	fmt.Fprintf(out, "Node:    %s\n", record.Node)
+	fmt.Fprintf(out, "Result:  %s\n", record.Result)
+	if record.Result == controller.ResultSkipped {
+		fmt.Fprintf(out, "         the node does not match the node selector\n")
+	}
+	if record.Result == controller.ResultDeferred {
+		fmt.Fprintf(out, "         a freeze window defers the changes\n")
+	}
+	if record.Error != "" {
+		fmt.Fprintf(out, "Error:   %s\n", record.Error)
+	}
+	if record.MatchStrategy != "" {
+		fmt.Fprintf(out, "Match:   %s\n", record.MatchStrategy)
+	}
+	printLabels(out, record.DesiredLabels)
+}
+
+// printLabels prints a label set sorted by key, if it is not empty
+func printLabels(out io.Writer, labelSet map[string]string) {
+	if len(labelSet) == 0 {
+		return
+	}
+
+	fmt.Fprintf(out, "Labels:\n")
+	keys := make([]string, 0, len(labelSet))
+	for key := range labelSet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s\t%s\n", key, labelSet[key])
+	}
+	_ = w.Flush()
+}
+
+// diffCommand shows the label changes the controller would make, without writing anything
+type diffCommand struct {
+	output string
+}
+
+// nodeDiff is the pending label changes of a node
+type nodeDiff struct {
+	Node   string      `json:"node"`
+	Device string      `json:"device,omitempty"`
+	Site   string      `json:"site,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Labels []labelDiff `json:"labels,omitempty"`
+}
+
+// labelDiff compares the current and desired value of a managed label
+type labelDiff struct {
+	Label   string `json:"label"`
+	Current string `json:"current"`
+	Desired string `json:"desired"`
+	// Action is unchanged, add, change or keep (an out-of-band value the conflict policy keeps)
+	Action string `json:"action"`
+}
+
+// Summary implements subcommand
+func (c *diffCommand) Summary() string {
+	return "List the current and desired labels of every node without changing anything"
+}
+
+// BindFlags implements subcommand
+func (c *diffCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&c.output, "output", "o", "table", "Output format: table or json")
+}
+
+// Run implements subcommand
+func (c *diffCommand) Run(ctx context.Context, env *commandEnv, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: diff [--output table|json]")
+	}
+	if err := validateOutput(c.output); err != nil {
+		return err
+	}
+	kubeClient, err := env.KubeClient()
+	if err != nil {
+		return err
+	}
+	nodes, err := listAllNodes(ctx, kubeClient)
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	config := env.Config.Current()
+	diffs := []nodeDiff{}
+	for i := range nodes {
+		node := &nodes[i]
+		// Nodes outside the node selector are left alone by the controller
+		if !config.Selector().Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		diffs = append(diffs, diffNode(ctx, node, config, env))
+	}
+
+	if c.output == "json" {
+		writeJSONTo(env.Out, diffs)
+		return nil
+	}
+	w := tabwriter.NewWriter(env.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tLABEL\tCURRENT\tDESIRED\tACTION")
+	for _, diff := range diffs {
+		if diff.Error != "" {
+			fmt.Fprintf(w, "%s\t\t\t\terror: %s\n", diff.Node, diff.Error)
+			continue
+		}
+		for _, label := range diff.Labels {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", diff.Node, label.Label, label.Current, label.Desired, label.Action)
+		}
+	}
+	return w.Flush()
+}
+
+// diffNode looks a node up in Nautobot and compares its labels with the desired ones, like a
+// reconcile that always consults Nautobot would
+func diffNode(ctx context.Context, node *corev1.Node, config *controller.Config, env *commandEnv) nodeDiff {
+	diff := nodeDiff{Node: node.Name}
+	deviceData, err := controller.LookupDevice(ctx, env.NautobotClient, node, env.LookupKey)
+	if errors.Is(err, nautobot.ErrDeviceNotFound) && config.VirtualMachines != nil {
+		deviceData, err = controller.LookupVirtualMachine(ctx, env.NautobotClient, config, node, env.LookupKey)
+	}
+	if err != nil {
+		diff.Error = err.Error()
+		return diff
+	}
+	deviceData = config.CompleteDevice(deviceData)
+	diff.Device, diff.Site = deviceData.Name, deviceData.SiteName
+	desired, err := mapping.Render(config.CompiledMappings(), deviceData, env.ClusterName)
+	if err != nil {
+		diff.Error = err.Error()
+		return diff
+	}
+
+	refused := controller.GuardZoneLabel(node, desired, env.AllowZoneChanges)
+	plan := controller.PlanLabels(node, desired, env.ConflictPolicy, deviceData.Name)
+	actions := map[string]string{}
+	for _, change := range plan.Changes {
+		actions[change.Key] = "change"
+		if change.OldValue == "" {
+			actions[change.Key] = "add"
+		}
+	}
+	for _, conflict := range plan.Conflicts {
+		if !conflict.NautobotWon {
+			actions[conflict.Key] = "keep"
+		}
+	}
+	for _, label := range desired {
+		if label.Value == "" {
+			continue
+		}
+		action := actions[label.Key]
+		if action == "" {
+			action = "unchanged"
+		}
+		// The zone label is kept, but the review shows the zone Nautobot wants
+		if label.Key == corev1.LabelTopologyZone && refused != "" {
+			label.Value, action = refused, "blocked"
+		}
+		diff.Labels = append(diff.Labels, labelDiff{
+			Label:   label.Key,
+			Current: node.Labels[label.Key],
+			Desired: label.Value,
+			Action:  action,
+		})
+	}
+	return diff
+}
+
+// exportCommand reports where every node is located according to Nautobot, e.g. for capacity
+// and audit reporting outside the cluster
+type exportCommand struct {
+	selector string
+	output   string
+}
+
+// nodeLocation is a row of the export report
+type nodeLocation struct {
+	Node     string `json:"node"`
+	Device   string `json:"device"`
+	DeviceID string `json:"deviceID"`
+	Site     string `json:"site"`
+	Rack     string `json:"rack"`
+	Region   string `json:"region"`
+	Status   string `json:"status"`
+	// Error is why the node could not be located, e.g. no matching device
+	Error string `json:"error,omitempty"`
+}
+
+// Summary implements subcommand
+func (c *exportCommand) Summary() string {
+	return "Write a CSV or JSON report of the Nautobot device, site, rack and region of every node"
+}
+
+// BindFlags implements subcommand
+func (c *exportCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.selector, "selector", "", "Label selector restricting the exported nodes")
+	fs.StringVarP(&c.output, "output", "o", "csv", "Output format: csv or json")
+}
+
+// Run implements subcommand
+func (c *exportCommand) Run(ctx context.Context, env *commandEnv, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: export [--selector <selector>] [--output csv|json]")
+	}
+	if c.output != "csv" && c.output != "json" {
+		return fmt.Errorf("invalid --output %q (expected csv or json)", c.output)
+	}
+	selector, err := labels.Parse(c.selector)
+	if err != nil {
+		return fmt.Errorf("invalid --selector: %w", err)
+	}
+	kubeClient, err := env.KubeClient()
+	if err != nil {
+		return err
+	}
+	nodes, err := listAllNodes(ctx, kubeClient, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	locations := make([]nodeLocation, 0, len(nodes))
+	for _, node := range nodes {
+		location := nodeLocation{Node: node.Name}
+		deviceData, err := controller.LookupDevice(ctx, env.NautobotClient, &node, env.LookupKey)
+		if config := env.Config.Current(); errors.Is(err, nautobot.ErrDeviceNotFound) && config.VirtualMachines != nil {
+			deviceData, err = controller.LookupVirtualMachine(ctx, env.NautobotClient, config, &node, env.LookupKey)
+		}
+		if err != nil {
+			location.Error = err.Error()
+			locations = append(locations, location)
+			continue
+		}
+		deviceData = env.Config.Current().CompleteDevice(deviceData)
+		location.Device, location.DeviceID = deviceData.Name, deviceData.ID
+		location.Site, location.Rack, location.Region = deviceData.SiteName, deviceData.RackName, deviceData.RegionName
+		location.Status = deviceData.Status
+		locations = append(locations, location)
+	}
+
+	if c.output == "json" {
+		writeJSONTo(env.Out, locations)
+		return nil
+	}
+	w := csv.NewWriter(env.Out)
+	_ = w.Write([]string{"node", "device", "device_id", "site", "rack", "region", "status", "error"})
+	for _, l := range locations {
+		_ = w.Write([]string{l.Node, l.Device, l.DeviceID, l.Site, l.Rack, l.Region, l.Status, l.Error})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// simulateCommand prints the label mutations the controller would perform for a saved Nautobot
+// dump, for what-if analysis of mapping changes before they reach production
+type simulateCommand struct {
+	nodesFile string
+	output    string
+}
+
+// Summary implements subcommand
+func (c *simulateCommand) Summary() string {
+	return "Print the label mutations for a Nautobot dump (--mock-nautobot) and the live or saved (--nodes) nodes"
+}
+
+// BindFlags implements subcommand
+func (c *simulateCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.nodesFile, "nodes", "",
+		"YAML or JSON node list, e.g. from kubectl get nodes -o yaml, to simulate instead of the nodes of the cluster")
+	fs.StringVarP(&c.output, "output", "o", "table", "Output format: table or json")
+}
+
+// Run implements subcommand
+func (c *simulateCommand) Run(ctx context.Context, env *commandEnv, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: simulate --mock-nautobot <dump> [--nodes <file>]")
+	}
+	if !env.MockNautobot {
+		return fmt.Errorf("simulate needs a saved Nautobot dump, pass it with --mock-nautobot <file>")
+	}
+	if err := validateOutput(c.output); err != nil {
+		return err
+	}
+	nodes, err := c.listNodes(ctx, env)
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	// The simulation is a diff reduced to what would change
+	config := env.Config.Current()
+	mutations := []nodeDiff{}
+	skipped, failed, changed := 0, 0, 0
+	for i := range nodes {
+		node := &nodes[i]
+		if !config.Selector().Matches(labels.Set(node.Labels)) {
+			skipped++
+			continue
+		}
+		diff := diffNode(ctx, node, config, env)
+		labelDiffs := diff.Labels
+		diff.Labels = nil
+		for _, label := range labelDiffs {
+			if label.Action != "unchanged" {
+				diff.Labels = append(diff.Labels, label)
+			}
+		}
+		switch {
+		case diff.Error != "":
+			failed++
+		case len(diff.Labels) > 0:
+			changed++
+		default:
+			continue
+		}
+		mutations = append(mutations, diff)
+	}
+
+	if c.output == "json" {
+		writeJSONTo(env.Out, mutations)
+		return nil
+	}
+	w := tabwriter.NewWriter(env.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tLABEL\tCURRENT\tDESIRED\tACTION")
+	for _, diff := range mutations {
+		if diff.Error != "" {
+			fmt.Fprintf(w, "%s\t\t\t\terror: %s\n", diff.Node, diff.Error)
+			continue
+		}
+		for _, label := range diff.Labels {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", diff.Node, label.Label, label.Current, label.Desired, label.Action)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Out, "\n%d node(s): %d would change, %d failed lookups (left unchanged), %d outside the node selector\n",
+		len(nodes), changed, failed, skipped)
+	return nil
+}
+
+// listNodes returns the nodes to simulate, from --nodes or the cluster
+func (c *simulateCommand) listNodes(ctx context.Context, env *commandEnv) ([]corev1.Node, error) {
+	var nodes corev1.NodeList
+	if c.nodesFile != "" {
+		data, err := os.ReadFile(c.nodesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read nodes: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &nodes); err != nil {
+			return nil, fmt.Errorf("failed to parse nodes %s: %w", c.nodesFile, err)
+		}
+		return nodes.Items, nil
+	}
+
+	kubeClient, err := env.KubeClient()
+	if err != nil {
+		return nil, err
+	}
+	return listAllNodes(ctx, kubeClient)
+}
+
+// listAllNodes lists nodes from the API server in pages, so large clusters are not returned in
+// a single response
+func listAllNodes(ctx context.Context, reader client.Reader, opts ...client.ListOption) ([]corev1.Node, error) {
+	var nodes []corev1.Node
+	continueToken := ""
+	for {
+		var page corev1.NodeList
+		pageOpts := append([]client.ListOption{client.Limit(nodeListPageSize), client.Continue(continueToken)}, opts...)
+		if err := reader.List(ctx, &page, pageOpts...); err != nil {
+			return nil, fmt.Errorf("failed to list nodes: %w", err)
+		}
+		nodes = append(nodes, page.Items...)
+		if continueToken = page.Continue; continueToken == "" {
+			return nodes, nil
+		}
+	}
+}
+
+// lookupCommand resolves a hostname in Nautobot the way the controller resolves node names
+type lookupCommand struct {
+	output string
+}
+
+// lookupResult is the JSON output of the lookup subcommand
+type lookupResult struct {
+	Hostname string `json:"hostname"`
+	// MatchStrategy describes how the device was matched, e.g. the query used
+	MatchStrategy string            `json:"matchStrategy"`
+	DeviceID      string            `json:"deviceID"`
+	Device        string            `json:"device"`
+	Site          string            `json:"site"`
+	Rack          string            `json:"rack"`
+	Status        string            `json:"status"`
+	Labels        map[string]string `json:"labels"`
+	// NautobotResponse is the device object exactly as returned by Nautobot
+	NautobotResponse json.RawMessage `json:"nautobotResponse"`
+}
+
+// Summary implements subcommand
+func (c *lookupCommand) Summary() string {
+	return "Resolve a hostname in Nautobot and print the device and derived labels (lookup <hostname>)"
+}
+
+// BindFlags implements subcommand
+func (c *lookupCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&c.output, "output", "o", "table", "Output format: table or json")
+}
+
+// Run implements subcommand
+func (c *lookupCommand) Run(ctx context.Context, env *commandEnv, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: lookup <hostname>")
+	}
+	if err := validateOutput(c.output); err != nil {
+		return err
+	}
+
+	hostname := args[0]
+	deviceData, err := env.NautobotClient.GetDeviceData(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	deviceData = env.Config.Current().CompleteDevice(deviceData)
+	desired, err := mapping.Render(env.Config.Current().CompiledMappings(), deviceData, env.ClusterName)
+	if err != nil {
+		return err
+	}
+	result := lookupResult{
+		Hostname:         hostname,
+		MatchStrategy:    "hostname: " + deviceData.Query,
+		DeviceID:         deviceData.ID,
+		Device:           deviceData.Name,
+		Site:             deviceData.SiteName,
+		Rack:             deviceData.RackName,
+		Status:           deviceData.Status,
+		Labels:           map[string]string{},
+		NautobotResponse: deviceData.Raw,
+	}
+	for _, label := range desired {
+		if label.Value != "" {
+			result.Labels[label.Key] = label.Value
+		}
+	}
+
+	if c.output == "json" {
+		writeJSONTo(env.Out, result)
+		return nil
+	}
+	fmt.Fprintf(env.Out, "Match:   %s\n", result.MatchStrategy)
+	fmt.Fprintf(env.Out, "Device:  %s (ID %s)\n", result.Device, result.DeviceID)
+	fmt.Fprintf(env.Out, "Site:    %s\n", result.Site)
+	fmt.Fprintf(env.Out, "Rack:    %s\n", result.Rack)
+	fmt.Fprintf(env.Out, "Status:  %s\n", result.Status)
+	printLabels(env.Out, result.Labels)
+	return nil
+}
+
+// cleanupCommand removes the labels, annotations and taints the controller applied, e.g. for
+// uninstalls or to roll back a bad mapping
+type cleanupCommand struct {
+	selector string
+	force    bool
+	dryRun   bool
+	output   string
+}
+
+// cleanupResult is what the cleanup subcommand did to a label, annotation or taint of a node
+type cleanupResult struct {
+	Node string `json:"node"`
+	// Kind is label, annotation or taint
+	Kind string `json:"kind"`
+	// Key is the key of the label or annotation, or the key:effect of the taint
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	// Action is removed, or kept for a label changed since the controller applied it
+	Action string `json:"action"`
+}
+
+// Summary implements subcommand
+func (c *cleanupCommand) Summary() string {
+	return "Remove the labels, annotations and taints the controller applied from all (or --selector) nodes"
+}
+
+// BindFlags implements subcommand
+func (c *cleanupCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.selector, "selector", "", "Label selector restricting the cleaned up nodes")
+	fs.BoolVar(&c.force, "force", false, "Also remove managed labels whose value was changed out-of-band since the controller applied it")
+	fs.BoolVar(&c.dryRun, "dry-run", false, "Only print what would be removed")
+	fs.StringVarP(&c.output, "output", "o", "table", "Output format: table or json")
+}
+
+// Run implements subcommand
+func (c *cleanupCommand) Run(ctx context.Context, env *commandEnv, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: cleanup [--selector <selector>] [--force] [--dry-run]")
+	}
+	if err := validateOutput(c.output); err != nil {
+		return err
+	}
+	selector, err := labels.Parse(c.selector)
+	if err != nil {
+		return fmt.Errorf("invalid --selector: %w", err)
+	}
+	kubeClient, err := env.KubeClient()
+	if err != nil {
+		return err
+	}
+	nodes, err := listAllNodes(ctx, kubeClient, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	removed := "removed"
+	if c.dryRun {
+		removed = "would remove"
+	}
+	config := env.Config.Current()
+	results := []cleanupResult{}
+	var errs []error
+	for i := range nodes {
+		node := &nodes[i]
+		annotations := controller.ManagedAnnotations(node, config)
+		taints := controller.ManagedTaints(node, config)
+		if len(annotations) == 0 && len(taints) == 0 {
+			continue
+		}
+
+		original := node.DeepCopy()
+		applied := controller.LastAppliedLabels(node)
+		keys := make([]string, 0, len(applied))
+		for key := range applied {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			current, exists := node.Labels[key]
+			switch {
+			case !exists:
+				continue
+			case current != applied[key] && !c.force:
+				results = append(results, cleanupResult{Node: node.Name, Kind: "label", Key: key, Value: current, Action: "kept"})
+			default:
+				delete(node.Labels, key)
+				results = append(results, cleanupResult{Node: node.Name, Kind: "label", Key: key, Value: current, Action: removed})
+			}
+		}
+		for _, key := range annotations {
+			results = append(results, cleanupResult{Node: node.Name, Kind: "annotation", Key: key, Value: node.Annotations[key], Action: removed})
+			delete(node.Annotations, key)
+		}
+		for _, taint := range taints {
+			results = append(results, cleanupResult{Node: node.Name, Kind: "taint", Key: taint.Key + ":" + string(taint.Effect), Value: taint.Value, Action: removed})
+		}
+		node.Spec.Taints = slices.DeleteFunc(node.Spec.Taints, func(taint corev1.Taint) bool {
+			return slices.ContainsFunc(taints, func(managed corev1.Taint) bool { return managed.MatchTaint(&taint) })
+		})
+
+		if c.dryRun {
+			continue
+		}
+		// A patch guarded by the resourceVersion only needs the patch verb, like the controller
+		// in --minimal-permissions mode
+		if err := kubeClient.Patch(ctx, node, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})); err != nil {
+			errs = append(errs, fmt.Errorf("failed to clean up node %s: %w", node.Name, err))
+		}
+	}
+
+	if c.output == "json" {
+		writeJSONTo(env.Out, results)
+	} else {
+		w := tabwriter.NewWriter(env.Out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NODE\tKIND\tKEY\tVALUE\tACTION")
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.Node, result.Kind, result.Key, result.Value, result.Action)
+		}
+		_ = w.Flush()
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// defaultSampleDevice is the device the mappings are rendered against when validate-config is
+// given no --sample-device, shaped like a device object of the Nautobot API
+const defaultSampleDevice = `{
+  "id": "00000000-0000-0000-0000-000000000000",
+  "name": "sample-node",
+  "site": {"display": "Sample Site", "name": "sample-site"},
+  "rack": {"display": "Sample Rack", "name": "sample-rack"},
+  "primary_ip4": {"id": "00000000-0000-0000-0000-000000000001", "address": "192.0.2.10/24"},
+  "primary_ip6": null,
+  "tags": [],
+  "status": {"value": "active"},
+  "custom_fields": {}
+}`
+
+// validateConfigCommand checks a configuration in depth for CI: besides the validation done at
+// startup, the mappings are rendered against sample devices and the results checked
+type validateConfigCommand struct {
+	sampleDevices []string
+	output        string
+}
+
+// sampleCheck is the outcome of rendering the mappings against one sample device
+type sampleCheck struct {
+	Sample string            `json:"sample"`
+	Labels map[string]string `json:"labels"`
+	// Problems fail the validation, e.g. templates referring to unknown fields or rendering
+	// illegal label values
+	Problems []string `json:"problems,omitempty"`
+	// Warnings are labels rendering empty, which the controller does not apply
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Summary implements subcommand
+func (c *validateConfigCommand) Summary() string {
+	return "Validate the configuration and render the mappings against sample devices (--sample-device <file>)"
+}
+
+// BindFlags implements subcommand
+func (c *validateConfigCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.StringArrayVar(&c.sampleDevices, "sample-device", nil,
+		"JSON file with a Nautobot device object to render the mappings against, can be repeated. Defaults to a built-in sample.")
+	fs.StringVarP(&c.output, "output", "o", "table", "Output format: table or json")
+}
+
+// Run implements subcommand. The flags and the configuration file were already validated by
+// the time a subcommand runs.
+func (c *validateConfigCommand) Run(_ context.Context, env *commandEnv, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: validate-config [--sample-device <file>]...")
+	}
+	if err := validateOutput(c.output); err != nil {
+		return err
+	}
+
+	samples := map[string]json.RawMessage{}
+	names := c.sampleDevices
+	for _, name := range names {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to read sample device: %w", err)
+		}
+		samples[name] = data
+	}
+	if len(names) == 0 {
+		names = []string{"built-in sample"}
+		samples[names[0]] = json.RawMessage(defaultSampleDevice)
+	}
+
+	mappings := env.Config.Current().CompiledMappings()
+	checks := make([]sampleCheck, 0, len(names))
+	problems := 0
+	for _, name := range names {
+		check, err := checkMappings(mappings, samples[name], env.ClusterName)
+		if err != nil {
+			return fmt.Errorf("invalid sample device %s: %w", name, err)
+		}
+		check.Sample = name
+		problems += len(check.Problems)
+		checks = append(checks, check)
+	}
+
+	if c.output == "json" {
+		writeJSONTo(env.Out, checks)
+	} else {
+		for _, check := range checks {
+			fmt.Fprintf(env.Out, "Sample:  %s\n", check.Sample)
+			printLabels(env.Out, check.Labels)
+			for _, problem := range check.Problems {
+				fmt.Fprintf(env.Out, "  error:   %s\n", problem)
+			}
+			for _, warning := range check.Warnings {
+				fmt.Fprintf(env.Out, "  warning: %s\n", warning)
+			}
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("found %d problem(s) in the configuration", problems)
+	}
+	if c.output == "table" {
+		fmt.Fprintln(env.Out, "Configuration is valid")
+	}
+	return nil
+}
+
+// checkMappings renders every mapping against a sample device and checks the label values
+func checkMappings(mappings []mapping.Mapping, sample json.RawMessage, clusterName string) (sampleCheck, error) {
+	check := sampleCheck{Labels: map[string]string{}}
+	device, err := nautobot.ParseDevice(sample)
+	if err != nil {
+		return check, err
+	}
+
+	data := mapping.TemplateData{DeviceData: device, ClusterName: clusterName}
+	for _, labelMapping := range mappings {
+		value, err := mapping.RenderTemplate(labelMapping.Template, data)
+		value = labelMapping.Normalize.Apply(value)
+		if value == "" {
+			value = labelMapping.Default
+		}
+		switch {
+		case err != nil:
+			check.Problems = append(check.Problems, fmt.Sprintf("label %q: %v", labelMapping.Label, err))
+		case value == "":
+			check.Warnings = append(check.Warnings, fmt.Sprintf("label %q renders empty and would not be applied", labelMapping.Label))
+		default:
+			if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+				check.Problems = append(check.Problems, fmt.Sprintf("label %q: invalid value %q: %s", labelMapping.Label, value, strings.Join(errs, "; ")))
+				continue
+			}
+			check.Labels[labelMapping.Label] = value
+		}
+	}
+	return check, nil
+}
+
+// versionCommand prints the build metadata of the binary. It runs before the configuration is
+// validated, so it works without any settings.
+type versionCommand struct {
+	output string
+}
+
+// Summary implements subcommand
+func (c *versionCommand) Summary() string {
+	return "Print the version, git commit, build date and Go version"
+}
+
+// BindFlags implements subcommand
+func (c *versionCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVarP(&c.output, "output", "o", "table", "Output format: table or json")
+}
+
+// Run implements subcommand. Only env.Out is set.
+func (c *versionCommand) Run(_ context.Context, env *commandEnv, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: version [--output table|json]")
+	}
+	if err := validateOutput(c.output); err != nil {
+		return err
+	}
+
+	build := currentBuild()
+	if c.output == "json" {
+		writeJSONTo(env.Out, build)
+		return nil
+	}
+	fmt.Fprintf(env.Out, "Version:     %s\n", build.Version)
+	fmt.Fprintf(env.Out, "Git commit:  %s\n", build.Commit)
+	fmt.Fprintf(env.Out, "Build date:  %s\n", build.BuildDate)
+	fmt.Fprintf(env.Out, "Go version:  %s\n", build.GoVersion)
+	fmt.Fprintf(env.Out, "Platform:    %s\n", build.Platform)
+	return nil
+}
+
+// writeJSONTo writes v as indented JSON
+func writeJSONTo(w io.Writer, v interface{}) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// flagEnvPrefix prefixes the environment variable of every flag
+const flagEnvPrefix = "NAUTOBOT_LABELER_"
+
+// flagEnvAliases are environment variables flags were read from before they all got one
+var flagEnvAliases = map[string]string{
+	"nautobot-url":                  "NAUTOBOT_URL",
+	"nautobot-token-file":           "NAUTOBOT_TOKEN_FILE",
+	"nautobot-secondary-token-file": "NAUTOBOT_SECONDARY_TOKEN_FILE",
+	"notify-webhook-url":            "NOTIFY_WEBHOOK_URL",
+}
+
+// flagEnvName returns the environment variable of a flag, e.g. NAUTOBOT_LABELER_LOG_LEVEL
+func flagEnvName(name string) string {
+	return flagEnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// lookupFlagEnv returns the environment value of a flag, preferring the prefixed variable
+func lookupFlagEnv(name string) (string, string, bool) {
+	env := flagEnvName(name)
+	if value, ok := os.LookupEnv(env); ok {
+		return env, value, true
+	}
+	if alias, ok := flagEnvAliases[name]; ok {
+		if value, ok := os.LookupEnv(alias); ok {
+			return alias, value, true
+		}
+	}
+	return "", "", false
+}
+
+// applyFlagEnv sets the flags that were not given on the command line from the environment
+func applyFlagEnv(fs *pflag.FlagSet) []error {
+	var errs []error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Changed {
+			return
+		}
+		if env, value, ok := lookupFlagEnv(f.Name); ok {
+			if err := fs.Set(f.Name, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for %s: %w", value, env, err))
+			}
+		}
+	})
+	return errs
+}
+
+// configFieldFlags are the flags overriding a field of the config file, with the field. The
+// flags section cannot set them, as the field of the same file is the place for their value.
+var configFieldFlags = map[string]string{
+	"nautobot-url":                  "nautobot.url",
+	"nautobot-token-file":           "nautobot.tokenFile",
+	"nautobot-secondary-token-file": "nautobot.secondaryTokenFile",
+	"node-selector":                 "nodeSelector",
+	"mapping-profiles":              "profiles",
+	"resync-interval":               "intervals.resync",
+	"unchanged-interval":            "intervals.unchanged",
+	"updated-interval":              "intervals.updated",
+	"retry-interval":                "intervals.retry",
+	"adaptive-min-interval":         "intervals.adaptiveMin",
+	"adaptive-max-interval":         "intervals.adaptiveMax",
+}
+
+// applyFlagFile sets the flags that were given neither on the command line nor in the
+// environment from the flags section of the config file. The values are set without marking
+// the flags changed, so only flags of the command line and the environment count as overrides.
+func applyFlagFile(fs *pflag.FlagSet, values map[string]string) []error {
+	var errs []error
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := fs.Lookup(name)
+		switch {
+		case f == nil:
+			errs = append(errs, fmt.Errorf("flags.%s: unknown flag", name))
+		case name == "config":
+			errs = append(errs, fmt.Errorf("flags.config: the config file cannot name itself"))
+		case configFieldFlags[name] != "":
+			errs = append(errs, fmt.Errorf("flags.%s: set %s of the config file instead", name, configFieldFlags[name]))
+		case !f.Changed:
+			if err := f.Value.Set(values[name]); err != nil {
+				errs = append(errs, fmt.Errorf("flags.%s: invalid value %q: %w", name, values[name], err))
+			}
+		}
+	}
+	return errs
+}
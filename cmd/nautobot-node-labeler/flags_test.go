@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/controller"
+)
+
+// newTestFlagSet returns a flag set with a few flags of the controller
+func newTestFlagSet() *pflag.FlagSet {
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.String("config", "", "")
+	fs.String("metrics-bind-address", ":8080", "")
+	fs.Int("max-concurrent-reconciles", 1, "")
+	fs.String("node-selector", "", "")
+	return fs
+}
+
+func TestApplyFlagFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		values  map[string]string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name:   "sets flags without marking them changed",
+			values: map[string]string{"metrics-bind-address": ":9090", "max-concurrent-reconciles": "4"},
+			want:   map[string]string{"metrics-bind-address": ":9090", "max-concurrent-reconciles": "4"},
+		},
+		{
+			name:   "command line wins",
+			args:   []string{"--metrics-bind-address=:7070"},
+			values: map[string]string{"metrics-bind-address": ":9090"},
+			want:   map[string]string{"metrics-bind-address": ":7070"},
+		},
+		{
+			name:    "unknown flag",
+			values:  map[string]string{"no-such-flag": "x"},
+			wantErr: "flags.no-such-flag: unknown flag",
+		},
+		{
+			name:    "config",
+			values:  map[string]string{"config": "other.yaml"},
+			wantErr: "flags.config: the config file cannot name itself",
+		},
+		{
+			name:    "flag of a config field",
+			values:  map[string]string{"node-selector": "role=a"},
+			want:    map[string]string{"node-selector": ""},
+			wantErr: "flags.node-selector: set nodeSelector of the config file instead",
+		},
+		{
+			name:    "invalid value",
+			values:  map[string]string{"max-concurrent-reconciles": "many"},
+			wantErr: `flags.max-concurrent-reconciles: invalid value "many"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := newTestFlagSet()
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			errs := applyFlagFile(fs, tt.values)
+			switch {
+			case tt.wantErr == "" && len(errs) > 0:
+				t.Fatalf("applyFlagFile() errors = %v", errs)
+			case tt.wantErr != "" && (len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), tt.wantErr)):
+				t.Fatalf("applyFlagFile() errors = %v, want %q", errs, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				if got := fs.Lookup(name).Value.String(); got != want {
+					t.Errorf("--%s = %q, want %q", name, got, want)
+				}
+			}
+			// Values of the file are no overrides
+			if len(tt.args) == 0 {
+				for name := range tt.values {
+					if fs.Changed(name) {
+						t.Errorf("--%s is marked changed", name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestApplyFlagFileReload(t *testing.T) {
+	writeConfig := func(t *testing.T, path, nodeSelector string) {
+		t.Helper()
+		data := "apiVersion: config.nautobot.io/v1alpha1\nkind: LabelerConfiguration\n" +
+			"nautobot: {url: https://nautobot.example.com, token: token}\n" +
+			"nodeSelector: " + nodeSelector + "\n" +
+			"flags: {metrics-bind-address: \":9090\"}\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "config file edits apply", want: "role=b"},
+		{name: "command line wins", args: []string{"--node-selector=role=c"}, want: "role=c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			writeConfig(t, path, "role=a")
+			o, fs := parseOptions(t, tt.args...)
+			fileFlags, err := controller.ReadConfigFlags(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if errs := applyFlagFile(fs, fileFlags); len(errs) > 0 {
+				t.Fatalf("applyFlagFile() errors = %v", errs)
+			}
+
+			// A reload parses the edited file with the same override
+			writeConfig(t, path, "role=b")
+			store, err := controller.NewConfigStore(path, o.configOverride(fs, ""))
+			if err != nil {
+				t.Fatalf("NewConfigStore() error = %v", err)
+			}
+			if got := store.Current().NodeSelector; got != tt.want {
+				t.Errorf("NodeSelector = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// LogOptions controls the format and verbosity of the controller's logs
+type LogOptions struct {
+	// Level is a zap level name (debug, info, warn, error) or a positive logr verbosity
+	Level string
+	// Encoder is json or console
+	Encoder string
+	// StacktraceLevel is the minimum level that includes stack traces
+	StacktraceLevel string
+	// Sampling enables zap's sampling of repetitive log lines
+	Sampling bool
+}
+
+// BindFlags registers the logging flags on the given flag set
+func (o *LogOptions) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.Level, "log-level", "info",
+		"Log level: debug, info, warn, error, or a positive integer for increasing logr verbosity")
+	fs.StringVar(&o.Encoder, "log-encoder", "json", "Log encoding: json or console")
+	fs.StringVar(&o.StacktraceLevel, "log-stacktrace-level", "error",
+		"Minimum level at which stack traces are included: debug, info, warn or error")
+	fs.BoolVar(&o.Sampling, "log-sampling", false,
+		"Sample repetitive log lines (first 100 per second, then every 100th) to bound log volume")
+}
+
+// parseLogLevel accepts zap level names as well as logr verbosity numbers (V(n) is zap level -n)
+func parseLogLevel(value string) (zapcore.Level, error) {
+	if verbosity, err := strconv.Atoi(value); err == nil {
+		if verbosity < 0 {
+			return 0, fmt.Errorf("log verbosity must not be negative, got %d", verbosity)
+		}
+		return zapcore.Level(-verbosity), nil
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", value)
+	}
+	return level, nil
+}
+
+// NewLogger builds the controller's logger from the options
+func (o *LogOptions) NewLogger() (logr.Logger, error) {
+	level, err := parseLogLevel(o.Level)
+	if err != nil {
+		return logr.Logger{}, err
+	}
+	stacktraceLevel, err := parseLogLevel(o.StacktraceLevel)
+	if err != nil {
+		return logr.Logger{}, err
+	}
+
+	config := zap.NewProductionConfig()
+	config.Level = zap.NewAtomicLevelAt(level)
+	config.EncoderConfig.EncodeTime = zapcore.RFC3339TimeEncoder
+	switch o.Encoder {
+	case "json":
+		config.Encoding = "json"
+	case "console":
+		config.Encoding = "console"
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	default:
+		return logr.Logger{}, fmt.Errorf("invalid log encoder %q (expected json or console)", o.Encoder)
+	}
+	// Stack traces are controlled below so they follow --log-stacktrace-level
+	config.DisableStacktrace = true
+	// Sampling is set up below, around the redaction of credentials
+	config.Sampling = nil
+	// zap's sampler cannot handle levels below debug, so high verbosity always disables sampling
+	sampling := o.Sampling && level >= zapcore.DebugLevel
+
+	logger, err := config.Build(zap.AddStacktrace(stacktraceLevel), zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		core = redact.Core(core)
+		if sampling {
+			core = zapcore.NewSamplerWithOptions(core, time.Second, 100, 100)
+		}
+		return core
+	}))
+	if err != nil {
+		return logr.Logger{}, fmt.Errorf("failed to build logger: %w", err)
+	}
+	return zapr.NewLogger(logger), nil
+}
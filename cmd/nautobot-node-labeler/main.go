@@ -0,0 +1,164 @@
+// Command nautobot-node-labeler runs the node labeling controller and its operational
+// subcommands
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/spf13/pflag"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/controller"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// exitWithStartupErrors prints every startup error, one per line, and exits
+func exitWithStartupErrors(errs []error) {
+	fmt.Fprintln(os.Stderr, "Invalid configuration:")
+	for _, err := range errs {
+		var aggregate utilerrors.Aggregate
+		if errors.As(err, &aggregate) {
+			for _, err := range utilerrors.Flatten(aggregate).Errors() {
+				fmt.Fprintf(os.Stderr, "  - %v\n", err)
+			}
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "  - %v\n", err)
+	}
+	os.Exit(1)
+}
+
+// isLoopbackAddress reports whether a host:port address binds to localhost only
+func isLoopbackAddress(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// main sets up the manager and starts the controller
+func main() {
+	var o options
+	o.BindFlags(pflag.CommandLine)
+	controller.FeatureGates.AddFlag(pflag.CommandLine)
+	// Pick up flags registered by libraries, e.g. --kubeconfig
+	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
+	pflag.Usage = usage
+	// A leading non-flag argument selects a one-off subcommand instead of the controller
+	command, args, err := parseSubcommand(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if command != nil {
+		command.BindFlags(pflag.CommandLine)
+	}
+	kubectlPlugin := runningAsKubectlPlugin()
+	if kubectlPlugin {
+		// Accept kubectl's name for the flag selecting the kubeconfig context
+		pflag.StringVar(&o.kubeContext, "context", "", "Alias of --kube-context, like kubectl's --context")
+	}
+	_ = pflag.CommandLine.Parse(args)
+	if kubectlPlugin && command == nil {
+		usage()
+		os.Exit(2)
+	}
+	if _, ok := command.(*versionCommand); ok {
+		if err := command.Run(context.Background(), &commandEnv{Out: os.Stdout}, pflag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Flags not given on the command line come from the environment, then from the flags
+	// section of the config file
+	var startupErrs []error
+	startupErrs = append(startupErrs, applyFlagEnv(pflag.CommandLine)...)
+	if o.configFile != "" {
+		fileFlags, err := controller.ReadConfigFlags(o.configFile)
+		if err != nil {
+			startupErrs = append(startupErrs, err)
+		}
+		startupErrs = append(startupErrs, applyFlagFile(pflag.CommandLine, fileFlags)...)
+	}
+	// With --mock-nautobot, the controller talks to fixtures served in-process
+	// and subcommands like benchmark with a mock of their own
+	mockNautobotURL, err := startMockNautobot(o.mockNautobotFixtures, command)
+	if err != nil {
+		startupErrs = append(startupErrs, err)
+	}
+
+	// Validate all settings up front and report every problem at once
+	logger, err := o.logOptions.NewLogger()
+	if err != nil {
+		startupErrs = append(startupErrs, err)
+	} else {
+		ctrl.SetLogger(logger)
+	}
+	s, errs := o.validate(pflag.CommandLine, mockNautobotURL)
+	startupErrs = append(startupErrs, errs...)
+	if len(startupErrs) > 0 {
+		exitWithStartupErrors(startupErrs)
+	}
+	if o.validateConfig {
+		fmt.Println("Configuration is valid")
+		return
+	}
+
+	devices := newDeviceSources(&o, s)
+	devices.mock = mockNautobotURL != ""
+	if command != nil {
+		env := &commandEnv{
+			NautobotClient: devices.nautobot,
+			Config:         s.configStore,
+			ConflictPolicy: s.conflictPolicy,
+			ClusterName:    o.clusterName,
+			MetadataOnly:   o.minimalPermissions,
+			AuditSink:      s.auditSink,
+			KubeContext:    o.kubeContext,
+			KubeAPIQPS:     o.kubeAPIQPS,
+			KubeAPIBurst:   o.kubeAPIBurst,
+			MockNautobot:   devices.mock,
+			Out:            os.Stdout,
+
+			AllowZoneChanges:        o.allowZoneChanges,
+			LookupKey:               s.lookupKey,
+			MaxConcurrentReconciles: o.maxConcurrentReconciles,
+		}
+		if err := command.Run(ctrl.SetupSignalHandler(), env, pflag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	runController(&o, s, devices)
+}
+
+// startMockNautobot starts the mock Nautobot of --mock-nautobot, or the one of a subcommand
+// like benchmark, and returns its URL. Without a mock the URL is empty.
+func startMockNautobot(fixtures string, command subcommand) (string, error) {
+	var mock *nautobot.MockServer
+	var err error
+	if fixtures != "" {
+		mock, err = nautobot.LoadMockServer(fixtures)
+	} else if mocking, ok := command.(mockingSubcommand); ok {
+		mock, err = mocking.MockNautobot()
+	}
+	if err != nil || mock == nil {
+		return "", err
+	}
+	return mock.Start()
+}
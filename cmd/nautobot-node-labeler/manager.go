@@ -0,0 +1,642 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/api/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/controller"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/plugin"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// runController sets up the manager with the controllers the options enable and runs it until
+// the process is signaled to stop
+func runController(o *options, s *settings, devices *deviceSources) {
+	build := currentBuild()
+	ctrl.Log.WithName("setup").Info("Starting nautobot-node-labeler", "Version", build.Version, "Commit", build.Commit,
+		"BuildDate", build.BuildDate, "GoVersion", build.GoVersion, "Platform", build.Platform)
+
+	// Outside a cluster the API server is taken from --kubeconfig or $KUBECONFIG, otherwise the
+	// in-cluster service account is used
+	restConfig, err := ctrlconfig.GetConfigWithContext(o.kubeContext)
+	if err != nil {
+		exitWithStartupErrors([]error{fmt.Errorf("failed to load Kubernetes client configuration: %w", err)})
+	}
+	restConfig.QPS, restConfig.Burst = o.kubeAPIQPS, o.kubeAPIBurst
+
+	clusterName := o.clusterName
+	if o.openShift && clusterName == "" {
+		infraClient, err := client.New(restConfig, client.Options{})
+		if err != nil {
+			exitWithStartupErrors([]error{fmt.Errorf("failed to create Kubernetes client: %w", err)})
+		}
+		clusterName, err = controller.OpenShiftClusterName(context.Background(), infraClient)
+		if err != nil {
+			exitWithStartupErrors([]error{err})
+		}
+		ctrl.Log.WithName("setup").Info("Using the OpenShift infrastructure name as cluster name", "ClusterName", clusterName)
+	}
+
+	members, err := loadMemberClusters(o, s, restConfig)
+	if err != nil {
+		exitWithStartupErrors([]error{err})
+	}
+
+	if o.tracingEndpoint != "" {
+		shutdownTracing, err := controller.SetupTracing(context.Background(), o.tracingEndpoint, o.tracingInsecure, o.tracingSamplingRatio)
+		if err != nil {
+			panic(fmt.Sprintf("Unable to set up tracing: %v", err))
+		}
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = shutdownTracing(shutdownCtx)
+		}()
+	}
+
+	// Create a controller-runtime manager
+	mgr, err := ctrl.NewManager(restConfig, o.managerOptions(s))
+	if err != nil {
+		panic(fmt.Sprintf("Unable to create manager: %v", err))
+	}
+
+	setBuildInfo()
+	controller.RecordFeatureGates()
+	if err := mgr.Add(manager.RunnableFunc(controller.TrackLeadership)); err != nil {
+		panic(fmt.Sprintf("Unable to add leadership tracking to manager: %v", err))
+	}
+
+	// Add core types (Node, etc.) to the scheme
+	if err := corev1.AddToScheme(mgr.GetScheme()); err != nil {
+		panic(fmt.Sprintf("Unable to add corev1 to scheme: %v", err))
+	}
+	if err := v1alpha1.AddToScheme(mgr.GetScheme()); err != nil {
+		panic(fmt.Sprintf("Unable to add v1alpha1 to scheme: %v", err))
+	}
+
+	w := &wiring{opts: o, settings: s, mgr: mgr, clusterName: clusterName, devices: devices}
+	w.addNautobot()
+	w.addStatus()
+	w.addClusterObjects()
+	w.addEventRecorder()
+	reconciler := w.addNodeReconciler()
+	w.addMemberClusters(reconciler, members)
+	w.addReverseSync(reconciler)
+
+	if o.shard.Sharded() {
+		ctrl.Log.WithName("setup").Info("Reconciling a shard of the nodes", "ShardIndex", o.shard.Index, "ShardCount", o.shard.Count)
+	}
+
+	// Start the manager (blocking call)
+	fmt.Println("Starting Nautobot Node Labeler Controller...")
+	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+		panic(fmt.Sprintf("Manager exited non-zero: %v", err))
+	}
+}
+
+// loadMemberClusters returns the member clusters of --member-kubeconfigs and the secrets of
+// --member-cluster-secrets-namespace
+func loadMemberClusters(o *options, s *settings, restConfig *rest.Config) ([]controller.MemberCluster, error) {
+	members, err := controller.LoadMemberKubeconfigs(o.memberKubeconfigs)
+	if err != nil {
+		return nil, err
+	}
+	if o.memberSecretsNamespace != "" {
+		secretsClient, err := client.New(restConfig, client.Options{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+		}
+		secretMembers, err := controller.LoadMemberSecrets(context.Background(), secretsClient, o.memberSecretsNamespace, s.memberSelector)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, secretMembers...)
+	}
+	memberNames := map[string]bool{}
+	for _, member := range members {
+		if memberNames[member.Name] {
+			return nil, fmt.Errorf("member cluster %s is configured twice", member.Name)
+		}
+		memberNames[member.Name] = true
+		member.Config.QPS, member.Config.Burst = o.kubeAPIQPS, o.kubeAPIBurst
+	}
+	return members, nil
+}
+
+// managerOptions returns the options of the manager: its metrics, probe, pprof and webhook
+// servers and leader election
+func (o *options) managerOptions(s *settings) ctrl.Options {
+	metricsOptions := metricsserver.Options{
+		BindAddress:   o.metricsAddr,
+		SecureServing: o.metricsSecure,
+		CertDir:       o.metricsCertDir,
+		CertName:      o.metricsCertName,
+		KeyName:       o.metricsKeyName,
+	}
+	metricsOptions.TLSOpts = append(metricsOptions.TLSOpts, s.applyTLSOptions)
+	if !o.enableHTTP2 {
+		metricsOptions.TLSOpts = append(metricsOptions.TLSOpts, func(c *tls.Config) {
+			c.NextProtos = []string{"http/1.1"}
+		})
+	}
+	if o.metricsAuth {
+		metricsOptions.FilterProvider = filters.WithAuthenticationAndAuthorization
+	}
+
+	var webhookServer webhook.Server
+	if o.nodeWebhook || o.podTopologyWebhook {
+		webhookServer = webhook.NewServer(webhook.Options{
+			Port:    o.webhookPort,
+			CertDir: o.webhookCertDir,
+			TLSOpts: []func(*tls.Config){s.applyTLSOptions},
+		})
+	}
+	leaseDuration, renewDeadline, retryPeriod := o.leaseDuration, o.renewDeadline, o.retryPeriod
+	return ctrl.Options{
+		Scheme: runtime.NewScheme(),
+		// You can fine-tune the cache if you want to limit which objects you watch
+		Cache: cache.Options{
+			DefaultNamespaces: map[string]cache.Config{
+				metav1.NamespaceAll: {},
+			},
+			// Cached nodes only keep what the controllers read, which matters with thousands
+			// of nodes
+			DefaultTransform: controller.TrimCachedObject,
+		},
+		Metrics:                metricsOptions,
+		HealthProbeBindAddress: o.probeAddr,
+		PprofBindAddress:       o.pprofAddr,
+		// Only the leader runs the controllers; standbys serve probes, metrics and debug
+		// endpoints and take over when the lease expires
+		LeaderElection:          o.leaderElect,
+		LeaderElectionID:        s.leaderElectionID,
+		LeaderElectionNamespace: o.leaderElectionNamespace,
+		LeaseDuration:           &leaseDuration,
+		RenewDeadline:           &renewDeadline,
+		RetryPeriod:             &retryPeriod,
+		// The process exits right after the manager stops, so the lease can be handed over
+		// without waiting for it to expire
+		LeaderElectionReleaseOnCancel: true,
+		WebhookServer:                 webhookServer,
+	}
+}
+
+// wiring is what the parts of the manager set up by runController share
+type wiring struct {
+	opts        *options
+	settings    *settings
+	mgr         ctrl.Manager
+	clusterName string
+	devices     *deviceSources
+
+	// Set up by addNautobot
+	nautobotCheck *controller.NautobotHealthCheck
+	startupGate   *controller.NautobotStartupGate
+	// Set up by addStatus
+	missingNodes *controller.MissingNodes
+	syncRecords  *controller.SyncRecords
+	recentErrors *controller.RecentErrors
+	debugServer  *controller.DebugServer
+	// Set up by addEventRecorder
+	recorder *controller.EventLimiter
+}
+
+// addNautobot makes the Nautobot client follow the config file, and adds the Nautobot health
+// checks and the startup gate
+func (w *wiring) addNautobot() {
+	o, s, mgr := w.opts, w.settings, w.mgr
+	nautobotClient, serviceNowClient := w.devices.nautobot, w.devices.serviceNow
+
+	// Follow changes of the Nautobot endpoint, tokens, device filters, lookup depth, relationships, location types, rack groups and circuits
+	s.configStore.OnChange(func(config *controller.Config) {
+		nautobotClient.SetEndpoint(config.Nautobot.URL, config.Nautobot.Token)
+		configureNautobotClient(nautobotClient, config)
+		if serviceNowClient != nil && config.ServiceNow != nil {
+			serviceNowClient.SetConfig(*config.ServiceNow)
+		}
+	})
+	// Also without files to watch the store handles SIGHUP, which would otherwise terminate the
+	// process
+	if err := mgr.Add(s.configStore); err != nil {
+		panic(fmt.Sprintf("Unable to add config watcher to manager: %v", err))
+	}
+
+	// Liveness only needs the process to respond; with the fail-fast startup policy readiness
+	// also requires a working Nautobot connection so bad tokens or DNS failures surface as an
+	// unready pod
+	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
+		panic(fmt.Sprintf("Unable to set up health check: %v", err))
+	}
+	w.nautobotCheck = &controller.NautobotHealthCheck{Source: w.devices.source, Interval: 30 * time.Second}
+	startupTimeout := o.startupTimeout
+	if s.startupPolicy == controller.StartupPolicyFailFast {
+		if err := mgr.AddReadyzCheck("nautobot", w.nautobotCheck.Check); err != nil {
+			panic(fmt.Sprintf("Unable to set up ready check: %v", err))
+		}
+	} else {
+		startupTimeout = 0
+	}
+	w.startupGate = controller.NewNautobotStartupGate(w.devices.source, startupTimeout)
+	// The mock serves the reads of the controller only
+	if !w.devices.mock {
+		w.startupGate.Permissions = &controller.TokenPermissions{
+			Checker:     nautobotClient,
+			Mode:        s.permissionCheck,
+			Permissions: controller.NautobotPermissions(s.configStore.Current()),
+		}
+	}
+	if err := mgr.Add(w.startupGate); err != nil {
+		panic(fmt.Sprintf("Unable to add Nautobot startup gate to manager: %v", err))
+	}
+}
+
+// addStatus tracks nodes without a Nautobot device and the last sync of every node, and
+// optionally exposes them on the debug server and in the status resource
+func (w *wiring) addStatus() {
+	o, s, mgr := w.opts, w.settings, w.mgr
+	w.missingNodes = controller.NewMissingNodes()
+	w.syncRecords = controller.NewSyncRecords()
+	// Nautobot responses are only served by the debug API
+	w.syncRecords.SkipResponses = o.debugAddr == ""
+	statusHandler := &controller.StatusHandler{
+		Client:       mgr.GetClient(),
+		MetadataOnly: o.minimalPermissions,
+		SyncRecords:  w.syncRecords,
+		MissingNodes: w.missingNodes,
+		HealthCheck:  w.nautobotCheck,
+		Shard:        o.shard,
+	}
+	if o.debugAddr != "" {
+		w.debugServer = controller.NewDebugServer(o.debugAddr)
+		w.debugServer.Secure = o.debugSecure
+		w.debugServer.CertDir = o.debugCertDir
+		w.debugServer.TLSOpts = []func(*tls.Config){s.applyTLSOptions}
+		if o.debugAuth {
+			var err error
+			w.debugServer.Filter, err = filters.WithAuthenticationAndAuthorization(mgr.GetConfig(), mgr.GetHTTPClient())
+			if err != nil {
+				panic(fmt.Sprintf("Unable to set up debug server authentication: %v", err))
+			}
+		}
+		w.debugServer.Handle("/debug/missing-nodes", w.missingNodes)
+		w.debugServer.Handle("/debug/nodes/", w.syncRecords)
+		w.recentErrors = controller.NewRecentErrors(o.debugRecentErrors)
+		w.debugServer.Handle("/debug/errors", w.recentErrors)
+		w.debugServer.Handle("/status", statusHandler)
+		if err := mgr.Add(w.debugServer); err != nil {
+			panic(fmt.Sprintf("Unable to add debug server to manager: %v", err))
+		}
+	}
+
+	// Publish the sync summary as a custom resource for GitOps health checks
+	if o.statusResourceName != "" {
+		publisher := &controller.StatusPublisher{
+			Client:   mgr.GetClient(),
+			Summary:  statusHandler,
+			Name:     o.statusResourceName + o.shard.Suffix(),
+			Interval: time.Minute,
+		}
+		if err := mgr.Add(publisher); err != nil {
+			panic(fmt.Sprintf("Unable to add status publisher to manager: %v", err))
+		}
+	}
+}
+
+// addClusterObjects adds the maintainers of the objects kept up to date besides nodes: the
+// PrometheusRule, the topology ConfigMap, the node group templates and topology-aware Services
+func (w *wiring) addClusterObjects() {
+	o, s, mgr := w.opts, w.settings, w.mgr
+	// Keep the recommended alerts in line with the metrics of this binary
+	if o.prometheusRuleName != "" {
+		rules := &controller.PrometheusRules{
+			Client:           mgr.GetClient(),
+			Namespace:        o.prometheusRuleNamespace,
+			Name:             o.prometheusRuleName,
+			Labels:           o.prometheusRuleLabels,
+			MissingLabelsFor: o.prometheusRuleMissingFor,
+			Interval:         5 * time.Minute,
+		}
+		if err := mgr.Add(rules); err != nil {
+			panic(fmt.Sprintf("Unable to add PrometheusRule maintainer to manager: %v", err))
+		}
+	}
+
+	// Publish the node topology to workloads that may not read nodes
+	if o.topologyConfigMapName != "" {
+		mirror := &controller.TopologyMirror{
+			Client:       mgr.GetClient(),
+			Reader:       mgr.GetAPIReader(),
+			Namespace:    o.topologyConfigMapNamespace,
+			Name:         o.topologyConfigMapName,
+			Interval:     time.Minute,
+			MetadataOnly: o.minimalPermissions,
+		}
+		if err := mgr.Add(mirror); err != nil {
+			panic(fmt.Sprintf("Unable to add topology ConfigMap maintainer to manager: %v", err))
+		}
+	}
+
+	if kinds := controller.SplitList(o.nodeGroupTemplates); len(kinds) > 0 {
+		templates := &controller.NodeGroupTemplates{
+			Client:       mgr.GetClient(),
+			Config:       s.configStore,
+			Kinds:        kinds,
+			Interval:     5 * time.Minute,
+			MetadataOnly: o.minimalPermissions,
+		}
+		if err := mgr.Add(templates); err != nil {
+			panic(fmt.Sprintf("Unable to add node group templates to manager: %v", err))
+		}
+	}
+	if services := controller.SplitList(o.topologyAwareServices); len(services) > 0 {
+		routing := &controller.TopologyRouting{
+			Client:       mgr.GetClient(),
+			Reader:       mgr.GetAPIReader(),
+			Services:     services,
+			Interval:     5 * time.Minute,
+			MetadataOnly: o.minimalPermissions,
+		}
+		if err := mgr.Add(routing); err != nil {
+			panic(fmt.Sprintf("Unable to add topology-aware routing to manager: %v", err))
+		}
+	}
+}
+
+// addEventRecorder adds the recorder of the controllers' events. Events are deduplicated and
+// rate limited, with summaries of the suppressed ones on the controller's pod if it knows its
+// name.
+func (w *wiring) addEventRecorder() {
+	w.recorder = controller.NewEventLimiter(redact.Recorder(w.mgr.GetEventRecorderFor("nautobot-node-labeler")), w.opts.eventDedupWindow, w.opts.eventRateLimit)
+	if podName, podNamespace := os.Getenv("POD_NAME"), os.Getenv("POD_NAMESPACE"); podName != "" && podNamespace != "" {
+		w.recorder.Summary = &corev1.ObjectReference{APIVersion: "v1", Kind: "Pod", Namespace: podNamespace, Name: podName}
+	}
+	if err := w.mgr.Add(w.recorder); err != nil {
+		panic(fmt.Sprintf("Unable to add event limiter to manager: %v", err))
+	}
+}
+
+// addNodeReconciler creates the node reconciler and, unless --reconcile-local-nodes is
+// disabled, registers it for the controller's own cluster, with the admission webhooks. Member
+// clusters get copies of it.
+func (w *wiring) addNodeReconciler() *controller.NodeReconciler {
+	o, s, mgr := w.opts, w.settings, w.mgr
+	nautobotClient := w.devices.nautobot
+
+	var notifier *controller.FailureNotifier
+	if o.notifyWebhookURL != "" {
+		notifier = controller.NewFailureNotifier(o.notifyWebhookURL, o.notifyFailureThreshold)
+	}
+
+	reconciler := &controller.NodeReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		NautobotClient: nautobotClient,
+		Source:         w.devices.source,
+		LookupKey:      s.lookupKey,
+		Recorder:       w.recorder,
+		ConflictPolicy: s.conflictPolicy,
+		Config:         s.configStore,
+		MissingNodes:   w.missingNodes,
+		PartialData:    controller.NewPartialData(),
+		Rollout:        controller.NewRollout(),
+		AuditSink:      s.auditSink,
+		Notifier:       notifier,
+		SyncRecords:    w.syncRecords,
+		RecentErrors:   w.recentErrors,
+	}
+	reconciler.MetadataOnly = o.minimalPermissions
+	reconciler.AllowZoneChanges = o.allowZoneChanges
+	reconciler.LabelHistory = o.labelHistory
+	if s.zoneVolumePolicy != controller.ZoneVolumePolicyOff {
+		reconciler.ZoneVolumes = &controller.ZoneVolumeCheck{Reader: mgr.GetAPIReader(), Policy: s.zoneVolumePolicy}
+	}
+	if o.disruptionCheck {
+		reconciler.Disruptions = &controller.DisruptionGuard{Reader: mgr.GetAPIReader(), MaxUnavailablePerRack: o.maxUnavailablePerRack}
+	}
+	if o.waitForNodeCondition != "" || o.recheckKubeletRestarts {
+		reconciler.Registration = controller.NewNodeRegistration(corev1.NodeConditionType(o.waitForNodeCondition), o.recheckKubeletRestarts)
+	}
+	reconciler.MaxConcurrentReconciles = o.maxConcurrentReconciles
+	reconciler.LookupTimeout = o.lookupTimeout
+	if controller.FeatureGates.Enabled(controller.AdaptiveRequeue) {
+		reconciler.Scheduler = controller.NewAdaptiveScheduler()
+	}
+	reconciler.LabelRefresh = controller.NewLabelRefresh()
+	reconciler.Startup = w.startupGate
+	reconciler.ClusterName = w.clusterName
+	reconciler.Shard = o.shard
+	if o.nodeWriteRate > 0 {
+		reconciler.WriteThrottle = controller.NewNodeWriteThrottle(o.nodeWriteRate, o.nodeWriteBatchSize)
+	}
+	if o.bulkResync && o.reconcileLocalNodes {
+		reconciler.BulkResync = controller.NewBulkResync(mgr.GetClient(), nautobotClient, s.configStore)
+		reconciler.BulkResync.MetadataOnly = o.minimalPermissions
+		reconciler.BulkResync.Startup = w.startupGate
+		reconciler.BulkResync.Shard = o.shard
+		reconciler.BulkResync.LookupKey = s.lookupKey
+		if err := mgr.Add(reconciler.BulkResync); err != nil {
+			panic(fmt.Sprintf("Unable to add bulk resync to manager: %v", err))
+		}
+	}
+	// Reloaded labeling rules and SIGHUPs resync all nodes
+	if o.reconcileLocalNodes {
+		reconciler.RulesResync = controller.NewRulesResync(mgr.GetClient())
+		reconciler.RulesResync.MetadataOnly = o.minimalPermissions
+		reconciler.RulesResync.Shard = o.shard
+		s.configStore.OnRulesChange(reconciler.RulesResync.Trigger)
+		if err := mgr.Add(reconciler.RulesResync); err != nil {
+			panic(fmt.Sprintf("Unable to add rules resync to manager: %v", err))
+		}
+	}
+	if o.deviceStoreInterval > 0 {
+		reconciler.DeviceStore = controller.NewDeviceStore(nautobotClient, o.deviceStoreInterval)
+		reconciler.DeviceStore.Startup = w.startupGate
+		if o.deviceStoreFile != "" {
+			reconciler.DeviceStore.File = o.deviceStoreFile
+			// A broken file only costs a full listing of Nautobot
+			if count, err := reconciler.DeviceStore.Load(); err != nil {
+				ctrl.Log.WithName("setup").Error(err, "Failed to load the device store")
+			} else if count > 0 {
+				ctrl.Log.WithName("setup").Info("Loaded the device store", "Devices", count, "File", o.deviceStoreFile)
+			}
+		}
+		if err := mgr.Add(reconciler.DeviceStore); err != nil {
+			panic(fmt.Sprintf("Unable to add device store to manager: %v", err))
+		}
+	}
+	if o.nodeFeaturesNamespace != "" {
+		reconciler.NodeFeatures = &controller.NodeFeatures{Client: mgr.GetClient(), Namespace: o.nodeFeaturesNamespace}
+	}
+	if o.mappingPluginAddress != "" {
+		transportCredentials := insecure.NewCredentials()
+		if o.mappingPluginTLS {
+			pluginTLSConfig := &tls.Config{}
+			s.applyTLSOptions(pluginTLSConfig)
+			transportCredentials = credentials.NewTLS(pluginTLSConfig)
+		}
+		pluginClient, err := plugin.NewClient(o.mappingPluginAddress, grpc.WithTransportCredentials(transportCredentials))
+		if err != nil {
+			panic(fmt.Sprintf("Unable to create mapping plugin client: %v", err))
+		}
+		reconciler.MappingPlugin = &controller.MappingPlugin{Mapper: pluginClient, Timeout: o.mappingPluginTimeout}
+	}
+	if providers := controller.SplitList(o.cloudFallbackProviders); len(providers) > 0 {
+		reconciler.CloudFallback = &controller.CloudFallback{Providers: providers}
+	}
+	if o.dnsNameAnnotations {
+		reconciler.DNSNames = &controller.DNSNames{Client: nautobotClient}
+	}
+	if o.clusterAPIMachines {
+		reconciler.Machines = &controller.MachineAnnotations{Client: mgr.GetClient()}
+	}
+	if o.nodeSyncResources {
+		reconciler.SyncResources = &controller.NodeSyncResources{Client: mgr.GetClient(), NautobotClient: nautobotClient}
+	}
+	if o.reconcileLocalNodes {
+		if err := reconciler.SetupWithManager(mgr); err != nil {
+			panic(fmt.Sprintf("Unable to setup controller.NodeReconciler with manager: %v", err))
+		}
+	}
+	if o.nodeWebhook {
+		mgr.GetWebhookServer().Register(controller.NodeWebhookPath, &webhook.Admission{
+			Handler: &controller.NodeLabelWebhook{Reconciler: reconciler, Timeout: o.nodeWebhookTimeout},
+		})
+	}
+	if o.podTopologyWebhook {
+		mgr.GetWebhookServer().Register(controller.PodWebhookPath, &webhook.Admission{
+			Handler: &controller.PodTopologyWebhook{Client: mgr.GetClient(), Config: s.configStore, MetadataOnly: o.minimalPermissions},
+		})
+	}
+	return reconciler
+}
+
+// addMemberClusters labels the nodes of every member cluster with their own reconciler, status
+// and status resource
+func (w *wiring) addMemberClusters(reconciler *controller.NodeReconciler, members []controller.MemberCluster) {
+	o, mgr := w.opts, w.mgr
+	for _, member := range members {
+		memberReconciler, err := controller.AddMemberCluster(mgr, member, reconciler)
+		if err != nil {
+			panic(fmt.Sprintf("Unable to add member cluster: %v", err))
+		}
+		memberStatus := &controller.StatusHandler{
+			Client:       memberReconciler.Client,
+			MetadataOnly: o.minimalPermissions,
+			SyncRecords:  memberReconciler.SyncRecords,
+			MissingNodes: memberReconciler.MissingNodes,
+			HealthCheck:  w.nautobotCheck,
+			Shard:        o.shard,
+		}
+		if w.debugServer != nil {
+			w.debugServer.Handle("/status/clusters/"+member.Name, memberStatus)
+		}
+		if o.statusResourceName != "" {
+			publisher := &controller.StatusPublisher{
+				Client:   mgr.GetClient(),
+				Summary:  memberStatus,
+				Name:     o.statusResourceName + "-" + member.Name + o.shard.Suffix(),
+				Interval: time.Minute,
+			}
+			if err := mgr.Add(publisher); err != nil {
+				panic(fmt.Sprintf("Unable to add status publisher to manager: %v", err))
+			}
+		}
+	}
+	if len(members) > 0 {
+		ctrl.Log.WithName("setup").Info("Labeling the nodes of member clusters", "Clusters", len(members))
+	}
+}
+
+// addReverseSync registers the reverse-sync controller if any Kubernetes -> Nautobot sync is
+// enabled
+func (w *wiring) addReverseSync(reconciler *controller.NodeReconciler) {
+	if w.settings.reverseSync == nil {
+		return
+	}
+	reverseSync := w.settings.reverseSync
+	reverseSync.Client = w.mgr.GetClient()
+	reverseSync.NautobotClient = w.devices.nautobot
+	reverseSync.Startup = w.startupGate
+	reverseSync.DeviceStore = reconciler.DeviceStore
+	reverseSync.Cluster = w.clusterName
+	reverseSync.Recorder = w.recorder
+	if w.startupGate.Permissions != nil {
+		w.startupGate.Permissions.Permissions = append(w.startupGate.Permissions.Permissions, reverseSync.Permissions()...)
+	}
+	if err := reverseSync.SetupWithManager(w.mgr); err != nil {
+		panic(fmt.Sprintf("Unable to setup controller.ReverseSyncReconciler with manager: %v", err))
+	}
+}
+
+// deviceSources are the clients of the systems nodes are labeled from
+type deviceSources struct {
+	nautobot   *nautobot.Client
+	serviceNow *controller.ServiceNowClient
+	// source serves the devices of nodes: Nautobot unless ServiceNow is configured, with the
+	// static devices as fallback
+	source controller.DeviceSource
+	// mock is set when the devices are served by the mock Nautobot
+	mock bool
+}
+
+// newDeviceSources creates the Nautobot client, and the ServiceNow client if the config file
+// configures ServiceNow
+func newDeviceSources(o *options, s *settings) *deviceSources {
+	config := s.configStore.Current()
+	nautobotTLSConfig := &tls.Config{}
+	s.applyTLSOptions(nautobotTLSConfig)
+	nautobotClient := nautobot.NewClient(config.Nautobot.URL, config.Nautobot.Token, nautobotTLSConfig)
+	configureNautobotClient(nautobotClient, config)
+	nautobotClient.SetLogRequests(o.logNautobotRequests)
+	if o.faults.Enabled() {
+		ctrl.Log.WithName("setup").Info("Injecting faults into Nautobot requests", "Latency", o.faults.Latency, "LatencyRate", o.faults.LatencyRate,
+			"ErrorRate", o.faults.ErrorRate, "NotFoundRate", o.faults.NotFoundRate)
+		nautobotClient.SetFaultInjection(o.faults)
+	}
+	devices := &deviceSources{nautobot: nautobotClient, source: nautobotClient}
+	if config.ServiceNow != nil {
+		devices.serviceNow = controller.NewServiceNowClient(*config.ServiceNow, nautobotTLSConfig)
+		devices.source = devices.serviceNow
+	}
+	if s.staticDevices != nil {
+		devices.source = &controller.FallbackSource{Source: devices.source, Static: s.staticDevices}
+	}
+	return devices
+}
+
+// configureNautobotClient applies the tokens, device filters and lookup depth of the config, and
+// includes the related objects its mappings read in device lookups
+func configureNautobotClient(nautobotClient *nautobot.Client, config *controller.Config) {
+	nautobotClient.SetSecondaryToken(config.Nautobot.SecondaryToken)
+	nautobotClient.SetDeviceFilters(config.Nautobot.DeviceFilters)
+	nautobotClient.SetDepth(config.LookupDepth())
+	nautobotClient.SetIncludeRelationships(len(config.Relationships) > 0)
+	nautobotClient.SetIncludeLocations(len(config.LocationTypes) > 0)
+	nautobotClient.SetIncludeRackGroups(len(config.RackGroupLevels()) > 0)
+	nautobotClient.SetIncludeInventoryItems(len(config.InventoryItemMappings()) > 0)
+	nautobotClient.SetIncludeHardwareNotices(config.Lifecycle != nil && config.Lifecycle.DeviceLifecyclePlugin)
+	nautobotClient.SetIncludePowerFeeds(config.PowerRedundancy != nil)
+	nautobotClient.SetIncludeCircuits(config.CircuitLookup())
+}
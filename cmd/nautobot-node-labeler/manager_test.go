@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+)
+
+func TestManagerOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantWebhook bool
+		wantHTTP2   bool
+	}{
+		{name: "defaults"},
+		{name: "HTTP/2", args: []string{"--enable-http2"}, wantHTTP2: true},
+		{name: "node webhook", args: []string{"--node-webhook"}, wantWebhook: true},
+		{name: "pod topology webhook", args: []string{"--pod-topology-webhook"}, wantWebhook: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, _ := parseOptions(t, append([]string{"--leader-elect", "--leader-election-lease-duration=30s"}, tt.args...)...)
+			s := &settings{leaderElectionID: "labeler-shard-1", applyTLSOptions: func(*tls.Config) {}}
+			opts := o.managerOptions(s)
+
+			if !opts.LeaderElection || opts.LeaderElectionID != "labeler-shard-1" || !opts.LeaderElectionReleaseOnCancel {
+				t.Errorf("leader election = %v, %q, release on cancel %v, want the settings of the shard",
+					opts.LeaderElection, opts.LeaderElectionID, opts.LeaderElectionReleaseOnCancel)
+			}
+			if *opts.LeaseDuration != 30*time.Second || *opts.RenewDeadline != 10*time.Second || *opts.RetryPeriod != 2*time.Second {
+				t.Errorf("lease durations = %v, %v, %v, want 30s, 10s, 2s", *opts.LeaseDuration, *opts.RenewDeadline, *opts.RetryPeriod)
+			}
+			// The manager must not share the durations with the options
+			*opts.LeaseDuration = time.Minute
+			if o.leaseDuration != 30*time.Second {
+				t.Errorf("--leader-election-lease-duration = %v after changing the manager options", o.leaseDuration)
+			}
+			if got := opts.WebhookServer != nil; got != tt.wantWebhook {
+				t.Errorf("webhook server = %v, want %v", got, tt.wantWebhook)
+			}
+
+			config := &tls.Config{}
+			for _, apply := range opts.Metrics.TLSOpts {
+				apply(config)
+			}
+			if got := len(config.NextProtos) == 0; got != tt.wantHTTP2 {
+				t.Errorf("metrics NextProtos = %v, want HTTP/2 %v", config.NextProtos, tt.wantHTTP2)
+			}
+		})
+	}
+}
@@ -0,0 +1,691 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/controller"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// options are the flags of the controller, documented by their usage
+type options struct {
+	configFile     string
+	validateConfig bool
+	kubeContext    string
+	clusterName    string
+	openShift      bool
+	// Nautobot, the device lookup and the other device sources
+	nautobotURL                string
+	nautobotTokenFile          string
+	nautobotSecondaryTokenFile string
+	logNautobotRequests        bool
+	faults                     nautobot.FaultInjection
+	lookupTimeout              time.Duration
+	lookupKeyName              string
+	providerIDLookup           bool
+	ipLookupAddresses          string
+	windowsNodeNames           string
+	bulkResync                 bool
+	deviceStoreInterval        time.Duration
+	deviceStoreFile            string
+	mockNautobotFixtures       string
+	staticDevicesFile          string
+	cloudFallbackProviders     string
+	startupPolicyName          string
+	startupTimeout             time.Duration
+	permissionCheckName        string
+	// Overrides of the config file
+	nodeSelector        string
+	mappingProfiles     string
+	resyncInterval      time.Duration
+	unchangedInterval   time.Duration
+	updatedInterval     time.Duration
+	retryInterval       time.Duration
+	adaptiveMinInterval time.Duration
+	adaptiveMaxInterval time.Duration
+	// Labeling
+	maxConcurrentReconciles int
+	nodeWriteRate           float64
+	nodeWriteBatchSize      int
+	minimalPermissions      bool
+	conflictPolicyName      string
+	allowZoneChanges        bool
+	zoneVolumePolicyName    string
+	disruptionCheck         bool
+	maxUnavailablePerRack   int
+	waitForNodeCondition    string
+	recheckKubeletRestarts  bool
+	labelHistory            int
+	reconcileLocalNodes     bool
+	shard                   controller.Shard
+	mappingPluginAddress    string
+	mappingPluginTimeout    time.Duration
+	mappingPluginTLS        bool
+	nodeFeaturesNamespace   string
+	dnsNameAnnotations      bool
+	nodeWebhook             bool
+	nodeWebhookTimeout      time.Duration
+	podTopologyWebhook      bool
+	webhookPort             int
+	webhookCertDir          string
+	// Member clusters
+	memberKubeconfigs      string
+	memberSecretsNamespace string
+	memberSecretsSelector  string
+	// Objects maintained besides nodes
+	clusterAPIMachines         bool
+	nodeGroupTemplates         string
+	topologyAwareServices      string
+	nodeSyncResources          bool
+	statusResourceName         string
+	prometheusRuleName         string
+	prometheusRuleNamespace    string
+	prometheusRuleLabels       map[string]string
+	prometheusRuleMissingFor   time.Duration
+	topologyConfigMapName      string
+	topologyConfigMapNamespace string
+	// Reverse sync
+	reverseSyncNodeIPs       bool
+	reverseSyncInterface     string
+	reverseSyncAddressTypes  string
+	reverseSyncCluster       string
+	reverseSyncClusterType   string
+	reverseSyncRoleTags      bool
+	reverseSyncLabelPrefixes string
+	reverseSyncLabelField    string
+	reverseSyncCustomFields  map[string]string
+	onNodeDeleteName         string
+	// Events, audit and notifications
+	eventDedupWindow       time.Duration
+	eventRateLimit         float64
+	auditSinkKind          string
+	auditFile              string
+	auditFileMaxSizeMB     int
+	auditFileMaxBackups    int
+	notifyWebhookURL       string
+	notifyFailureThreshold int
+	// Servers, leader election and the Kubernetes client
+	metricsAddr             string
+	metricsSecure           bool
+	metricsAuth             bool
+	metricsCertDir          string
+	metricsCertName         string
+	metricsKeyName          string
+	enableHTTP2             bool
+	probeAddr               string
+	pprofAddr               string
+	debugAddr               string
+	debugRecentErrors       int
+	debugSecure             bool
+	debugAuth               bool
+	debugCertDir            string
+	tracingEndpoint         string
+	tracingInsecure         bool
+	tracingSamplingRatio    float64
+	leaderElect             bool
+	leaderElectionID        string
+	leaderElectionNamespace string
+	leaseDuration           time.Duration
+	renewDeadline           time.Duration
+	retryPeriod             time.Duration
+	kubeAPIQPS              float32
+	kubeAPIBurst            int
+	logOptions              LogOptions
+	tlsOptions              TLSOptions
+}
+
+// BindFlags registers the flags of the controller on the given flag set
+func (o *options) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.kubeContext, "kube-context", "",
+		"Kubeconfig context to use when running out-of-cluster with --kubeconfig or $KUBECONFIG; defaults to the current context")
+	fs.StringVar(&o.clusterName, "cluster-name", "",
+		"Name of this cluster, available as .ClusterName in label mappings and reverse-sync custom field templates")
+	fs.BoolVar(&o.reverseSyncNodeIPs, "reverse-sync-node-ips", false,
+		"Push node addresses into Nautobot IPAM and set them as the device's primary IPs")
+	fs.StringVar(&o.reverseSyncInterface, "reverse-sync-interface", "",
+		"Name of the device interface node addresses are assigned to in Nautobot")
+	fs.StringVar(&o.reverseSyncAddressTypes, "reverse-sync-address-types", "InternalIP,ExternalIP",
+		"Comma-separated node address types to push, in order of preference for the primary IP")
+	fs.StringVar(&o.reverseSyncCluster, "reverse-sync-cluster", "",
+		"Name of the Nautobot virtualization cluster whose members are kept in sync with the cluster's nodes")
+	fs.StringVar(&o.reverseSyncClusterType, "reverse-sync-cluster-type", "Kubernetes",
+		"Nautobot cluster type used when the virtualization cluster has to be created")
+	fs.BoolVar(&o.reverseSyncRoleTags, "reverse-sync-role-tags", false,
+		"Tag Nautobot devices with k8s-control-plane / k8s-worker according to their node's role")
+	fs.StringVar(&o.reverseSyncLabelPrefixes, "reverse-sync-label-prefixes", "",
+		"Comma-separated node label prefixes whose labels are pushed into a device custom field as JSON")
+	fs.StringVar(&o.reverseSyncLabelField, "reverse-sync-label-custom-field", "k8s_labels",
+		"Name of the Nautobot device custom field receiving the selected node labels")
+	fs.StringToStringVar(&o.reverseSyncCustomFields, "reverse-sync-custom-fields", nil,
+		"Device custom fields to set, as name=template pairs evaluated against .ClusterName, .Node and .Device, "+
+			"e.g. k8s_cluster={{ .ClusterName }}")
+	fs.StringVar(&o.metricsAddr, "metrics-bind-address", ":8080",
+		"The address the metrics endpoint binds to. Use 0 to disable serving metrics.")
+	fs.BoolVar(&o.metricsSecure, "metrics-secure", false,
+		"Serve metrics over HTTPS. A self-signed certificate is generated unless --metrics-cert-dir is set.")
+	fs.BoolVar(&o.metricsAuth, "metrics-auth", false,
+		"Require metrics clients to authenticate (TokenReview) and be authorized (SubjectAccessReview) by the API server")
+	fs.StringVar(&o.metricsCertDir, "metrics-cert-dir", "", "Directory containing the metrics serving certificate and key")
+	fs.StringVar(&o.metricsCertName, "metrics-cert-name", "tls.crt", "Metrics serving certificate file name within --metrics-cert-dir")
+	fs.StringVar(&o.metricsKeyName, "metrics-key-name", "tls.key", "Metrics serving key file name within --metrics-cert-dir")
+	fs.BoolVar(&o.enableHTTP2, "enable-http2", false,
+		"Enable HTTP/2 for the metrics server. Disabled by default to avoid the HTTP/2 Stream Cancellation and Rapid Reset CVEs.")
+	fs.BoolVar(&o.leaderElect, "leader-elect", false,
+		"Elect a leader through a Lease so only one replica runs the controllers. Required with more than one replica.")
+	fs.StringVar(&o.leaderElectionID, "leader-election-id", "nautobot-node-labeler",
+		"Name of the leader election Lease. Differently configured instances in one cluster need different names.")
+	fs.StringVar(&o.leaderElectionNamespace, "leader-election-namespace", "",
+		"Namespace of the leader election Lease. Defaults to the pod's namespace; required out-of-cluster.")
+	fs.DurationVar(&o.leaseDuration, "leader-election-lease-duration", 15*time.Second,
+		"How long standbys wait before taking over a lease that was not renewed")
+	fs.DurationVar(&o.renewDeadline, "leader-election-renew-deadline", 10*time.Second,
+		"How long the leader keeps retrying to renew its lease before giving up leadership")
+	fs.DurationVar(&o.retryPeriod, "leader-election-retry-period", 2*time.Second,
+		"How often leader election clients retry acquiring or renewing the lease")
+	fs.StringVar(&o.startupPolicyName, "startup-policy", string(controller.StartupPolicyFailFast),
+		"Behavior while Nautobot is unreachable at startup: fail-fast (stay unready until Nautobot answers) or "+
+			"degraded (become ready, keep existing labels and retry in the background). Reconciles wait for Nautobot either way.")
+	fs.DurationVar(&o.startupTimeout, "startup-timeout", 0,
+		"With --startup-policy=fail-fast, exit if Nautobot has not answered within this time (0 waits forever)")
+	fs.StringVar(&o.permissionCheckName, "nautobot-permission-check", string(controller.PermissionCheckWarn),
+		"Check once Nautobot answered that its token has the permissions the configuration needs: warn (log the missing ones), "+
+			"fail (exit if any is missing) or none")
+	fs.StringVar(&o.probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	fs.StringVar(&o.pprofAddr, "pprof-bind-address", "",
+		"Localhost address serving net/http/pprof, e.g. 127.0.0.1:6060. Disabled when empty.")
+	fs.StringVar(&o.tracingEndpoint, "tracing-endpoint", "",
+		"OTLP/gRPC endpoint (host:port) receiving reconcile traces. Tracing is disabled when empty.")
+	fs.BoolVar(&o.tracingInsecure, "tracing-insecure", false, "Connect to the tracing endpoint without TLS")
+	fs.Float64Var(&o.tracingSamplingRatio, "tracing-sampling-ratio", 1.0, "Fraction of reconciles to trace, between 0 and 1")
+	fs.StringVar(&o.nautobotURL, "nautobot-url", "", "Base URL of Nautobot, e.g. https://nautobot.example.com (config nautobot.url)")
+	fs.StringVar(&o.nautobotTokenFile, "nautobot-token-file", "",
+		"File holding the Nautobot token, e.g. a projected Secret, re-read when it changes (config nautobot.tokenFile)")
+	fs.StringVar(&o.nautobotSecondaryTokenFile, "nautobot-secondary-token-file", "",
+		"File holding a second Nautobot token tried when the first is rejected, for zero-downtime rotation "+
+			"(config nautobot.secondaryTokenFile)")
+	fs.BoolVar(&o.logNautobotRequests, "log-nautobot-requests", false,
+		"Log every request to Nautobot with its status and duration, for debugging; the token and other credentials are redacted")
+	fs.DurationVar(&o.faults.Latency, "fault-injection-latency", 0,
+		"Delay of the Nautobot requests slowed down by --fault-injection-latency-rate (needs the FaultInjection feature gate)")
+	fs.Float64Var(&o.faults.LatencyRate, "fault-injection-latency-rate", 0,
+		"Fraction of the Nautobot requests delayed by --fault-injection-latency, between 0 and 1")
+	fs.Float64Var(&o.faults.ErrorRate, "fault-injection-error-rate", 0,
+		"Fraction of the Nautobot requests answered with 503 instead of being sent, between 0 and 1")
+	fs.Float64Var(&o.faults.NotFoundRate, "fault-injection-not-found-rate", 0,
+		"Fraction of the Nautobot device and virtual machine lookups answered with no results, between 0 and 1")
+	fs.DurationVar(&o.lookupTimeout, "nautobot-lookup-timeout", 30*time.Second,
+		"Maximum duration of the device lookup of a reconcile, all its requests and retries included (0 disables it)")
+	fs.BoolVar(&o.bulkResync, "bulk-resync", false,
+		"Check all nodes against Nautobot once per resync interval with a few GraphQL queries (250 devices each) "+
+			"instead of one REST request per node, including nodes that already carry all labels")
+	fs.DurationVar(&o.deviceStoreInterval, "device-store-interval", 0,
+		"Keep a local copy of all Nautobot devices, refreshed at this interval, and look nodes up in it before asking "+
+			"Nautobot. Disabled when 0.")
+	fs.StringVar(&o.deviceStoreFile, "device-store-file", "",
+		"File keeping the device store across restarts, so a restarted controller does not list all of Nautobot again")
+	fs.IntVar(&o.maxConcurrentReconciles, "max-concurrent-reconciles", 4,
+		"Number of nodes each controller reconciles in parallel")
+	fs.Float64Var(&o.nodeWriteRate, "node-write-rate", 0,
+		"Maximum average number of node label writes per second, applied in batches of --node-write-batch-size. "+
+			"Only --kube-api-qps limits them when 0.")
+	fs.IntVar(&o.nodeWriteBatchSize, "node-write-batch-size", 50, "Number of node label writes applied together with --node-write-rate")
+	fs.DurationVar(&o.eventDedupWindow, "event-dedup-window", 10*time.Minute,
+		"Emit at most one event per object and reason within this window, and summarize the suppressed events after it (0 disables)")
+	fs.Float64Var(&o.eventRateLimit, "event-rate-limit", 60, "Maximum number of events emitted per minute (0 does not cap them)")
+	fs.StringVar(&o.memberKubeconfigs, "member-kubeconfigs", "",
+		"Comma-separated <cluster>=<kubeconfig path> entries of member clusters whose nodes are labeled too")
+	fs.StringVar(&o.memberSecretsNamespace, "member-cluster-secrets-namespace", "",
+		"Namespace of kubeconfig secrets, e.g. of Cluster API, of member clusters whose nodes are labeled too")
+	fs.StringVar(&o.memberSecretsSelector, "member-cluster-secrets-selector", controller.ClusterNameLabel,
+		"Label selector of the member cluster kubeconfig secrets")
+	fs.StringVar(&o.nodeFeaturesNamespace, "node-features-namespace", "",
+		"Publish node labels as Node Feature Discovery NodeFeature objects in this namespace, usually NFD's, "+
+			"instead of writing them to the nodes")
+	fs.StringVar(&o.mappingPluginAddress, "mapping-plugin-address", "",
+		"gRPC address of a mapping plugin computing the labels and taints of nodes, e.g. unix:///run/plugin/plugin.sock")
+	fs.DurationVar(&o.mappingPluginTimeout, "mapping-plugin-timeout", 5*time.Second, "Timeout of a mapping plugin call")
+	fs.BoolVar(&o.mappingPluginTLS, "mapping-plugin-tls", false, "Connect to the mapping plugin with TLS")
+	fs.StringVar(&o.topologyAwareServices, "topology-aware-services", "",
+		"Comma-separated Services, as namespace/name or namespace/*, whose topology-aware routing is enabled "+
+			"while the zone labels of all nodes are verified")
+	fs.StringVar(&o.nodeGroupTemplates, "node-group-templates", "",
+		"Comma-separated node groups whose autoscaler annotations get the labels expected on their new nodes: "+
+			controller.NodeGroupsClusterAPI+" (Cluster Autoscaler on MachineDeployments), "+controller.NodeGroupsKarpenter+" (NodePools)")
+	fs.BoolVar(&o.clusterAPIMachines, "cluster-api-machines", false,
+		"Annotate the Cluster API Machines of nodes and their MachineDeployments with the site and rack of the devices")
+	fs.BoolVar(&o.nodeWebhook, "node-webhook", false,
+		"Serve a mutating admission webhook labeling nodes as they register, before pods can be scheduled onto them")
+	fs.DurationVar(&o.nodeWebhookTimeout, "node-webhook-timeout", 2*time.Second,
+		"Time the admission webhook waits for a device lookup before admitting the node unlabeled")
+	fs.BoolVar(&o.podTopologyWebhook, "pod-topology-webhook", false,
+		"Serve a mutating admission webhook copying the managed labels of nodes onto their pods")
+	fs.IntVar(&o.webhookPort, "webhook-port", 9443, "Port of the admission webhook server")
+	fs.StringVar(&o.webhookCertDir, "webhook-cert-dir", "",
+		"Directory with the tls.crt and tls.key of the admission webhook server; defaults to controller-runtime's")
+	fs.StringVar(&o.cloudFallbackProviders, "cloud-fallback-providers", "",
+		"Comma-separated provider ID schemes, e.g. aws,gce,azure, of cloud instances labeled from their zone and region "+
+			"labels when Nautobot has no device for them")
+	fs.BoolVar(&o.dnsNameAnnotations, "dns-name-annotation", false,
+		"Annotate nodes with the DNS name of their device's primary IP in Nautobot ("+controller.DNSNameAnnotation+")")
+	fs.BoolVar(&o.openShift, "openshift", false,
+		"OpenShift compatibility: leave the node labels OpenShift operators own alone and default --cluster-name to the "+
+			"infrastructure name")
+	fs.BoolVar(&o.reconcileLocalNodes, "reconcile-local-nodes", true,
+		"Label the nodes of the controller's own cluster; disable for a management cluster that only labels member clusters")
+	fs.IntVar(&o.shard.Index, "shard-index", 0, "Index of the shard of nodes this replica reconciles, from 0 to --shard-count - 1")
+	fs.IntVar(&o.shard.Count, "shard-count", 1,
+		"Number of shards the nodes are split into by a hash of their name, each reconciled by its own --shard-index")
+	fs.Float32Var(&o.kubeAPIQPS, "kube-api-qps", 20, "Sustained rate of requests to the Kubernetes API server, per second")
+	fs.IntVar(&o.kubeAPIBurst, "kube-api-burst", 30, "Burst of requests to the Kubernetes API server above --kube-api-qps")
+	fs.StringVar(&o.mockNautobotFixtures, "mock-nautobot", "",
+		"Serve canned devices and sites from this YAML fixtures file in-process and use them instead of Nautobot, "+
+			"for demos and end-to-end tests without a real Nautobot. Overrides the Nautobot URL and tokens.")
+	fs.StringVar(&o.staticDevicesFile, "static-devices-file", "",
+		"YAML or .csv file with the site, rack and other device data of nodes by name, consulted for nodes without a device "+
+			"in Nautobot, e.g. legacy hosts that are never modeled")
+	fs.StringVar(&o.nodeSelector, "node-selector", "", "Label selector restricting the labeled nodes (config nodeSelector)")
+	fs.StringVar(&o.mappingProfiles, "mapping-profiles", "", "Comma-separated profiles whose mappings are added, e.g. metallb (config profiles)")
+	fs.DurationVar(&o.resyncInterval, "resync-interval", 0,
+		"Requeue delay for nodes that already have all labels (config intervals.resync, default 12h)")
+	fs.DurationVar(&o.unchangedInterval, "unchanged-interval", 0,
+		"Requeue delay after a lookup that changed nothing (config intervals.unchanged, default 6h)")
+	fs.DurationVar(&o.updatedInterval, "updated-interval", 0,
+		"Requeue delay after the node was updated (config intervals.updated, default 1h)")
+	fs.DurationVar(&o.retryInterval, "retry-interval", 0,
+		"Requeue delay after a failed lookup (config intervals.retry, default 5m)")
+	fs.DurationVar(&o.adaptiveMinInterval, "adaptive-min-interval", 0,
+		"Shortest per-node check interval with the controller.AdaptiveRequeue feature gate (config intervals.adaptiveMin, default 15m)")
+	fs.DurationVar(&o.adaptiveMaxInterval, "adaptive-max-interval", 0,
+		"Longest per-node check interval with the controller.AdaptiveRequeue feature gate (config intervals.adaptiveMax, default 24h)")
+	fs.StringVar(&o.configFile, "config", "",
+		"Path of a YAML config file (LabelerConfiguration) with the Nautobot endpoint, label mappings, requeue intervals, "+
+			"node selector and flag values. It is reloaded on change or SIGHUP.")
+	fs.BoolVar(&o.validateConfig, "validate-config", false, "Validate the flags and the --config file and exit, e.g. in CI")
+	fs.StringVar(&o.debugAddr, "debug-bind-address", "",
+		"The address the debug endpoints (e.g. /debug/missing-nodes) bind to. Disabled when empty.")
+	fs.BoolVar(&o.minimalPermissions, "minimal-permissions", false,
+		"Watch only node metadata and write labels with patches, so get, list, watch and patch on nodes are all the controller needs for labeling")
+	fs.BoolVar(&o.nodeSyncResources, "node-sync-resources", false,
+		"Maintain a NodeNautobotSync object per node recording its last lookup, matched device, applied labels and recent errors")
+	fs.StringVar(&o.statusResourceName, "status-resource-name", "",
+		"Name of the cluster-scoped NautobotLabelerStatus object the leader keeps up to date. Disabled when empty.")
+	fs.StringVar(&o.prometheusRuleName, "prometheus-rule-name", "",
+		"Name of a Prometheus Operator PrometheusRule with the recommended alerts the leader keeps up to date. Disabled when empty.")
+	fs.StringVar(&o.prometheusRuleNamespace, "prometheus-rule-namespace", os.Getenv("POD_NAMESPACE"),
+		"Namespace of the PrometheusRule, by default the controller's own")
+	fs.StringToStringVar(&o.prometheusRuleLabels, "prometheus-rule-labels", nil,
+		"Labels of the PrometheusRule, e.g. release=kube-prometheus-stack to match the ruleSelector of the Prometheus instance")
+	fs.DurationVar(&o.prometheusRuleMissingFor, "prometheus-rule-missing-labels-for", 30*time.Minute,
+		"How long nodes may have no Nautobot device or lack labels before the PrometheusRule alerts")
+	fs.StringVar(&o.topologyConfigMapName, "topology-configmap-name", "",
+		"Name of a ConfigMap mirroring the topology labels of every node the leader keeps up to date, for workloads that cannot read nodes. Disabled when empty.")
+	fs.StringVar(&o.topologyConfigMapNamespace, "topology-configmap-namespace", os.Getenv("POD_NAMESPACE"),
+		"Namespace of the topology ConfigMap, by default the controller's own")
+	fs.StringVar(&o.auditSinkKind, "audit-sink", "none",
+		"Where to record node label changes: none, stdout (JSON lines) or file (rotating JSON lines file)")
+	fs.StringVar(&o.auditFile, "audit-file", "/var/log/nautobot-node-labeler/audit.log", "Path of the audit file for --audit-sink=file")
+	fs.IntVar(&o.auditFileMaxSizeMB, "audit-file-max-size-mb", 100, "Size in megabytes at which the audit file is rotated")
+	fs.IntVar(&o.auditFileMaxBackups, "audit-file-max-backups", 5, "Number of rotated audit files to keep")
+	fs.IntVar(&o.labelHistory, "label-history", 0,
+		"Number of label changes kept in the nautobot.io/label-history annotation of each node. Disabled when 0.")
+	fs.StringVar(&o.notifyWebhookURL, "notify-webhook-url", "",
+		"Slack-compatible webhook notified when a node keeps failing to sync")
+	fs.IntVar(&o.notifyFailureThreshold, "notify-failure-threshold", 5,
+		"Number of consecutive sync failures of a node that triggers a notification")
+	fs.IntVar(&o.debugRecentErrors, "debug-recent-errors", 100, "Number of recent reconcile errors kept for /debug/errors")
+	fs.BoolVar(&o.debugSecure, "debug-secure", false,
+		"Serve the debug endpoints over HTTPS. A self-signed certificate is generated unless --debug-cert-dir is set.")
+	fs.BoolVar(&o.debugAuth, "debug-auth", false,
+		"Require debug endpoint clients to authenticate and be authorized (non-resource URL /debug/*) by the API server")
+	fs.StringVar(&o.debugCertDir, "debug-cert-dir", "", "Directory containing tls.crt and tls.key for the debug server")
+	fs.StringVar(&o.onNodeDeleteName, "on-node-delete", string(controller.NodeDeleteActionNone),
+		"What to do with a deleted node's Nautobot device: none, offline (set status) or tag (add k8s-removed)")
+	fs.StringVar(&o.lookupKeyName, "lookup-key", "name",
+		"What nodes are matched to devices by: name (the node object name), hostname (the kubernetes.io/hostname label) or label:<key>")
+	fs.BoolVar(&o.providerIDLookup, "provider-id-lookup", false,
+		"Match nodes of Metal3, Tinkerbell and MAAS to the device named like the host their providerID references first")
+	fs.StringVar(&o.ipLookupAddresses, "ip-lookup-addresses", "InternalIP,ExternalIP",
+		"Node addresses matched to the primary IPs of devices in the device store, in order of preference: InternalIP or ExternalIP, optionally of a family, e.g. InternalIP/IPv6,InternalIP/IPv4")
+	fs.StringVar(&o.windowsNodeNames, "windows-node-names", string(controller.WindowsNamesAsIs),
+		"How the devices of Windows nodes (kubernetes.io/os=windows) are named: as-is (like Linux nodes), upper (uppercase short hostname) or netbios (uppercase, cut to 15 characters)")
+	fs.StringVar(&o.conflictPolicyName, "conflict-policy", string(controller.ConflictPolicyOverwrite),
+		"Which side wins when a managed value was changed out-of-band: overwrite, nautobot or kubernetes")
+	fs.BoolVar(&o.allowZoneChanges, "allow-zone-changes", false,
+		"Let Nautobot change the zone label of nodes that already have one, which can strand workloads on zonal volumes")
+	fs.StringVar(&o.zoneVolumePolicyName, "zone-volume-check", string(controller.ZoneVolumePolicyBlock),
+		"What to do with a zone change of a node whose pods use volumes bound to its zone: block, warn or off")
+	fs.BoolVar(&o.disruptionCheck, "disruption-check", false,
+		"Check PodDisruptionBudgets and --max-unavailable-per-rack before adding NoExecute taints, which evict pods, and hold back taints that would violate them")
+	fs.IntVar(&o.maxUnavailablePerRack, "max-unavailable-per-rack", 0,
+		"With --disruption-check, how many nodes of a rack may be unavailable (unschedulable, not ready or NoExecute tainted) before no other is tainted NoExecute (0 for no limit)")
+	fs.StringVar(&o.waitForNodeCondition, "wait-for-node-condition", "",
+		"Condition nodes must report as True before their first sync, e.g. Ready, as some bootstrap flows wipe or override labels during registration (empty to sync right away)")
+	fs.BoolVar(&o.recheckKubeletRestarts, "recheck-after-kubelet-restart", false,
+		"Check the labels of nodes again after their kubelet restarted, their node rebooted or turned Ready again, also of nodes that carry all labels")
+	o.logOptions.BindFlags(fs)
+	o.tlsOptions.BindFlags(fs)
+}
+
+// configOverride returns the override of the config file: settings of the config file that
+// were also given as flags or environment variables take the flag value, also after a reload.
+// With a mockNautobotURL, the controller talks to the mock Nautobot.
+func (o *options) configOverride(fs *pflag.FlagSet, mockNautobotURL string) func(*configv1alpha1.LabelerConfiguration) {
+	return func(config *configv1alpha1.LabelerConfiguration) {
+		if o.nautobotURL != "" {
+			config.Nautobot.URL = o.nautobotURL
+		}
+		if token := os.Getenv("NAUTOBOT_TOKEN"); token != "" {
+			config.Nautobot.Token, config.Nautobot.TokenFile = token, ""
+		}
+		if o.nautobotTokenFile != "" {
+			config.Nautobot.Token, config.Nautobot.TokenFile = "", o.nautobotTokenFile
+		}
+		if token := os.Getenv("NAUTOBOT_SECONDARY_TOKEN"); token != "" {
+			config.Nautobot.SecondaryToken, config.Nautobot.SecondaryTokenFile = token, ""
+		}
+		if o.nautobotSecondaryTokenFile != "" {
+			config.Nautobot.SecondaryToken, config.Nautobot.SecondaryTokenFile = "", o.nautobotSecondaryTokenFile
+		}
+		if fs.Changed("node-selector") {
+			config.NodeSelector = o.nodeSelector
+		}
+		if fs.Changed("mapping-profiles") {
+			config.Profiles = controller.SplitList(o.mappingProfiles)
+		}
+		if o.openShift {
+			if dropped := controller.DropOpenShiftManagedMappings(config); len(dropped) > 0 {
+				ctrl.Log.WithName("config").Info("Ignoring mappings of labels owned by OpenShift", "Labels", dropped)
+			}
+		}
+		for _, interval := range []struct {
+			flag  string
+			value time.Duration
+			field *metav1.Duration
+		}{
+			{"resync-interval", o.resyncInterval, &config.Intervals.Resync},
+			{"unchanged-interval", o.unchangedInterval, &config.Intervals.Unchanged},
+			{"updated-interval", o.updatedInterval, &config.Intervals.Updated},
+			{"retry-interval", o.retryInterval, &config.Intervals.Retry},
+			{"adaptive-min-interval", o.adaptiveMinInterval, &config.Intervals.AdaptiveMin},
+			{"adaptive-max-interval", o.adaptiveMaxInterval, &config.Intervals.AdaptiveMax},
+		} {
+			if fs.Changed(interval.flag) {
+				interval.field.Duration = interval.value
+			}
+		}
+		if mockNautobotURL != "" {
+			config.Nautobot.URL, config.Nautobot.Token, config.Nautobot.TokenFile = mockNautobotURL, nautobot.MockToken, ""
+			config.Nautobot.SecondaryToken, config.Nautobot.SecondaryTokenFile = "", ""
+		}
+	}
+}
+
+// settings are what the options parse to
+type settings struct {
+	configStore      *controller.ConfigStore
+	staticDevices    *controller.StaticDevices
+	lookupKey        controller.LookupKey
+	conflictPolicy   controller.ConflictPolicy
+	zoneVolumePolicy controller.ZoneVolumePolicy
+	startupPolicy    controller.StartupPolicy
+	permissionCheck  controller.PermissionCheckMode
+	memberSelector   labels.Selector
+	auditSink        controller.AuditSink
+	applyTLSOptions  func(*tls.Config)
+	// leaderElectionID is the --leader-election-id of the shard
+	leaderElectionID string
+	// reverseSync holds the reverse-sync settings, nil if reverse sync is not configured
+	reverseSync *controller.ReverseSyncReconciler
+}
+
+// validate parses and checks the options, with the config file and the given flag set, and
+// reports every problem at once
+func (o *options) validate(fs *pflag.FlagSet, mockNautobotURL string) (*settings, []error) {
+	s := &settings{}
+	var errs []error
+	var err error
+	if o.staticDevicesFile != "" {
+		if s.staticDevices, err = controller.LoadStaticDevices(o.staticDevicesFile); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.lookupKey, err = controller.ParseLookupKey(o.lookupKeyName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	s.lookupKey.ProviderID = o.providerIDLookup
+	if s.lookupKey.Addresses, err = controller.ParseAddressSelectors(o.ipLookupAddresses); err != nil {
+		errs = append(errs, err)
+	}
+	if s.lookupKey.WindowsNames, err = controller.ParseWindowsNameStyle(o.windowsNodeNames); err != nil {
+		errs = append(errs, err)
+	}
+	if o.providerIDLookup && o.minimalPermissions {
+		errs = append(errs, fmt.Errorf("--provider-id-lookup reads node specs, which --minimal-permissions does not"))
+	}
+	s.conflictPolicy, err = controller.ParseConflictPolicy(o.conflictPolicyName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	onNodeDelete, err := controller.ParseNodeDeleteAction(o.onNodeDeleteName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	s.zoneVolumePolicy, err = controller.ParseZoneVolumePolicy(o.zoneVolumePolicyName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if o.maxUnavailablePerRack < 0 || (o.maxUnavailablePerRack > 0 && !o.disruptionCheck) {
+		errs = append(errs, fmt.Errorf("--max-unavailable-per-rack must not be negative and requires --disruption-check, got %d", o.maxUnavailablePerRack))
+	}
+	if o.disruptionCheck && o.minimalPermissions {
+		errs = append(errs, fmt.Errorf("--disruption-check reads node specs, which --minimal-permissions does not"))
+	}
+	if (o.waitForNodeCondition != "" || o.recheckKubeletRestarts) && o.minimalPermissions {
+		errs = append(errs, fmt.Errorf("--wait-for-node-condition and --recheck-after-kubelet-restart read node status, which --minimal-permissions does not"))
+	}
+	s.startupPolicy, err = controller.ParseStartupPolicy(o.startupPolicyName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	s.permissionCheck, err = controller.ParsePermissionCheckMode(o.permissionCheckName)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if o.labelHistory < 0 {
+		errs = append(errs, fmt.Errorf("--label-history must not be negative"))
+	}
+	if o.deviceStoreInterval < 0 {
+		errs = append(errs, fmt.Errorf("--device-store-interval must not be negative"))
+	}
+	if o.deviceStoreFile != "" && o.deviceStoreInterval == 0 {
+		errs = append(errs, fmt.Errorf("--device-store-file requires --device-store-interval"))
+	}
+	if o.maxConcurrentReconciles < 1 {
+		errs = append(errs, fmt.Errorf("--max-concurrent-reconciles must be at least 1, got %d", o.maxConcurrentReconciles))
+	}
+	if o.eventDedupWindow < 0 || o.eventRateLimit < 0 {
+		errs = append(errs, fmt.Errorf("--event-dedup-window and --event-rate-limit must not be negative, got %v and %v",
+			o.eventDedupWindow, o.eventRateLimit))
+	}
+	if o.prometheusRuleName != "" && o.prometheusRuleNamespace == "" {
+		errs = append(errs, fmt.Errorf("--prometheus-rule-name requires --prometheus-rule-namespace outside a pod"))
+	}
+	if o.topologyConfigMapName != "" && o.topologyConfigMapNamespace == "" {
+		errs = append(errs, fmt.Errorf("--topology-configmap-name requires --topology-configmap-namespace outside a pod"))
+	}
+	if o.prometheusRuleMissingFor < time.Minute {
+		errs = append(errs, fmt.Errorf("--prometheus-rule-missing-labels-for must be at least 1m, got %v", o.prometheusRuleMissingFor))
+	}
+	if o.nodeWriteRate < 0 || o.nodeWriteBatchSize < 1 {
+		errs = append(errs, fmt.Errorf(
+			"--node-write-rate must not be negative and --node-write-batch-size must be at least 1, got %v and %d",
+			o.nodeWriteRate, o.nodeWriteBatchSize))
+	}
+	for _, service := range controller.SplitList(o.topologyAwareServices) {
+		if namespace, name, ok := strings.Cut(service, "/"); !ok || namespace == "" || name == "" {
+			errs = append(errs, fmt.Errorf("invalid --topology-aware-services entry %q, expected namespace/name or namespace/*", service))
+		}
+	}
+	for _, kind := range controller.SplitList(o.nodeGroupTemplates) {
+		if kind != controller.NodeGroupsClusterAPI && kind != controller.NodeGroupsKarpenter {
+			errs = append(errs, fmt.Errorf("invalid --node-group-templates entry %q, expected %s or %s",
+				kind, controller.NodeGroupsClusterAPI, controller.NodeGroupsKarpenter))
+		}
+	}
+	s.memberSelector, err = labels.Parse(o.memberSecretsSelector)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid --member-cluster-secrets-selector: %w", err))
+	}
+	if err := o.shard.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if o.kubeAPIQPS <= 0 || o.kubeAPIBurst < 1 {
+		errs = append(errs, fmt.Errorf("--kube-api-qps and --kube-api-burst must be positive, got %v and %d", o.kubeAPIQPS, o.kubeAPIBurst))
+	}
+	if o.lookupTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--nautobot-lookup-timeout must not be negative, got %v", o.lookupTimeout))
+	}
+	if o.nodeWebhook && o.nodeWebhookTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("--node-webhook-timeout must be positive, got %v", o.nodeWebhookTimeout))
+	}
+	if o.nodeWebhook && o.nodeFeaturesNamespace != "" {
+		errs = append(errs, fmt.Errorf("--node-webhook cannot be combined with --node-features-namespace, Node Feature Discovery writes the labels"))
+	}
+	if o.mappingPluginAddress != "" {
+		if o.mappingPluginTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("--mapping-plugin-timeout must be positive, got %v", o.mappingPluginTimeout))
+		}
+		// The plugin's taints are part of the node spec
+		if o.minimalPermissions || o.nodeFeaturesNamespace != "" {
+			errs = append(errs, fmt.Errorf("--mapping-plugin-address cannot be combined with --minimal-permissions or --node-features-namespace"))
+		}
+	}
+	if o.startupTimeout < 0 {
+		errs = append(errs, fmt.Errorf("--startup-timeout must not be negative"))
+	}
+
+	s.configStore, err = controller.NewConfigStore(o.configFile, o.configOverride(fs, mockNautobotURL))
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if o.pprofAddr != "" && !isLoopbackAddress(o.pprofAddr) {
+		errs = append(errs, fmt.Errorf("--pprof-bind-address must bind to localhost, got %q", o.pprofAddr))
+	}
+	if o.debugRecentErrors < 0 {
+		errs = append(errs, fmt.Errorf("--debug-recent-errors must not be negative"))
+	}
+	if o.debugAuth && !o.debugSecure {
+		errs = append(errs, fmt.Errorf("--debug-auth requires --debug-secure, bearer tokens must not be sent in clear text"))
+	}
+	if o.metricsAuth && !o.metricsSecure {
+		errs = append(errs, fmt.Errorf("--metrics-auth requires --metrics-secure, bearer tokens must not be sent in clear text"))
+	}
+	// Every shard elects its own leader
+	s.leaderElectionID = o.leaderElectionID + o.shard.Suffix()
+	if o.leaderElect {
+		if problems := validation.IsDNS1123Subdomain(s.leaderElectionID); len(problems) > 0 {
+			errs = append(errs, fmt.Errorf("invalid --leader-election-id %q: %s", s.leaderElectionID, strings.Join(problems, "; ")))
+		}
+		if o.retryPeriod <= 0 || o.renewDeadline <= o.retryPeriod || o.leaseDuration <= o.renewDeadline {
+			errs = append(errs, fmt.Errorf(
+				"leader election durations must satisfy lease duration > renew deadline > retry period > 0, got %v, %v, %v",
+				o.leaseDuration, o.renewDeadline, o.retryPeriod))
+		}
+	}
+	if o.tracingSamplingRatio < 0 || o.tracingSamplingRatio > 1 {
+		errs = append(errs, fmt.Errorf("--tracing-sampling-ratio must be between 0 and 1, got %v", o.tracingSamplingRatio))
+	}
+	if o.notifyWebhookURL != "" && o.notifyFailureThreshold < 1 {
+		errs = append(errs, fmt.Errorf("--notify-failure-threshold must be at least 1, got %d", o.notifyFailureThreshold))
+	}
+	if o.reverseSyncNodeIPs && o.reverseSyncInterface == "" {
+		errs = append(errs, fmt.Errorf("--reverse-sync-interface is required when --reverse-sync-node-ips is enabled"))
+	}
+	s.applyTLSOptions, err = o.tlsOptions.Apply()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	customFields, err := controller.CompileCustomFields(o.reverseSyncCustomFields)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	labelPrefixes := controller.SplitList(o.reverseSyncLabelPrefixes)
+	reverseSyncRequested := o.reverseSyncNodeIPs || o.reverseSyncCluster != "" || o.reverseSyncRoleTags ||
+		onNodeDelete != controller.NodeDeleteActionNone || len(labelPrefixes) > 0 || len(customFields) > 0
+	if reverseSyncRequested && !controller.FeatureGates.Enabled(controller.ReverseSync) {
+		errs = append(errs, fmt.Errorf("reverse sync is configured but the %s feature gate is disabled", controller.ReverseSync))
+	}
+	if reverseSyncRequested {
+		var addressTypes []corev1.NodeAddressType
+		for _, addressType := range controller.SplitList(o.reverseSyncAddressTypes) {
+			addressTypes = append(addressTypes, corev1.NodeAddressType(addressType))
+		}
+		s.reverseSync = &controller.ReverseSyncReconciler{
+			SyncNodeIPs:    o.reverseSyncNodeIPs,
+			InterfaceName:  o.reverseSyncInterface,
+			AddressTypes:   addressTypes,
+			ClusterName:    o.reverseSyncCluster,
+			ClusterType:    o.reverseSyncClusterType,
+			PruneInterval:  1 * time.Hour,
+			SyncRoleTags:   o.reverseSyncRoleTags,
+			OnNodeDelete:   onNodeDelete,
+			LabelPrefixes:  labelPrefixes,
+			LabelField:     o.reverseSyncLabelField,
+			CustomFields:   customFields,
+			LookupKey:      s.lookupKey,
+			ConflictPolicy: s.conflictPolicy,
+
+			MaxConcurrentReconciles: o.maxConcurrentReconciles,
+			Shard:                   o.shard,
+		}
+	}
+	if o.mappingPluginAddress != "" && !controller.FeatureGates.Enabled(controller.ExternalMappingPlugin) {
+		errs = append(errs, fmt.Errorf("--mapping-plugin-address is configured but the %s feature gate is disabled", controller.ExternalMappingPlugin))
+	}
+	if o.nodeWebhook && !controller.FeatureGates.Enabled(controller.NodeWebhook) {
+		errs = append(errs, fmt.Errorf("--node-webhook is configured but the %s feature gate is disabled", controller.NodeWebhook))
+	}
+	if o.podTopologyWebhook && !controller.FeatureGates.Enabled(controller.PodTopologyLabels) {
+		errs = append(errs, fmt.Errorf("--pod-topology-webhook is configured but the %s feature gate is disabled", controller.PodTopologyLabels))
+	}
+	if err := o.faults.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if o.faults.Enabled() && !controller.FeatureGates.Enabled(controller.FaultInjection) {
+		errs = append(errs, fmt.Errorf("fault injection is configured but the %s feature gate is disabled", controller.FaultInjection))
+	}
+	s.auditSink, err = controller.NewAuditSink(o.auditSinkKind, o.auditFile, o.auditFileMaxSizeMB, o.auditFileMaxBackups)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if s.configStore != nil && s.configStore.Current().ServiceNow != nil {
+		// These read devices from Nautobot in bulk or link to them there
+		if o.bulkResync || o.deviceStoreInterval > 0 || o.nodeSyncResources {
+			errs = append(errs, fmt.Errorf(
+				"--bulk-resync, --device-store-interval and --node-sync-resources do not work with ServiceNow as the device source"))
+		}
+		if reverseSyncRequested && s.configStore.Current().Nautobot.URL == "" {
+			errs = append(errs, fmt.Errorf("reverse sync writes to Nautobot and needs its URL also with ServiceNow as the device source"))
+		}
+	}
+	// The power redundancy taint is part of the node spec
+	if s.configStore != nil && s.configStore.Current().PowerRedundancy != nil && (o.minimalPermissions || o.nodeFeaturesNamespace != "") {
+		errs = append(errs, fmt.Errorf("powerRedundancy cannot be combined with --minimal-permissions or --node-features-namespace"))
+	}
+
+	return s, errs
+}
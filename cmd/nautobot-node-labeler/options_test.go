@@ -0,0 +1,166 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// parseOptions returns the options of the controller parsed from args
+func parseOptions(t *testing.T, args ...string) (*options, *pflag.FlagSet) {
+	t.Helper()
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	o := &options{}
+	o.BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return o, fs
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("NAUTOBOT_TOKEN", "token")
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "valid"},
+		{
+			name:    "max concurrent reconciles",
+			args:    []string{"--max-concurrent-reconciles=0"},
+			wantErr: "--max-concurrent-reconciles must be at least 1, got 0",
+		},
+		{
+			name:    "pprof on all interfaces",
+			args:    []string{"--pprof-bind-address=:6060"},
+			wantErr: `--pprof-bind-address must bind to localhost, got ":6060"`,
+		},
+		{
+			name:    "debug auth without TLS",
+			args:    []string{"--debug-auth"},
+			wantErr: "--debug-auth requires --debug-secure",
+		},
+		{
+			name:    "invalid leader election ID",
+			args:    []string{"--leader-elect", "--leader-election-id=Nautobot_Labeler"},
+			wantErr: `invalid --leader-election-id "Nautobot_Labeler"`,
+		},
+		{
+			name:    "leader election durations",
+			args:    []string{"--leader-elect", "--leader-election-renew-deadline=20s"},
+			wantErr: "leader election durations must satisfy lease duration > renew deadline > retry period > 0",
+		},
+		{
+			name:    "reverse sync without the feature gate",
+			args:    []string{"--reverse-sync-cluster=prod"},
+			wantErr: "reverse sync is configured but the ReverseSync feature gate is disabled",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, fs := parseOptions(t, append([]string{"--nautobot-url=https://nautobot.example.com"}, tt.args...)...)
+			s, errs := o.validate(fs, "")
+			switch {
+			case tt.wantErr == "" && len(errs) > 0:
+				t.Fatalf("validate() errors = %v", errs)
+			case tt.wantErr != "" && (len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), tt.wantErr)):
+				t.Fatalf("validate() errors = %v, want %q", errs, tt.wantErr)
+			}
+			if tt.wantErr == "" && s.configStore.Current().Nautobot.URL != "https://nautobot.example.com" {
+				t.Errorf("Nautobot URL = %q, want the one of --nautobot-url", s.configStore.Current().Nautobot.URL)
+			}
+		})
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	t.Setenv("NAUTOBOT_TOKEN", "token")
+	o, fs := parseOptions(t, "--nautobot-url=https://nautobot.example.com", "--leader-elect", "--shard-count=3", "--shard-index=2")
+	s, errs := o.validate(fs, "")
+	if len(errs) > 0 {
+		t.Fatalf("validate() errors = %v", errs)
+	}
+	if want := "nautobot-node-labeler-shard-2"; s.leaderElectionID != want {
+		t.Errorf("leaderElectionID = %q, want %q", s.leaderElectionID, want)
+	}
+	if s.reverseSync != nil {
+		t.Errorf("reverseSync = %+v, want nil without reverse sync flags", s.reverseSync)
+	}
+	if s.applyTLSOptions == nil {
+		t.Errorf("applyTLSOptions is not set")
+	}
+}
+
+func TestConfigOverride(t *testing.T) {
+	t.Setenv("NAUTOBOT_TOKEN", "")
+	t.Setenv("NAUTOBOT_SECONDARY_TOKEN", "")
+	tests := []struct {
+		name  string
+		args  []string
+		mock  string
+		check func(t *testing.T, config *configv1alpha1.LabelerConfiguration)
+	}{
+		{
+			name: "flags not given keep the config file",
+			check: func(t *testing.T, config *configv1alpha1.LabelerConfiguration) {
+				if config.NodeSelector != "role=a" || config.Intervals.Resync.Duration != time.Hour || config.Nautobot.URL != "https://nautobot.example.com" {
+					t.Errorf("config = %+v, want the config file", config)
+				}
+			},
+		},
+		{
+			name: "flags given override the config file",
+			args: []string{"--node-selector=role=b", "--resync-interval=5m", "--nautobot-url=https://other.example.com"},
+			check: func(t *testing.T, config *configv1alpha1.LabelerConfiguration) {
+				if config.NodeSelector != "role=b" || config.Intervals.Resync.Duration != 5*time.Minute || config.Nautobot.URL != "https://other.example.com" {
+					t.Errorf("config = %+v, want the flags", config)
+				}
+			},
+		},
+		{
+			name: "token file replaces the token",
+			args: []string{"--nautobot-token-file=/var/run/token"},
+			check: func(t *testing.T, config *configv1alpha1.LabelerConfiguration) {
+				if config.Nautobot.Token != "" || config.Nautobot.TokenFile != "/var/run/token" {
+					t.Errorf("token = %q, token file = %q, want the token file only", config.Nautobot.Token, config.Nautobot.TokenFile)
+				}
+			},
+		},
+		{
+			name: "mock Nautobot",
+			mock: "http://127.0.0.1:8000",
+			check: func(t *testing.T, config *configv1alpha1.LabelerConfiguration) {
+				if config.Nautobot.URL != "http://127.0.0.1:8000" || config.Nautobot.Token != nautobot.MockToken || config.Nautobot.SecondaryToken != "" {
+					t.Errorf("Nautobot = %+v, want the mock", config.Nautobot)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, fs := parseOptions(t, tt.args...)
+			config := &configv1alpha1.LabelerConfiguration{NodeSelector: "role=a"}
+			config.Nautobot.URL, config.Nautobot.Token, config.Nautobot.SecondaryToken = "https://nautobot.example.com", "token", "secondary"
+			config.Intervals.Resync.Duration = time.Hour
+			o.configOverride(fs, tt.mock)(config)
+			tt.check(t, config)
+		})
+	}
+}
+
+func TestStartMockNautobot(t *testing.T) {
+	url, err := startMockNautobot("", nil)
+	if url != "" || err != nil {
+		t.Errorf("startMockNautobot() = %q, %v, want no mock", url, err)
+	}
+	if _, err := startMockNautobot(filepath.Join(t.TempDir(), "missing.yaml"), nil); err == nil {
+		t.Errorf("startMockNautobot() of missing fixtures returned no error")
+	}
+}
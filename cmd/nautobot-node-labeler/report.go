@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// unknownSite groups the nodes whose lookup failed or whose device has no site
+const unknownSite = "(unknown)"
+
+// reportCommand compares the labels of every node with Nautobot and writes a drift report with
+// a summary per site and the drifted labels per node, e.g. to attach to a change ticket
+type reportCommand struct {
+	selector string
+	output   string
+	all      bool
+}
+
+// driftReport is the drift of the nodes of a cluster from Nautobot at a point in time
+type driftReport struct {
+	Cluster     string      `json:"cluster,omitempty"`
+	GeneratedAt time.Time   `json:"generatedAt"`
+	Total       siteDrift   `json:"total"`
+	Sites       []siteDrift `json:"sites"`
+	// Nodes are the nodes that drifted or failed, or all nodes with --all
+	Nodes []nodeDiff `json:"nodes"`
+}
+
+// siteDrift counts the nodes of a site by their drift
+type siteDrift struct {
+	Site    string `json:"site,omitempty"`
+	Nodes   int    `json:"nodes"`
+	InSync  int    `json:"inSync"`
+	Drifted int    `json:"drifted"`
+	// Errors are the nodes whose lookup or rendering failed
+	Errors int `json:"errors"`
+	// Labels are the drifted labels of all nodes
+	Labels int `json:"labels"`
+}
+
+// Summary implements subcommand
+func (c *reportCommand) Summary() string {
+	return "Write a Markdown or JSON report of the drift of every node's labels from Nautobot, by site and node"
+}
+
+// BindFlags implements subcommand
+func (c *reportCommand) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.selector, "selector", "", "Label selector restricting the reported nodes")
+	fs.StringVarP(&c.output, "output", "o", "markdown", "Output format: markdown or json")
+	fs.BoolVar(&c.all, "all", false, "List every node with all managed labels, not only the drifted ones")
+}
+
+// Run implements subcommand
+func (c *reportCommand) Run(ctx context.Context, env *commandEnv, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: report [--selector <selector>] [--all] [--output markdown|json]")
+	}
+	if c.output != "markdown" && c.output != "json" {
+		return fmt.Errorf("invalid --output %q (expected markdown or json)", c.output)
+	}
+	selector, err := labels.Parse(c.selector)
+	if err != nil {
+		return fmt.Errorf("invalid --selector: %w", err)
+	}
+	kubeClient, err := env.KubeClient()
+	if err != nil {
+		return err
+	}
+	nodes, err := listAllNodes(ctx, kubeClient, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return err
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	config := env.Config.Current()
+	report := driftReport{Cluster: env.ClusterName, GeneratedAt: time.Now().UTC(), Nodes: []nodeDiff{}}
+	sites := map[string]*siteDrift{}
+	for i := range nodes {
+		node := &nodes[i]
+		// Nodes outside the node selector are left alone by the controller
+		if !config.Selector().Matches(labels.Set(node.Labels)) {
+			continue
+		}
+		diff := diffNode(ctx, node, config, env)
+		site := diff.Site
+		if site == "" {
+			site = unknownSite
+		}
+		if sites[site] == nil {
+			sites[site] = &siteDrift{Site: site}
+		}
+		drifted := driftedLabels(diff.Labels)
+		for _, counts := range []*siteDrift{sites[site], &report.Total} {
+			counts.Nodes++
+			counts.Labels += len(drifted)
+			switch {
+			case diff.Error != "":
+				counts.Errors++
+			case len(drifted) > 0:
+				counts.Drifted++
+			default:
+				counts.InSync++
+			}
+		}
+		if c.all {
+			report.Nodes = append(report.Nodes, diff)
+		} else if diff.Error != "" || len(drifted) > 0 {
+			diff.Labels = drifted
+			report.Nodes = append(report.Nodes, diff)
+		}
+	}
+	report.Sites = make([]siteDrift, 0, len(sites))
+	for _, site := range sites {
+		report.Sites = append(report.Sites, *site)
+	}
+	sort.Slice(report.Sites, func(i, j int) bool { return report.Sites[i].Site < report.Sites[j].Site })
+
+	if c.output == "json" {
+		writeJSONTo(env.Out, report)
+		return nil
+	}
+	writeDriftMarkdown(env.Out, report)
+	return nil
+}
+
+// driftedLabels returns the labels whose current value differs from Nautobot's
+func driftedLabels(diffs []labelDiff) []labelDiff {
+	var drifted []labelDiff
+	for _, diff := range diffs {
+		if diff.Action != "unchanged" {
+			drifted = append(drifted, diff)
+		}
+	}
+	return drifted
+}
+
+// writeDriftMarkdown writes a drift report as a Markdown document
+func writeDriftMarkdown(out io.Writer, report driftReport) {
+	fmt.Fprintf(out, "# Node label drift report\n\n")
+	if report.Cluster != "" {
+		fmt.Fprintf(out, "- Cluster: %s\n", markdownCell(report.Cluster))
+	}
+	fmt.Fprintf(out, "- Generated: %s\n", report.GeneratedAt.Format(time.RFC3339))
+	fmt.Fprintf(out, "- Nodes: %d, %d in sync, %d drifted, %d failed\n", report.Total.Nodes, report.Total.InSync, report.Total.Drifted, report.Total.Errors)
+	fmt.Fprintf(out, "- Drifted labels: %d\n\n", report.Total.Labels)
+
+	fmt.Fprintf(out, "## Sites\n\n")
+	fmt.Fprintf(out, "| Site | Nodes | In sync | Drifted | Failed | Drifted labels |\n")
+	fmt.Fprintf(out, "|------|------:|--------:|--------:|-------:|---------------:|\n")
+	for _, site := range report.Sites {
+		fmt.Fprintf(out, "| %s | %d | %d | %d | %d | %d |\n", markdownCell(site.Site), site.Nodes, site.InSync, site.Drifted, site.Errors, site.Labels)
+	}
+
+	fmt.Fprintf(out, "\n## Nodes\n\n")
+	if len(report.Nodes) == 0 {
+		fmt.Fprintf(out, "All nodes match Nautobot.\n")
+		return
+	}
+	for _, node := range report.Nodes {
+		fmt.Fprintf(out, "### %s\n\n", markdownCell(node.Node))
+		if node.Error != "" {
+			fmt.Fprintf(out, "Lookup failed: %s\n\n", markdownCell(node.Error))
+			continue
+		}
+		if node.Site != "" {
+			fmt.Fprintf(out, "Device `%s` in site %s\n\n", node.Device, markdownCell(node.Site))
+		} else {
+			fmt.Fprintf(out, "Device `%s`\n\n", node.Device)
+		}
+		if len(node.Labels) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "| Label | Cluster | Nautobot | Action |\n")
+		fmt.Fprintf(out, "|-------|---------|----------|--------|\n")
+		for _, label := range node.Labels {
+			fmt.Fprintf(out, "| `%s` | %s | %s | %s |\n", label.Label, markdownCode(label.Current), markdownCode(label.Desired), label.Action)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// markdownCell escapes text for a Markdown table cell or paragraph
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "*", `\*`, "_", `\_`).Replace(text)
+}
+
+// markdownCode formats a label value as code, or as a dash when it is unset
+func markdownCode(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return "`" + value + "`"
+}
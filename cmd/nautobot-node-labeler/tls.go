@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// tlsVersions are the accepted --tls-min-version values
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSOptions restricts the TLS versions and cipher suites used by the Nautobot client and the
+// metrics and debug servers
+type TLSOptions struct {
+	// MinVersion is the minimum TLS version, 1.2 or 1.3
+	MinVersion string
+	// CipherSuites are IANA cipher suite names; empty keeps Go's defaults
+	CipherSuites []string
+}
+
+// BindFlags registers the TLS flags on the given flag set
+func (o *TLSOptions) BindFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.MinVersion, "tls-min-version", "1.2",
+		"Minimum TLS version for the Nautobot client and the metrics and debug servers: 1.2 or 1.3")
+	fs.StringSliceVar(&o.CipherSuites, "tls-cipher-suites", nil,
+		"Comma-separated TLS 1.2 cipher suites (IANA names) allowed for the Nautobot client and the metrics and debug servers. "+
+			"Defaults to Go's secure suites. Possible values: "+strings.Join(secureCipherSuiteNames(), ","))
+}
+
+// Apply returns a function setting the options on a tls.Config
+func (o *TLSOptions) Apply() (func(*tls.Config), error) {
+	minVersion, ok := tlsVersions[o.MinVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid --tls-min-version %q (expected 1.2 or 1.3)", o.MinVersion)
+	}
+	// Go does not make TLS 1.3 suites configurable, so suites only matter when 1.2 is allowed
+	if minVersion == tls.VersionTLS13 && len(o.CipherSuites) > 0 {
+		return nil, fmt.Errorf("--tls-cipher-suites has no effect with --tls-min-version=1.3")
+	}
+
+	ids := map[string]uint16{}
+	for _, suite := range configurableCipherSuites() {
+		ids[suite.Name] = suite.ID
+	}
+	var cipherSuites []uint16
+	for _, name := range o.CipherSuites {
+		id, ok := ids[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		cipherSuites = append(cipherSuites, id)
+	}
+
+	return func(c *tls.Config) {
+		c.MinVersion = minVersion
+		if len(cipherSuites) > 0 {
+			c.CipherSuites = cipherSuites
+		}
+	}, nil
+}
+
+// configurableCipherSuites returns Go's secure cipher suites that can be used with TLS 1.2
+func configurableCipherSuites() []*tls.CipherSuite {
+	var suites []*tls.CipherSuite
+	for _, suite := range tls.CipherSuites() {
+		for _, version := range suite.SupportedVersions {
+			if version == tls.VersionTLS12 {
+				suites = append(suites, suite)
+				break
+			}
+		}
+	}
+	return suites
+}
+
+// secureCipherSuiteNames lists the cipher suites accepted by --tls-cipher-suites
+func secureCipherSuiteNames() []string {
+	var names []string
+	for _, suite := range configurableCipherSuites() {
+		names = append(names, suite.Name)
+	}
+	sort.Strings(names)
+	return names
+}
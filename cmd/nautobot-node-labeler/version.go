@@ -0,0 +1,86 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var buildInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nautobot_labeler_build_info",
+		Help: "Build of the running controller. Always 1.",
+	},
+	[]string{"version", "commit", "go_version"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(buildInfo)
+}
+
+// version, commit and buildDate are set at build time, e.g.
+// go build -ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// buildVersion identifies the running binary
+type buildVersion struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+	Platform  string `json:"platform"`
+}
+
+// currentBuild returns the build metadata of the running binary
+func currentBuild() buildVersion {
+	return buildVersion{
+		Version:   version,
+		Commit:    buildCommit(),
+		BuildDate: buildTime(),
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+}
+
+// buildCommit returns the commit the binary was built from, falling back to the VCS
+// information embedded by the go tool
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// buildTime returns when the binary was built, falling back to the time of the commit embedded
+// by the go tool
+func buildTime() string {
+	if buildDate != "" {
+		return buildDate
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.time" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// setBuildInfo publishes the build info metric
+func setBuildInfo() {
+	buildInfo.WithLabelValues(version, buildCommit(), runtime.Version()).Set(1)
+}
@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+)
+
+var adaptiveRequeueInterval = prometheus.NewHistogram(
+	prometheus.HistogramOpts{
+		Name:    "nautobot_labeler_adaptive_requeue_interval_seconds",
+		Help:    "Check intervals chosen for nodes by the adaptive requeue.",
+		Buckets: []float64{900, 1800, 3600, 3 * 3600, 6 * 3600, 12 * 3600, 24 * 3600, 48 * 3600},
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(adaptiveRequeueInterval)
+}
+
+// AdaptiveScheduler schedules the next Nautobot check of every node by how often its labels
+// change: a check deriving the same labels as the last one doubles the node's interval, up to
+// intervals.adaptiveMax, a change resets it to intervals.adaptiveMin. Stable nodes are checked
+// rarely and recently moved hardware often.
+type AdaptiveScheduler struct {
+	mu    sync.Mutex
+	nodes map[string]adaptiveState
+}
+
+// adaptiveState is what AdaptiveScheduler knows about a node
+type adaptiveState struct {
+	// labels are the labels derived by the last check, encoded as JSON
+	labels   string
+	interval time.Duration
+	next     time.Time
+}
+
+// NewAdaptiveScheduler returns an AdaptiveScheduler that knows no nodes yet
+func NewAdaptiveScheduler() *AdaptiveScheduler {
+	return &AdaptiveScheduler{nodes: map[string]adaptiveState{}}
+}
+
+// Due reports whether a node should be checked against Nautobot even though it carries all
+// labels: nodes not checked since startup and nodes whose interval elapsed are due. A nil
+// AdaptiveScheduler never has due nodes.
+func (a *AdaptiveScheduler) Due(nodeName string) bool {
+	if a == nil {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, ok := a.nodes[nodeName]
+	return !ok || !time.Now().Before(state.next)
+}
+
+// Delay returns the time until a node's next check, at most fallback. A nil AdaptiveScheduler
+// returns fallback.
+func (a *AdaptiveScheduler) Delay(nodeName string, fallback time.Duration) time.Duration {
+	if a == nil {
+		return fallback
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, ok := a.nodes[nodeName]
+	if !ok {
+		return fallback
+	}
+	return max(min(time.Until(state.next), fallback), 0)
+}
+
+// Observe records the labels a check derived for a node and returns the delay until its next
+// check. Nodes seen for the first time start at fallback within the bounds. A nil
+// AdaptiveScheduler returns fallback.
+func (a *AdaptiveScheduler) Observe(nodeName string, desiredLabels map[string]string, intervals configv1alpha1.Intervals,
+	fallback time.Duration) time.Duration {
+	if a == nil {
+		return fallback
+	}
+	// Maps are encoded with sorted keys, so equal label sets compare equal
+	encoded, _ := json.Marshal(desiredLabels)
+	minInterval, maxInterval := intervals.AdaptiveMin.Duration, intervals.AdaptiveMax.Duration
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, ok := a.nodes[nodeName]
+	switch {
+	case !ok:
+		state.interval = fallback
+	case state.labels != string(encoded):
+		state.interval = minInterval
+	default:
+		state.interval *= 2
+	}
+	state.interval = max(min(state.interval, maxInterval), minInterval)
+	state.labels = string(encoded)
+	state.next = time.Now().Add(state.interval)
+	a.nodes[nodeName] = state
+	adaptiveRequeueInterval.Observe(state.interval.Seconds())
+	return state.interval
+}
+
+// Delete forgets a node. A nil AdaptiveScheduler ignores it.
+func (a *AdaptiveScheduler) Delete(nodeName string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.nodes, nodeName)
+}
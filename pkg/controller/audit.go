@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a single mutation the controller made to a node
+type AuditRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	Node      string    `json:"node"`
+	// Kind is the kind of node field that changed, e.g. "label"
+	Kind     string `json:"kind"`
+	Key      string `json:"key"`
+	OldValue string `json:"oldValue"`
+	NewValue string `json:"newValue"`
+	// Device identifies the Nautobot device the new value was derived from
+	Device string `json:"device,omitempty"`
+}
+
+// AuditSink persists audit records for compliance review
+type AuditSink interface {
+	Record(record AuditRecord) error
+}
+
+// NewAuditSink returns the sink for the given kind: "none", "stdout" or "file"
+func NewAuditSink(kind, path string, maxSizeMB, maxBackups int) (AuditSink, error) {
+	switch kind {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return &jsonAuditSink{w: os.Stdout}, nil
+	case "file":
+		if path == "" {
+			return nil, fmt.Errorf("an audit file path is required for the file audit sink")
+		}
+		return &jsonAuditSink{w: &rotatingFile{
+			path:       path,
+			maxSize:    int64(maxSizeMB) * 1024 * 1024,
+			maxBackups: maxBackups,
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q (expected none, stdout or file)", kind)
+	}
+}
+
+// jsonAuditSink writes one JSON document per line
+type jsonAuditSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Record implements AuditSink
+func (s *jsonAuditSink) Record(record AuditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// rotatingFile is an append-only file that is rotated to path.1, path.2, ... once it exceeds
+// maxSize, keeping at most maxBackups old files.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+// Write implements io.Writer. Callers serialize writes.
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.maxSize > 0 && f.size+int64(len(p)) > f.maxSize && f.size > 0 {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// open opens (or creates) the current file for appending
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate shifts the backups by one, dropping the oldest, and starts a new file
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+
+	if f.maxBackups > 0 {
+		_ = os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+		}
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+	return f.open()
+}
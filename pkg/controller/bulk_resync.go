@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// bulkResyncBatchSize is how many device names go into one GraphQL query
+const bulkResyncBatchSize = 250
+
+var (
+	bulkResyncDuration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nautobot_labeler_bulk_resync_duration_seconds",
+			Help: "Duration of the last bulk resync query round.",
+		},
+	)
+	bulkResyncDevices = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nautobot_labeler_bulk_resync_devices",
+			Help: "Number of nodes whose device was found by the last bulk resync.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(bulkResyncDuration, bulkResyncDevices)
+}
+
+// BulkResync refreshes every node once per resync interval from a few GraphQL queries instead of
+// one REST request per node. The fetched devices are handed to the reconciles it triggers, which
+// then check even nodes that already carry all labels against Nautobot.
+type BulkResync struct {
+	Client         client.Reader
+	NautobotClient nautobot.Interface
+	Config         *ConfigStore
+	// LookupKey selects the names nodes are matched to devices by
+	LookupKey LookupKey
+	// MetadataOnly lists only node metadata, sharing the reconciler's metadata-only cache
+	MetadataOnly bool
+	// Startup, if set, holds the first resync until Nautobot answered once
+	Startup *NautobotStartupGate
+	// Shard selects the nodes resynced by this replica
+	Shard Shard
+
+	events chan event.GenericEvent
+
+	mu      sync.Mutex
+	devices map[string]*nautobot.DeviceData
+}
+
+// NewBulkResync returns a BulkResync reading nodes with the given client
+func NewBulkResync(reader client.Reader, nautobotClient nautobot.Interface, config *ConfigStore) *BulkResync {
+	return &BulkResync{
+		Client:         reader,
+		NautobotClient: nautobotClient,
+		Config:         config,
+		events:         make(chan event.GenericEvent),
+	}
+}
+
+// Source returns the source of the reconciles triggered by the resyncs
+func (b *BulkResync) Source() source.Source {
+	return source.Channel(b.events, &handler.EnqueueRequestForObject{})
+}
+
+// Start runs a resync right away and then every resync interval
+func (b *BulkResync) Start(ctx context.Context) error {
+	if err := b.Startup.Wait(ctx); err != nil {
+		return nil
+	}
+	for {
+		b.resync(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(b.Config.Current().Intervals.Resync.Duration):
+		}
+	}
+}
+
+// resync looks up the devices of all nodes and triggers a reconcile of every node found.
+// Nodes of failed queries are left to their regular lookups.
+func (b *BulkResync) resync(ctx context.Context) {
+	logger := log.FromContext(ctx).WithName("bulk-resync")
+	started := time.Now()
+
+	nodes, err := listNodeMetadata(ctx, b.Client, b.MetadataOnly)
+	if err != nil {
+		logger.Error(err, "Failed to list nodes")
+		return
+	}
+	owned := 0
+	nodesByHostname := map[string][]string{}
+	for _, node := range nodes {
+		if !b.Shard.Owns(node.Name) {
+			continue
+		}
+		owned++
+		hostname := nautobot.ShortHostname(b.LookupKey.Name(&node))
+		// Devices named by ID are left to the regular lookups, the query matches names only
+		if name := node.Annotations[DeviceNameAnnotation]; name != "" {
+			if nautobot.IsDeviceID(name) {
+				continue
+			}
+			hostname = name
+		}
+		nodesByHostname[hostname] = append(nodesByHostname[hostname], node.Name)
+	}
+	hostnames := make([]string, 0, len(nodesByHostname))
+	for hostname := range nodesByHostname {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	devices := map[string]*nautobot.DeviceData{}
+	for start := 0; start < len(hostnames); start += bulkResyncBatchSize {
+		batch := hostnames[start:min(start+bulkResyncBatchSize, len(hostnames))]
+		found, err := b.NautobotClient.GetDevicesData(ctx, batch)
+		if err != nil {
+			logger.Error(err, "Failed to look up devices, falling back to one REST request per device", "Devices", len(batch))
+			found = b.lookUpEach(ctx, batch)
+		}
+		for hostname, deviceData := range found {
+			for _, nodeName := range nodesByHostname[hostname] {
+				devices[nodeName] = deviceData
+			}
+		}
+	}
+
+	b.mu.Lock()
+	b.devices = devices
+	b.mu.Unlock()
+	bulkResyncDuration.Set(time.Since(started).Seconds())
+	bulkResyncDevices.Set(float64(len(devices)))
+	logger.Info("Looked up all nodes", "Nodes", owned, "Found", len(devices), "Duration", time.Since(started))
+
+	for nodeName := range devices {
+		node := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}
+		select {
+		case b.events <- event.GenericEvent{Object: node}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// lookUpEach looks the devices up by name one REST request at a time, for batches whose GraphQL
+// query failed. Devices that are not found or whose lookup fails are left out.
+func (b *BulkResync) lookUpEach(ctx context.Context, names []string) map[string]*nautobot.DeviceData {
+	logger := log.FromContext(ctx).WithName("bulk-resync")
+	found := map[string]*nautobot.DeviceData{}
+	for _, name := range names {
+		if ctx.Err() != nil {
+			break
+		}
+		deviceData, err := b.NautobotClient.GetDevice(ctx, name)
+		if errors.Is(err, nautobot.ErrDeviceNotFound) {
+			continue
+		}
+		if err != nil {
+			logger.Error(err, "Failed to look up device", "Device", name)
+			continue
+		}
+		found[name] = deviceData
+	}
+	return found
+}
+
+// Take returns and forgets the device fetched for a node by the last resync, or nil. A nil
+// BulkResync has no devices.
+func (b *BulkResync) Take(nodeName string) *nautobot.DeviceData {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	deviceData := b.devices[nodeName]
+	delete(b.devices, nodeName)
+	return deviceData
+}
@@ -0,0 +1,21 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+func TestBulkResyncLookUpEach(t *testing.T) {
+	fake := nautobot.NewFake(&nautobot.DeviceData{ID: "d1", Name: "worker-1"}, &nautobot.DeviceData{ID: "d2", Name: "worker-2"})
+	b := NewBulkResync(nil, fake, nil)
+
+	found := b.lookUpEach(context.Background(), []string{"worker-1", "worker-2", "worker-3"})
+	if len(found) != 2 || found["worker-1"].ID != "d1" || found["worker-2"].ID != "d2" {
+		t.Errorf("lookUpEach() = %v, want worker-1 and worker-2", found)
+	}
+	if got := fake.CallCount("GetDevice"); got != 3 {
+		t.Errorf("GetDevice calls = %d, want 3", got)
+	}
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// ciliumVirtualRouterPrefix prefixes the annotation of Cilium's BGP control plane carrying the
+// per-node settings of the virtual router of an ASN
+const ciliumVirtualRouterPrefix = "cilium.io/bgp-virtual-router."
+
+// ciliumBGPAnnotation renders the virtual router annotation of a node with CiliumBGP: its key
+// names the local ASN of desiredLabels and its value sets the router ID. The key is empty
+// without CiliumBGP or when the ASN or router ID render empty.
+func ciliumBGPAnnotation(config *Config, device *nautobot.DeviceData, desiredLabels map[string]string, clusterName string) (string, string, error) {
+	if config.routerID == nil {
+		return "", "", nil
+	}
+	routerID, err := mapping.RenderTemplate(config.routerID, mapping.TemplateData{DeviceData: device, ClusterName: clusterName})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render BGP router ID: %w", err)
+	}
+	asn := desiredLabels[configv1alpha1.BGPLocalASNLabel]
+	if asn == "" || routerID == "" {
+		return "", "", nil
+	}
+	return ciliumVirtualRouterPrefix + asn, "router-id=" + routerID, nil
+}
+
+// applyCiliumBGPAnnotation sets the virtual router annotation of a node, dropping those of other
+// ASNs, and returns the changes. An empty key drops all of them.
+func applyCiliumBGPAnnotation(node *corev1.Node, key, value, device string) []AuditRecord {
+	var changes []AuditRecord
+	for existing, existingValue := range node.Annotations {
+		if strings.HasPrefix(existing, ciliumVirtualRouterPrefix) && existing != key {
+			delete(node.Annotations, existing)
+			changes = append(changes, AuditRecord{
+				Node:     node.Name,
+				Kind:     "annotation",
+				Key:      existing,
+				OldValue: existingValue,
+				Device:   device,
+			})
+		}
+	}
+	if key != "" && node.Annotations[key] != value {
+		changes = append(changes, AuditRecord{
+			Node:     node.Name,
+			Kind:     "annotation",
+			Key:      key,
+			OldValue: node.Annotations[key],
+			NewValue: value,
+			Device:   device,
+		})
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[key] = value
+	}
+	return changes
+}
+
+// hasCiliumBGPAnnotation reports whether a node carries a virtual router annotation
+func hasCiliumBGPAnnotation(node *corev1.Node) bool {
+	for key := range node.Annotations {
+		if strings.HasPrefix(key, ciliumVirtualRouterPrefix) {
+			return true
+		}
+	}
+	return false
+}
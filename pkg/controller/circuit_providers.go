@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// circuitProviders returns the sorted names of the providers of a device's circuits in one of
+// the counted statuses, each once
+func circuitProviders(circuits *configv1alpha1.CircuitProvidersConfig, device *nautobot.DeviceData) []string {
+	providers := []string{}
+	for _, circuit := range device.Circuits {
+		if circuit.Provider == "" || slices.Contains(providers, circuit.Provider) {
+			continue
+		}
+		for _, status := range circuits.Statuses {
+			if strings.EqualFold(circuit.Status, status) {
+				providers = append(providers, circuit.Provider)
+				break
+			}
+		}
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// applyCircuitProvidersAnnotation sets the circuit providers annotation to the slugs of the
+// providers of a node's device, removing it without any, and returns the changes
+func applyCircuitProvidersAnnotation(node *corev1.Node, circuits *configv1alpha1.CircuitProvidersConfig, device *nautobot.DeviceData) []AuditRecord {
+	if circuits == nil || circuits.Annotation == "" {
+		return nil
+	}
+	slugs := make([]string, 0, len(device.CircuitProviders))
+	for _, provider := range device.CircuitProviders {
+		slugs = append(slugs, configv1alpha1.Slug(provider))
+	}
+	value := strings.Join(slugs, ",")
+	current := node.Annotations[circuits.Annotation]
+	if current == value {
+		return nil
+	}
+	change := AuditRecord{
+		Node:     node.Name,
+		Kind:     "annotation",
+		Key:      circuits.Annotation,
+		OldValue: current,
+		NewValue: value,
+		Device:   device.Name,
+	}
+	if value == "" {
+		delete(node.Annotations, circuits.Annotation)
+		return []AuditRecord{change}
+	}
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[circuits.Annotation] = value
+	return []AuditRecord{change}
+}
+
+// hasCircuitProvidersAnnotation reports whether a node has the circuit providers annotation, if
+// one is configured
+func hasCircuitProvidersAnnotation(node *corev1.Node, circuits *configv1alpha1.CircuitProvidersConfig) bool {
+	return circuits == nil || circuits.Annotation == "" || node.Annotations[circuits.Annotation] != ""
+}
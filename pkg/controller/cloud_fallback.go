@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// Labels the cloud provider sets on its instances
+const (
+	regionLabel       = "topology.kubernetes.io/region"
+	instanceTypeLabel = "node.kubernetes.io/instance-type"
+)
+
+var cloudFallbacksTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_cloud_fallbacks_total",
+		Help: "Number of nodes without a Nautobot device labeled from their cloud provider topology, by provider.",
+	},
+	[]string{"provider"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(cloudFallbacksTotal)
+}
+
+// CloudFallback labels the cloud instances of hybrid clusters that have no device in Nautobot
+// from the topology their cloud provider reports, instead of failing on them forever: a node
+// whose provider ID has one of Providers as scheme, e.g. aws:///eu-west-1a/i-0abc, and that
+// carries a zone label becomes a device with the zone as site.
+type CloudFallback struct {
+	// Providers are the provider ID schemes of cloud instances, e.g. aws, gce or azure
+	Providers []string
+}
+
+// Lookup returns the device of a cloud node, nil for a nil CloudFallback and other nodes. The
+// site is the zone, the region the region; the provider and instance type are custom fields.
+func (c *CloudFallback) Lookup(node *corev1.Node) *nautobot.DeviceData {
+	device := c.device(node)
+	if device != nil {
+		provider, _, _ := strings.Cut(node.Spec.ProviderID, "://")
+		cloudFallbacksTotal.WithLabelValues(provider).Inc()
+	}
+	return device
+}
+
+// device is Lookup without counting the fallback, for the source precedence
+func (c *CloudFallback) device(node *corev1.Node) *nautobot.DeviceData {
+	if c == nil {
+		return nil
+	}
+	provider, _, ok := strings.Cut(node.Spec.ProviderID, "://")
+	if !ok || !c.cloud(provider) || node.Labels[zoneLabel] == "" {
+		return nil
+	}
+	return &nautobot.DeviceData{
+		Name:       node.Name,
+		SiteName:   node.Labels[zoneLabel],
+		RegionName: node.Labels[regionLabel],
+		CustomFields: map[string]interface{}{
+			"provider":     provider,
+			"instanceType": node.Labels[instanceTypeLabel],
+		},
+		Query: "cloud provider " + provider,
+	}
+}
+
+// cloud reports whether a provider ID scheme is one of Providers
+func (c *CloudFallback) cloud(provider string) bool {
+	for _, candidate := range c.Providers {
+		if candidate == provider {
+			return true
+		}
+	}
+	return false
+}
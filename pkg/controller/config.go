@@ -0,0 +1,440 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
+
+	"github.com/fsnotify/fsnotify"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// Config is the active configuration: a versioned configuration file with its mappings and node
+// selector prepared for use
+type Config struct {
+	configv1alpha1.LabelerConfiguration
+
+	mappings []mapping.Mapping
+	selector labels.Selector
+	// routerID renders the BGP router ID of CiliumBGP, nil without it
+	routerID *template.Template
+	// freezeWindows are the parsed FreezeWindows
+	freezeWindows []freezeWindow
+	// inventoryItems are the compiled Accelerators and NICs
+	inventoryItems []inventoryItemMapping
+}
+
+var configScheme = runtime.NewScheme()
+
+// configCodecs decode configuration files strictly, rejecting unknown fields
+var configCodecs = serializer.NewCodecFactory(configScheme, serializer.EnableStrict)
+
+func init() {
+	utilruntime.Must(configv1alpha1.AddToScheme(configScheme))
+}
+
+// configOverride applies settings given as flags or environment variables on top of the file
+type configOverride func(config *configv1alpha1.LabelerConfiguration)
+
+// complete validates the configuration and prepares the mappings and node selector
+func (c *Config) complete() error {
+	if errs := configv1alpha1.Validate(&c.LabelerConfiguration); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	// Reloads cannot enable it either
+	if c.PowerRedundancy != nil && !FeatureGates.Enabled(PowerRedundancy) {
+		return fmt.Errorf("powerRedundancy is configured but the %s feature gate is disabled", PowerRedundancy)
+	}
+	if c.Nautobot.TokenFile != "" {
+		token, err := readTokenFile(c.Nautobot.TokenFile)
+		if err != nil {
+			return err
+		}
+		c.Nautobot.Token = token
+	}
+	if c.Nautobot.SecondaryTokenFile != "" {
+		token, err := readTokenFile(c.Nautobot.SecondaryTokenFile)
+		if err != nil {
+			return err
+		}
+		c.Nautobot.SecondaryToken = token
+	}
+	if c.ServiceNow != nil && c.ServiceNow.PasswordFile != "" {
+		password, err := readTokenFile(c.ServiceNow.PasswordFile)
+		if err != nil {
+			return err
+		}
+		c.ServiceNow.Password = password
+	}
+	mappings, err := mapping.Compile(c.AllMappings())
+	if err != nil {
+		return err
+	}
+	selector, err := labels.Parse(c.NodeSelector)
+	if err != nil {
+		return fmt.Errorf("invalid nodeSelector: %w", err)
+	}
+	if c.CiliumBGP != nil {
+		c.routerID, err = template.New("routerID").Option("missingkey=zero").Funcs(configv1alpha1.TemplateFuncs).Parse(c.CiliumBGP.RouterID)
+		if err != nil {
+			return fmt.Errorf("invalid ciliumBGP.routerID template: %w", err)
+		}
+	}
+	freezeWindows, err := compileFreezeWindows(c.FreezeWindows)
+	if err != nil {
+		return err
+	}
+	inventoryItems, err := compileInventoryItemMappings(c.InventoryItemMappings())
+	if err != nil {
+		return err
+	}
+	c.mappings, c.selector, c.freezeWindows, c.inventoryItems = mappings, selector, freezeWindows, inventoryItems
+	return nil
+}
+
+// CompiledMappings returns all label mappings, those of profiles included, with parsed templates
+func (c *Config) CompiledMappings() []mapping.Mapping {
+	return c.mappings
+}
+
+// Selector returns the parsed node selector
+func (c *Config) Selector() labels.Selector {
+	return c.selector
+}
+
+// CompleteDevice returns a device with the region of its site from siteRegions if it has none,
+// for devices with inventory items, the summaries of their accelerators and NICs and, for
+// devices with circuits, the providers counted, copied if anything changes
+func (c *Config) CompleteDevice(device *nautobot.DeviceData) *nautobot.DeviceData {
+	region, ok := c.SiteRegions[device.SiteName]
+	fillRegion := ok && device.RegionName == ""
+	fillInventory := len(c.inventoryItems) > 0 && device.InventoryItems != nil
+	fillCircuits := c.CircuitProviders != nil && device.Circuits != nil
+	if !fillRegion && !fillInventory && !fillCircuits {
+		return device
+	}
+	completed := *device
+	if fillRegion {
+		completed.RegionName = region
+	}
+	if fillInventory {
+		completed.Inventory = summarizeInventory(c.inventoryItems, device.InventoryItems)
+	}
+	if fillCircuits {
+		completed.CircuitProviders = circuitProviders(c.CircuitProviders, device)
+	}
+	return &completed
+}
+
+// bulkResyncable reports whether the devices of the bulk resync have all the data the
+// configuration reads. Its GraphQL query returns neither relationships, location ancestries,
+// rack groups, inventory items, hardware notices, power feeds, circuits nor the device objects of
+// the REST API the thermal zone reads.
+func (c *Config) bulkResyncable() bool {
+	return len(c.Relationships) == 0 && len(c.LocationTypes) == 0 && len(c.RackGroups) == 0 && c.ThermalZone == nil && c.CircuitProviders == nil &&
+		len(c.TopologyDomains) == 0 && len(c.InventoryItemMappings()) == 0 &&
+		(c.Lifecycle == nil || !c.Lifecycle.DeviceLifecyclePlugin) && c.PowerRedundancy == nil
+}
+
+// locationAncestryDepth is the lookup depth with location types: the device's location and its
+// nearest three ancestors come with the device, further ancestors are looked up
+const locationAncestryDepth = 4
+
+// LookupDepth returns the depth of the nested objects of REST device lookups: nautobot.depth,
+// or else 1 or the depth the topology domains, location types, rack groups and the thermal zone
+// read if deeper
+func (c *Config) LookupDepth() int {
+	if c.Nautobot.Depth > 0 {
+		return c.Nautobot.Depth
+	}
+	depth := 1
+	for _, domain := range c.TopologyDomains {
+		depth = max(depth, configv1alpha1.FieldDepth(domain.Field))
+	}
+	if len(c.LocationTypes) > 0 {
+		depth = max(depth, locationAncestryDepth)
+	}
+	// The rack, its group and the groups of the configured levels above it
+	for _, level := range c.RackGroupLevels() {
+		depth = max(depth, min(level+2, configv1alpha1.MaxNautobotDepth))
+	}
+	return depth
+}
+
+// CircuitLookup returns whether device lookups include circuits, and the location type of the
+// ancestor locations they terminate at
+func (c *Config) CircuitLookup() (bool, string) {
+	if c.CircuitProviders == nil {
+		return false, ""
+	}
+	return true, c.CircuitProviders.LocationType
+}
+
+// serviceNowPassword returns the ServiceNow password, "" without ServiceNow
+func (c *Config) serviceNowPassword() string {
+	if c.ServiceNow == nil {
+		return ""
+	}
+	return c.ServiceNow.Password
+}
+
+// decodeConfig decodes and defaults a configuration file. Files without apiVersion and kind
+// predate the versioned format and are read as config.nautobot.io/v1alpha1; newer versions are
+// converted to it by the scheme.
+func decodeConfig(data []byte) (*configv1alpha1.LabelerConfiguration, error) {
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &typeMeta); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if typeMeta.APIVersion == "" && typeMeta.Kind == "" {
+		var config configv1alpha1.LabelerConfiguration
+		if err := yaml.UnmarshalStrict(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+		configv1alpha1.SetDefaults(&config)
+		return &config, nil
+	}
+
+	obj, _, err := configCodecs.UniversalDecoder(configv1alpha1.GroupVersion).Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	config, ok := obj.(*configv1alpha1.LabelerConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("unexpected config kind %s", obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+	return config, nil
+}
+
+// parseConfig reads a configuration file and applies the override. An empty file yields the
+// defaults.
+func parseConfig(data []byte, override configOverride) (*Config, error) {
+	decoded, err := decodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{LabelerConfiguration: *decoded}
+	if override != nil {
+		override(&config.LabelerConfiguration)
+	}
+	if err := config.complete(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	return config, nil
+}
+
+// ReadConfigFlags returns the flags section of a configuration file
+func ReadConfigFlags(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	config, err := decodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return config.Flags, nil
+}
+
+// readTokenFile reads a token or password, ignoring surrounding whitespace such as a trailing
+// newline
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("credentials file %s is empty", path)
+	}
+	return token, nil
+}
+
+// ConfigStore holds the current configuration and reloads it when the config file or the token
+// file changes, or on SIGHUP. An invalid file is rejected and the previous configuration stays
+// active. Reloads changing the labeling rules, and every SIGHUP, request a resync of all nodes.
+type ConfigStore struct {
+	// Path is the config file; the defaults are used when empty
+	Path string
+
+	override configOverride
+	current  atomic.Pointer[Config]
+
+	mu        sync.Mutex
+	lastData  []byte
+	listeners []func(*Config)
+	resyncs   []func(trigger string)
+}
+
+// NewConfigStore loads the config file at path, or the defaults when path is empty, and applies
+// the override
+func NewConfigStore(path string, override configOverride) (*ConfigStore, error) {
+	s := &ConfigStore{Path: path, override: override}
+	if _, _, err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Current returns the active configuration
+func (s *ConfigStore) Current() *Config {
+	return s.current.Load()
+}
+
+// OnChange registers a function called with the new configuration after every reload
+func (s *ConfigStore) OnChange(fn func(*Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// OnRulesChange registers a function called with the trigger of a reload, e.g. SIGHUP, when all
+// nodes should be checked against the active labeling rules: after reloads changing the rules
+// and after every SIGHUP
+func (s *ConfigStore) OnRulesChange(fn func(trigger string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resyncs = append(s.resyncs, fn)
+}
+
+// load reads the config file and activates it if it or the token changed, reporting whether
+// it did and whether the labeling rules changed
+func (s *ConfigStore) load() (bool, bool, error) {
+	var data []byte
+	if s.Path != "" {
+		var err error
+		if data, err = os.ReadFile(s.Path); err != nil {
+			return false, false, fmt.Errorf("failed to read config file: %w", err)
+		}
+	}
+	config, err := parseConfig(data, s.override)
+	if err != nil {
+		return false, false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.current.Load()
+	if previous != nil && bytes.Equal(data, s.lastData) &&
+		previous.Nautobot.Token == config.Nautobot.Token && previous.Nautobot.SecondaryToken == config.Nautobot.SecondaryToken &&
+		previous.serviceNowPassword() == config.serviceNowPassword() {
+		return false, false, nil
+	}
+	s.lastData = data
+	s.current.Store(config)
+	for _, listener := range s.listeners {
+		listener(config)
+	}
+	return true, previous != nil && !reflect.DeepEqual(labelingRules(previous), labelingRules(config)), nil
+}
+
+// resync calls the OnRulesChange functions
+func (s *ConfigStore) resync(trigger string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, fn := range s.resyncs {
+		fn(trigger)
+	}
+}
+
+// labelingRules returns the parts of a configuration deciding the labels, annotations and taints
+// of nodes: everything but the Nautobot endpoint and tokens, ServiceNow, intervals and flags
+func labelingRules(config *Config) configv1alpha1.LabelerConfiguration {
+	rules := config.LabelerConfiguration
+	rules.Nautobot = configv1alpha1.NautobotConfig{DeviceFilters: rules.Nautobot.DeviceFilters}
+	rules.ServiceNow = nil
+	rules.Intervals = configv1alpha1.Intervals{}
+	rules.Flags = nil
+	return rules
+}
+
+// Start watches the config and token files until the context is cancelled
+func (s *ConfigStore) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("config")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config file watcher: %w", err)
+	}
+	defer watcher.Close()
+	// Watch the directories, since ConfigMap and Secret volumes replace files by swapping symlinks
+	watched := map[string]bool{}
+	nautobot := s.Current().Nautobot
+	paths := []string{s.Path, nautobot.TokenFile, nautobot.SecondaryTokenFile}
+	if serviceNow := s.Current().ServiceNow; serviceNow != nil {
+		paths = append(paths, serviceNow.PasswordFile)
+	}
+	for _, path := range paths {
+		if path == "" || watched[filepath.Dir(path)] {
+			continue
+		}
+		if err := watcher.Add(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		watched[filepath.Dir(path)] = true
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	reload := func(trigger string) {
+		changed, rulesChanged, err := s.load()
+		if err != nil {
+			logger.Error(err, "Rejected config reload, keeping the previous config", "Path", s.Path, "Trigger", trigger)
+			return
+		}
+		if changed {
+			logger.Info("Reloaded config", "Path", s.Path, "Trigger", trigger, "RulesChanged", rulesChanged)
+		}
+		// A SIGHUP always resyncs, so an operator can force all nodes to be checked again
+		if rulesChanged || trigger == "SIGHUP" {
+			s.resync(trigger)
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-hangup:
+			reload("SIGHUP")
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
+				reload("file change")
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error(err, "Config file watcher failed", "Path", s.Path)
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every replica follows the config
+func (s *ConfigStore) NeedLeaderElection() bool {
+	return false
+}
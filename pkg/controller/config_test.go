@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/component-base/featuregate"
+)
+
+func TestParseConfigPowerRedundancyGate(t *testing.T) {
+	config := []byte("apiVersion: config.nautobot.io/v1alpha1\nkind: LabelerConfiguration\nnautobot: {url: https://nautobot.example.com, token: token}\npowerRedundancy: {}\n")
+
+	tests := []struct {
+		name    string
+		enabled bool
+		wantErr bool
+	}{
+		{name: "gate disabled", wantErr: true},
+		{name: "gate enabled", enabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFeatureGate(t, PowerRedundancy, tt.enabled)
+			_, err := parseConfig(config, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// setFeatureGate sets a feature gate for the duration of a test
+func setFeatureGate(t *testing.T, feature featuregate.Feature, enabled bool) {
+	t.Helper()
+	previous := FeatureGates.Enabled(feature)
+	if err := FeatureGates.SetFromMap(map[string]bool{string(feature): enabled}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = FeatureGates.SetFromMap(map[string]bool{string(feature): previous}) })
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+)
+
+// LastAppliedLabelsAnnotation records the label values the controller last applied, so values
+// changed out-of-band in the cluster can be told apart from ones we set ourselves.
+const LastAppliedLabelsAnnotation = "nautobot.io/last-applied-labels"
+
+// ConflictPolicy decides which side wins when the cluster and Nautobot disagree about a value
+// the controller manages.
+type ConflictPolicy string
+
+const (
+	// ConflictPolicyOverwrite lets the sync direction win: Nautobot for labels, the cluster for
+	// reverse-synced fields. This matches the behavior before conflicts were detected.
+	ConflictPolicyOverwrite ConflictPolicy = "overwrite"
+	// ConflictPolicyNautobot always keeps the Nautobot value
+	ConflictPolicyNautobot ConflictPolicy = "nautobot"
+	// ConflictPolicyKubernetes always keeps the cluster value
+	ConflictPolicyKubernetes ConflictPolicy = "kubernetes"
+)
+
+// ParseConflictPolicy validates a conflict policy name
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(value); policy {
+	case ConflictPolicyOverwrite, ConflictPolicyNautobot, ConflictPolicyKubernetes:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown conflict policy %q (expected overwrite, nautobot or kubernetes)", value)
+	}
+}
+
+// nautobotWins reports whether Nautobot's value wins a conflict. fromNautobot is true for fields
+// synced from Nautobot into the cluster and false for reverse-synced fields.
+func (p ConflictPolicy) nautobotWins(fromNautobot bool) bool {
+	switch p {
+	case ConflictPolicyNautobot:
+		return true
+	case ConflictPolicyKubernetes:
+		return false
+	default:
+		return fromNautobot
+	}
+}
+
+// recordConflict counts a conflict and emits a Warning event on the affected object describing
+// both values and which one was kept.
+func recordConflict(recorder record.EventRecorder, obj runtime.Object, field, clusterValue, nautobotValue string, nautobotWon bool) {
+	winner := "kubernetes"
+	if nautobotWon {
+		winner = "nautobot"
+	}
+	conflictsTotal.WithLabelValues(field, winner).Inc()
+
+	if recorder != nil {
+		recorder.Eventf(obj, corev1.EventTypeWarning, "Conflict",
+			"%s differs between the cluster (%q) and Nautobot (%q); keeping the %s value", field, clusterValue, nautobotValue, winner)
+	}
+}
+
+// LastAppliedLabels returns the label values recorded by the last successful sync.
+func LastAppliedLabels(node *corev1.Node) map[string]string {
+	applied := map[string]string{}
+	if raw, ok := node.Annotations[LastAppliedLabelsAnnotation]; ok {
+		// A corrupted annotation is treated as if nothing had been applied yet
+		_ = json.Unmarshal([]byte(raw), &applied)
+	}
+	return applied
+}
+
+// labelsChangedOutOfBand reports whether any managed label differs from what we last applied.
+func labelsChangedOutOfBand(node *corev1.Node) bool {
+	for key, value := range LastAppliedLabels(node) {
+		if node.Labels[key] != value {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelConflict is a managed label whose cluster value was changed out-of-band
+type LabelConflict struct {
+	Key           string
+	ClusterValue  string
+	NautobotValue string
+	NautobotWon   bool
+}
+
+// LabelPlan is what applying the desired labels to a node would do
+type LabelPlan struct {
+	// Changes are the labels to set, as audit records without a timestamp
+	Changes []AuditRecord
+	// Conflicts are the out-of-band changes found, with the policy's decision
+	Conflicts []LabelConflict
+	// Applied are the Nautobot values of the managed labels, recorded as last applied, also of
+	// labels whose cluster value is kept
+	Applied map[string]string
+}
+
+// PlanLabels compares a node's labels with the desired ones without modifying the node. Empty
+// desired values are never applied; out-of-band changes are resolved with the conflict policy.
+func PlanLabels(node *corev1.Node, desired []mapping.Value, policy ConflictPolicy, device string) LabelPlan {
+	lastApplied := LastAppliedLabels(node)
+	plan := LabelPlan{Applied: map[string]string{}}
+	for _, label := range desired {
+		if label.Value == "" {
+			continue
+		}
+
+		current, exists := node.Labels[label.Key]
+		if current != label.Value {
+			// A value that differs from what we last applied was changed out-of-band
+			if exists && lastApplied[label.Key] != "" && current != lastApplied[label.Key] {
+				nautobotWon := policy.nautobotWins(true)
+				plan.Conflicts = append(plan.Conflicts, LabelConflict{
+					Key:           label.Key,
+					ClusterValue:  current,
+					NautobotValue: label.Value,
+					NautobotWon:   nautobotWon,
+				})
+				// A kept cluster value is not recorded as applied, so the conflict is seen again
+				// at every lookup and cleanup leaves the value alone
+				if !nautobotWon {
+					plan.Applied[label.Key] = label.Value
+					continue
+				}
+			}
+			plan.Changes = append(plan.Changes, AuditRecord{
+				Node:     node.Name,
+				Kind:     "label",
+				Key:      label.Key,
+				OldValue: current,
+				NewValue: label.Value,
+				Device:   device,
+			})
+		}
+		plan.Applied[label.Key] = label.Value
+	}
+	return plan
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"k8s.io/client-go/util/cert"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+)
+
+// DebugServer serves operator-facing debug endpoints. It runs on every replica, not only the
+// leader, since each replica reports its own view.
+type DebugServer struct {
+	// Addr is the address the server binds to
+	Addr string
+	// Secure serves HTTPS, using the certificate in CertDir or a self-signed one
+	Secure bool
+	// CertDir holds tls.crt and tls.key for Secure serving
+	CertDir string
+	// Filter, if set, wraps every handler, e.g. to authenticate and authorize requests
+	Filter metricsserver.Filter
+	// TLSOpts customize the TLS configuration of Secure serving
+	TLSOpts []func(*tls.Config)
+
+	mux *http.ServeMux
+}
+
+// NewDebugServer returns a DebugServer listening on addr
+func NewDebugServer(addr string) *DebugServer {
+	return &DebugServer{Addr: addr, mux: http.NewServeMux()}
+}
+
+// Handle registers a handler for the given pattern
+func (s *DebugServer) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start runs the server until the context is cancelled
+func (s *DebugServer) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("debug-server")
+
+	var handler http.Handler = s.mux
+	if s.Filter != nil {
+		filtered, err := s.Filter(logger, s.mux)
+		if err != nil {
+			return fmt.Errorf("failed to set up debug server filter: %w", err)
+		}
+		handler = filtered
+	}
+
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if s.Secure {
+		certificate, err := s.certificate()
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			NextProtos:   []string{"http/1.1"},
+		}
+		for _, opt := range s.TLSOpts {
+			opt(server.TLSConfig)
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("Starting debug server", "Address", s.Addr, "Secure", s.Secure)
+	var err error
+	if s.Secure {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// certificate loads the serving certificate from CertDir or generates a self-signed one
+func (s *DebugServer) certificate() (tls.Certificate, error) {
+	if s.CertDir != "" {
+		certificate, err := tls.LoadX509KeyPair(filepath.Join(s.CertDir, "tls.crt"), filepath.Join(s.CertDir, "tls.key"))
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("failed to load debug server certificate: %w", err)
+		}
+		return certificate, nil
+	}
+
+	certPEM, keyPEM, err := cert.GenerateSelfSignedCertKey("nautobot-node-labeler", nil, nil)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate self-signed debug server certificate: %w", err)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (s *DebugServer) NeedLeaderElection() bool {
+	return false
+}
+
+// writeJSON encodes v as the JSON response body
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	writeJSONTo(w, v)
+}
+
+// writeJSONTo writes v as indented JSON
+func writeJSONTo(w io.Writer, v interface{}) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// DeviceSource looks up the devices of nodes. Nautobot is the default source; other inventories
+// implement it so nodes can be labeled from them, e.g. while migrating to Nautobot. Devices of
+// every source are nautobot.DeviceData, so label mappings work the same with all of them.
+type DeviceSource interface {
+	// GetDeviceData returns the device of a node, or an error wrapping nautobot.ErrDeviceNotFound
+	GetDeviceData(ctx context.Context, nodeName string) (*nautobot.DeviceData, error)
+	// GetDevice returns the device with an exact name or ID, or an error wrapping
+	// nautobot.ErrDeviceNotFound
+	GetDevice(ctx context.Context, nameOrID string) (*nautobot.DeviceData, error)
+	// Ping checks that the source is reachable and accepts the credentials
+	Ping(ctx context.Context) error
+}
+
+var (
+	_ DeviceSource = &nautobot.Client{}
+	_ DeviceSource = &ServiceNowClient{}
+	_ DeviceSource = &FallbackSource{}
+)
+
+// DeviceNameAnnotation names the device of a node by its exact Nautobot name or ID, for nodes
+// whose names will never match their devices. It takes precedence over all other matching.
+const DeviceNameAnnotation = "nautobot.io/device-name"
+
+// LookupDevice returns the device of a node from source: the device named by its
+// DeviceNameAnnotation if set, else the device named like the host its provider ID references
+// with key.ProviderID, else the device matching the name key selects
+func LookupDevice(ctx context.Context, source DeviceSource, node *corev1.Node, key LookupKey) (*nautobot.DeviceData, error) {
+	if name := node.Annotations[DeviceNameAnnotation]; name != "" {
+		return source.GetDevice(ctx, name)
+	}
+	if hardware, ok := key.providerIDHardware(node); ok {
+		deviceData, err := source.GetDevice(ctx, hardware)
+		if !errors.Is(err, nautobot.ErrDeviceNotFound) {
+			return deviceData, err
+		}
+	}
+	return source.GetDeviceData(ctx, key.Name(node))
+}
@@ -0,0 +1,258 @@
+package controller
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// deviceSerialAnnotation names the serial number of a node's device, e.g. set at provisioning,
+// for nodes whose name does not match their device
+const deviceSerialAnnotation = "nautobot.io/device-serial"
+
+var (
+	deviceStoreDevices = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nautobot_labeler_device_store_devices",
+			Help: "Number of devices in the local device store.",
+		},
+	)
+	deviceStoreLastRefresh = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nautobot_labeler_device_store_last_refresh_timestamp_seconds",
+			Help: "Unix time of the last successful refresh of the local device store.",
+		},
+	)
+	deviceStoreLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_device_store_lookups_total",
+			Help: "Number of node lookups in the local device store, by result (hit, miss).",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(deviceStoreDevices, deviceStoreLastRefresh, deviceStoreLookupsTotal)
+}
+
+// DeviceStore is a local copy of all Nautobot devices indexed by name, serial and primary IP,
+// refreshed periodically. Reconciles read from it instead of waiting for Nautobot and only look
+// devices up on demand when they are not in the store.
+type DeviceStore struct {
+	NautobotClient nautobot.Interface
+	// Interval is the time between refreshes
+	Interval time.Duration
+	// Startup, if set, holds the first refresh until Nautobot answered once
+	Startup *NautobotStartupGate
+	// File, if set, keeps a copy of the store across restarts; see Load
+	File string
+
+	// refreshed is when the devices in the store were listed
+	refreshed time.Time
+
+	mu       sync.RWMutex
+	byName   map[string]*nautobot.DeviceData
+	byID     map[string]*nautobot.DeviceData
+	bySerial map[string]*nautobot.DeviceData
+	byIP     map[string]*nautobot.DeviceData
+}
+
+// NewDeviceStore returns an empty store refreshed from nautobotClient every interval
+func NewDeviceStore(nautobotClient nautobot.Interface, interval time.Duration) *DeviceStore {
+	return &DeviceStore{NautobotClient: nautobotClient, Interval: interval}
+}
+
+// Start refreshes the store right away, or once the devices loaded from File are an interval
+// old, and then every interval. Failed refreshes keep the previous devices.
+func (s *DeviceStore) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("device-store")
+	if err := s.Startup.Wait(ctx); err != nil {
+		return nil
+	}
+
+	delay := s.Interval - time.Since(s.refreshed)
+	for {
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(delay):
+			}
+		}
+		started := time.Now()
+		if count, err := s.refresh(ctx); err != nil {
+			logger.Error(err, "Failed to refresh the device store")
+		} else {
+			logger.Info("Refreshed the device store", "Devices", count, "Duration", time.Since(started))
+			if err := s.save(); err != nil {
+				logger.Error(err, "Failed to save the device store", "File", s.File)
+			}
+		}
+		delay = s.Interval
+	}
+}
+
+// deviceStoreSnapshot is the content of a device store file
+type deviceStoreSnapshot struct {
+	Refreshed time.Time              `json:"refreshed"`
+	Devices   []*nautobot.DeviceData `json:"devices"`
+}
+
+// Load fills the store from File, if it exists, so a restarted controller answers lookups
+// right away and lists Nautobot again only when the saved devices are an interval old
+func (s *DeviceStore) Load() (int, error) {
+	file, err := os.Open(s.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open device store file: %w", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read device store file %s: %w", s.File, err)
+	}
+	var snapshot deviceStoreSnapshot
+	if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return 0, fmt.Errorf("failed to read device store file %s: %w", s.File, err)
+	}
+	return s.set(snapshot.Devices, snapshot.Refreshed), nil
+}
+
+// save writes the store to File, replacing it atomically. It does nothing without File.
+func (s *DeviceStore) save() error {
+	if s.File == "" {
+		return nil
+	}
+	s.mu.RLock()
+	snapshot := deviceStoreSnapshot{Refreshed: s.refreshed, Devices: make([]*nautobot.DeviceData, 0, len(s.byName))}
+	for _, device := range s.byName {
+		snapshot.Devices = append(snapshot.Devices, device)
+	}
+	s.mu.RUnlock()
+
+	temp, err := os.CreateTemp(filepath.Dir(s.File), filepath.Base(s.File)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	writer := gzip.NewWriter(temp)
+	err = json.NewEncoder(writer).Encode(snapshot)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), s.File)
+}
+
+// refresh replaces the store with the current devices of Nautobot and returns their number
+func (s *DeviceStore) refresh(ctx context.Context) (int, error) {
+	devices, err := s.NautobotClient.ListDevices(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return s.set(devices, time.Now()), nil
+}
+
+// set replaces the devices of the store, listed at refreshed, and returns their number
+func (s *DeviceStore) set(devices []*nautobot.DeviceData, refreshed time.Time) int {
+	byName := make(map[string]*nautobot.DeviceData, len(devices))
+	byID := make(map[string]*nautobot.DeviceData, len(devices))
+	bySerial := map[string]*nautobot.DeviceData{}
+	byIP := map[string]*nautobot.DeviceData{}
+	for _, device := range devices {
+		byName[device.Name] = device
+		byID[device.ID] = device
+		if device.Serial != "" {
+			bySerial[device.Serial] = device
+		}
+		for _, address := range []*nautobot.IPAddress{device.PrimaryIP4, device.PrimaryIP6} {
+			if address == nil {
+				continue
+			}
+			// Nautobot addresses carry their prefix length, e.g. 10.0.0.5/24
+			if ip := net.ParseIP(strings.SplitN(address.Address, "/", 2)[0]); ip != nil {
+				byIP[ip.String()] = device
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byName, s.byID, s.bySerial, s.byIP, s.refreshed = byName, byID, bySerial, byIP, refreshed
+	deviceStoreDevices.Set(float64(len(byName)))
+	deviceStoreLastRefresh.Set(float64(refreshed.Unix()))
+	return len(byName)
+}
+
+// Lookup finds the device of a node by its device name annotation alone if set, else by its
+// provider ID with key.ProviderID, its serial annotation, the short hostname of the name key
+// selects, then the addresses key.Addresses select. It returns nil if the device is not in the
+// store; a nil store is always empty.
+func (s *DeviceStore) Lookup(node *corev1.Node, key LookupKey) *nautobot.DeviceData {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found := func(device *nautobot.DeviceData, strategy string) *nautobot.DeviceData {
+		deviceStoreLookupsTotal.WithLabelValues("hit").Inc()
+		// Callers get their own copy, telling how the device was matched
+		deviceData := *device
+		deviceData.Query = "device store: " + strategy
+		return &deviceData
+	}
+	if name := node.Annotations[DeviceNameAnnotation]; name != "" {
+		if device, ok := s.byName[name]; ok {
+			return found(device, "name "+name)
+		}
+		if device, ok := s.byID[name]; ok {
+			return found(device, "ID "+name)
+		}
+		deviceStoreLookupsTotal.WithLabelValues("miss").Inc()
+		return nil
+	}
+	if hardware, ok := key.providerIDHardware(node); ok {
+		if device, ok := s.byName[hardware]; ok {
+			return found(device, "provider ID "+node.Spec.ProviderID)
+		}
+	}
+	if serial := node.Annotations[deviceSerialAnnotation]; serial != "" {
+		if device, ok := s.bySerial[serial]; ok {
+			return found(device, "serial "+serial)
+		}
+	}
+	if device, ok := s.byName[nautobot.ShortHostname(key.Name(node))]; ok {
+		return found(device, "name "+device.Name)
+	}
+	for _, ip := range key.nodeIPs(node) {
+		if device, ok := s.byIP[ip.String()]; ok {
+			return found(device, "primary IP "+ip.String())
+		}
+	}
+	deviceStoreLookupsTotal.WithLabelValues("miss").Inc()
+	return nil
+}
@@ -0,0 +1,244 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var disruptionsRefusedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_disruptions_refused_total",
+		Help: "Number of NoExecute taints not added to nodes as evicting their pods was refused, by cause (pdb, rack, error).",
+	},
+	[]string{"cause"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(disruptionsRefusedTotal)
+}
+
+// mirrorPodAnnotation marks the mirror pods of static pods, which taints do not evict
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// DisruptionRefusedError is returned by DisruptionGuard.Check when tainting a node would
+// disrupt more than allowed
+type DisruptionRefusedError struct {
+	// Cause is pdb or rack
+	Cause string
+	// Reasons explain the refusal, e.g. the budgets that would be violated
+	Reasons []string
+}
+
+func (e *DisruptionRefusedError) Error() string {
+	return "evicting the pods of the node is refused: " + strings.Join(e.Reasons, "; ")
+}
+
+// DisruptionGuard checks before a NoExecute taint is added to a node, which evicts its pods
+// without the eviction API, that the eviction honors the PodDisruptionBudgets of the pods and
+// leaves enough nodes of the node's rack available
+type DisruptionGuard struct {
+	// Reader lists pods, PodDisruptionBudgets and nodes, usually the uncached API reader
+	Reader client.Reader
+	// MaxUnavailablePerRack is how many nodes of a rack may be unavailable, i.e. unschedulable,
+	// not ready or NoExecute tainted, before no other node of the rack is tainted; 0 for no limit
+	MaxUnavailablePerRack int
+}
+
+// Check returns a DisruptionRefusedError if adding taints to a node would evict more pods of a
+// PodDisruptionBudget than it allows, or make one node too many of its rack unavailable. Pods
+// tolerating the taints for good are not evicted. A nil DisruptionGuard allows every taint.
+func (g *DisruptionGuard) Check(ctx context.Context, node *corev1.Node, taints []corev1.Taint) error {
+	if g == nil {
+		return nil
+	}
+	if rack := node.Labels[rackLabel]; rack != "" && g.MaxUnavailablePerRack > 0 {
+		unavailable, err := g.unavailableNodes(ctx, node.Name, rack)
+		if err != nil {
+			return err
+		}
+		if len(unavailable) >= g.MaxUnavailablePerRack {
+			return &DisruptionRefusedError{Cause: "rack", Reasons: []string{fmt.Sprintf(
+				"rack %s already has %d unavailable nodes (%s), at most %d are allowed",
+				rack, len(unavailable), strings.Join(unavailable, ", "), g.MaxUnavailablePerRack)}}
+		}
+	}
+
+	var pods corev1.PodList
+	if err := g.Reader.List(ctx, &pods, client.MatchingFields{"spec.nodeName": node.Name}); err != nil {
+		return fmt.Errorf("failed to list pods of node %s: %w", node.Name, err)
+	}
+	evicted := map[string][]corev1.Pod{}
+	for _, pod := range pods.Items {
+		if evictedBy(&pod, taints) {
+			evicted[pod.Namespace] = append(evicted[pod.Namespace], pod)
+		}
+	}
+	namespaces := make([]string, 0, len(evicted))
+	for namespace := range evicted {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	var reasons []string
+	for _, namespace := range namespaces {
+		var budgets policyv1.PodDisruptionBudgetList
+		if err := g.Reader.List(ctx, &budgets, client.InNamespace(namespace)); err != nil {
+			return fmt.Errorf("failed to list PodDisruptionBudgets of namespace %s: %w", namespace, err)
+		}
+		for _, budget := range budgets.Items {
+			// A budget without a selector selects no pods
+			if budget.Spec.Selector == nil {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(budget.Spec.Selector)
+			if err != nil {
+				return fmt.Errorf("failed to parse selector of PodDisruptionBudget %s/%s: %w", namespace, budget.Name, err)
+			}
+			matched := 0
+			for _, pod := range evicted[namespace] {
+				if selector.Matches(labels.Set(pod.Labels)) {
+					matched++
+				}
+			}
+			if matched > int(budget.Status.DisruptionsAllowed) {
+				reasons = append(reasons, fmt.Sprintf("evicting %d pods of PodDisruptionBudget %s/%s exceeds the %d disruptions it allows",
+					matched, namespace, budget.Name, budget.Status.DisruptionsAllowed))
+			}
+		}
+	}
+	if len(reasons) > 0 {
+		return &DisruptionRefusedError{Cause: "pdb", Reasons: reasons}
+	}
+	return nil
+}
+
+// unavailableNodes returns the names of the nodes of a rack other than nodeName that are
+// unschedulable, not ready or NoExecute tainted
+func (g *DisruptionGuard) unavailableNodes(ctx context.Context, nodeName, rack string) ([]string, error) {
+	var nodes corev1.NodeList
+	if err := g.Reader.List(ctx, &nodes, client.MatchingLabels{rackLabel: rack}); err != nil {
+		return nil, fmt.Errorf("failed to list nodes of rack %s: %w", rack, err)
+	}
+	var unavailable []string
+	for _, other := range nodes.Items {
+		if other.Name != nodeName && !nodeAvailable(&other) {
+			unavailable = append(unavailable, other.Name)
+		}
+	}
+	sort.Strings(unavailable)
+	return unavailable, nil
+}
+
+// nodeAvailable reports whether a node is schedulable, ready and without NoExecute taints
+func nodeAvailable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoExecute {
+			return false
+		}
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// evictedBy reports whether taints evict a running pod, i.e. it does not tolerate one of them
+// without a time limit. Mirror pods are left to the kubelet.
+func evictedBy(pod *corev1.Pod, taints []corev1.Taint) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return false
+	}
+	for i := range taints {
+		tolerated := false
+		for _, toleration := range pod.Spec.Tolerations {
+			if toleration.ToleratesTaint(&taints[i]) && toleration.TolerationSeconds == nil {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return true
+		}
+	}
+	return false
+}
+
+// guardTaints returns taints without the NoExecute taints a node does not carry yet, and true,
+// if the Disruptions guard refuses to evict the node's pods for them; taints as they are and
+// false otherwise. Failed checks refuse too.
+func (r *NodeReconciler) guardTaints(ctx context.Context, node *corev1.Node, taints []corev1.Taint) ([]corev1.Taint, bool) {
+	if r.Disruptions == nil {
+		return taints, false
+	}
+	var added, kept []corev1.Taint
+	for _, taint := range taints {
+		if taint.Effect == corev1.TaintEffectNoExecute && !hasTaint(node, taint) {
+			added = append(added, taint)
+		} else {
+			kept = append(kept, taint)
+		}
+	}
+	if len(added) == 0 {
+		return taints, false
+	}
+
+	err := r.Disruptions.Check(ctx, node, added)
+	if err == nil {
+		return taints, false
+	}
+	logger := log.FromContext(ctx)
+	var refused *DisruptionRefusedError
+	if errors.As(err, &refused) {
+		disruptionsRefusedTotal.WithLabelValues(refused.Cause).Inc()
+		logger.Info("Not adding NoExecute taints, evicting the node's pods is refused", "NodeName", node.Name, "Reasons", refused.Reasons)
+	} else {
+		disruptionsRefusedTotal.WithLabelValues("error").Inc()
+		logger.Error(err, "Failed to check the disruption of adding NoExecute taints, not adding them", "NodeName", node.Name)
+	}
+	recordDisruptionRefused(r.Recorder, node, added, err)
+	return kept, true
+}
+
+// hasTaint reports whether a node has a taint of the key and effect of taint
+func hasTaint(node *corev1.Node, taint corev1.Taint) bool {
+	for _, existing := range node.Spec.Taints {
+		if existing.MatchTaint(&taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDisruptionRefused emits a Warning event on a node whose NoExecute taints were not added
+func recordDisruptionRefused(recorder record.EventRecorder, node *corev1.Node, taints []corev1.Taint, err error) {
+	if recorder == nil {
+		return
+	}
+	names := make([]string, 0, len(taints))
+	for _, taint := range taints {
+		names = append(names, taint.ToString())
+	}
+	recorder.Eventf(node, corev1.EventTypeWarning, "DisruptionRefused",
+		"Not adding the taints %s, which would evict the node's pods: %v", strings.Join(names, ", "), err)
+}
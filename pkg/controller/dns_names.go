@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// DNSNameAnnotation carries the DNS name of the primary IP of a node's device
+const DNSNameAnnotation = "nautobot.io/dns-name"
+
+// DNSNames publishes the DNS name Nautobot records for the primary IP of a node's device, e.g.
+// its management FQDN, as a node annotation for external-dns style tooling and inventory
+// scripts. The IPv4 address is preferred over the IPv6 one.
+type DNSNames struct {
+	Client nautobot.Interface
+}
+
+// Lookup returns the DNS name of a device's primary IP, "" if it has none. Addresses of device
+// lookups that left the DNS name out, like the nested addresses of the REST API, are fetched.
+func (d *DNSNames) Lookup(ctx context.Context, device *nautobot.DeviceData) (string, error) {
+	for _, address := range []*nautobot.IPAddress{device.PrimaryIP4, device.PrimaryIP6} {
+		if address == nil {
+			continue
+		}
+		if address.DNSName != "" || address.ID == "" {
+			return address.DNSName, nil
+		}
+		return d.Client.GetIPAddressDNSName(ctx, address.ID)
+	}
+	return "", nil
+}
+
+// applyDNSNameAnnotation sets the DNS name annotation of a node, removing it for an empty name,
+// and returns the change
+func applyDNSNameAnnotation(node *corev1.Node, dnsName, device string) []AuditRecord {
+	current := node.Annotations[DNSNameAnnotation]
+	if current == dnsName {
+		return nil
+	}
+	change := AuditRecord{
+		Node:     node.Name,
+		Kind:     "annotation",
+		Key:      DNSNameAnnotation,
+		OldValue: current,
+		NewValue: dnsName,
+		Device:   device,
+	}
+	if dnsName == "" {
+		delete(node.Annotations, DNSNameAnnotation)
+	} else {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[DNSNameAnnotation] = dnsName
+	}
+	return []AuditRecord{change}
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/flowcontrol"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var eventsSuppressedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_events_suppressed_total",
+		Help: "Number of events not emitted, by reason and cause (duplicate, rate_limit).",
+	},
+	[]string{"reason", "cause"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(eventsSuppressedTotal)
+}
+
+// EventLimiter is an EventRecorder that deduplicates and rate limits the events of another, so
+// an outage failing every node does not flood the events API. An object gets at most one event
+// of a reason per Window, and events beyond the rate are dropped; the suppressed events are
+// counted in a summary event on the Summary object every Window.
+type EventLimiter struct {
+	Recorder record.EventRecorder
+	// Window is the deduplication window, which also paces the summaries. Events are not
+	// deduplicated without one.
+	Window time.Duration
+	// Summary, if set, is the object summary events are recorded on, e.g. the controller's pod;
+	// without one summaries are only logged
+	Summary runtime.Object
+
+	// limiter caps the events emitted, nil for no cap
+	limiter flowcontrol.RateLimiter
+
+	mu sync.Mutex
+	// last is when the last event of an object and reason was emitted, by object and reason
+	last map[string]time.Time
+	// suppressed counts the events suppressed since the last summary, by reason
+	suppressed map[string]int
+}
+
+// NewEventLimiter returns a limiter of the events of recorder to one per object and reason per
+// window and at most perMinute events a minute, with bursts of as many. A perMinute of 0 does
+// not cap the rate.
+func NewEventLimiter(recorder record.EventRecorder, window time.Duration, perMinute float64) *EventLimiter {
+	l := &EventLimiter{Recorder: recorder, Window: window, last: map[string]time.Time{}, suppressed: map[string]int{}}
+	if perMinute > 0 {
+		l.limiter = flowcontrol.NewTokenBucketRateLimiter(float32(perMinute/60), max(int(perMinute), 1))
+	}
+	return l
+}
+
+// Event implements record.EventRecorder
+func (l *EventLimiter) Event(object runtime.Object, eventtype, reason, message string) {
+	if l.allow(object, reason) {
+		l.Recorder.Event(object, eventtype, reason, message)
+	}
+}
+
+// Eventf implements record.EventRecorder
+func (l *EventLimiter) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if l.allow(object, reason) {
+		l.Recorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	}
+}
+
+// AnnotatedEventf implements record.EventRecorder
+func (l *EventLimiter) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if l.allow(object, reason) {
+		l.Recorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+	}
+}
+
+// allow reports whether an event of an object may be emitted, counting it as suppressed if not
+func (l *EventLimiter) allow(object runtime.Object, reason string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := reason
+	if accessor, err := meta.Accessor(object); err == nil {
+		key = fmt.Sprintf("%T/%s/%s/%s", object, accessor.GetNamespace(), accessor.GetName(), reason)
+	}
+	now := time.Now()
+	if last, ok := l.last[key]; ok && l.Window > 0 && now.Sub(last) < l.Window {
+		l.suppressed[reason]++
+		eventsSuppressedTotal.WithLabelValues(reason, "duplicate").Inc()
+		return false
+	}
+	if l.limiter != nil && !l.limiter.TryAccept() {
+		l.suppressed[reason]++
+		eventsSuppressedTotal.WithLabelValues(reason, "rate_limit").Inc()
+		return false
+	}
+	if l.Window > 0 {
+		l.last[key] = now
+	}
+	return true
+}
+
+// Start summarizes the suppressed events every window until ctx is done
+func (l *EventLimiter) Start(ctx context.Context) error {
+	if l.Window <= 0 {
+		return nil
+	}
+	logger := log.FromContext(ctx).WithName("events")
+	ticker := time.NewTicker(l.Window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if summary, total := l.summarize(); total > 0 {
+			logger.Info("Suppressed duplicate and rate limited events", "Window", l.Window, "Count", total, "Reasons", summary)
+			if l.Summary != nil {
+				l.Recorder.Eventf(l.Summary, corev1.EventTypeWarning, "EventsSuppressed",
+					"Suppressed %d events in the last %s: %s", total, l.Window, summary)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Only the leader reconciles
+// nodes, so only it has events to summarize.
+func (l *EventLimiter) NeedLeaderElection() bool {
+	return true
+}
+
+// summarize returns the suppressed events by reason, e.g. "LookupFailed 120, DeviceNotFound 3",
+// and their total, and forgets them along with the events outside the window
+func (l *EventLimiter) summarize() (string, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	total := 0
+	reasons := make([]string, 0, len(l.suppressed))
+	for reason, count := range l.suppressed {
+		total += count
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if l.suppressed[reasons[i]] != l.suppressed[reasons[j]] {
+			return l.suppressed[reasons[i]] > l.suppressed[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	parts := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		parts = append(parts, fmt.Sprintf("%s %d", reason, l.suppressed[reason]))
+	}
+	l.suppressed = map[string]int{}
+	now := time.Now()
+	for key, last := range l.last {
+		if now.Sub(last) >= l.Window {
+			delete(l.last, key)
+		}
+	}
+	return strings.Join(parts, ", "), total
+}
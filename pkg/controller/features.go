@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/component-base/featuregate"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Feature gates let experimental capabilities ship disabled and be enabled per cluster with
+// --feature-gates, like in Kubernetes components. New gates start as Alpha (off by default);
+// graduated gates become Beta (on by default) before they are removed.
+const (
+	// ReverseSync enables the --reverse-sync-* and --on-node-delete controllers pushing node data
+	// back into Nautobot, which write to Nautobot instead of only reading from it
+	ReverseSync featuregate.Feature = "ReverseSync"
+	// ExternalMappingPlugin enables --mapping-plugin-address, whose plugin sets labels and taints
+	ExternalMappingPlugin featuregate.Feature = "ExternalMappingPlugin"
+	// PowerRedundancy enables the powerRedundancy configuration tainting nodes with failed power
+	// feeds
+	PowerRedundancy featuregate.Feature = "PowerRedundancy"
+	// NodeWebhook enables --node-webhook, labeling nodes in the admission of their registration
+	NodeWebhook featuregate.Feature = "NodeWebhook"
+	// PodTopologyLabels enables --pod-topology-webhook, copying the labels of nodes onto pods
+	PodTopologyLabels featuregate.Feature = "PodTopologyLabels"
+	// AdaptiveRequeue checks every node against Nautobot at an interval adapted to how often its
+	// labels change
+	AdaptiveRequeue featuregate.Feature = "AdaptiveRequeue"
+	// FaultInjection enables the --fault-injection-* flags making requests to Nautobot slow or
+	// fail, for resilience tests in staging
+	FaultInjection featuregate.Feature = "FaultInjection"
+)
+
+// defaultFeatureGates are all known feature gates with their defaults
+var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	ReverseSync:           {Default: false, PreRelease: featuregate.Alpha},
+	ExternalMappingPlugin: {Default: false, PreRelease: featuregate.Alpha},
+	PowerRedundancy:       {Default: false, PreRelease: featuregate.Alpha},
+	NodeWebhook:           {Default: false, PreRelease: featuregate.Alpha},
+	PodTopologyLabels:     {Default: false, PreRelease: featuregate.Alpha},
+	AdaptiveRequeue:       {Default: false, PreRelease: featuregate.Alpha},
+	FaultInjection:        {Default: false, PreRelease: featuregate.Alpha},
+}
+
+// FeatureGates holds the feature gates of the controller, set with --feature-gates
+var FeatureGates = featuregate.NewFeatureGate()
+
+var featureEnabled = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nautobot_labeler_feature_enabled",
+		Help: "Whether a feature gate is enabled (1) or not (0).",
+	},
+	[]string{"name", "stage"},
+)
+
+func init() {
+	utilruntime.Must(FeatureGates.Add(defaultFeatureGates))
+	metrics.Registry.MustRegister(featureEnabled)
+}
+
+// RecordFeatureGates exports the state of every known feature gate
+func RecordFeatureGates() {
+	for feature, spec := range defaultFeatureGates {
+		value := 0.0
+		if FeatureGates.Enabled(feature) {
+			value = 1
+		}
+		featureEnabled.WithLabelValues(string(feature), string(spec.PreRelease)).Set(value)
+	}
+}
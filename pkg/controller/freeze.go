@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+)
+
+var (
+	freezeWindowActive = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nautobot_labeler_freeze_window_active",
+			Help: "Whether a freeze window defers node changes (1) or not (0), as of the last reconcile.",
+		},
+	)
+	changesDeferredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_changes_deferred_total",
+			Help: "Total number of reconciles whose node changes were deferred, by reason (freeze_window, rollout, rollout_aborted).",
+		},
+		[]string{"reason"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(freezeWindowActive, changesDeferredTotal)
+}
+
+// freezeWindow is a FreezeWindow with its parsed schedule and time zone
+type freezeWindow struct {
+	schedule *configv1alpha1.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+// compileFreezeWindows parses the schedules and time zones of validated freeze windows
+func compileFreezeWindows(windows []configv1alpha1.FreezeWindow) ([]freezeWindow, error) {
+	compiled := make([]freezeWindow, 0, len(windows))
+	for i, window := range windows {
+		schedule, err := configv1alpha1.ParseSchedule(window.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid freezeWindows[%d].schedule: %w", i, err)
+		}
+		location, err := time.LoadLocation(window.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid freezeWindows[%d].timeZone: %w", i, err)
+		}
+		compiled = append(compiled, freezeWindow{schedule: schedule, duration: window.Duration.Duration, location: location})
+	}
+	return compiled, nil
+}
+
+// FrozenUntil returns the end of the freeze windows now is in, and whether it is in one
+func (c *Config) FrozenUntil(now time.Time) (time.Time, bool) {
+	var until time.Time
+	for _, window := range c.freezeWindows {
+		local := now.In(window.location)
+		// The latest start ends last; one exactly duration ago has just ended
+		start, ok := window.schedule.Last(local, local.Add(-window.duration))
+		if end := start.Add(window.duration); ok && end.After(until) {
+			until = end
+		}
+	}
+	frozen := until.After(now)
+	if frozen {
+		freezeWindowActive.Set(1)
+	} else {
+		freezeWindowActive.Set(0)
+	}
+	return until, frozen
+}
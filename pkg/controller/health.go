@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NautobotHealthCheck is a readiness check verifying Nautobot connectivity and authentication.
+// Results are cached for Interval so frequent probes don't turn into a request storm.
+type NautobotHealthCheck struct {
+	// Source is the device source checked, usually Nautobot
+	Source DeviceSource
+	// Interval is the minimum time between two requests to Nautobot
+	Interval time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	lastErr   error
+}
+
+// Check implements healthz.Checker
+func (h *NautobotHealthCheck) Check(req *http.Request) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.checkedAt.IsZero() || time.Since(h.checkedAt) >= h.Interval {
+		h.lastErr = h.Source.Ping(req.Context())
+		h.checkedAt = time.Now()
+	}
+	if h.lastErr != nil {
+		return fmt.Errorf("nautobot is not reachable: %w", h.lastErr)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// inventoryItemMapping is an InventoryItemMapping with its name pattern compiled
+type inventoryItemMapping struct {
+	configv1alpha1.InventoryItemMapping
+	// namePattern is nil without a NamePattern
+	namePattern *regexp.Regexp
+}
+
+// compileInventoryItemMappings compiles the name patterns of validated inventory item mappings
+func compileInventoryItemMappings(mappings []configv1alpha1.InventoryItemMapping) ([]inventoryItemMapping, error) {
+	compiled := make([]inventoryItemMapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		inventoryItems := inventoryItemMapping{InventoryItemMapping: mapping}
+		if mapping.NamePattern != "" {
+			var err error
+			if inventoryItems.namePattern, err = regexp.Compile(mapping.NamePattern); err != nil {
+				return nil, fmt.Errorf("invalid namePattern of inventory items %s: %w", mapping.Name, err)
+			}
+		}
+		compiled = append(compiled, inventoryItems)
+	}
+	return compiled, nil
+}
+
+// matches reports whether an inventory item is one of the mapping's kind
+func (m inventoryItemMapping) matches(item nautobot.InventoryItem) bool {
+	if m.Manufacturer != "" && item.Manufacturer != m.Manufacturer {
+		return false
+	}
+	if m.namePattern != nil && !m.namePattern.MatchString(item.Name) {
+		return false
+	}
+	if m.Tag == "" {
+		return true
+	}
+	for _, tag := range item.Tags {
+		if tag == m.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeInventory counts the inventory items of each mapping, with their model and fields
+// where they share one value
+func summarizeInventory(mappings []inventoryItemMapping, items []nautobot.InventoryItem) map[string]*nautobot.InventorySummary {
+	summaries := make(map[string]*nautobot.InventorySummary, len(mappings))
+	for _, mapping := range mappings {
+		summary := &nautobot.InventorySummary{Fields: map[string]string{}}
+		// mixed holds the fields the items differ in, "" for the model
+		mixed := map[string]bool{}
+		shared := func(key, current, value string) string {
+			if summary.Count > 0 && value != current {
+				mixed[key] = true
+			}
+			if mixed[key] {
+				return ""
+			}
+			return value
+		}
+		for _, item := range items {
+			if !mapping.matches(item) {
+				continue
+			}
+			summary.Model = shared("", summary.Model, configv1alpha1.Field(item.Raw, mapping.ModelField))
+			for suffix, path := range mapping.Fields {
+				summary.Fields[suffix] = shared(suffix, summary.Fields[suffix], configv1alpha1.Field(item.Raw, path))
+			}
+			summary.Count++
+		}
+		summaries[mapping.Name] = summary
+	}
+	return summaries
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"encoding/json"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// LabelHistoryAnnotation keeps the last label changes of a node as a JSON list, oldest first
+const LabelHistoryAnnotation = "nautobot.io/label-history"
+
+// LabelHistoryEntry is a label change in the LabelHistoryAnnotation
+type LabelHistoryEntry struct {
+	Time  time.Time `json:"time"`
+	Label string    `json:"label"`
+	// Old is empty for added labels
+	Old string `json:"old,omitempty"`
+	New string `json:"new"`
+	// Device is the Nautobot device the value came from
+	Device string `json:"device,omitempty"`
+}
+
+// LabelHistory returns the label history of a node, oldest first
+func LabelHistory(node *corev1.Node) []LabelHistoryEntry {
+	var history []LabelHistoryEntry
+	if raw, ok := node.Annotations[LabelHistoryAnnotation]; ok {
+		// A corrupted annotation starts a new history
+		_ = json.Unmarshal([]byte(raw), &history)
+	}
+	return history
+}
+
+// recordLabelHistory appends the label changes to the history of a node, keeping its last size
+// entries. A size of 0 keeps no history.
+func recordLabelHistory(node *corev1.Node, changes []AuditRecord, size int, now time.Time) {
+	if size <= 0 {
+		return
+	}
+	history := LabelHistory(node)
+	added := false
+	for _, change := range changes {
+		if change.Kind != "label" {
+			continue
+		}
+		history = append(history, LabelHistoryEntry{
+			Time:   now.UTC().Truncate(time.Second),
+			Label:  change.Key,
+			Old:    change.OldValue,
+			New:    change.NewValue,
+			Device: change.Device,
+		})
+		added = true
+	}
+	if !added {
+		return
+	}
+	if len(history) > size {
+		history = history[len(history)-size:]
+	}
+	raw, err := json.Marshal(history)
+	if err != nil {
+		return
+	}
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[LabelHistoryAnnotation] = string(raw)
+}
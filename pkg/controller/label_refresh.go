@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"sync"
+	"time"
+)
+
+// refreshInterval returns the smallest refresh interval of the mappings, 0 if none has one
+func (c *Config) refreshInterval() time.Duration {
+	var interval time.Duration
+	for _, mapping := range c.AllMappings() {
+		if mapping.RefreshInterval != nil && (interval == 0 || mapping.RefreshInterval.Duration < interval) {
+			interval = mapping.RefreshInterval.Duration
+		}
+	}
+	return interval
+}
+
+// LabelRefresh remembers when every node was last checked against Nautobot, so nodes carrying
+// all labels are checked again once the smallest refresh interval of their labels elapsed
+type LabelRefresh struct {
+	mu      sync.Mutex
+	checked map[string]time.Time
+}
+
+// NewLabelRefresh returns a LabelRefresh that knows no nodes yet
+func NewLabelRefresh() *LabelRefresh {
+	return &LabelRefresh{checked: map[string]time.Time{}}
+}
+
+// Due reports whether a node should be checked for a refresh interval: nodes not checked since
+// startup and nodes whose interval elapsed are due. Nothing is due without an interval or with
+// a nil LabelRefresh.
+func (l *LabelRefresh) Due(nodeName string, interval time.Duration) bool {
+	if l == nil || interval <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	checked, ok := l.checked[nodeName]
+	return !ok || time.Since(checked) >= interval
+}
+
+// Delay returns the time until a node's next check for a refresh interval, at most fallback.
+// Without an interval or with a nil LabelRefresh, it returns fallback.
+func (l *LabelRefresh) Delay(nodeName string, interval, fallback time.Duration) time.Duration {
+	if l == nil || interval <= 0 {
+		return fallback
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	checked, ok := l.checked[nodeName]
+	if !ok {
+		return min(interval, fallback)
+	}
+	return max(min(interval-time.Since(checked), fallback), 0)
+}
+
+// Checked records that a node was checked against Nautobot. A nil LabelRefresh ignores it.
+func (l *LabelRefresh) Checked(nodeName string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checked[nodeName] = time.Now()
+}
+
+// Delete forgets a node. A nil LabelRefresh ignores it.
+func (l *LabelRefresh) Delete(nodeName string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.checked, nodeName)
+}
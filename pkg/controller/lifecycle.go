@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// Annotations of the lifecycle dates of a node's hardware
+const (
+	WarrantyExpiryAnnotation = "nautobot.io/warranty-expiry"
+	EndOfLifeAnnotation      = "nautobot.io/end-of-life"
+	EndOfSaleAnnotation      = "nautobot.io/end-of-sale"
+)
+
+// lifecycleAnnotations returns the lifecycle annotations a configuration manages with their
+// values for a device, "" for dates the device has none of
+func lifecycleAnnotations(lifecycle *configv1alpha1.LifecycleConfig, device *nautobot.DeviceData) map[string]string {
+	annotations := map[string]string{}
+	if lifecycle.WarrantyCustomField != "" {
+		annotations[WarrantyExpiryAnnotation] = customFieldString(device, lifecycle.WarrantyCustomField)
+	}
+	if lifecycle.EndOfLifeCustomField != "" || lifecycle.DeviceLifecyclePlugin {
+		annotations[EndOfLifeAnnotation] = customFieldString(device, lifecycle.EndOfLifeCustomField)
+	}
+	if lifecycle.DeviceLifecyclePlugin {
+		annotations[EndOfSaleAnnotation] = ""
+		if notice := device.HardwareNotice; notice != nil {
+			annotations[EndOfSaleAnnotation] = notice.EndOfSale
+			if annotations[EndOfLifeAnnotation] == "" {
+				annotations[EndOfLifeAnnotation] = notice.EndOfSupport
+			}
+		}
+	}
+	return annotations
+}
+
+// customFieldString returns the value of a custom field of a device as text, "" if it is unset
+func customFieldString(device *nautobot.DeviceData, name string) string {
+	switch value := device.CustomFields[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// applyLifecycleAnnotations sets the lifecycle annotations of a node to the dates of its device
+// and returns the changes. Dates the device has none of are empty, so nodes that were checked
+// can be told from those that were not.
+func applyLifecycleAnnotations(node *corev1.Node, lifecycle *configv1alpha1.LifecycleConfig, device *nautobot.DeviceData) []AuditRecord {
+	var changes []AuditRecord
+	for annotation, value := range lifecycleAnnotations(lifecycle, device) {
+		current, ok := node.Annotations[annotation]
+		if ok && current == value {
+			continue
+		}
+		changes = append(changes, AuditRecord{
+			Node:     node.Name,
+			Kind:     "annotation",
+			Key:      annotation,
+			OldValue: current,
+			NewValue: value,
+			Device:   device.Name,
+		})
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[annotation] = value
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// hasLifecycleAnnotations reports whether a node has all lifecycle annotations a configuration
+// manages, if only empty ones
+func hasLifecycleAnnotations(node *corev1.Node, lifecycle *configv1alpha1.LifecycleConfig) bool {
+	if lifecycle == nil {
+		return true
+	}
+	for annotation := range lifecycleAnnotations(lifecycle, &nautobot.DeviceData{}) {
+		if _, ok := node.Annotations[annotation]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// LookupKey selects the string a node is matched to its device by: its object name, the zero
+// value, or the value of one of its labels, e.g. for nodes registered with providerID-style
+// names whose hostname is only in the kubernetes.io/hostname label
+type LookupKey struct {
+	// label is the label holding the device name, empty for the object name
+	label string
+	// ProviderID, if set, matches nodes of bare-metal provisioners to the device named like the
+	// host their provider ID references first
+	ProviderID bool
+	// WindowsNames normalizes the names of Windows nodes, those labeled kubernetes.io/os=windows,
+	// to the names of their devices
+	WindowsNames WindowsNameStyle
+	// Addresses select the node addresses matched to primary IPs, in order of preference;
+	// InternalIP and ExternalIP addresses of both families if empty
+	Addresses []AddressSelector
+}
+
+// AddressSelector selects node addresses by type and IP family
+type AddressSelector struct {
+	Type corev1.NodeAddressType
+	// Family is IPv4 or IPv6, empty for both
+	Family string
+}
+
+// Address families of AddressSelector
+const (
+	IPv4 = "IPv4"
+	IPv6 = "IPv6"
+)
+
+// defaultAddressSelectors are the addresses matched without AddressSelectors
+var defaultAddressSelectors = []AddressSelector{{Type: corev1.NodeInternalIP}, {Type: corev1.NodeExternalIP}}
+
+// ParseAddressSelectors parses a comma-separated list of address selectors, each InternalIP or
+// ExternalIP, optionally with a family, e.g. InternalIP/IPv6,InternalIP/IPv4,ExternalIP
+func ParseAddressSelectors(value string) ([]AddressSelector, error) {
+	var selectors []AddressSelector
+	for _, item := range SplitList(value) {
+		addressType, family, _ := strings.Cut(item, "/")
+		selector := AddressSelector{Type: corev1.NodeAddressType(addressType)}
+		if selector.Type != corev1.NodeInternalIP && selector.Type != corev1.NodeExternalIP {
+			return nil, fmt.Errorf("unknown address type %q in %q (expected InternalIP or ExternalIP)", addressType, item)
+		}
+		switch {
+		case family == "":
+		case strings.EqualFold(family, IPv4):
+			selector.Family = IPv4
+		case strings.EqualFold(family, IPv6):
+			selector.Family = IPv6
+		default:
+			return nil, fmt.Errorf("unknown address family %q in %q (expected IPv4 or IPv6)", family, item)
+		}
+		selectors = append(selectors, selector)
+	}
+	return selectors, nil
+}
+
+// matches reports whether an address of a node is selected
+func (s AddressSelector) matches(address corev1.NodeAddress) (net.IP, bool) {
+	ip := net.ParseIP(address.Address)
+	if address.Type != s.Type || ip == nil {
+		return nil, false
+	}
+	switch s.Family {
+	case IPv4:
+		return ip, ip.To4() != nil
+	case IPv6:
+		return ip, ip.To4() == nil
+	default:
+		return ip, true
+	}
+}
+
+// nodeIPs returns the IPs of the node's addresses the Addresses select, in their order and
+// without repeats, so both families of dual-stack nodes are tried
+func (k LookupKey) nodeIPs(node *corev1.Node) []net.IP {
+	selectors := k.Addresses
+	if len(selectors) == 0 {
+		selectors = defaultAddressSelectors
+	}
+	var ips []net.IP
+	seen := map[string]bool{}
+	for _, selector := range selectors {
+		for _, address := range node.Status.Addresses {
+			if ip, ok := selector.matches(address); ok && !seen[ip.String()] {
+				seen[ip.String()] = true
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// WindowsNameStyle is how the devices of Windows nodes are named in Nautobot. Windows hosts are
+// often inventoried under their uppercase NetBIOS name while their kubelet registers lowercase
+// node names.
+type WindowsNameStyle string
+
+const (
+	// WindowsNamesAsIs matches Windows nodes like Linux nodes
+	WindowsNamesAsIs WindowsNameStyle = "as-is"
+	// WindowsNamesUpper matches Windows nodes by the uppercase short hostname
+	WindowsNamesUpper WindowsNameStyle = "upper"
+	// WindowsNamesNetBIOS matches Windows nodes by their NetBIOS name, the uppercase short
+	// hostname cut to 15 characters
+	WindowsNamesNetBIOS WindowsNameStyle = "netbios"
+)
+
+// netBIOSNameLength is the length NetBIOS names are cut to
+const netBIOSNameLength = 15
+
+// ParseWindowsNameStyle validates a Windows name style
+func ParseWindowsNameStyle(value string) (WindowsNameStyle, error) {
+	switch style := WindowsNameStyle(value); style {
+	case WindowsNamesAsIs, WindowsNamesUpper, WindowsNamesNetBIOS:
+		return style, nil
+	default:
+		return "", fmt.Errorf("unknown Windows node name style %q (expected as-is, upper or netbios)", value)
+	}
+}
+
+// normalize returns the device name of a Windows node looked up by name
+func (s WindowsNameStyle) normalize(name string) string {
+	switch s {
+	case WindowsNamesUpper:
+		return strings.ToUpper(nautobot.ShortHostname(name))
+	case WindowsNamesNetBIOS:
+		short := nautobot.ShortHostname(name)
+		if len(short) > netBIOSNameLength {
+			short = short[:netBIOSNameLength]
+		}
+		return strings.ToUpper(short)
+	default:
+		return name
+	}
+}
+
+// ParseLookupKey parses a lookup key: name, hostname for the kubernetes.io/hostname label, or
+// label:<key>
+func ParseLookupKey(value string) (LookupKey, error) {
+	switch {
+	case value == "name":
+		return LookupKey{}, nil
+	case value == "hostname":
+		return LookupKey{label: corev1.LabelHostname}, nil
+	case strings.HasPrefix(value, "label:"):
+		label := strings.TrimPrefix(value, "label:")
+		if errs := validation.IsQualifiedName(label); len(errs) > 0 {
+			return LookupKey{}, fmt.Errorf("invalid lookup key label %q: %s", label, strings.Join(errs, "; "))
+		}
+		return LookupKey{label: label}, nil
+	default:
+		return LookupKey{}, fmt.Errorf("unknown lookup key %q (expected name, hostname or label:<key>)", value)
+	}
+}
+
+// Name returns the string a node is looked up by, its object name if the label of the key is
+// missing, normalized with WindowsNames for Windows nodes
+func (k LookupKey) Name(node metav1.Object) string {
+	name := node.GetName()
+	if k.label != "" {
+		if value := node.GetLabels()[k.label]; value != "" {
+			name = value
+		}
+	}
+	if node.GetLabels()[corev1.LabelOSStable] == "windows" {
+		name = k.WindowsNames.normalize(name)
+	}
+	return name
+}
+
+// providerIDHardware returns the host the provider ID of a node references with ProviderID,
+// and whether it references one
+func (k LookupKey) providerIDHardware(node *corev1.Node) (string, bool) {
+	if !k.ProviderID {
+		return "", false
+	}
+	return ProviderIDHardware(node.Spec.ProviderID)
+}
+
+// ProviderIDHardware returns the bare-metal host a provider ID references, e.g. the
+// BareMetalHost of baremetalhost:///<namespace>/<host>, and whether it references one:
+//
+//   - baremetalhost:///<namespace>/<host> of the Bare Metal Operator
+//   - metal3://<namespace>/<host>/<machine> of the Cluster API provider Metal3
+//   - tinkerbell://<namespace>/<hardware> of the Cluster API provider Tinkerbell
+//   - maas:///<zone>/<system ID> of the Cluster API provider MAAS
+func ProviderIDHardware(providerID string) (string, bool) {
+	scheme, path, ok := strings.Cut(providerID, "://")
+	if !ok {
+		return "", false
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for _, segment := range segments {
+		if segment == "" {
+			return "", false
+		}
+	}
+	switch {
+	case scheme == "baremetalhost" && strings.HasPrefix(path, "/") && len(segments) == 2,
+		scheme == "tinkerbell" && len(segments) == 2,
+		scheme == "maas" && strings.HasPrefix(path, "/") && len(segments) == 2:
+		return segments[1], true
+	case scheme == "metal3" && len(segments) == 3:
+		return segments[1], true
+	default:
+		return "", false
+	}
+}
+
+// String returns the key as ParseLookupKey reads it
+func (k LookupKey) String() string {
+	switch k.label {
+	case "":
+		return "name"
+	case corev1.LabelHostname:
+		return "hostname"
+	default:
+		return "label:" + k.label
+	}
+}
@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// Cluster API annotations of nodes naming their Machine, and the label of Machines naming their
+// MachineDeployment
+const (
+	machineAnnotation          = "cluster.x-k8s.io/machine"
+	clusterNamespaceAnnotation = "cluster.x-k8s.io/cluster-namespace"
+	deploymentNameLabel        = "cluster.x-k8s.io/deployment-name"
+)
+
+// Topology annotations maintained on Cluster API objects: the site and rack of a Machine's
+// device, and all sites and racks of a MachineDeployment's Machines, comma-separated
+const (
+	machineSiteAnnotation     = "nautobot.io/site"
+	machineRackAnnotation     = "nautobot.io/rack"
+	deploymentSitesAnnotation = "nautobot.io/sites"
+	deploymentRacksAnnotation = "nautobot.io/racks"
+)
+
+var (
+	machineGVK           = schema.GroupVersionKind{Group: "cluster.x-k8s.io", Version: "v1beta1", Kind: "Machine"}
+	machineDeploymentGVK = schema.GroupVersionKind{Group: "cluster.x-k8s.io", Version: "v1beta1", Kind: "MachineDeployment"}
+)
+
+// MachineAnnotations propagates the site and rack of nodes to the Cluster API Machines owning
+// them and to their MachineDeployments, so the topology a deployment's nodes are expected in is
+// known before replacement nodes join. Machines are read uncached from the cluster of Client,
+// the management cluster, also when the nodes are in a member cluster.
+type MachineAnnotations struct {
+	Client client.Client
+}
+
+// Update annotates the Machine of a node with the site and rack of its device, and its
+// MachineDeployment with those of all its Machines. Nodes without a Machine are ignored, as is
+// a nil MachineAnnotations.
+func (m *MachineAnnotations) Update(ctx context.Context, node *corev1.Node, deviceData *nautobot.DeviceData) error {
+	if m == nil {
+		return nil
+	}
+	name, namespace := node.Annotations[machineAnnotation], node.Annotations[clusterNamespaceAnnotation]
+	if name == "" || namespace == "" {
+		return nil
+	}
+
+	machine := &unstructured.Unstructured{}
+	machine.SetGroupVersionKind(machineGVK)
+	if err := m.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, machine); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get Machine %s/%s: %w", namespace, name, err)
+	}
+	changed, err := m.annotate(ctx, machine, map[string]string{
+		machineSiteAnnotation: deviceData.SiteName,
+		machineRackAnnotation: deviceData.RackName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to annotate Machine %s/%s: %w", namespace, name, err)
+	}
+	deployment := machine.GetLabels()[deploymentNameLabel]
+	if !changed || deployment == "" {
+		return nil
+	}
+	return m.updateDeployment(ctx, namespace, deployment)
+}
+
+// updateDeployment annotates a MachineDeployment with the sites and racks of its Machines
+func (m *MachineAnnotations) updateDeployment(ctx context.Context, namespace, name string) error {
+	machines := &unstructured.UnstructuredList{}
+	machines.SetGroupVersionKind(machineGVK.GroupVersion().WithKind(machineGVK.Kind + "List"))
+	if err := m.Client.List(ctx, machines, client.InNamespace(namespace), client.MatchingLabels{deploymentNameLabel: name}); err != nil {
+		return fmt.Errorf("failed to list Machines of MachineDeployment %s/%s: %w", namespace, name, err)
+	}
+	sites, racks := map[string]bool{}, map[string]bool{}
+	for _, machine := range machines.Items {
+		if site := machine.GetAnnotations()[machineSiteAnnotation]; site != "" {
+			sites[site] = true
+		}
+		if rack := machine.GetAnnotations()[machineRackAnnotation]; rack != "" {
+			racks[rack] = true
+		}
+	}
+
+	deployment := &unstructured.Unstructured{}
+	deployment.SetGroupVersionKind(machineDeploymentGVK)
+	if err := m.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, deployment); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to get MachineDeployment %s/%s: %w", namespace, name, err)
+	}
+	if _, err := m.annotate(ctx, deployment, map[string]string{
+		deploymentSitesAnnotation: joinSorted(sites),
+		deploymentRacksAnnotation: joinSorted(racks),
+	}); err != nil {
+		return fmt.Errorf("failed to annotate MachineDeployment %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// annotate merge-patches the annotations of obj, removing those with empty values, and reports
+// whether anything changed
+func (m *MachineAnnotations) annotate(ctx context.Context, obj *unstructured.Unstructured, annotations map[string]string) (bool, error) {
+	original := obj.DeepCopy()
+	current := obj.GetAnnotations()
+	if current == nil {
+		current = map[string]string{}
+	}
+	changed := false
+	for key, value := range annotations {
+		if existing, ok := current[key]; value == "" && ok {
+			delete(current, key)
+			changed = true
+		} else if value != "" && existing != value {
+			current[key] = value
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	obj.SetAnnotations(current)
+	return true, m.Client.Patch(ctx, obj, client.MergeFrom(original))
+}
+
+// joinSorted joins the keys of a set in order, separated by commas
+func joinSorted(set map[string]bool) string {
+	values := make([]string, 0, len(set))
+	for value := range set {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+)
+
+// managedAnnotations are the node annotations the controller sets whatever its configuration.
+// Features adding node annotations list them here, so cleanup removes them.
+var managedAnnotations = []string{
+	LastAppliedLabelsAnnotation,
+	MissingLabelsAnnotation,
+	LabelHistoryAnnotation,
+	DNSNameAnnotation,
+	WarrantyExpiryAnnotation,
+	EndOfLifeAnnotation,
+	EndOfSaleAnnotation,
+	PluginTaintsAnnotation,
+}
+
+// ManagedAnnotations returns the sorted keys of the annotations of a node the controller
+// manages: the fixed ones, those named by the configuration and, with CiliumBGP, the Cilium
+// virtual router annotations
+func ManagedAnnotations(node *corev1.Node, config *Config) []string {
+	managed := map[string]bool{}
+	for _, key := range managedAnnotations {
+		managed[key] = true
+	}
+	for _, relationship := range config.Relationships {
+		if relationship.Annotation != "" {
+			managed[relationship.Annotation] = true
+		}
+	}
+	if config.CircuitProviders != nil && config.CircuitProviders.Annotation != "" {
+		managed[config.CircuitProviders.Annotation] = true
+	}
+
+	var keys []string
+	for key := range node.Annotations {
+		if managed[key] || (config.CiliumBGP != nil && strings.HasPrefix(key, ciliumVirtualRouterPrefix)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ManagedTaints returns the taints of a node the controller set: the power redundancy taint,
+// by its configured and its default key, and the plugin taints recorded in
+// PluginTaintsAnnotation
+func ManagedTaints(node *corev1.Node, config *Config) []corev1.Taint {
+	keys := map[string]bool{configv1alpha1.PowerRedundancyTaint: true}
+	if config.PowerRedundancy != nil {
+		keys[config.PowerRedundancy.TaintKey] = true
+	}
+	plugin := map[string]bool{}
+	for _, id := range strings.Split(node.Annotations[PluginTaintsAnnotation], ",") {
+		plugin[id] = true
+	}
+
+	var taints []corev1.Taint
+	for _, taint := range node.Spec.Taints {
+		if keys[taint.Key] || plugin[taint.Key+":"+string(taint.Effect)] {
+			taints = append(taints, taint)
+		}
+	}
+	return taints
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+)
+
+func TestManagedAnnotations(t *testing.T) {
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{
+		LastAppliedLabelsAnnotation:                 "{}",
+		DNSNameAnnotation:                           "worker-1.example.com",
+		EndOfLifeAnnotation:                         "2030-01-01",
+		"example.com/storage-shelves":               "shelf-1",
+		"example.com/circuit-providers":             "acme",
+		ciliumVirtualRouterPrefix + "65001":         "router-id=10.0.0.1",
+		"node.alpha.kubernetes.io/ttl":              "0",
+		"volumes.kubernetes.io/controller-managed":  "true",
+		"example.com/not-managed-by-the-controller": "x",
+	}}}
+
+	tests := []struct {
+		name   string
+		config configv1alpha1.LabelerConfiguration
+		want   []string
+	}{
+		{
+			name: "fixed annotations only",
+			want: []string{DNSNameAnnotation, EndOfLifeAnnotation, LastAppliedLabelsAnnotation},
+		},
+		{
+			name: "configured annotations",
+			config: configv1alpha1.LabelerConfiguration{
+				Relationships:    []configv1alpha1.RelationshipMapping{{Relationship: "shelf", Annotation: "example.com/storage-shelves"}},
+				CircuitProviders: &configv1alpha1.CircuitProvidersConfig{Annotation: "example.com/circuit-providers"},
+				CiliumBGP:        &configv1alpha1.CiliumBGPConfig{LocalASN: "65001"},
+			},
+			want: []string{
+				ciliumVirtualRouterPrefix + "65001", "example.com/circuit-providers", "example.com/storage-shelves",
+				DNSNameAnnotation, EndOfLifeAnnotation, LastAppliedLabelsAnnotation,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ManagedAnnotations(node, &Config{LabelerConfiguration: tt.config})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ManagedAnnotations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManagedTaints(t *testing.T) {
+	powerLost := corev1.Taint{Key: configv1alpha1.PowerRedundancyTaint, Effect: corev1.TaintEffectPreferNoSchedule}
+	customPowerLost := corev1.Taint{Key: "example.com/power", Effect: corev1.TaintEffectNoSchedule}
+	pluginTaint := corev1.Taint{Key: "example.com/maintenance", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	// The same key with another effect was not set by the plugin
+	other := corev1.Taint{Key: "example.com/maintenance", Effect: corev1.TaintEffectNoExecute}
+	unreachable := corev1.Taint{Key: corev1.TaintNodeUnreachable, Effect: corev1.TaintEffectNoExecute}
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{PluginTaintsAnnotation: "example.com/maintenance:NoSchedule"}},
+		Spec:       corev1.NodeSpec{Taints: []corev1.Taint{powerLost, customPowerLost, pluginTaint, other, unreachable}},
+	}
+
+	tests := []struct {
+		name   string
+		config configv1alpha1.LabelerConfiguration
+		want   []corev1.Taint
+	}{
+		{name: "power redundancy off", want: []corev1.Taint{powerLost, pluginTaint}},
+		{
+			name:   "custom power redundancy taint",
+			config: configv1alpha1.LabelerConfiguration{PowerRedundancy: &configv1alpha1.PowerRedundancyConfig{TaintKey: "example.com/power"}},
+			want:   []corev1.Taint{powerLost, customPowerLost, pluginTaint},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ManagedTaints(node, &Config{LabelerConfiguration: tt.config})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ManagedTaints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/plugin"
+)
+
+// PluginTaintsAnnotation lists the taints the mapping plugin set on a node, as key:effect, so
+// they are removed again once the plugin no longer returns them
+const PluginTaintsAnnotation = "nautobot.io/plugin-taints"
+
+var (
+	mappingPluginRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_mapping_plugin_requests_total",
+			Help: "Number of mapping plugin calls, by result (success, error).",
+		},
+		[]string{"result"},
+	)
+
+	mappingPluginDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "nautobot_labeler_mapping_plugin_duration_seconds",
+			Help:    "Duration of mapping plugin calls.",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(mappingPluginRequestsTotal, mappingPluginDuration)
+}
+
+// MappingPlugin hands the mapping of nodes to an out-of-process plugin: it gets the node, the
+// device and the labels rendered by the mappings, and returns the labels and taints to apply.
+type MappingPlugin struct {
+	Mapper plugin.Mapper
+	// Timeout bounds every call
+	Timeout time.Duration
+}
+
+// Map asks the plugin for the desired labels and taints of a node
+func (p *MappingPlugin) Map(ctx context.Context, node *corev1.Node, deviceData *nautobot.DeviceData, clusterName string,
+	rendered []mapping.Value) ([]mapping.Value, []corev1.Taint, error) {
+	rawNode, err := json.Marshal(node)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode node: %w", err)
+	}
+	req := &plugin.MapRequest{
+		NodeName:    node.Name,
+		Node:        rawNode,
+		Device:      deviceData.Raw,
+		ClusterName: clusterName,
+		Labels:      map[string]string{},
+	}
+	for _, label := range rendered {
+		if label.Value != "" {
+			req.Labels[label.Key] = label.Value
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	start := time.Now()
+	resp, err := p.Mapper.Map(ctx, req)
+	mappingPluginDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		mappingPluginRequestsTotal.WithLabelValues("error").Inc()
+		return nil, nil, fmt.Errorf("mapping plugin failed: %w", err)
+	}
+	desired, taints, err := pluginResult(resp)
+	if err != nil {
+		mappingPluginRequestsTotal.WithLabelValues("error").Inc()
+		return nil, nil, err
+	}
+	mappingPluginRequestsTotal.WithLabelValues("success").Inc()
+	return desired, taints, nil
+}
+
+// pluginResult validates the answer of a plugin and converts it, labels sorted by key
+func pluginResult(resp *plugin.MapResponse) ([]mapping.Value, []corev1.Taint, error) {
+	desired := make([]mapping.Value, 0, len(resp.Labels))
+	for key, value := range resp.Labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return nil, nil, fmt.Errorf("mapping plugin returned invalid label key %q: %s", key, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return nil, nil, fmt.Errorf("mapping plugin returned invalid value %q for label %s: %s", value, key, strings.Join(errs, "; "))
+		}
+		desired = append(desired, mapping.Value{Key: key, Value: value})
+	}
+	sort.Slice(desired, func(i, j int) bool { return desired[i].Key < desired[j].Key })
+
+	taints := make([]corev1.Taint, 0, len(resp.Taints))
+	seen := map[string]bool{}
+	for _, taint := range resp.Taints {
+		if errs := validation.IsQualifiedName(taint.Key); len(errs) > 0 {
+			return nil, nil, fmt.Errorf("mapping plugin returned invalid taint key %q: %s", taint.Key, strings.Join(errs, "; "))
+		}
+		if taint.Value != "" {
+			if errs := validation.IsValidLabelValue(taint.Value); len(errs) > 0 {
+				return nil, nil, fmt.Errorf("mapping plugin returned invalid value %q for taint %s: %s", taint.Value, taint.Key, strings.Join(errs, "; "))
+			}
+		}
+		effect := corev1.TaintEffect(taint.Effect)
+		switch effect {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return nil, nil, fmt.Errorf("mapping plugin returned unsupported effect %q for taint %s", taint.Effect, taint.Key)
+		}
+		id := taint.Key + ":" + taint.Effect
+		if seen[id] {
+			return nil, nil, fmt.Errorf("mapping plugin returned taint %s twice", id)
+		}
+		seen[id] = true
+		taints = append(taints, corev1.Taint{Key: taint.Key, Value: taint.Value, Effect: effect})
+	}
+	return desired, taints, nil
+}
+
+// pluginTaints returns the key:effect of the taints the plugin set on a node
+func pluginTaints(node *corev1.Node) map[string]bool {
+	owned := map[string]bool{}
+	if value := node.Annotations[PluginTaintsAnnotation]; value != "" {
+		for _, id := range strings.Split(value, ",") {
+			owned[id] = true
+		}
+	}
+	return owned
+}
+
+// applyPluginTaints makes the plugin's taints of a node match desired, returning the changes and
+// whether the node was modified. Taints of the same key and effect set by others are taken
+// over; taints the plugin set before but no longer returns are removed.
+func applyPluginTaints(node *corev1.Node, desired []corev1.Taint, device string) ([]AuditRecord, bool) {
+	owned := pluginTaints(node)
+	wanted := map[string]corev1.Taint{}
+	ids := make([]string, 0, len(desired))
+	for _, taint := range desired {
+		id := taint.Key + ":" + string(taint.Effect)
+		wanted[id] = taint
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var changes []AuditRecord
+	record := func(id, oldValue, newValue string) {
+		changes = append(changes, AuditRecord{
+			Node:     node.Name,
+			Kind:     "taint",
+			Key:      id,
+			OldValue: oldValue,
+			NewValue: newValue,
+			Device:   device,
+		})
+	}
+	taints := make([]corev1.Taint, 0, len(node.Spec.Taints)+len(desired))
+	for _, taint := range node.Spec.Taints {
+		id := taint.Key + ":" + string(taint.Effect)
+		want, ok := wanted[id]
+		switch {
+		case ok:
+			if taint.Value != want.Value {
+				record(id, taint.ToString(), want.ToString())
+				taint.Value = want.Value
+			}
+			delete(wanted, id)
+		case owned[id]:
+			record(id, taint.ToString(), "")
+			continue
+		}
+		taints = append(taints, taint)
+	}
+	for _, id := range ids {
+		if taint, ok := wanted[id]; ok {
+			record(id, "", taint.ToString())
+			taints = append(taints, taint)
+		}
+	}
+	node.Spec.Taints = taints
+
+	annotation := strings.Join(ids, ",")
+	if annotation == node.Annotations[PluginTaintsAnnotation] {
+		return changes, len(changes) > 0
+	}
+	if annotation == "" {
+		delete(node.Annotations, PluginTaintsAnnotation)
+	} else {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[PluginTaintsAnnotation] = annotation
+	}
+	return changes, true
+}
@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clientmetrics "k8s.io/client-go/tools/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// Reconcile outcomes used as the "result" label
+const (
+	ResultUpdated   = "updated"
+	ResultUnchanged = "unchanged"
+	ResultSkipped   = "skipped"
+	ResultError     = "error"
+	// ResultDeferred is a reconcile whose changes wait for the end of a freeze window
+	ResultDeferred = "deferred"
+)
+
+var (
+	nodesReconciledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_nodes_reconciled_total",
+			Help: "Number of node reconciles, by result (updated, unchanged, skipped, error).",
+		},
+		[]string{"result"},
+	)
+
+	labelsAppliedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_labels_applied_total",
+			Help: "Number of node label writes, by label key and change type (added, changed).",
+		},
+		[]string{"label", "change"},
+	)
+
+	reconcileSkipsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_reconcile_skips_total",
+			Help: "Number of reconciles that skipped the Nautobot lookup, by reason.",
+		},
+		[]string{"reason"},
+	)
+
+	reconcileErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_reconcile_errors_total",
+			Help: "Number of failed reconciles, by reason and error class.",
+		},
+		[]string{"reason", "class"},
+	)
+
+	reconcileDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "nautobot_labeler_reconcile_duration_seconds",
+			Help:    "Duration of node reconciles in seconds, by result.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+		},
+		[]string{"result"},
+	)
+
+	conflictsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_conflicts_total",
+			Help: "Number of conflicts between cluster and Nautobot values, by field and winning side.",
+		},
+		[]string{"field", "winner"},
+	)
+
+	nodeInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nautobot_node_info",
+			Help: "Datacenter topology of each node as derived from Nautobot. Always 1.",
+		},
+		[]string{"node", "zone", "rack", "site"},
+	)
+
+	kubeAPIRateLimiterDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "nautobot_labeler_kube_api_rate_limiter_duration_seconds",
+			Help:    "Time requests to the Kubernetes API server waited for the --kube-api-qps and --kube-api-burst limits, by verb.",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+		},
+		[]string{"verb"},
+	)
+
+	isLeader = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "nautobot_labeler_leader",
+			Help: "1 if this replica is the elected leader running the controllers, 0 otherwise.",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		nodesReconciledTotal,
+		labelsAppliedTotal,
+		reconcileSkipsTotal,
+		reconcileErrorsTotal,
+		reconcileDuration,
+		conflictsTotal,
+		nodeInfo,
+		kubeAPIRateLimiterDuration,
+		isLeader,
+	)
+	// controller-runtime already registered the client-go metrics it exports, which leaves the
+	// rate limiter latency unset
+	clientmetrics.RateLimiterLatency = rateLimiterLatency{}
+}
+
+// rateLimiterLatency implements client-go's rate limiter latency metric
+type rateLimiterLatency struct{}
+
+// Observe implements clientmetrics.LatencyMetric
+func (rateLimiterLatency) Observe(_ context.Context, verb string, _ url.URL, latency time.Duration) {
+	kubeAPIRateLimiterDuration.WithLabelValues(verb).Observe(latency.Seconds())
+}
+
+// observeReconcile records the outcome and duration of a single reconcile
+func observeReconcile(result string, started time.Time) {
+	nodesReconciledTotal.WithLabelValues(result).Inc()
+	reconcileDuration.WithLabelValues(result).Observe(time.Since(started).Seconds())
+}
+
+// countReconcileError increments the error counter for a failed reconcile step
+func countReconcileError(reason string, err error) {
+	reconcileErrorsTotal.WithLabelValues(reason, errorClass(err)).Inc()
+}
+
+// errorClass buckets an error so alerts can tell an unreachable Nautobot from bad data: auth,
+// not_found, timeout, 5xx, conflict, validation or other.
+func errorClass(err error) string {
+	var apiErr *nautobot.APIError
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.StatusCode; {
+		case code == http.StatusUnauthorized || code == http.StatusForbidden:
+			return "auth"
+		case code == http.StatusNotFound:
+			return "not_found"
+		case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+			return "timeout"
+		case code >= 500:
+			return "5xx"
+		case code == http.StatusConflict:
+			return "conflict"
+		case code == http.StatusBadRequest || code == http.StatusUnprocessableEntity:
+			return "validation"
+		}
+		return "other"
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, nautobot.ErrDeviceNotFound):
+		return "not_found"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout(),
+		apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return "timeout"
+	case apierrors.IsUnauthorized(err), apierrors.IsForbidden(err):
+		return "auth"
+	case apierrors.IsNotFound(err):
+		return "not_found"
+	case apierrors.IsConflict(err):
+		return "conflict"
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return "validation"
+	case apierrors.IsInternalError(err), apierrors.IsServiceUnavailable(err):
+		return "5xx"
+	}
+	return "other"
+}
+
+// TrackLeadership sets the leader gauge while this replica is the leader. The manager only
+// starts leader election runnables, which this is, once the replica was elected.
+func TrackLeadership(ctx context.Context) error {
+	isLeader.Set(1)
+	<-ctx.Done()
+	isLeader.Set(0)
+	return nil
+}
+
+// setNodeInfo replaces the topology info series of a node. The zone and rack come from the node's
+// labels; site is the Nautobot site name, falling back to the zone label (which is derived from
+// the site) when Nautobot was not consulted.
+func setNodeInfo(node *corev1.Node, site string) {
+	zone, rack := node.Labels[zoneLabel], node.Labels[rackLabel]
+	if site == "" {
+		site = zone
+	}
+	nodeInfo.DeletePartialMatch(prometheus.Labels{"node": node.Name})
+	nodeInfo.WithLabelValues(node.Name, zone, rack, site).Set(1)
+}
+
+// deleteNodeInfo drops the topology info series of a deleted node
+func deleteNodeInfo(nodeName string) {
+	nodeInfo.DeletePartialMatch(prometheus.Labels{"node": nodeName})
+}
@@ -0,0 +1,180 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// ClusterNameLabel names the cluster of Cluster API kubeconfig secrets
+const ClusterNameLabel = "cluster.x-k8s.io/cluster-name"
+
+var memberClusterReconcilesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_member_cluster_reconciles_total",
+		Help: "Number of node reconciles in member clusters, by cluster and result (updated, unchanged, skipped, error).",
+	},
+	[]string{"cluster", "result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(memberClusterReconcilesTotal)
+}
+
+// MemberCluster is a cluster whose nodes are reconciled by a controller running elsewhere, e.g.
+// in a management cluster
+type MemberCluster struct {
+	Name   string
+	Config *rest.Config
+}
+
+// LoadMemberKubeconfigs loads the member clusters of a comma-separated list of name=path
+// entries, each path a kubeconfig file using its current context
+func LoadMemberKubeconfigs(value string) ([]MemberCluster, error) {
+	var members []MemberCluster
+	for _, entry := range SplitList(value) {
+		name, path, ok := strings.Cut(entry, "=")
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("invalid member kubeconfig %q, expected <cluster>=<path>", entry)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubeconfig of member cluster %s: %w", name, err)
+		}
+		config, err := clientcmd.RESTConfigFromKubeConfig(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig of member cluster %s: %w", name, err)
+		}
+		members = append(members, MemberCluster{Name: name, Config: config})
+	}
+	return members, nil
+}
+
+// LoadMemberSecrets loads the member clusters of the kubeconfig secrets in namespace matching
+// selector, as written by Cluster API: the kubeconfig is the "value" key, or else "kubeconfig",
+// and the cluster is named by the cluster.x-k8s.io/cluster-name label, or else by the secret
+// name without its -kubeconfig suffix
+func LoadMemberSecrets(ctx context.Context, reader client.Reader, namespace string, selector labels.Selector) ([]MemberCluster, error) {
+	var secrets corev1.SecretList
+	if err := reader.List(ctx, &secrets, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list member cluster secrets: %w", err)
+	}
+	var members []MemberCluster
+	for _, secret := range secrets.Items {
+		name := secret.Labels[ClusterNameLabel]
+		if name == "" {
+			name = strings.TrimSuffix(secret.Name, "-kubeconfig")
+		}
+		data, ok := secret.Data["value"]
+		if !ok {
+			data, ok = secret.Data["kubeconfig"]
+		}
+		if !ok {
+			return nil, fmt.Errorf("member cluster secret %s/%s has neither a value nor a kubeconfig key", secret.Namespace, secret.Name)
+		}
+		config, err := clientcmd.RESTConfigFromKubeConfig(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kubeconfig of member cluster %s from secret %s/%s: %w",
+				name, secret.Namespace, secret.Name, err)
+		}
+		members = append(members, MemberCluster{Name: name, Config: config})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members, nil
+}
+
+// AddMemberCluster caches the nodes of a member cluster in mgr and registers a copy of template
+// reconciling them, with its own sync state and the member's name as the cluster name of label
+// templates. Bulk resyncs, resyncs after config reloads and NodeNautobotSync objects are only
+// maintained for the controller's own cluster.
+func AddMemberCluster(mgr ctrl.Manager, member MemberCluster, template *NodeReconciler) (*NodeReconciler, error) {
+	memberCluster, err := cluster.New(member.Config, func(options *cluster.Options) {
+		options.Scheme = mgr.GetScheme()
+		options.Cache.DefaultTransform = TrimCachedObject
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up member cluster %s: %w", member.Name, err)
+	}
+	if err := mgr.Add(memberCluster); err != nil {
+		return nil, fmt.Errorf("failed to add member cluster %s to manager: %w", member.Name, err)
+	}
+
+	reconciler := *template
+	reconciler.Client = memberCluster.GetClient()
+	reconciler.Recorder = redact.Recorder(memberCluster.GetEventRecorderFor("nautobot-node-labeler"))
+	reconciler.ClusterName = member.Name
+	reconciler.MemberCluster = member.Name
+	reconciler.MissingNodes = NewMissingNodes()
+	reconciler.SyncRecords = NewSyncRecords()
+	reconciler.SyncRecords.SkipResponses = template.SyncRecords.SkipResponses
+	reconciler.BulkResync = nil
+	reconciler.RulesResync = nil
+	reconciler.SyncResources = nil
+	if template.Scheduler != nil {
+		reconciler.Scheduler = NewAdaptiveScheduler()
+	}
+	if template.LabelRefresh != nil {
+		reconciler.LabelRefresh = NewLabelRefresh()
+	}
+	if template.PartialData != nil {
+		reconciler.PartialData = NewPartialData()
+	}
+	if template.Rollout != nil {
+		reconciler.Rollout = NewRollout()
+	}
+	if template.ZoneVolumes != nil {
+		reconciler.ZoneVolumes = &ZoneVolumeCheck{Reader: memberCluster.GetAPIReader(), Policy: template.ZoneVolumes.Policy}
+	}
+	if template.Disruptions != nil {
+		reconciler.Disruptions = &DisruptionGuard{Reader: memberCluster.GetAPIReader(), MaxUnavailablePerRack: template.Disruptions.MaxUnavailablePerRack}
+	}
+	if template.Registration != nil {
+		reconciler.Registration = NewNodeRegistration(template.Registration.Condition, template.Registration.RecheckRestarts)
+	}
+	if template.NodeFeatures != nil {
+		reconciler.NodeFeatures = &NodeFeatures{Client: memberCluster.GetClient(), Namespace: template.NodeFeatures.Namespace}
+	}
+	if template.WriteThrottle != nil {
+		// Every member cluster has its own API server to spare
+		reconciler.WriteThrottle = NewNodeWriteThrottle(template.WriteThrottle.Rate, template.WriteThrottle.BatchSize)
+	}
+
+	var node client.Object = &corev1.Node{}
+	if reconciler.MetadataOnly {
+		metadata := &metav1.PartialObjectMetadata{}
+		metadata.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+		node = metadata
+	}
+	var predicates []predicate.Predicate
+	if reconciler.Shard.Sharded() {
+		predicates = append(predicates, reconciler.Shard.Predicate())
+	}
+	err = ctrl.NewControllerManagedBy(mgr).
+		Named("node-" + member.Name).
+		WatchesRawSource(source.Kind(memberCluster.GetCache(), node, &handler.EnqueueRequestForObject{}, predicates...)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: reconciler.MaxConcurrentReconciles}).
+		Complete(&reconciler)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up reconciler of member cluster %s: %w", member.Name, err)
+	}
+	return &reconciler, nil
+}
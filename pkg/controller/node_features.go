@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// nfdNodeNameLabel names the node a NodeFeature object is about; managedByLabel tells the
+// controller's objects from those of nfd-worker
+const (
+	nfdNodeNameLabel = "nfd.node.kubernetes.io/node-name"
+	managedByLabel   = "app.kubernetes.io/managed-by"
+)
+
+var nodeFeatureGVK = schema.GroupVersionKind{Group: "nfd.k8s-sigs.io", Version: "v1alpha1", Kind: "NodeFeature"}
+
+// NodeFeatures publishes the labels of nodes as Node Feature Discovery NodeFeature objects
+// (NFD v0.14+), which nfd-master applies to the nodes, instead of writing the labels itself.
+// Each node has one object named <node>-nautobot in Namespace, owned by the node.
+type NodeFeatures struct {
+	Client    client.Client
+	Namespace string
+}
+
+// Apply creates or updates the NodeFeature of a node with the given labels and reports whether
+// it changed
+func (f *NodeFeatures) Apply(ctx context.Context, node *corev1.Node, desiredLabels map[string]string) (bool, error) {
+	labels := make(map[string]interface{}, len(desiredLabels))
+	for key, value := range desiredLabels {
+		labels[key] = value
+	}
+
+	feature := &unstructured.Unstructured{}
+	feature.SetGroupVersionKind(nodeFeatureGVK)
+	key := client.ObjectKey{Namespace: f.Namespace, Name: node.Name + "-nautobot"}
+	if err := f.Client.Get(ctx, key, feature); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to get NodeFeature: %w", err)
+		}
+		feature.SetNamespace(key.Namespace)
+		feature.SetName(key.Name)
+		feature.SetLabels(map[string]string{nfdNodeNameLabel: node.Name, managedByLabel: "nautobot-node-labeler"})
+		// Owned by the node so it is garbage collected when the node goes away
+		feature.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       node.Name,
+			UID:        node.UID,
+		}})
+		feature.Object["spec"] = map[string]interface{}{
+			"features": map[string]interface{}{
+				"flags":      map[string]interface{}{},
+				"attributes": map[string]interface{}{},
+				"instances":  map[string]interface{}{},
+			},
+			"labels": labels,
+		}
+		if err := f.Client.Create(ctx, feature); err != nil {
+			return false, fmt.Errorf("failed to create NodeFeature: %w", err)
+		}
+		return true, nil
+	}
+
+	current, _, err := unstructured.NestedMap(feature.Object, "spec", "labels")
+	if err != nil {
+		return false, fmt.Errorf("failed to read NodeFeature labels: %w", err)
+	}
+	if reflect.DeepEqual(current, labels) || (len(current) == 0 && len(labels) == 0) {
+		return false, nil
+	}
+	original := feature.DeepCopy()
+	if err := unstructured.SetNestedMap(feature.Object, labels, "spec", "labels"); err != nil {
+		return false, fmt.Errorf("failed to set NodeFeature labels: %w", err)
+	}
+	if err := f.Client.Patch(ctx, feature, client.MergeFrom(original)); err != nil {
+		return false, fmt.Errorf("failed to update NodeFeature: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Node group kinds whose expected labels NodeGroupTemplates maintains
+const (
+	NodeGroupsClusterAPI = "cluster-api"
+	NodeGroupsKarpenter  = "karpenter"
+)
+
+const (
+	// autoscalerLabelsAnnotation holds the labels Cluster Autoscaler expects on new nodes of a
+	// Cluster API MachineDeployment when scaling it from zero
+	autoscalerLabelsAnnotation = "capacity.cluster-autoscaler.kubernetes.io/labels"
+	// expectedLabelsAnnotation holds the labels expected on new nodes of a Karpenter NodePool
+	expectedLabelsAnnotation = "nautobot.io/expected-labels"
+	// karpenterNodePoolLabel names the NodePool of a Karpenter node
+	karpenterNodePoolLabel = "karpenter.sh/nodepool"
+)
+
+var nodePoolGVK = schema.GroupVersionKind{Group: "karpenter.sh", Version: "v1", Kind: "NodePool"}
+
+// NodeGroupTemplates periodically writes the labels expected on new nodes of node groups into
+// their autoscaler annotations, so scale-from-zero decisions see the topology of nodes that do
+// not exist yet. A group's expected labels are the managed labels all its current nodes agree
+// on, e.g. the zone of a group within one site; groups without nodes keep their annotation.
+type NodeGroupTemplates struct {
+	Client client.Client
+	Config *ConfigStore
+	// Kinds are the node groups maintained, cluster-api and karpenter
+	Kinds []string
+	// Interval is the time between two updates
+	Interval time.Duration
+	// MetadataOnly lists only node metadata, sharing the reconciler's metadata-only cache
+	MetadataOnly bool
+}
+
+// Start implements manager.Runnable
+func (t *NodeGroupTemplates) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("node-group-templates")
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.update(ctx); err != nil {
+			logger.Error(err, "Failed to update node group templates")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// update writes the expected labels of every node group with nodes
+func (t *NodeGroupTemplates) update(ctx context.Context) error {
+	nodes, err := listNodeMetadata(ctx, t.Client, t.MetadataOnly)
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	var managed []string
+	for _, mapping := range t.Config.Current().mappings {
+		managed = append(managed, mapping.Label)
+	}
+
+	for _, kind := range t.Kinds {
+		switch kind {
+		case NodeGroupsClusterAPI:
+			err = t.updateMachineDeployments(ctx, nodes, managed)
+		case NodeGroupsKarpenter:
+			err = t.updateNodePools(ctx, nodes, managed)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateMachineDeployments sets the Cluster Autoscaler labels of the MachineDeployments of the
+// nodes' Machines
+func (t *NodeGroupTemplates) updateMachineDeployments(ctx context.Context, nodes []metav1.ObjectMeta, managed []string) error {
+	machines := &unstructured.UnstructuredList{}
+	machines.SetGroupVersionKind(machineGVK.GroupVersion().WithKind(machineGVK.Kind + "List"))
+	if err := t.Client.List(ctx, machines); err != nil {
+		return fmt.Errorf("failed to list Machines: %w", err)
+	}
+	deployments := map[client.ObjectKey]string{}
+	for _, machine := range machines.Items {
+		if deployment := machine.GetLabels()[deploymentNameLabel]; deployment != "" {
+			deployments[client.ObjectKeyFromObject(&machine)] = deployment
+		}
+	}
+
+	groups := map[client.ObjectKey][]*metav1.ObjectMeta{}
+	for i := range nodes {
+		node := &nodes[i]
+		machine := client.ObjectKey{Namespace: node.Annotations[clusterNamespaceAnnotation], Name: node.Annotations[machineAnnotation]}
+		if deployment, ok := deployments[machine]; ok {
+			key := client.ObjectKey{Namespace: machine.Namespace, Name: deployment}
+			groups[key] = append(groups[key], node)
+		}
+	}
+	for key, groupNodes := range groups {
+		err := t.annotate(ctx, machineDeploymentGVK, key, autoscalerLabelsAnnotation, expectedLabels(groupNodes, managed), managed)
+		if err != nil {
+			return fmt.Errorf("failed to annotate MachineDeployment %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// updateNodePools sets the expected labels of the Karpenter NodePools of the nodes
+func (t *NodeGroupTemplates) updateNodePools(ctx context.Context, nodes []metav1.ObjectMeta, managed []string) error {
+	groups := map[string][]*metav1.ObjectMeta{}
+	for i := range nodes {
+		if pool := nodes[i].Labels[karpenterNodePoolLabel]; pool != "" {
+			groups[pool] = append(groups[pool], &nodes[i])
+		}
+	}
+	for pool, groupNodes := range groups {
+		err := t.annotate(ctx, nodePoolGVK, client.ObjectKey{Name: pool}, expectedLabelsAnnotation, expectedLabels(groupNodes, managed), managed)
+		if err != nil {
+			return fmt.Errorf("failed to annotate NodePool %s: %w", pool, err)
+		}
+	}
+	return nil
+}
+
+// annotate replaces the managed labels in the key=value list of an object's annotation with
+// expected, keeping the other labels of the list, and patches the object if that changed it.
+// Missing objects are ignored.
+func (t *NodeGroupTemplates) annotate(ctx context.Context, gvk schema.GroupVersionKind, key client.ObjectKey, annotation string,
+	expected map[string]string, managed []string) error {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	if err := t.Client.Get(ctx, key, obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	annotations := obj.GetAnnotations()
+	labels := parseLabelList(annotations[annotation])
+	for _, label := range managed {
+		delete(labels, label)
+	}
+	for label, value := range expected {
+		labels[label] = value
+	}
+	value := formatLabelList(labels)
+	if value == annotations[annotation] {
+		return nil
+	}
+
+	original := obj.DeepCopy()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if value == "" {
+		delete(annotations, annotation)
+	} else {
+		annotations[annotation] = value
+	}
+	obj.SetAnnotations(annotations)
+	err := t.Client.Patch(ctx, obj, client.MergeFrom(original))
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// expectedLabels returns the managed labels all nodes carry with the same value
+func expectedLabels(nodes []*metav1.ObjectMeta, managed []string) map[string]string {
+	expected := map[string]string{}
+	for _, label := range managed {
+		value := nodes[0].Labels[label]
+		for _, node := range nodes[1:] {
+			if node.Labels[label] != value {
+				value = ""
+				break
+			}
+		}
+		if value != "" {
+			expected[label] = value
+		}
+	}
+	return expected
+}
+
+// parseLabelList parses a comma-separated list of key=value labels, ignoring malformed entries
+func parseLabelList(value string) map[string]string {
+	labels := map[string]string{}
+	for _, entry := range SplitList(value) {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return labels
+}
+
+// formatLabelList formats labels as a comma-separated list of key=value, sorted by key
+func formatLabelList(labels map[string]string) string {
+	entries := make([]string, 0, len(labels))
+	for key, value := range labels {
+		entries = append(entries, key+"="+value)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ",")
+}
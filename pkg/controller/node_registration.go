@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var kubeletRestartsTotal = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_kubelet_restarts_total",
+		Help: "Number of kubelet restarts, reboots and first Ready conditions of nodes observed, after which their labels were checked again.",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(kubeletRestartsTotal)
+}
+
+// NodeRegistration holds the first sync of nodes until they report a condition, usually Ready,
+// and checks the labels of nodes again after their kubelet restarted, as some bootstrap flows
+// wipe or override labels while kubelet registers the node. It reads node status, so it needs
+// full node objects rather than metadata.
+type NodeRegistration struct {
+	// Condition, if set, is the condition nodes must report as True before their first sync
+	Condition corev1.NodeConditionType
+	// RecheckRestarts checks the labels of nodes again after kubelet restarts
+	RecheckRestarts bool
+
+	mu sync.Mutex
+	// registrations are the last registrations seen of the nodes
+	registrations map[string]kubeletRegistration
+}
+
+// kubeletRegistration identifies a run of kubelet: a restart turns the node Ready again, and a
+// reboot or upgrade changes the boot ID or kubelet version
+type kubeletRegistration struct {
+	readySince     string
+	bootID         string
+	kubeletVersion string
+}
+
+// NewNodeRegistration returns a NodeRegistration waiting for condition, none if empty
+func NewNodeRegistration(condition corev1.NodeConditionType, recheckRestarts bool) *NodeRegistration {
+	return &NodeRegistration{
+		Condition:       condition,
+		RecheckRestarts: recheckRestarts,
+		registrations:   map[string]kubeletRegistration{},
+	}
+}
+
+// Waiting reports whether a node that was never synced, i.e. has no LastAppliedLabelsAnnotation,
+// does not report the condition as True yet. Synced nodes are never held, also when they turn
+// not ready later. A nil NodeRegistration holds no node.
+func (n *NodeRegistration) Waiting(node *corev1.Node) bool {
+	if n == nil || n.Condition == "" || node.Annotations[LastAppliedLabelsAnnotation] != "" {
+		return false
+	}
+	return !hasNodeCondition(node, n.Condition)
+}
+
+// Restarted reports whether the kubelet of a node restarted, or the node rebooted or turned
+// Ready for the first time, since the node was last seen. Nodes seen for the first time did not
+// restart. A nil NodeRegistration tracks no restarts.
+func (n *NodeRegistration) Restarted(node *corev1.Node) bool {
+	if n == nil || !n.RecheckRestarts {
+		return false
+	}
+	current := kubeletRegistration{bootID: node.Status.NodeInfo.BootID, kubeletVersion: node.Status.NodeInfo.KubeletVersion}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			current.readySince = condition.LastTransitionTime.UTC().String()
+		}
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	last, seen := n.registrations[node.Name]
+	// A node not ready keeps its last registration, it restarted once it is ready again
+	if current.readySince == "" {
+		current.readySince = last.readySince
+	}
+	n.registrations[node.Name] = current
+	restarted := seen && current != last
+	if restarted {
+		kubeletRestartsTotal.Inc()
+	}
+	return restarted
+}
+
+// Delete forgets a deleted node
+func (n *NodeRegistration) Delete(nodeName string) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.registrations, nodeName)
+}
+
+// hasNodeCondition reports whether a node reports a condition as True
+func hasNodeCondition(node *corev1.Node, conditionType corev1.NodeConditionType) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/api/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// maxSyncErrors is the number of failed syncs kept in a NodeNautobotSync
+const maxSyncErrors = 10
+
+// NodeSyncResources maintains a NodeNautobotSync object per node, a kubectl-queryable record of
+// its sync that outlives events.
+type NodeSyncResources struct {
+	Client         client.Client
+	NautobotClient nautobot.Interface
+}
+
+// Update records a sync of the node. Lookup details are left untouched when deviceData is nil
+// and the sync did not fail in the lookup.
+func (s *NodeSyncResources) Update(ctx context.Context, node *corev1.Node, deviceData *nautobot.DeviceData, appliedLabels map[string]string, syncErr error) error {
+	if s == nil {
+		return nil
+	}
+
+	var record v1alpha1.NodeNautobotSync
+	if err := s.Client.Get(ctx, client.ObjectKey{Name: node.Name}, &record); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get NodeNautobotSync: %w", err)
+		}
+		record = v1alpha1.NodeNautobotSync{ObjectMeta: metav1.ObjectMeta{
+			Name: node.Name,
+			// Owned by the node so it is garbage collected when the node goes away
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "v1",
+				Kind:       "Node",
+				Name:       node.Name,
+				UID:        node.UID,
+			}},
+		}}
+		if err := s.Client.Create(ctx, &record); err != nil {
+			return fmt.Errorf("failed to create NodeNautobotSync: %w", err)
+		}
+	}
+
+	now := metav1.NewTime(time.Now())
+	status := &record.Status
+	status.LastSyncTime = &now
+	switch {
+	case deviceData != nil:
+		status.LookupResult = v1alpha1.LookupResultFound
+		status.DeviceName = deviceData.Name
+		status.DeviceURL = ""
+		// Devices of the static devices file have no Nautobot ID
+		if deviceData.ID != "" {
+			status.DeviceURL = s.NautobotClient.DeviceURL(deviceData.ID)
+		}
+		status.AppliedLabels = appliedLabels
+	case errors.Is(syncErr, nautobot.ErrDeviceNotFound):
+		status.LookupResult = v1alpha1.LookupResultNotFound
+		status.DeviceName, status.DeviceURL = "", ""
+	case syncErr != nil:
+		status.LookupResult = v1alpha1.LookupResultError
+	}
+	if syncErr != nil {
+		status.Errors = append([]v1alpha1.SyncError{{Time: now, Message: redact.String(syncErr.Error())}}, status.Errors...)
+		if len(status.Errors) > maxSyncErrors {
+			status.Errors = status.Errors[:maxSyncErrors]
+		}
+	}
+
+	if err := s.Client.Status().Update(ctx, &record); err != nil {
+		return fmt.Errorf("failed to update NodeNautobotSync status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// NodeWebhookPath is where the API server sends Node admission requests
+const NodeWebhookPath = "/mutate-node"
+
+var nodeWebhookRequestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_node_webhook_requests_total",
+		Help: "Number of Node creations seen by the admission webhook, by result (updated, unchanged, skipped, error).",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(nodeWebhookRequestsTotal)
+}
+
+// NodeLabelWebhook is a mutating admission webhook labeling nodes as they register, so pods are
+// never scheduled onto a node before its topology labels are set. It looks the device up
+// synchronously like a reconcile would, within Timeout, and fails open: nodes whose lookup fails
+// or takes too long are admitted unchanged and labeled by the reconciler later.
+type NodeLabelWebhook struct {
+	// Reconciler provides the device lookup, mappings, conflict policy and audit sink
+	Reconciler *NodeReconciler
+	// Timeout bounds the device lookup, below the webhook timeout of the API server
+	Timeout time.Duration
+}
+
+// Handle implements admission.Handler
+func (w *NodeLabelWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	logger := log.FromContext(ctx).WithName("node-webhook")
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+	var node corev1.Node
+	if err := json.Unmarshal(req.Object.Raw, &node); err != nil {
+		nodeWebhookRequestsTotal.WithLabelValues(ResultError).Inc()
+		return admission.Allowed("failed to decode node, admitted unchanged")
+	}
+	config := w.Reconciler.Config.Current()
+	if !w.Reconciler.Shard.Owns(node.Name) || !config.selector.Matches(labels.Set(node.Labels)) {
+		nodeWebhookRequestsTotal.WithLabelValues(ResultSkipped).Inc()
+		return admission.Allowed("")
+	}
+
+	deviceData, err := w.lookup(ctx, &node)
+	if err != nil {
+		logger.Info("Admitting node without labels, the reconciler labels it later", "NodeName", node.Name, "Error", err.Error())
+		nodeWebhookRequestsTotal.WithLabelValues(ResultError).Inc()
+		return admission.Allowed("device lookup failed, admitted without labels")
+	}
+	deviceData = config.CompleteDevice(deviceData)
+	desired, err := mapping.Render(config.mappings, deviceData, w.Reconciler.ClusterName)
+	if err != nil {
+		logger.Info("Admitting node without labels, the reconciler labels it later", "NodeName", node.Name, "Error", err.Error())
+		nodeWebhookRequestsTotal.WithLabelValues(ResultError).Inc()
+		return admission.Allowed("failed to map device data to labels, admitted without labels")
+	}
+	GuardZoneLabel(&node, desired, w.Reconciler.AllowZoneChanges)
+	plan := PlanLabels(&node, desired, w.Reconciler.ConflictPolicy, deviceData.Name)
+	if len(plan.Changes) == 0 {
+		nodeWebhookRequestsTotal.WithLabelValues(ResultUnchanged).Inc()
+		return admission.Allowed("")
+	}
+	if _, frozen := config.FrozenUntil(time.Now()); frozen {
+		changesDeferredTotal.WithLabelValues("freeze_window").Inc()
+		nodeWebhookRequestsTotal.WithLabelValues(ResultDeferred).Inc()
+		return admission.Allowed("freeze window, admitted without labels")
+	}
+
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+	for _, change := range plan.Changes {
+		node.Labels[change.Key] = change.NewValue
+	}
+	recordLabelHistory(&node, plan.Changes, w.Reconciler.LabelHistory, time.Now())
+	// Recorded like a reconcile would, so the reconciler sees the labels as its own
+	raw, err := json.Marshal(plan.Applied)
+	if err != nil {
+		nodeWebhookRequestsTotal.WithLabelValues(ResultError).Inc()
+		return admission.Allowed("failed to encode applied labels, admitted without labels")
+	}
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[LastAppliedLabelsAnnotation] = string(raw)
+	mutated, err := json.Marshal(&node)
+	if err != nil {
+		nodeWebhookRequestsTotal.WithLabelValues(ResultError).Inc()
+		return admission.Allowed("failed to encode node, admitted without labels")
+	}
+
+	logger.Info("Labeling node at registration", "NodeName", node.Name, "Site", deviceData.SiteName, "Rack", deviceData.RackName)
+	nodeWebhookRequestsTotal.WithLabelValues(ResultUpdated).Inc()
+	w.Reconciler.recordChanges(ctx, plan.Changes)
+	return admission.PatchResponseFromRaw(req.Object.Raw, mutated)
+}
+
+// lookup finds the device of a node like Reconcile, giving up after Timeout. A lookup that
+// times out still completes in the background and warms the client's caches.
+func (w *NodeLabelWebhook) lookup(ctx context.Context, node *corev1.Node) (*nautobot.DeviceData, error) {
+	if stored := w.Reconciler.DeviceStore.Lookup(node, w.Reconciler.LookupKey); stored != nil {
+		return stored, nil
+	}
+	type result struct {
+		deviceData *nautobot.DeviceData
+		err        error
+	}
+	done := make(chan result, 1)
+	lookupCtx := context.WithoutCancel(ctx)
+	go func() {
+		deviceData, err := LookupDevice(lookupCtx, w.Reconciler.deviceSource(), node, w.Reconciler.LookupKey)
+		if config := w.Reconciler.Config.Current(); errors.Is(err, nautobot.ErrDeviceNotFound) && config.VirtualMachines != nil {
+			deviceData, err = LookupVirtualMachine(lookupCtx, w.Reconciler.NautobotClient, config, node, w.Reconciler.LookupKey)
+		}
+		done <- result{deviceData, err}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, w.Timeout)
+	defer cancel()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-done:
+		return result.deviceData, result.err
+	}
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var nodeWritesWaiting = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "nautobot_labeler_node_writes_waiting",
+		Help: "Number of node label writes waiting for the next write batch.",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(nodeWritesWaiting)
+}
+
+// NodeWriteThrottle applies node writes in batches of BatchSize, at most one batch every
+// BatchSize / Rate seconds, so relabeling thousands of nodes after a mapping change is spread
+// out instead of hitting the API server all at once. Full batches are logged with the writes
+// still waiting.
+type NodeWriteThrottle struct {
+	// Rate is the maximum average number of writes per second
+	Rate float64
+	// BatchSize is the number of writes applied together
+	BatchSize int
+
+	mu sync.Mutex
+	// batchEnd is when the current batch's period ends and the next batch may start
+	batchEnd time.Time
+	// inBatch is the number of writes of the current batch
+	inBatch int
+	// total is the number of writes since startup
+	total int
+	// waiting is the number of writes waiting for a later batch
+	waiting int
+}
+
+// NewNodeWriteThrottle returns a throttle of batchSize writes at rate writes per second
+func NewNodeWriteThrottle(rate float64, batchSize int) *NodeWriteThrottle {
+	return &NodeWriteThrottle{Rate: rate, BatchSize: batchSize}
+}
+
+// Wait blocks until a write fits into a batch or ctx is done. A nil NodeWriteThrottle never
+// blocks.
+func (t *NodeWriteThrottle) Wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	period := time.Duration(float64(t.BatchSize) / t.Rate * float64(time.Second))
+	counted := false
+	for {
+		t.mu.Lock()
+		now := time.Now()
+		if !now.Before(t.batchEnd) {
+			if t.inBatch >= t.BatchSize {
+				log.FromContext(ctx).WithName("node-writes").Info("Applied a batch of node writes",
+					"Writes", t.inBatch, "TotalWrites", t.total, "Waiting", t.waiting)
+			}
+			t.batchEnd, t.inBatch = now.Add(period), 0
+		}
+		if t.inBatch < t.BatchSize {
+			t.inBatch++
+			t.total++
+			if counted {
+				t.waiting--
+				nodeWritesWaiting.Dec()
+			}
+			t.mu.Unlock()
+			return nil
+		}
+		if !counted {
+			counted = true
+			t.waiting++
+			nodeWritesWaiting.Inc()
+		}
+		delay := t.batchEnd.Sub(now)
+		t.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			t.mu.Lock()
+			t.waiting--
+			nodeWritesWaiting.Dec()
+			t.mu.Unlock()
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
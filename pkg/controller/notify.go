@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// FailureNotifier posts a message to a webhook (Slack-compatible) once a node has failed to
+// sync Threshold times in a row, so inventory mismatches reach a human quickly.
+type FailureNotifier struct {
+	// WebhookURL receives a JSON payload of the form {"text": "..."}
+	WebhookURL string
+	// Threshold is the number of consecutive failures that triggers a notification
+	Threshold int
+
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+// NewFailureNotifier returns a FailureNotifier posting to webhookURL
+func NewFailureNotifier(webhookURL string, threshold int) *FailureNotifier {
+	// The URL of Slack-compatible webhooks is their credential
+	redact.Register(webhookURL)
+	return &FailureNotifier{
+		WebhookURL: webhookURL,
+		Threshold:  threshold,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		failures:   map[string]int{},
+	}
+}
+
+// RecordFailure counts a failed sync and notifies once when the streak reaches the threshold.
+func (n *FailureNotifier) RecordFailure(ctx context.Context, nodeName string, cause error) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	n.failures[nodeName]++
+	count := n.failures[nodeName]
+	n.mu.Unlock()
+
+	if count != n.Threshold {
+		return
+	}
+
+	text := redact.String(fmt.Sprintf("nautobot-node-labeler: node %s failed to sync %d times in a row: %v", nodeName, count, cause))
+	// Post in the background so a slow webhook never holds up reconciles
+	go func() {
+		if err := n.post(text); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to send failure notification", "NodeName", nodeName)
+		}
+	}()
+}
+
+// RecordSuccess resets the failure streak of a node
+func (n *FailureNotifier) RecordSuccess(nodeName string) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.failures, nodeName)
+}
+
+// post sends a notification message to the webhook
+func (n *FailureNotifier) post(text string) error {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	resp, err := n.httpClient.Post(n.WebhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to contact notification webhook: %w", redact.Error(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notification webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+)
+
+var infrastructureGVK = schema.GroupVersionKind{Group: "config.openshift.io", Version: "v1", Kind: "Infrastructure"}
+
+// openShiftManagedLabelPrefixes are the node labels owned by OpenShift: node roles select the
+// MachineConfigPools of the Machine Config Operator, and the operator and the Machine API keep
+// labels of their own
+var openShiftManagedLabelPrefixes = []string{
+	"node-role.kubernetes.io/",
+	"machineconfiguration.openshift.io/",
+	"machine.openshift.io/",
+	"node.openshift.io/",
+}
+
+// openShiftManagedLabel reports whether OpenShift owns a node label
+func openShiftManagedLabel(label string) bool {
+	for _, prefix := range openShiftManagedLabelPrefixes {
+		if strings.HasPrefix(label, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// DropOpenShiftManagedMappings removes the mappings of labels owned by OpenShift, so the
+// controller never writes or checks them, and returns the labels removed
+func DropOpenShiftManagedMappings(config *configv1alpha1.LabelerConfiguration) []string {
+	var dropped []string
+	mappings := config.Mappings[:0:0]
+	for _, mapping := range config.Mappings {
+		if openShiftManagedLabel(mapping.Label) {
+			dropped = append(dropped, mapping.Label)
+			continue
+		}
+		mappings = append(mappings, mapping)
+	}
+	config.Mappings = mappings
+	return dropped
+}
+
+// OpenShiftClusterName returns the infrastructure name of an OpenShift cluster, the identity
+// the installer gave it, from the cluster-wide Infrastructure resource
+func OpenShiftClusterName(ctx context.Context, reader client.Reader) (string, error) {
+	infrastructure := &unstructured.Unstructured{}
+	infrastructure.SetGroupVersionKind(infrastructureGVK)
+	if err := reader.Get(ctx, client.ObjectKey{Name: "cluster"}, infrastructure); err != nil {
+		return "", fmt.Errorf("failed to get OpenShift infrastructure: %w", err)
+	}
+	name, _, err := unstructured.NestedString(infrastructure.Object, "status", "infrastructureName")
+	if err != nil {
+		return "", fmt.Errorf("failed to read OpenShift infrastructure name: %w", err)
+	}
+	if name == "" {
+		return "", fmt.Errorf("OpenShift infrastructure has no infrastructure name")
+	}
+	return name, nil
+}
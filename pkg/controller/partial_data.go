@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+)
+
+// MissingLabelsAnnotation lists the mapped labels the device of a node had no data for at the
+// last lookup, e.g. the rack of a device not mounted in one, comma-separated
+const MissingLabelsAnnotation = "nautobot.io/missing-labels"
+
+var partialDataNodes = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nautobot_labeler_partial_data_nodes",
+		Help: "Number of nodes whose device had no data for a mapped label at the last lookup, by label.",
+	},
+	[]string{"label"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(partialDataNodes)
+}
+
+// PartialData tracks the nodes whose device lacked data for some mapped labels. Those labels
+// count as present until the node's next check with intervals.partial, so nodes with partial
+// data are not looked up again at every event.
+type PartialData struct {
+	mu    sync.Mutex
+	nodes map[string]partialState
+}
+
+// partialState is what PartialData knows about a node
+type partialState struct {
+	missing []string
+	next    time.Time
+}
+
+// NewPartialData returns a PartialData that knows no nodes yet
+func NewPartialData() *PartialData {
+	return &PartialData{nodes: map[string]partialState{}}
+}
+
+// Record sets the labels a lookup had no data for, none clearing the node, and when to check
+// it again. A nil PartialData ignores it.
+func (p *PartialData) Record(nodeName string, missing []string, interval time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.forget(nodeName)
+	if len(missing) == 0 {
+		return
+	}
+	p.nodes[nodeName] = partialState{missing: missing, next: time.Now().Add(interval)}
+	for _, label := range missing {
+		partialDataNodes.WithLabelValues(label).Inc()
+	}
+}
+
+// Pending returns the missing labels of a node until its next check is due, nil for nodes
+// that are due or have complete data. A nil PartialData knows no nodes.
+func (p *PartialData) Pending(nodeName string) []string {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state, ok := p.nodes[nodeName]
+	if !ok || !time.Now().Before(state.next) {
+		return nil
+	}
+	return state.missing
+}
+
+// Delete forgets a node. A nil PartialData ignores it.
+func (p *PartialData) Delete(nodeName string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.forget(nodeName)
+}
+
+// forget drops a node and its metrics. Callers hold mu.
+func (p *PartialData) forget(nodeName string) {
+	state, ok := p.nodes[nodeName]
+	if !ok {
+		return
+	}
+	for _, label := range state.missing {
+		partialDataNodes.WithLabelValues(label).Dec()
+	}
+	delete(p.nodes, nodeName)
+}
+
+// missingLabels returns the sorted keys of the desired labels that rendered empty or fell back
+// to their default
+func missingLabels(desired []mapping.Value) []string {
+	var missing []string
+	for _, label := range desired {
+		if label.Value == "" || label.Defaulted {
+			missing = append(missing, label.Key)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// applyMissingLabelsAnnotation sets the missing labels annotation of a node, removing it when
+// no label is missing, and returns the change
+func applyMissingLabelsAnnotation(node *corev1.Node, missing []string, device string) []AuditRecord {
+	current, value := node.Annotations[MissingLabelsAnnotation], strings.Join(missing, ",")
+	if current == value {
+		return nil
+	}
+	change := AuditRecord{
+		Node:     node.Name,
+		Kind:     "annotation",
+		Key:      MissingLabelsAnnotation,
+		OldValue: current,
+		NewValue: value,
+		Device:   device,
+	}
+	if value == "" {
+		delete(node.Annotations, MissingLabelsAnnotation)
+	} else {
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[MissingLabelsAnnotation] = value
+	}
+	return []AuditRecord{change}
+}
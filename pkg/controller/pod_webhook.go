@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/prometheus/client_golang/prometheus"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// PodWebhookPath is where the API server sends Pod and pods/binding admission requests
+const PodWebhookPath = "/mutate-pod"
+
+var podWebhookRequestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_pod_webhook_requests_total",
+		Help: "Number of Pod creations and bindings seen by the pod topology webhook, by result (updated, unchanged, skipped, error).",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(podWebhookRequestsTotal)
+}
+
+// PodTopologyWebhook is a mutating admission webhook copying the managed labels of a node onto
+// its pods, so log pipelines and cost tooling can group pods by topology without joining them
+// against nodes. Pods created with a node name, e.g. static and DaemonSet pods, get the labels
+// as labels. Pods placed by the scheduler get them as annotations: the API server copies the
+// annotations of a pods/binding, but not its labels, onto the pod. Like the node webhook it
+// fails open, admitting pods unchanged when the node cannot be read.
+type PodTopologyWebhook struct {
+	// Client reads nodes, usually from the manager's cache
+	Client client.Reader
+	// Config provides the mappings whose labels are copied
+	Config *ConfigStore
+	// MetadataOnly reads node metadata only, for --minimal-permissions
+	MetadataOnly bool
+}
+
+// Handle implements admission.Handler
+func (w *PodTopologyWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1.Create {
+		return admission.Allowed("")
+	}
+	switch req.SubResource {
+	case "":
+		var pod corev1.Pod
+		if err := json.Unmarshal(req.Object.Raw, &pod); err != nil {
+			podWebhookRequestsTotal.WithLabelValues(ResultError).Inc()
+			return admission.Allowed("failed to decode pod, admitted unchanged")
+		}
+		if pod.Spec.NodeName == "" {
+			// Labeled as annotations at binding
+			podWebhookRequestsTotal.WithLabelValues(ResultSkipped).Inc()
+			return admission.Allowed("")
+		}
+		return w.mutate(ctx, req, pod.Name, &pod.Labels, pod.Spec.NodeName, &pod)
+	case "binding":
+		var binding corev1.Binding
+		if err := json.Unmarshal(req.Object.Raw, &binding); err != nil {
+			podWebhookRequestsTotal.WithLabelValues(ResultError).Inc()
+			return admission.Allowed("failed to decode binding, admitted unchanged")
+		}
+		return w.mutate(ctx, req, binding.Name, &binding.Annotations, binding.Target.Name, &binding)
+	default:
+		return admission.Allowed("")
+	}
+}
+
+// mutate adds the managed labels of a node missing in target, the label or annotation map of
+// the pod or binding obj, and returns the patch of obj
+func (w *PodTopologyWebhook) mutate(ctx context.Context, req admission.Request, name string, target *map[string]string, nodeName string, obj interface{}) admission.Response {
+	logger := log.FromContext(ctx).WithName("pod-webhook")
+	node, err := getNodeMetadata(ctx, w.Client, nodeName, w.MetadataOnly)
+	if err != nil {
+		logger.Info("Admitting pod without topology labels", "Pod", req.Namespace+"/"+name, "NodeName", nodeName, "Error", err.Error())
+		podWebhookRequestsTotal.WithLabelValues(ResultError).Inc()
+		return admission.Allowed("failed to read node, admitted without topology labels")
+	}
+
+	changed := false
+	for _, mapping := range w.Config.Current().mappings {
+		value, ok := node.Labels[mapping.Label]
+		if !ok {
+			continue
+		}
+		// Labels set on the pod itself win
+		if _, set := (*target)[mapping.Label]; set {
+			continue
+		}
+		if *target == nil {
+			*target = map[string]string{}
+		}
+		(*target)[mapping.Label] = value
+		changed = true
+	}
+	if !changed {
+		podWebhookRequestsTotal.WithLabelValues(ResultUnchanged).Inc()
+		return admission.Allowed("")
+	}
+
+	mutated, err := json.Marshal(obj)
+	if err != nil {
+		podWebhookRequestsTotal.WithLabelValues(ResultError).Inc()
+		return admission.Allowed("failed to encode pod, admitted without topology labels")
+	}
+	podWebhookRequestsTotal.WithLabelValues(ResultUpdated).Inc()
+	return admission.PatchResponseFromRaw(req.Object.Raw, mutated)
+}
+
+// getNodeMetadata reads the metadata of a node, with a metadata-only request if metadataOnly
+func getNodeMetadata(ctx context.Context, reader client.Reader, name string, metadataOnly bool) (*metav1.ObjectMeta, error) {
+	if metadataOnly {
+		var node metav1.PartialObjectMetadata
+		node.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+		if err := reader.Get(ctx, client.ObjectKey{Name: name}, &node); err != nil {
+			return nil, err
+		}
+		return &node.ObjectMeta, nil
+	}
+
+	var node corev1.Node
+	if err := reader.Get(ctx, client.ObjectKey{Name: name}, &node); err != nil {
+		return nil, err
+	}
+	return &node.ObjectMeta, nil
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// failedPowerFeeds returns the names of the power feeds of a device's rack in a failed status
+func failedPowerFeeds(power *configv1alpha1.PowerRedundancyConfig, device *nautobot.DeviceData) []string {
+	var failed []string
+	for _, feed := range device.PowerFeeds {
+		for _, status := range power.FailedStatuses {
+			if strings.EqualFold(feed.Status, status) {
+				failed = append(failed, feed.Name)
+				break
+			}
+		}
+	}
+	return failed
+}
+
+// applyPowerRedundancyTaint taints a node while a power feed of its rack is in a failed status
+// and removes the taint once redundancy is restored, returning the changes. Taints of the key
+// with another effect, e.g. from before the effect was reconfigured, are replaced. Without power,
+// i.e. once the feature was turned off, the taint of the default key is removed.
+func applyPowerRedundancyTaint(node *corev1.Node, power *configv1alpha1.PowerRedundancyConfig, device *nautobot.DeviceData) []AuditRecord {
+	lost := false
+	want := corev1.Taint{Key: configv1alpha1.PowerRedundancyTaint}
+	if power != nil {
+		lost = len(failedPowerFeeds(power, device)) > 0
+		want = corev1.Taint{Key: power.TaintKey, Effect: corev1.TaintEffect(power.TaintEffect)}
+	}
+
+	var changes []AuditRecord
+	record := func(taint corev1.Taint, oldValue, newValue string) {
+		changes = append(changes, AuditRecord{
+			Node:     node.Name,
+			Kind:     "taint",
+			Key:      taint.Key + ":" + string(taint.Effect),
+			OldValue: oldValue,
+			NewValue: newValue,
+			Device:   device.Name,
+		})
+	}
+	tainted := false
+	taints := make([]corev1.Taint, 0, len(node.Spec.Taints)+1)
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == want.Key {
+			if lost && taint.Effect == want.Effect {
+				tainted = true
+			} else {
+				record(taint, taint.ToString(), "")
+				continue
+			}
+		}
+		taints = append(taints, taint)
+	}
+	if lost && !tainted {
+		record(want, "", want.ToString())
+		taints = append(taints, want)
+	}
+	if len(changes) > 0 {
+		node.Spec.Taints = taints
+	}
+	return changes
+}
+
+// hasStalePowerRedundancyTaint reports whether a node keeps the power redundancy taint of the
+// default key although the feature is off
+func hasStalePowerRedundancyTaint(node *corev1.Node, power *configv1alpha1.PowerRedundancyConfig) bool {
+	if power != nil {
+		return false
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == configv1alpha1.PowerRedundancyTaint {
+			return true
+		}
+	}
+	return false
+}
+
+// recordPowerRedundancyLost emits a Warning event on a node tainted for the failed power feeds
+// of its rack
+func recordPowerRedundancyLost(recorder record.EventRecorder, node *corev1.Node, power *configv1alpha1.PowerRedundancyConfig, device *nautobot.DeviceData) {
+	if recorder == nil {
+		return
+	}
+	recorder.Eventf(node, corev1.EventTypeWarning, "PowerRedundancyLost",
+		"Rack %s lost redundant power (failed feeds: %s); tainted %s", device.RackName,
+		strings.Join(failedPowerFeeds(power, device), ", "), power.TaintKey)
+}
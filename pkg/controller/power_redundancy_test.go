@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+func TestApplyPowerRedundancyTaint(t *testing.T) {
+	power := &configv1alpha1.PowerRedundancyConfig{
+		TaintKey:       configv1alpha1.PowerRedundancyTaint,
+		TaintEffect:    string(corev1.TaintEffectPreferNoSchedule),
+		FailedStatuses: []string{"failed"},
+	}
+	tainted := corev1.Taint{Key: configv1alpha1.PowerRedundancyTaint, Effect: corev1.TaintEffectPreferNoSchedule}
+	other := corev1.Taint{Key: "example.com/other", Effect: corev1.TaintEffectNoSchedule}
+	failed := []nautobot.PowerFeed{{Name: "feed-a", Status: "active"}, {Name: "feed-b", Status: "failed"}}
+	redundant := []nautobot.PowerFeed{{Name: "feed-a", Status: "active"}, {Name: "feed-b", Status: "active"}}
+
+	tests := []struct {
+		name        string
+		power       *configv1alpha1.PowerRedundancyConfig
+		feeds       []nautobot.PowerFeed
+		taints      []corev1.Taint
+		wantTaints  []corev1.Taint
+		wantChanges int
+	}{
+		{name: "feed failed", power: power, feeds: failed, taints: []corev1.Taint{other}, wantTaints: []corev1.Taint{other, tainted}, wantChanges: 1},
+		{name: "already tainted", power: power, feeds: failed, taints: []corev1.Taint{tainted}, wantTaints: []corev1.Taint{tainted}},
+		{name: "redundancy restored", power: power, feeds: redundant, taints: []corev1.Taint{tainted, other}, wantTaints: []corev1.Taint{other}, wantChanges: 1},
+		{name: "feature turned off", feeds: failed, taints: []corev1.Taint{other, tainted}, wantTaints: []corev1.Taint{other}, wantChanges: 1},
+		{name: "feature off without taint", feeds: failed, taints: []corev1.Taint{other}, wantTaints: []corev1.Taint{other}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &corev1.Node{Spec: corev1.NodeSpec{Taints: tt.taints}}
+			changes := applyPowerRedundancyTaint(node, tt.power, &nautobot.DeviceData{PowerFeeds: tt.feeds})
+			if len(changes) != tt.wantChanges {
+				t.Errorf("applyPowerRedundancyTaint() made %d changes, want %d: %v", len(changes), tt.wantChanges, changes)
+			}
+			if len(node.Spec.Taints) != len(tt.wantTaints) {
+				t.Fatalf("taints = %v, want %v", node.Spec.Taints, tt.wantTaints)
+			}
+			for i, taint := range node.Spec.Taints {
+				if !taint.MatchTaint(&tt.wantTaints[i]) {
+					t.Errorf("taints = %v, want %v", node.Spec.Taints, tt.wantTaints)
+				}
+			}
+			if stale := hasStalePowerRedundancyTaint(node, tt.power); stale {
+				t.Errorf("hasStalePowerRedundancyTaint() = true after applying")
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var prometheusRuleGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "PrometheusRule"}
+
+// PrometheusRules maintains a Prometheus Operator PrometheusRule with the recommended alerts on
+// the controller's metrics, so the alerts change along with the metrics the binary exposes.
+// Changes made to the object by hand are overwritten at the next interval.
+type PrometheusRules struct {
+	Client    client.Client
+	Namespace string
+	Name      string
+	// Labels are added to the object, e.g. for the ruleSelector of the Prometheus instance
+	Labels map[string]string
+	// MissingLabelsFor is how long nodes may lack a device or labels before it alerts
+	MissingLabelsFor time.Duration
+	// Interval is the time between two updates
+	Interval time.Duration
+}
+
+// Start implements manager.Runnable
+func (p *PrometheusRules) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("prometheus-rules")
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.apply(ctx); err != nil {
+			logger.Error(err, "Failed to maintain PrometheusRule", "Namespace", p.Namespace, "Name", p.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// apply creates the PrometheusRule if needed and resets its labels and rules if they differ
+func (p *PrometheusRules) apply(ctx context.Context) error {
+	labels := map[string]string{managedByLabel: "nautobot-node-labeler"}
+	for key, value := range p.Labels {
+		labels[key] = value
+	}
+	spec := map[string]interface{}{
+		"groups": []interface{}{map[string]interface{}{
+			"name":  "nautobot-node-labeler",
+			"rules": p.rules(),
+		}},
+	}
+
+	rule := &unstructured.Unstructured{}
+	rule.SetGroupVersionKind(prometheusRuleGVK)
+	if err := p.Client.Get(ctx, client.ObjectKey{Namespace: p.Namespace, Name: p.Name}, rule); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get PrometheusRule: %w", err)
+		}
+		rule.SetNamespace(p.Namespace)
+		rule.SetName(p.Name)
+		rule.SetLabels(labels)
+		rule.Object["spec"] = spec
+		if err := p.Client.Create(ctx, rule); err != nil {
+			return fmt.Errorf("failed to create PrometheusRule: %w", err)
+		}
+		return nil
+	}
+
+	current := rule.GetLabels()
+	labelsDiffer := false
+	for key, value := range labels {
+		if current[key] != value {
+			labelsDiffer = true
+		}
+	}
+	if !labelsDiffer && reflect.DeepEqual(rule.Object["spec"], spec) {
+		return nil
+	}
+	original := rule.DeepCopy()
+	if current == nil {
+		current = map[string]string{}
+	}
+	for key, value := range labels {
+		current[key] = value
+	}
+	rule.SetLabels(current)
+	rule.Object["spec"] = spec
+	if err := p.Client.Patch(ctx, rule, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("failed to update PrometheusRule: %w", err)
+	}
+	return nil
+}
+
+// rules returns the alerting rules, as unstructured values of strings so they compare equal to
+// the rules read back from the API server
+func (p *PrometheusRules) rules() []interface{} {
+	missingFor := promDuration(p.MissingLabelsFor)
+	return []interface{}{
+		alertRule("NautobotLabelerNautobotUnreachable", "warning", "10m",
+			`sum(rate(nautobot_labeler_nautobot_requests_total{code=~"error|401|403|5.."}[5m])) / sum(rate(nautobot_labeler_nautobot_requests_total[5m])) > 0.5`,
+			"Nautobot is unreachable from the node labeler",
+			"{{ $value | humanizePercentage }} of the Nautobot API requests of the node labeler fail with connection, authentication or server errors; node labels are not kept up to date."),
+		alertRule("NautobotLabelerNodesWithoutDevice", "warning", missingFor,
+			`sum(nautobot_labeler_nodes_missing_in_nautobot) > 0`,
+			"Nodes have no device in Nautobot",
+			"{{ $value }} nodes have had no matching Nautobot device for "+missingFor+" and carry none of the Nautobot labels; see /debug/missing-nodes."),
+		alertRule("NautobotLabelerNodesMissingLabels", "warning", missingFor,
+			`sum by (label) (nautobot_labeler_partial_data_nodes) > 0`,
+			"Nodes are missing the label {{ $labels.label }}",
+			"The Nautobot devices of {{ $value }} nodes have had no data for the label {{ $labels.label }} for "+missingFor+"."),
+		alertRule("NautobotLabelerDriftDetected", "info", "",
+			`sum by (field) (increase(nautobot_labeler_conflicts_total[1h])) > 0`,
+			"Cluster and Nautobot disagree on {{ $labels.field }}",
+			"{{ $value }} conflicts between the cluster's and Nautobot's value of {{ $labels.field }} in the last hour; one side drifted from the other."),
+	}
+}
+
+// alertRule returns an alerting rule of a PrometheusRule, pending for as long as forDuration
+// unless it is empty
+func alertRule(name, severity, forDuration, expr, summary, description string) map[string]interface{} {
+	rule := map[string]interface{}{
+		"alert":  name,
+		"expr":   expr,
+		"labels": map[string]interface{}{"severity": severity},
+		"annotations": map[string]interface{}{
+			"summary":     summary,
+			"description": description,
+		},
+	}
+	if forDuration != "" {
+		rule["for"] = forDuration
+	}
+	return rule
+}
+
+// promDuration formats a duration the way Prometheus parses it, e.g. "30m" for 30 minutes
+func promDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	default:
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+}
@@ -0,0 +1,798 @@
+// Package controller reconciles Kubernetes nodes with their Nautobot devices: the node
+// reconciler, its configuration and the optional components wired into it
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// Topology labels managed by the controller
+const (
+	zoneLabel = "topology.kubernetes.io/zone"
+	rackLabel = "topology.kubernetes.io/rack"
+)
+
+// NodeReconciler is our custom reconciler that will label Nodes with info from Nautobot.
+type NodeReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	NautobotClient nautobot.Interface
+	// Source, if set, looks devices up instead of NautobotClient
+	Source DeviceSource
+	// LookupKey selects the names nodes are matched to devices by, their object names by default
+	LookupKey LookupKey
+	Recorder  record.EventRecorder
+	// ConflictPolicy decides whether out-of-band label changes are kept or overwritten
+	ConflictPolicy ConflictPolicy
+	// LabelHistory is the number of label changes kept in the LabelHistoryAnnotation, none if 0
+	LabelHistory int
+	// AllowZoneChanges lets Nautobot change the zone label of nodes that already have one
+	AllowZoneChanges bool
+	// ZoneVolumes, if set, checks the volumes of a node's pods before its zone label changes
+	ZoneVolumes *ZoneVolumeCheck
+	// Disruptions, if set, checks PodDisruptionBudgets and the rack of a node before NoExecute
+	// taints are added to it
+	Disruptions *DisruptionGuard
+	// Registration, if set, holds the first sync of nodes until they are ready and checks nodes
+	// again after kubelet restarts
+	Registration *NodeRegistration
+	// MissingNodes tracks nodes without a Nautobot device
+	MissingNodes *MissingNodes
+	// Rollout, if set, paces changes of existing label values with the rollout settings
+	Rollout *Rollout
+	// PartialData, if set, spares nodes whose device lacks data for some labels the lookup at
+	// every reconcile until intervals.partial elapsed
+	PartialData *PartialData
+	// AuditSink, if set, receives a record of every label change
+	AuditSink AuditSink
+	// Notifier, if set, is told about failed and successful syncs
+	Notifier *FailureNotifier
+	// Config provides the mappings, intervals and node selector
+	Config *ConfigStore
+	// SyncRecords keeps the last sync details of every node for the debug API
+	SyncRecords *SyncRecords
+	// RecentErrors, if set, keeps the last reconcile errors for the debug API
+	RecentErrors *RecentErrors
+	// SyncResources, if set, maintains a NodeNautobotSync object per node
+	SyncResources *NodeSyncResources
+	// ForceLookup consults Nautobot even for nodes that already carry all labels
+	ForceLookup bool
+	// Startup, if set, holds Nautobot lookups until Nautobot answered once
+	Startup *NautobotStartupGate
+	// ClusterName is available to label templates as .ClusterName
+	ClusterName string
+	// MetadataOnly watches only node metadata and writes labels with patches, so nodes need no
+	// update permission
+	MetadataOnly bool
+	// BulkResync, if set, triggers periodic reconciles of all nodes with prefetched devices
+	BulkResync *BulkResync
+	// RulesResync, if set, triggers reconciles of all nodes after the labeling rules changed
+	RulesResync *RulesResync
+	// DeviceStore, if set, answers lookups locally; devices missing in it are looked up on demand
+	DeviceStore *DeviceStore
+	// LookupTimeout, if set, bounds the device lookup of a reconcile, all its requests included
+	LookupTimeout time.Duration
+	// MaxConcurrentReconciles is the number of nodes reconciled in parallel, 1 if unset
+	MaxConcurrentReconciles int
+	// Scheduler, if set, schedules the Nautobot checks of every node, also of nodes that
+	// carry all labels
+	Scheduler *AdaptiveScheduler
+	// LabelRefresh, if set, checks nodes at the refresh intervals of their mappings, also nodes
+	// that carry all labels
+	LabelRefresh *LabelRefresh
+	// Shard selects the nodes reconciled by this replica
+	Shard Shard
+	// WriteThrottle, if set, spreads node writes out in batches
+	WriteThrottle *NodeWriteThrottle
+	// NodeFeatures, if set, receives the labels of nodes instead of the nodes themselves
+	NodeFeatures *NodeFeatures
+	// CloudFallback, if set, labels cloud instances without a Nautobot device from their zone
+	CloudFallback *CloudFallback
+	// MappingPlugin, if set, computes the desired labels and taints from the rendered labels. The
+	// plugin is asked on every reconcile, the lookup is never skipped for nodes with all labels.
+	MappingPlugin *MappingPlugin
+	// DNSNames, if set, annotates nodes with the DNS name of their device's primary IP
+	DNSNames *DNSNames
+	// Machines, if set, propagates the site and rack of nodes to their Cluster API Machines
+	Machines *MachineAnnotations
+	// MemberCluster names the member cluster the nodes belong to, empty for the controller's own
+	// cluster
+	MemberCluster string
+}
+
+// Reconcile is where we apply the logic to label the Node from Nautobot data.
+func (r *NodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	logger.Info("Reconciling Node", "NodeName", req.Name)
+
+	ctx, span := startSpan(ctx, "Reconcile", req.Name)
+	started := time.Now()
+	result := ResultUnchanged
+	var syncErr error
+	var deviceData *nautobot.DeviceData
+	var desiredLabels, appliedLabels map[string]string
+	var node corev1.Node
+	nodeDeleted := false
+	defer func() {
+		observeReconcile(result, started)
+		if r.MemberCluster != "" {
+			memberClusterReconcilesTotal.WithLabelValues(r.MemberCluster, result).Inc()
+		}
+		span.SetAttributes(attribute.String("result", result))
+		span.End()
+		if nodeDeleted {
+			return
+		}
+		r.recordSync(req.Name, result, syncErr, deviceData, desiredLabels)
+		if syncErr != nil {
+			r.RecentErrors.Add(req.Name, syncErr)
+		}
+		// Skipped and deferred reconciles have nothing new to record
+		if node.UID != "" && result != ResultSkipped && result != ResultDeferred {
+			if err := r.SyncResources.Update(ctx, &node, deviceData, appliedLabels, syncErr); err != nil {
+				logger.Error(err, "Failed to record node sync", "NodeName", req.Name)
+			}
+		}
+		if deviceData != nil && result != ResultDeferred {
+			if err := r.Machines.Update(ctx, &node, deviceData); err != nil {
+				logger.Error(err, "Failed to annotate Cluster API objects", "NodeName", req.Name)
+			}
+		}
+	}()
+
+	// 1. Fetch the Node from Kubernetes
+	if err := r.getNode(ctx, req.NamespacedName, &node); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			result = ResultError
+			syncErr = err
+			countReconcileError("node_get", err)
+		} else {
+			nodeDeleted = true
+			deleteNodeInfo(req.Name)
+			r.MissingNodes.Remove(req.Name)
+			r.SyncRecords.Delete(req.Name)
+			r.Scheduler.Delete(req.Name)
+			r.LabelRefresh.Delete(req.Name)
+			r.PartialData.Delete(req.Name)
+			r.Registration.Delete(req.Name)
+		}
+		// If the Node is deleted or doesn't exist, just return
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	config := r.Config.Current()
+	// Nodes outside the node selector are left alone; check again later in case the selector
+	// or the node's labels change
+	if !config.selector.Matches(labels.Set(node.Labels)) {
+		result = ResultSkipped
+		reconcileSkipsTotal.WithLabelValues("filtered").Inc()
+		return ctrl.Result{RequeueAfter: config.Intervals.Resync.Duration}, nil
+	}
+
+	// Nodes are first synced once kubelet registered them completely, as some bootstrap flows
+	// wipe or override labels until then; the status update reporting the condition reconciles
+	// them again
+	if r.Registration.Waiting(&node) {
+		result = ResultSkipped
+		reconcileSkipsTotal.WithLabelValues("not_registered").Inc()
+		logger.V(1).Info("Waiting for the node to report its registration condition", "NodeName", node.Name, "Condition", r.Registration.Condition)
+		return ctrl.Result{}, nil
+	}
+	// After a kubelet restart, which can have wiped or overridden labels, the node is checked again
+	kubeletRestarted := r.Registration.Restarted(&node)
+	if kubeletRestarted {
+		logger.Info("Kubelet restarted, checking the node's labels again", "NodeName", node.Name)
+	}
+
+	// A bulk resync may have fetched the device already, so checking the labels costs nothing
+	prefetched := r.BulkResync.Take(node.Name)
+	// The bulk resync matches names only, and its devices lack relationships, location
+	// ancestries, rack groups, inventory items, hardware notices, power feeds, circuits and the
+	// REST device objects of topology domains; nodes with a provider ID match, and all nodes
+	// with any of those configured, are looked up alone
+	if _, ok := r.LookupKey.providerIDHardware(&node); ok || !config.bulkResyncable() {
+		prefetched = nil
+	}
+	// After a reload of the labeling rules every node is checked again, with all labels or not
+	rulesReloaded := r.RulesResync.Take(node.Name)
+
+	// Check if the node already has our labels and they're non-empty
+	// Skip reconciliation if the node already has all required labels, unless someone changed
+	// them since our last sync and the values need to be checked against Nautobot, or their last
+	// changes were deferred. Labels its device had no data for count as present until their
+	// next check.
+	pending := r.PartialData.Pending(node.Name)
+	refreshInterval := config.refreshInterval()
+	if !r.ForceLookup && prefetched == nil && !rulesReloaded && !kubeletRestarted && hasAllLabels(&node, config.mappings, pending) && !labelsChangedOutOfBand(&node) &&
+		!r.Scheduler.Due(node.Name) && !r.LabelRefresh.Due(node.Name, refreshInterval) && (config.routerID == nil || hasCiliumBGPAnnotation(&node)) &&
+		(r.DNSNames == nil || node.Annotations[DNSNameAnnotation] != "") && hasRelationshipAnnotations(&node, config.Relationships) &&
+		hasCircuitProvidersAnnotation(&node, config.CircuitProviders) &&
+		hasLifecycleAnnotations(&node, config.Lifecycle) && r.MappingPlugin == nil && config.PowerRedundancy == nil &&
+		!hasStalePowerRedundancyTaint(&node, config.PowerRedundancy) && node.Annotations[DeviceNameAnnotation] == "" && !r.deferred(node.Name) {
+		logger.Info("Node already has all required labels", "NodeName", node.Name)
+		result = ResultSkipped
+		if len(pending) > 0 {
+			reconcileSkipsTotal.WithLabelValues("partial_data").Inc()
+		} else {
+			reconcileSkipsTotal.WithLabelValues("labels_present").Inc()
+		}
+		setNodeInfo(&node, "")
+		// Requeue for periodic refresh
+		return ctrl.Result{RequeueAfter: r.LabelRefresh.Delay(node.Name, refreshInterval, r.Scheduler.Delay(node.Name, config.Intervals.Resync.Duration))}, nil
+	}
+
+	// Nodes without a device are looked up again after intervals.notFound rather than at every
+	// event, unless their device name annotation changed
+	if backoff := r.MissingNodes.Backoff(node.Name, node.Annotations[DeviceNameAnnotation]); backoff > 0 && !r.ForceLookup && prefetched == nil && !rulesReloaded && !kubeletRestarted {
+		result = ResultSkipped
+		reconcileSkipsTotal.WithLabelValues("not_found").Inc()
+		return ctrl.Result{RequeueAfter: backoff}, nil
+	}
+
+	// 2. Query Nautobot to get site and rack info, once it answered after startup instead of
+	// failing node by node
+	if err := r.Startup.Wait(ctx); err != nil {
+		result = ResultSkipped
+		return ctrl.Result{}, nil
+	}
+	var err error
+	if prefetched != nil {
+		deviceData = prefetched
+	} else if stored := r.DeviceStore.Lookup(&node, r.LookupKey); stored != nil {
+		deviceData = stored
+	} else {
+		lookupCtx, lookupSpan := startSpan(ctx, "Nautobot.GetDeviceData", node.Name)
+		if r.LookupTimeout > 0 {
+			var cancel context.CancelFunc
+			lookupCtx, cancel = context.WithTimeout(lookupCtx, r.LookupTimeout)
+			defer cancel()
+		}
+		deviceData, err = LookupDevice(lookupCtx, r.deviceSource(), &node, r.LookupKey)
+		endSpan(lookupSpan, err)
+		// Nodes of virtual machines have no device
+		if errors.Is(err, nautobot.ErrDeviceNotFound) && config.VirtualMachines != nil {
+			vmCtx, vmSpan := startSpan(lookupCtx, "Nautobot.GetVirtualMachine", node.Name)
+			deviceData, err = LookupVirtualMachine(vmCtx, r.NautobotClient, config, &node, r.LookupKey)
+			endSpan(vmSpan, err)
+		}
+	}
+	// The reconcile was cancelled, e.g. at shutdown; the node is reconciled again after the
+	// restart
+	if err != nil && ctx.Err() != nil {
+		result = ResultSkipped
+		return ctrl.Result{}, nil
+	}
+	// Cloud instances of hybrid clusters are labeled from their provider's topology instead
+	fromCloud := false
+	if errors.Is(err, nautobot.ErrDeviceNotFound) {
+		if cloud := r.CloudFallback.Lookup(&node); cloud != nil {
+			deviceData, err, fromCloud = cloud, nil, true
+		}
+	}
+	// A node without a device is an inventory gap, not an outage: it's reported once and
+	// looked up again much later
+	if errors.Is(err, nautobot.ErrDeviceNotFound) {
+		result = ResultError
+		syncErr = err
+		countReconcileError("nautobot_lookup", err)
+		if r.MissingNodes.Add(node.Name, node.Annotations[DeviceNameAnnotation], config.Intervals.NotFound.Duration) {
+			logger.Error(err, "No Nautobot device found for node", "NodeName", node.Name)
+			if r.Recorder != nil {
+				r.Recorder.Eventf(&node, corev1.EventTypeWarning, "DeviceNotFound",
+					"No Nautobot device found for the node; looking it up again every %s", config.Intervals.NotFound.Duration)
+			}
+		} else {
+			logger.V(1).Info("Node still has no Nautobot device", "NodeName", node.Name)
+		}
+		r.Notifier.RecordFailure(ctx, node.Name, err)
+		return ctrl.Result{RequeueAfter: config.Intervals.NotFound.Duration}, nil
+	}
+	if err != nil {
+		logger.Error(err, "Failed to get device data from Nautobot", "NodeName", node.Name)
+		result = ResultError
+		syncErr = err
+		countReconcileError("nautobot_lookup", err)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(&node, corev1.EventTypeWarning, "LookupFailed",
+				"Failed to look the node's device up: %v; retrying in %s", err, config.Intervals.Retry.Duration)
+		}
+		r.Notifier.RecordFailure(ctx, node.Name, err)
+		// Requeue with backoff for errors
+		return ctrl.Result{RequeueAfter: config.Intervals.Retry.Duration}, nil
+	}
+
+	r.MissingNodes.Remove(node.Name)
+	r.Notifier.RecordSuccess(node.Name)
+	deviceData = config.CompleteDevice(deviceData)
+
+	// 3. Update node labels if needed
+	original := node.DeepCopy()
+	updated := false
+	if node.Labels == nil {
+		node.Labels = map[string]string{}
+	}
+
+	lastApplied := LastAppliedLabels(&node)
+	// With a source precedence, the labels of every source with a device are merged
+	var desired []mapping.Value
+	var sourceConflicts []SourceConflict
+	if config.SourcePrecedence != nil {
+		desired, sourceConflicts, err = MergeSources(config, &node, r.sourceDevices(&node, deviceData, fromCloud), r.ClusterName)
+	} else {
+		desired, err = mapping.Render(config.mappings, deviceData, r.ClusterName)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to map device data to labels", "NodeName", node.Name)
+		result = ResultError
+		syncErr = err
+		countReconcileError("mapping", err)
+		return ctrl.Result{RequeueAfter: config.Intervals.Retry.Duration}, nil
+	}
+	for _, conflict := range sourceConflicts {
+		logger.Info("Device sources disagree about a label, keeping its value", "NodeName", node.Name, "Label", conflict.Label, "Values", conflict.Values)
+		recordSourceConflict(r.Recorder, &node, conflict)
+	}
+	var desiredTaints []corev1.Taint
+	if r.MappingPlugin != nil {
+		if desired, desiredTaints, err = r.MappingPlugin.Map(ctx, &node, deviceData, r.ClusterName, desired); err != nil {
+			logger.Error(err, "Failed to map device data with the mapping plugin", "NodeName", node.Name)
+			result = ResultError
+			syncErr = err
+			countReconcileError("mapping_plugin", err)
+			return ctrl.Result{RequeueAfter: config.Intervals.Retry.Duration}, nil
+		}
+	}
+	if refused := GuardZoneLabel(&node, desired, r.AllowZoneChanges); refused != "" {
+		logger.Info("Keeping zone label, the node already has a zone", "NodeName", node.Name, "Zone", node.Labels[zoneLabel], "NautobotZone", refused)
+		recordZoneChangeBlocked(r.Recorder, &node, refused)
+	}
+	zone, volumes, err := r.ZoneVolumes.Guard(ctx, &node, desired)
+	if err != nil {
+		logger.Error(err, "Failed to check the volumes of the node before changing its zone", "NodeName", node.Name)
+		result = ResultError
+		syncErr = err
+		countReconcileError("volume_check", err)
+		return ctrl.Result{RequeueAfter: config.Intervals.Retry.Duration}, nil
+	}
+	if len(volumes) > 0 {
+		blocked := r.ZoneVolumes.Policy != ZoneVolumePolicyWarn
+		logger.Info("Volumes of the node's pods require its current zone", "NodeName", node.Name, "Zone", node.Labels[zoneLabel], "NautobotZone", zone, "Volumes", volumes, "Blocked", blocked)
+		recordZoneVolumeConflict(r.Recorder, &node, zone, volumes, blocked)
+	}
+	desiredLabels = map[string]string{}
+	for _, label := range desired {
+		if label.Value != "" {
+			desiredLabels[label.Key] = label.Value
+		}
+	}
+	// Devices lacking data for some labels, e.g. devices without a rack, get the labels there is
+	// data for and are checked again after the longer partial interval
+	missing := missingLabels(desired)
+	if len(missing) > 0 {
+		logger.Info("Device has no data for some labels", "NodeName", node.Name, "Device", deviceData.Name, "Labels", missing)
+	}
+	r.PartialData.Record(node.Name, missing, config.Intervals.Partial.Duration)
+	r.LabelRefresh.Checked(node.Name)
+	// Nodes whose NoExecute taints were refused are checked again after the retry interval
+	disruptionRefused := false
+	requeueAfter := func(fallback time.Duration) time.Duration {
+		interval := r.Scheduler.Observe(node.Name, desiredLabels, config.Intervals, fallback)
+		if len(missing) > 0 {
+			interval = config.Intervals.Partial.Duration
+		}
+		if disruptionRefused {
+			interval = min(interval, config.Intervals.Retry.Duration)
+		}
+		// Labels with refresh intervals are checked again in time
+		return r.LabelRefresh.Delay(node.Name, refreshInterval, interval)
+	}
+	// With Node Feature Discovery, nfd-master applies the labels of the NodeFeature object
+	if r.NodeFeatures != nil {
+		changes := PlanLabels(&node, desired, r.ConflictPolicy, deviceData.Name).Changes
+		if len(changes) > 0 {
+			until, reason, err := r.holdChanges(ctx, config, changes)
+			if err != nil {
+				logger.Error(err, "Failed to pace the node changes", "NodeName", node.Name)
+				result = ResultError
+				syncErr = err
+				countReconcileError("rollout", err)
+				return ctrl.Result{RequeueAfter: config.Intervals.Retry.Duration}, nil
+			}
+			if reason != "" {
+				result = ResultDeferred
+				return r.deferChanges(ctx, &node, changes, until, reason), nil
+			}
+		}
+		changed, err := r.NodeFeatures.Apply(ctx, &node, desiredLabels)
+		if relabels(changes) {
+			r.Rollout.Done(config, err)
+		}
+		if err != nil {
+			logger.Error(err, "Failed to publish node labels", "NodeName", node.Name)
+			result = ResultError
+			syncErr = err
+			countReconcileError("node_feature", err)
+			return ctrl.Result{RequeueAfter: config.Intervals.Retry.Duration}, nil
+		}
+		appliedLabels = desiredLabels
+		setNodeInfo(&node, deviceData.SiteName)
+		if !changed {
+			logger.Info("No label updates needed", "NodeName", node.Name)
+			return ctrl.Result{RequeueAfter: requeueAfter(config.Intervals.Unchanged.Duration)}, nil
+		}
+		logger.Info("Updated NodeFeature labels", "NodeName", node.Name, "Site", deviceData.SiteName, "Rack", deviceData.RackName)
+		result = ResultUpdated
+		r.recordChanges(ctx, changes)
+		return ctrl.Result{RequeueAfter: requeueAfter(config.Intervals.Updated.Duration)}, nil
+	}
+	bgpKey, bgpValue, err := ciliumBGPAnnotation(config, deviceData, desiredLabels, r.ClusterName)
+	if err != nil {
+		logger.Error(err, "Failed to map device data to BGP settings", "NodeName", node.Name)
+		result = ResultError
+		syncErr = err
+		countReconcileError("mapping", err)
+		return ctrl.Result{RequeueAfter: config.Intervals.Retry.Duration}, nil
+	}
+	var dnsName string
+	if r.DNSNames != nil {
+		if dnsName, err = r.DNSNames.Lookup(ctx, deviceData); err != nil {
+			logger.Error(err, "Failed to get DNS name from Nautobot", "NodeName", node.Name)
+			result = ResultError
+			syncErr = err
+			countReconcileError("nautobot_lookup", err)
+			return ctrl.Result{RequeueAfter: config.Intervals.Retry.Duration}, nil
+		}
+	}
+	plan := PlanLabels(&node, desired, r.ConflictPolicy, deviceData.Name)
+	for _, conflict := range plan.Conflicts {
+		recordConflict(r.Recorder, &node, conflict.Key, conflict.ClusterValue, conflict.NautobotValue, conflict.NautobotWon)
+		if !conflict.NautobotWon {
+			logger.Info("Keeping out-of-band label value", "NodeName", node.Name, "Label", conflict.Key, "Value", conflict.ClusterValue)
+		}
+	}
+	for _, change := range plan.Changes {
+		node.Labels[change.Key] = change.NewValue
+		updated = true
+	}
+	changes, applied := plan.Changes, plan.Applied
+	recordLabelHistory(&node, changes, r.LabelHistory, time.Now())
+	// Cilium's BGP control plane reads the router ID of the local ASN from an annotation
+	if config.routerID != nil {
+		if bgpChanges := applyCiliumBGPAnnotation(&node, bgpKey, bgpValue, deviceData.Name); len(bgpChanges) > 0 {
+			changes = append(changes, bgpChanges...)
+			updated = true
+		}
+	}
+	if r.DNSNames != nil {
+		if dnsChanges := applyDNSNameAnnotation(&node, dnsName, deviceData.Name); len(dnsChanges) > 0 {
+			changes = append(changes, dnsChanges...)
+			updated = true
+		}
+	}
+	if relationshipChanges := applyRelationshipAnnotations(&node, config.Relationships, deviceData); len(relationshipChanges) > 0 {
+		changes = append(changes, relationshipChanges...)
+		updated = true
+	}
+	if circuitChanges := applyCircuitProvidersAnnotation(&node, config.CircuitProviders, deviceData); len(circuitChanges) > 0 {
+		changes = append(changes, circuitChanges...)
+		updated = true
+	}
+	if config.Lifecycle != nil {
+		if lifecycleChanges := applyLifecycleAnnotations(&node, config.Lifecycle, deviceData); len(lifecycleChanges) > 0 {
+			changes = append(changes, lifecycleChanges...)
+			updated = true
+		}
+	}
+
+	if missingChanges := applyMissingLabelsAnnotation(&node, missing, deviceData.Name); len(missingChanges) > 0 {
+		changes = append(changes, missingChanges...)
+		updated = true
+	}
+
+	if r.MappingPlugin != nil {
+		var refused bool
+		desiredTaints, refused = r.guardTaints(ctx, &node, desiredTaints)
+		disruptionRefused = disruptionRefused || refused
+		if taintChanges, taintsUpdated := applyPluginTaints(&node, desiredTaints, deviceData.Name); taintsUpdated {
+			changes = append(changes, taintChanges...)
+			updated = true
+		}
+	}
+	powerRefused := false
+	if power := config.PowerRedundancy; power != nil && len(failedPowerFeeds(power, deviceData)) > 0 {
+		want := corev1.Taint{Key: power.TaintKey, Effect: corev1.TaintEffect(power.TaintEffect)}
+		_, powerRefused = r.guardTaints(ctx, &node, []corev1.Taint{want})
+		disruptionRefused = disruptionRefused || powerRefused
+	}
+	if !powerRefused {
+		if powerChanges := applyPowerRedundancyTaint(&node, config.PowerRedundancy, deviceData); len(powerChanges) > 0 {
+			if powerChanges[len(powerChanges)-1].NewValue != "" {
+				recordPowerRedundancyLost(r.Recorder, &node, config.PowerRedundancy, deviceData)
+			}
+			changes = append(changes, powerChanges...)
+			updated = true
+		}
+	}
+
+	appliedLabels = applied
+
+	// Remember what we applied so later out-of-band changes can be detected
+	if !equalStringMaps(applied, lastApplied) {
+		raw, err := json.Marshal(applied)
+		if err != nil {
+			result = ResultError
+			syncErr = err
+			countReconcileError("encode", err)
+			return ctrl.Result{}, fmt.Errorf("failed to encode applied labels: %w", err)
+		}
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[LastAppliedLabelsAnnotation] = string(raw)
+		updated = true
+	}
+
+	// Changes wait for the end of a freeze window or their rollout step, the node is checked
+	// again then
+	if updated {
+		until, reason, err := r.holdChanges(ctx, config, changes)
+		if err != nil {
+			logger.Error(err, "Failed to pace the node changes", "NodeName", node.Name)
+			result = ResultError
+			syncErr = err
+			countReconcileError("rollout", err)
+			return ctrl.Result{RequeueAfter: config.Intervals.Retry.Duration}, nil
+		}
+		if reason != "" {
+			result = ResultDeferred
+			return r.deferChanges(ctx, &node, changes, until, reason), nil
+		}
+	}
+
+	// 4. Persist changes if the labels changed
+	if updated {
+		logger.Info("Updating node labels", "NodeName", node.Name, "Site", deviceData.SiteName, "Rack", deviceData.RackName)
+		updateCtx, updateSpan := startSpan(ctx, "Node.Update", node.Name)
+		err := r.writeNode(updateCtx, &node, original)
+		endSpan(updateSpan, err)
+		if relabels(changes) {
+			r.Rollout.Done(config, err)
+		}
+		if err != nil {
+			logger.Error(err, "Failed to update node labels")
+			result = ResultError
+			syncErr = err
+			countReconcileError("node_update", err)
+			r.Notifier.RecordFailure(ctx, node.Name, err)
+			return ctrl.Result{RequeueAfter: 1 * time.Minute}, err
+		}
+		result = ResultUpdated
+		r.recordChanges(ctx, changes)
+		setNodeInfo(&node, deviceData.SiteName)
+		return ctrl.Result{RequeueAfter: requeueAfter(config.Intervals.Updated.Duration)}, nil
+	}
+
+	// If we got here, no updates were needed
+	logger.Info("No label updates needed", "NodeName", node.Name)
+	setNodeInfo(&node, deviceData.SiteName)
+	return ctrl.Result{RequeueAfter: requeueAfter(config.Intervals.Unchanged.Duration)}, nil
+}
+
+// holdChanges returns why and until when the changes of a node are deferred: during freeze
+// windows, and for changes of existing label values beyond the current rollout step. The
+// reason is empty if they can be applied now.
+func (r *NodeReconciler) holdChanges(ctx context.Context, config *Config, changes []AuditRecord) (time.Time, string, error) {
+	if until, frozen := config.FrozenUntil(time.Now()); frozen {
+		return until, "freeze_window", nil
+	}
+	if !relabels(changes) {
+		return time.Time{}, "", nil
+	}
+	until, err := r.Rollout.Admit(ctx, config, r.countNodes)
+	switch {
+	case err != nil || until.IsZero():
+		return time.Time{}, "", err
+	case r.Rollout.Aborted(config):
+		return until, "rollout_aborted", nil
+	default:
+		return until, "rollout", nil
+	}
+}
+
+// deferred reports whether the last reconcile of a node deferred its changes
+func (r *NodeReconciler) deferred(nodeName string) bool {
+	if r.SyncRecords == nil {
+		return false
+	}
+	record, ok := r.SyncRecords.Get(nodeName)
+	return ok && record.Result == ResultDeferred
+}
+
+// countNodes counts the nodes this instance reconciles
+func (r *NodeReconciler) countNodes(ctx context.Context) (int, error) {
+	names, err := listNodeNames(ctx, r.Client, r.MetadataOnly)
+	if err != nil {
+		return 0, err
+	}
+	return len(r.Shard.Filter(names)), nil
+}
+
+// deferChanges reports the deferred changes of a node and requeues it for when they may be
+// applied
+func (r *NodeReconciler) deferChanges(ctx context.Context, node *corev1.Node, changes []AuditRecord, until time.Time, reason string) ctrl.Result {
+	changesDeferredTotal.WithLabelValues(reason).Inc()
+	pending := make([]string, 0, len(changes))
+	for _, change := range changes {
+		pending = append(pending, fmt.Sprintf("%s=%s", change.Key, change.NewValue))
+	}
+	log.FromContext(ctx).Info("Deferring node changes", "NodeName", node.Name, "Reason", reason, "Until", until, "Changes", pending)
+	return ctrl.Result{RequeueAfter: time.Until(until)}
+}
+
+// deviceSource returns the source of devices, NautobotClient unless Source is set
+func (r *NodeReconciler) deviceSource() DeviceSource {
+	if r.Source != nil {
+		return r.Source
+	}
+	return r.NautobotClient
+}
+
+// getNode fetches a node, only its metadata in MetadataOnly mode
+func (r *NodeReconciler) getNode(ctx context.Context, key client.ObjectKey, node *corev1.Node) error {
+	if !r.MetadataOnly {
+		return r.Get(ctx, key, node)
+	}
+
+	metadata := &metav1.PartialObjectMetadata{}
+	metadata.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+	if err := r.Get(ctx, key, metadata); err != nil {
+		return err
+	}
+	node.ObjectMeta = metadata.ObjectMeta
+	return nil
+}
+
+// writeNode persists the label and annotation changes of a node. MetadataOnly mode sends a merge
+// patch against original, guarded by its resourceVersion like an update would be.
+func (r *NodeReconciler) writeNode(ctx context.Context, node, original *corev1.Node) error {
+	if err := r.WriteThrottle.Wait(ctx); err != nil {
+		return err
+	}
+	if !r.MetadataOnly {
+		return r.Update(ctx, node)
+	}
+	return r.Patch(ctx, node, client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{}))
+}
+
+// TrimCachedObject drops the managed fields of cached objects and the image list of cached nodes,
+// usually the bulk of a node object. Writes leave both untouched: omitted managed fields are
+// kept by the API server and node updates ignore the status.
+func TrimCachedObject(obj interface{}) (interface{}, error) {
+	if accessor, err := meta.Accessor(obj); err == nil {
+		accessor.SetManagedFields(nil)
+	}
+	if node, ok := obj.(*corev1.Node); ok {
+		node.Status.Images = nil
+	}
+	return obj, nil
+}
+
+// recordSync stores the outcome of a reconcile for the debug API
+func (r *NodeReconciler) recordSync(nodeName, result string, err error, deviceData *nautobot.DeviceData, desiredLabels map[string]string) {
+	if r.SyncRecords == nil {
+		return
+	}
+
+	record := NodeSyncRecord{Node: nodeName, Result: result, Time: time.Now().UTC()}
+	if err != nil {
+		record.Error = redact.String(err.Error())
+	}
+	if deviceData != nil {
+		record.MatchStrategy = "hostname: " + deviceData.Query
+		record.DesiredLabels = desiredLabels
+		record.NautobotResponse = deviceData.Raw
+		record.LookupTime = &record.Time
+	}
+	r.SyncRecords.Record(record)
+}
+
+// recordChanges counts the applied label changes and writes them to the audit sink
+func (r *NodeReconciler) recordChanges(ctx context.Context, changes []AuditRecord) {
+	now := time.Now().UTC()
+	for _, change := range changes {
+		if change.Kind == "label" {
+			changeType := "added"
+			if change.OldValue != "" {
+				changeType = "changed"
+			}
+			labelsAppliedTotal.WithLabelValues(change.Key, changeType).Inc()
+		}
+
+		if r.AuditSink == nil {
+			continue
+		}
+		change.Timestamp = now
+		if err := r.AuditSink.Record(change); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to write audit record", "NodeName", change.Node, "Label", change.Key)
+		}
+	}
+}
+
+// hasAllLabels checks if the node already has all the mapped labels with non-empty values, besides
+// the missing ones
+func hasAllLabels(node *corev1.Node, mappings []mapping.Mapping, missing []string) bool {
+	for _, mapping := range mappings {
+		if node.Labels[mapping.Label] == "" && !slices.Contains(missing, mapping.Label) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalStringMaps reports whether two string maps hold the same entries
+func equalStringMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// SetupWithManager registers the controller with the manager
+func (r *NodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	var opts []builder.ForOption
+	if r.MetadataOnly {
+		opts = append(opts, builder.OnlyMetadata)
+	}
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Node{}, opts...). // Watch Node objects
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles})
+	if r.BulkResync != nil {
+		bldr = bldr.WatchesRawSource(r.BulkResync.Source())
+	}
+	if r.RulesResync != nil {
+		bldr = bldr.WatchesRawSource(r.RulesResync.Source())
+	}
+	if r.Shard.Sharded() {
+		bldr = bldr.WithEventFilter(r.Shard.Predicate())
+	}
+	return bldr.Complete(r)
+}
+
+// SplitList splits a comma-separated flag value, dropping empty entries
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,235 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot/nautobottest"
+)
+
+// newTestReconciler returns a reconciler of the given nodes looking devices up in nautobotClient,
+// with the default mappings and the node selector
+func newTestReconciler(t *testing.T, nautobotClient nautobot.Interface, nodeSelector string, nodes ...*corev1.Node) (*NodeReconciler, client.Client, *record.FakeRecorder) {
+	t.Helper()
+	config, err := NewConfigStore("", func(config *configv1alpha1.LabelerConfiguration) {
+		config.Nautobot.URL = "https://nautobot.example.com"
+		config.Nautobot.Token = "token"
+		config.NodeSelector = nodeSelector
+	})
+	if err != nil {
+		t.Fatalf("NewConfigStore() error = %v", err)
+	}
+	builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme)
+	for _, node := range nodes {
+		builder = builder.WithObjects(node)
+	}
+	kubeClient := builder.Build()
+	recorder := record.NewFakeRecorder(10)
+	return &NodeReconciler{
+		Client:         kubeClient,
+		Scheme:         clientgoscheme.Scheme,
+		NautobotClient: nautobotClient,
+		Recorder:       recorder,
+		Config:         config,
+		MissingNodes:   NewMissingNodes(),
+		SyncRecords:    NewSyncRecords(),
+	}, kubeClient, recorder
+}
+
+func TestReconcile(t *testing.T) {
+	device := &nautobot.DeviceData{ID: "d1", Name: "worker-1", SiteName: "ams1", RackName: "r01"}
+
+	tests := []struct {
+		name         string
+		devices      []*nautobot.DeviceData
+		err          error
+		nodeSelector string
+		wantLabels   map[string]string
+		wantEvent    string
+		wantRequeue  bool
+	}{
+		{
+			name:        "labels the node from its device",
+			devices:     []*nautobot.DeviceData{device},
+			wantLabels:  map[string]string{zoneLabel: "ams1", rackLabel: "r01"},
+			wantRequeue: true,
+		},
+		{
+			name:        "device not found",
+			wantEvent:   "DeviceNotFound",
+			wantRequeue: true,
+		},
+		{
+			name:        "lookup fails",
+			devices:     []*nautobot.DeviceData{device},
+			err:         errors.New("503 Service Unavailable"),
+			wantEvent:   "LookupFailed",
+			wantRequeue: true,
+		},
+		{
+			name:         "node outside the node selector",
+			devices:      []*nautobot.DeviceData{device},
+			nodeSelector: "node-role.kubernetes.io/worker",
+			wantRequeue:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nautobotClient := nautobot.NewFake(tt.devices...)
+			nautobotClient.SetErr(tt.err)
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1.example.com"}}
+			r, kubeClient, recorder := newTestReconciler(t, nautobotClient, tt.nodeSelector, node)
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: node.Name}})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if (result.RequeueAfter > 0) != tt.wantRequeue {
+				t.Errorf("Reconcile() RequeueAfter = %v, want requeue %v", result.RequeueAfter, tt.wantRequeue)
+			}
+
+			var got corev1.Node
+			if err := kubeClient.Get(context.Background(), client.ObjectKeyFromObject(node), &got); err != nil {
+				t.Fatalf("failed to get node: %v", err)
+			}
+			for _, key := range []string{zoneLabel, rackLabel} {
+				if got.Labels[key] != tt.wantLabels[key] {
+					t.Errorf("label %s = %q, want %q", key, got.Labels[key], tt.wantLabels[key])
+				}
+			}
+
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if tt.wantEvent != "" && !strings.Contains(strings.Join(events, "\n"), tt.wantEvent) {
+				t.Errorf("events = %v, want %s", events, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestReconcileSkipsLabeledNodes(t *testing.T) {
+	nautobotClient := nautobot.NewFake(&nautobot.DeviceData{ID: "d1", Name: "worker-1", SiteName: "ams1", RackName: "r01"})
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}}
+	r, _, _ := newTestReconciler(t, nautobotClient, "", node)
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: node.Name}}
+	for range 2 {
+		if _, err := r.Reconcile(context.Background(), request); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+	}
+	if got := nautobotClient.CallCount("GetDeviceData"); got != 1 {
+		t.Errorf("GetDeviceData calls = %d, want 1, the labeled node is not looked up again", got)
+	}
+
+	// A label changed out of band is checked against Nautobot again
+	var labeled corev1.Node
+	if err := r.Get(context.Background(), request.NamespacedName, &labeled); err != nil {
+		t.Fatal(err)
+	}
+	labeled.Labels[rackLabel] = "r02"
+	if err := r.Update(context.Background(), &labeled); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.Background(), request); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if got := nautobotClient.CallCount("GetDeviceData"); got != 2 {
+		t.Errorf("GetDeviceData calls = %d, want 2", got)
+	}
+}
+
+func TestReconcileAgainstNautobot(t *testing.T) {
+	server := nautobottest.NewServer(nautobot.MockFixtures{
+		Devices: []map[string]interface{}{
+			{"id": "d1", "name": "worker-1", "site": map[string]interface{}{"id": "fra1", "name": "fra1"}, "rack": map[string]interface{}{"id": "r1", "name": "r1"}},
+		},
+		Sites: []map[string]interface{}{{"id": "fra1", "name": "fra1"}},
+	})
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		failure    *nautobottest.Failure
+		wantLabels map[string]string
+	}{
+		{name: "device served", wantLabels: map[string]string{zoneLabel: "fra1", rackLabel: "r1"}},
+		{name: "Nautobot unavailable", failure: &nautobottest.Failure{Path: "/api/dcim/devices/", Status: 503}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.ClearFailures()
+			if tt.failure != nil {
+				server.InjectFailure(*tt.failure)
+			}
+			node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}}
+			r, kubeClient, _ := newTestReconciler(t, server.Client(), "", node)
+
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: node.Name}}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			var got corev1.Node
+			if err := kubeClient.Get(context.Background(), client.ObjectKeyFromObject(node), &got); err != nil {
+				t.Fatalf("failed to get node: %v", err)
+			}
+			for _, key := range []string{zoneLabel, rackLabel} {
+				if got.Labels[key] != tt.wantLabels[key] {
+					t.Errorf("label %s = %q, want %q", key, got.Labels[key], tt.wantLabels[key])
+				}
+			}
+		})
+	}
+}
+
+func TestReconcileKeepsClusterValueOnEveryPass(t *testing.T) {
+	nautobotClient := nautobot.NewFake(&nautobot.DeviceData{ID: "d1", Name: "worker-1", SiteName: "ams1", RackName: "r01"})
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name: "worker-1",
+		// The rack label was changed in the cluster since it was applied
+		Labels:      map[string]string{zoneLabel: "ams1", rackLabel: "r02"},
+		Annotations: map[string]string{LastAppliedLabelsAnnotation: `{"` + zoneLabel + `":"ams1","` + rackLabel + `":"r01"}`},
+	}}
+	r, kubeClient, recorder := newTestReconciler(t, nautobotClient, "", node)
+	r.ConflictPolicy = ConflictPolicyKubernetes
+
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: node.Name}}
+	for pass := 1; pass <= 2; pass++ {
+		if _, err := r.Reconcile(context.Background(), request); err != nil {
+			t.Fatalf("pass %d: Reconcile() error = %v", pass, err)
+		}
+		var got corev1.Node
+		if err := kubeClient.Get(context.Background(), request.NamespacedName, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Labels[rackLabel] != "r02" {
+			t.Errorf("pass %d: rack label = %q, want the cluster value r02", pass, got.Labels[rackLabel])
+		}
+		if applied := LastAppliedLabels(&got)[rackLabel]; applied != "r01" {
+			t.Errorf("pass %d: last applied rack = %q, want the Nautobot value r01", pass, applied)
+		}
+		var conflicts int
+		for len(recorder.Events) > 0 {
+			if strings.Contains(<-recorder.Events, "Conflict") {
+				conflicts++
+			}
+		}
+		if conflicts != 1 {
+			t.Errorf("pass %d: %d Conflict events, want 1", pass, conflicts)
+		}
+	}
+}
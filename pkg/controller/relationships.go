@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// applyRelationshipAnnotations sets the annotations of relationships to the names of the objects
+// related to a node's device, removing those of relationships without any, and returns the
+// changes
+func applyRelationshipAnnotations(node *corev1.Node, relationships []configv1alpha1.RelationshipMapping, device *nautobot.DeviceData) []AuditRecord {
+	var changes []AuditRecord
+	for _, relationship := range relationships {
+		if relationship.Annotation == "" {
+			continue
+		}
+		value := strings.Join(device.Relationships[relationship.Relationship], ",")
+		current := node.Annotations[relationship.Annotation]
+		if current == value {
+			continue
+		}
+		changes = append(changes, AuditRecord{
+			Node:     node.Name,
+			Kind:     "annotation",
+			Key:      relationship.Annotation,
+			OldValue: current,
+			NewValue: value,
+			Device:   device.Name,
+		})
+		if value == "" {
+			delete(node.Annotations, relationship.Annotation)
+			continue
+		}
+		if node.Annotations == nil {
+			node.Annotations = map[string]string{}
+		}
+		node.Annotations[relationship.Annotation] = value
+	}
+	return changes
+}
+
+// hasRelationshipAnnotations reports whether a node has the annotations of all relationships
+func hasRelationshipAnnotations(node *corev1.Node, relationships []configv1alpha1.RelationshipMapping) bool {
+	for _, relationship := range relationships {
+		if relationship.Annotation != "" && node.Annotations[relationship.Annotation] == "" {
+			return false
+		}
+	}
+	return true
+}
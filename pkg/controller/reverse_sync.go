@@ -0,0 +1,651 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// ClusterMemberTag tags the virtual machines the controller created for the nodes of the
+// cluster; only those are removed when their node is gone
+const ClusterMemberTag = "nautobot-node-labeler"
+
+// ReverseSyncReconciler pushes data that kubelet reports about a Node back into Nautobot, so the
+// source of truth stays aligned with what is actually running in the cluster.
+type ReverseSyncReconciler struct {
+	client.Client
+	NautobotClient *nautobot.Client
+
+	// SyncNodeIPs enables pushing node addresses into Nautobot IPAM
+	SyncNodeIPs bool
+	// InterfaceName is the device interface the node addresses are assigned to
+	InterfaceName string
+	// AddressTypes are the node address types to push, in order of preference for the primary IP
+	AddressTypes []corev1.NodeAddressType
+
+	// ClusterName is the Nautobot virtualization cluster modelling this Kubernetes cluster.
+	// Membership sync is disabled when empty.
+	ClusterName string
+	// ClusterType is the Nautobot cluster type used when the cluster has to be created
+	ClusterType string
+	// PruneInterval is how often virtual machines without a matching node are removed
+	PruneInterval time.Duration
+
+	// SyncRoleTags enables tagging devices with their node's Kubernetes role
+	SyncRoleTags bool
+	// OnNodeDelete is applied to the node's device when the node is deleted
+	OnNodeDelete NodeDeleteAction
+	// LabelPrefixes selects the node labels serialized into LabelField. Disabled when empty.
+	LabelPrefixes []string
+	// LabelField is the device custom field receiving the selected labels as JSON
+	LabelField string
+	// CustomFields are device custom fields rendered from templates. Disabled when empty.
+	CustomFields []customFieldTemplate
+	// Cluster is the --cluster-name, available to CustomFields templates as .ClusterName
+	Cluster string
+	// Startup, if set, holds reconciles until Nautobot answered once
+	Startup *NautobotStartupGate
+	// LookupKey selects the names nodes are matched to devices by. Deleted nodes are looked up
+	// by their object names.
+	LookupKey LookupKey
+	// DeviceStore, if set, is the device store of the NodeReconciler; devices in it are not
+	// looked up again
+	DeviceStore *DeviceStore
+
+	Recorder record.EventRecorder
+	// ConflictPolicy decides whether Nautobot values that differ from the node are overwritten
+	ConflictPolicy ConflictPolicy
+	// MaxConcurrentReconciles is the number of nodes reconciled in parallel, 1 if unset
+	MaxConcurrentReconciles int
+	// Shard selects the nodes synced by this replica; the first shard prunes cluster members
+	Shard Shard
+
+	clusterIDMu sync.Mutex
+	clusterID   string
+	// memberTagID is the ID of ClusterMemberTag
+	memberTagID string
+}
+
+// Permissions returns the permissions of the Nautobot token the enabled reverse-sync fields
+// write with
+func (r *ReverseSyncReconciler) Permissions() []nautobot.Permission {
+	var permissions []nautobot.Permission
+	if r.SyncNodeIPs || r.SyncRoleTags || r.OnNodeDelete != NodeDeleteActionNone || len(r.LabelPrefixes) > 0 || len(r.CustomFields) > 0 {
+		permissions = append(permissions, nautobot.Permission{Path: "/api/dcim/devices/", Action: nautobot.PermissionChange})
+	}
+	if r.SyncNodeIPs {
+		permissions = append(permissions, nautobot.Permission{Path: "/api/ipam/ip-addresses/", Action: nautobot.PermissionAdd})
+	}
+	if r.SyncRoleTags || r.OnNodeDelete == NodeDeleteActionTag || r.ClusterName != "" {
+		permissions = append(permissions, nautobot.Permission{Path: "/api/extras/tags/", Action: nautobot.PermissionAdd})
+	}
+	if r.ClusterName != "" {
+		permissions = append(permissions, nautobot.Permission{Path: "/api/virtualization/virtual-machines/", Action: nautobot.PermissionAdd})
+	}
+	return permissions
+}
+
+// Reconcile pushes the enabled reverse-sync fields for a Node into Nautobot.
+func (r *ReverseSyncReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	if err := r.Startup.Wait(ctx); err != nil {
+		return ctrl.Result{}, nil
+	}
+
+	var node corev1.Node
+	if err := r.Get(ctx, req.NamespacedName, &node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return r.reconcileDeletedNode(ctx, req.Name)
+		}
+		return ctrl.Result{}, err
+	}
+
+	if r.ClusterName != "" {
+		if err := r.ensureClusterMember(ctx, node.Name); err != nil {
+			logger.Error(err, "Failed to sync virtualization cluster membership", "NodeName", node.Name)
+			return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+		}
+	}
+
+	if !r.needsDevice() {
+		return ctrl.Result{RequeueAfter: 1 * time.Hour}, nil
+	}
+
+	deviceData := r.DeviceStore.Lookup(&node, r.LookupKey)
+	if deviceData == nil {
+		var err error
+		deviceData, err = LookupDevice(ctx, r.NautobotClient, &node, r.LookupKey)
+		if err != nil {
+			logger.Error(err, "Failed to get device data from Nautobot for reverse sync", "NodeName", node.Name)
+			return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+		}
+	}
+
+	if r.SyncNodeIPs {
+		if err := r.syncNodeIPs(ctx, &node, deviceData); err != nil {
+			logger.Error(err, "Failed to sync node IPs to Nautobot", "NodeName", node.Name)
+			return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+		}
+	}
+
+	if r.SyncRoleTags {
+		add, remove := roleTagChanges(nodeRoleTag(&node))
+		if err := r.updateDeviceTags(ctx, node.Name, deviceData, add, remove); err != nil {
+			logger.Error(err, "Failed to sync role tags to Nautobot", "NodeName", node.Name)
+			return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+		}
+	}
+
+	if len(r.LabelPrefixes) > 0 {
+		if err := r.syncLabelCustomField(ctx, &node, deviceData); err != nil {
+			logger.Error(err, "Failed to sync node labels to Nautobot custom field", "NodeName", node.Name)
+			return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+		}
+	}
+
+	if len(r.CustomFields) > 0 {
+		if err := r.syncCustomFields(ctx, &node, deviceData); err != nil {
+			logger.Error(err, "Failed to sync custom fields to Nautobot", "NodeName", node.Name)
+			return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: 1 * time.Hour}, nil
+}
+
+// customFieldTemplate is a device custom field whose value is rendered from a template
+type customFieldTemplate struct {
+	name string
+	tmpl *template.Template
+}
+
+// customFieldTemplateData is what custom field templates are evaluated against
+type customFieldTemplateData struct {
+	ClusterName string
+	Node        *corev1.Node
+	Device      *nautobot.DeviceData
+}
+
+// CompileCustomFields parses name=template pairs, sorted by field name
+func CompileCustomFields(fields map[string]string) ([]customFieldTemplate, error) {
+	compiled := make([]customFieldTemplate, 0, len(fields))
+	for name, value := range fields {
+		tmpl, err := template.New(name).Option("missingkey=zero").Funcs(configv1alpha1.TemplateFuncs).Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for custom field %q: %w", name, err)
+		}
+		compiled = append(compiled, customFieldTemplate{name: name, tmpl: tmpl})
+	}
+	sort.Slice(compiled, func(i, j int) bool { return compiled[i].name < compiled[j].name })
+	return compiled, nil
+}
+
+// syncCustomFields renders CustomFields for a node and updates the ones that differ on its
+// device. Fields rendering to an empty value are left untouched.
+func (r *ReverseSyncReconciler) syncCustomFields(ctx context.Context, node *corev1.Node, deviceData *nautobot.DeviceData) error {
+	data := customFieldTemplateData{ClusterName: r.Cluster, Node: node, Device: deviceData}
+	changed := map[string]interface{}{}
+	for _, field := range r.CustomFields {
+		value, err := mapping.RenderTemplate(field.tmpl, data)
+		if err != nil {
+			return fmt.Errorf("failed to render custom field %q: %w", field.name, err)
+		}
+		if value == "" {
+			continue
+		}
+		if current, ok := deviceData.CustomFields[field.name].(string); ok && current == value {
+			continue
+		}
+		changed[field.name] = value
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Updating device custom fields in Nautobot", "NodeName", node.Name, "Device", deviceData.ID, "CustomFields", len(changed))
+	return r.NautobotClient.UpdateDevice(ctx, deviceData.ID, map[string]interface{}{"custom_fields": changed})
+}
+
+// syncLabelCustomField serializes the node labels matching LabelPrefixes into the device's
+// LabelField custom field, so Nautobot-side automation can consume cluster-assigned metadata.
+func (r *ReverseSyncReconciler) syncLabelCustomField(ctx context.Context, node *corev1.Node, deviceData *nautobot.DeviceData) error {
+	selected := map[string]string{}
+	for key, value := range node.Labels {
+		for _, prefix := range r.LabelPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				selected[key] = value
+				break
+			}
+		}
+	}
+
+	// json.Marshal sorts map keys, so the serialized value is stable across reconciles
+	raw, err := json.Marshal(selected)
+	if err != nil {
+		return fmt.Errorf("failed to encode node labels: %w", err)
+	}
+	if current, ok := deviceData.CustomFields[r.LabelField].(string); ok && current == string(raw) {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Updating device label custom field in Nautobot", "NodeName", node.Name, "Device", deviceData.ID, "CustomField", r.LabelField)
+	return r.NautobotClient.UpdateDevice(ctx, deviceData.ID, map[string]interface{}{
+		"custom_fields": map[string]interface{}{r.LabelField: string(raw)},
+	})
+}
+
+// reconcileDeletedNode cleans up Nautobot state that belonged to a node that has left the cluster.
+func (r *ReverseSyncReconciler) reconcileDeletedNode(ctx context.Context, nodeName string) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if r.ClusterName != "" {
+		if err := r.removeClusterMember(ctx, nodeName); err != nil {
+			logger.Error(err, "Failed to remove node from virtualization cluster", "NodeName", nodeName)
+			return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+		}
+	}
+
+	if !r.SyncRoleTags && r.OnNodeDelete == NodeDeleteActionNone {
+		return ctrl.Result{}, nil
+	}
+
+	deviceData, err := r.NautobotClient.GetDeviceData(ctx, nodeName)
+	if err != nil {
+		logger.Error(err, "Failed to get device data from Nautobot for departed node", "NodeName", nodeName)
+		return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+	}
+
+	var add, remove []string
+	if r.SyncRoleTags {
+		_, remove = roleTagChanges("")
+	}
+	switch r.OnNodeDelete {
+	case NodeDeleteActionTag:
+		add = append(add, removedTag)
+	case NodeDeleteActionOffline:
+		if deviceData.Status != "offline" {
+			logger.Info("Marking device offline in Nautobot", "NodeName", nodeName, "Device", deviceData.ID)
+			if err := r.NautobotClient.UpdateDeviceStatus(ctx, deviceData.ID, "Offline"); err != nil {
+				logger.Error(err, "Failed to mark Nautobot device offline", "NodeName", nodeName)
+				return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+			}
+		}
+	}
+
+	if err := r.updateDeviceTags(ctx, nodeName, deviceData, add, remove); err != nil {
+		logger.Error(err, "Failed to update tags of departed node's Nautobot device", "NodeName", nodeName)
+		return ctrl.Result{RequeueAfter: 5 * time.Minute}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// needsDevice reports whether any enabled sync operates on the node's Nautobot device
+func (r *ReverseSyncReconciler) needsDevice() bool {
+	return r.SyncNodeIPs || r.SyncRoleTags || len(r.LabelPrefixes) > 0 || len(r.CustomFields) > 0
+}
+
+// Tags applied to devices backing cluster nodes
+const (
+	controlPlaneRoleTag = "k8s-control-plane"
+	workerRoleTag       = "k8s-worker"
+	removedTag          = "k8s-removed"
+)
+
+// NodeDeleteAction is what happens to a node's Nautobot device when the node is deleted
+type NodeDeleteAction string
+
+const (
+	// NodeDeleteActionNone leaves the device untouched
+	NodeDeleteActionNone NodeDeleteAction = "none"
+	// NodeDeleteActionOffline sets the device status to offline
+	NodeDeleteActionOffline NodeDeleteAction = "offline"
+	// NodeDeleteActionTag adds the k8s-removed tag to the device
+	NodeDeleteActionTag NodeDeleteAction = "tag"
+)
+
+// ParseNodeDeleteAction validates a node deletion action name
+func ParseNodeDeleteAction(value string) (NodeDeleteAction, error) {
+	switch action := NodeDeleteAction(value); action {
+	case NodeDeleteActionNone, NodeDeleteActionOffline, NodeDeleteActionTag:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown node deletion action %q (expected none, offline or tag)", value)
+	}
+}
+
+// nodeRoleTag returns the role tag for a node based on the well-known node-role labels.
+func nodeRoleTag(node *corev1.Node) string {
+	for _, label := range []string{"node-role.kubernetes.io/control-plane", "node-role.kubernetes.io/master"} {
+		if _, ok := node.Labels[label]; ok {
+			return controlPlaneRoleTag
+		}
+	}
+	return workerRoleTag
+}
+
+// roleTagChanges returns the tags to add and remove so desired is the only role tag on the
+// device. An empty desired tag removes all role tags.
+func roleTagChanges(desired string) (add, remove []string) {
+	for _, tag := range []string{controlPlaneRoleTag, workerRoleTag} {
+		if tag == desired {
+			add = append(add, tag)
+		} else {
+			remove = append(remove, tag)
+		}
+	}
+	return add, remove
+}
+
+// updateDeviceTags adds and removes tags on the device, leaving unrelated tags untouched.
+func (r *ReverseSyncReconciler) updateDeviceTags(ctx context.Context, nodeName string, deviceData *nautobot.DeviceData, add, remove []string) error {
+	removeSet := map[string]bool{}
+	for _, name := range remove {
+		removeSet[name] = true
+	}
+
+	tagIDs := []string{}
+	present := map[string]bool{}
+	changed := false
+	for _, tag := range deviceData.Tags {
+		if removeSet[tag.Name] {
+			changed = true
+			continue
+		}
+		present[tag.Name] = true
+		tagIDs = append(tagIDs, tag.ID)
+	}
+
+	for _, name := range add {
+		if present[name] {
+			continue
+		}
+		tagID, err := r.NautobotClient.EnsureTag(ctx, name)
+		if err != nil {
+			return err
+		}
+		tagIDs = append(tagIDs, tagID)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Updating device tags in Nautobot", "NodeName", nodeName, "Device", deviceData.ID, "Added", add, "Removed", remove)
+	return r.NautobotClient.UpdateDevice(ctx, deviceData.ID, map[string]interface{}{"tags": tagIDs})
+}
+
+// getClusterID resolves (and caches) the ID of the virtualization cluster, creating it if needed.
+func (r *ReverseSyncReconciler) getClusterID(ctx context.Context) (string, error) {
+	r.clusterIDMu.Lock()
+	defer r.clusterIDMu.Unlock()
+
+	if r.clusterID == "" {
+		id, err := r.NautobotClient.EnsureVirtualizationCluster(ctx, r.ClusterName, r.ClusterType)
+		if err != nil {
+			return "", err
+		}
+		r.clusterID = id
+	}
+	return r.clusterID, nil
+}
+
+// getMemberTagID resolves (and caches) the ID of ClusterMemberTag, creating it if needed.
+func (r *ReverseSyncReconciler) getMemberTagID(ctx context.Context) (string, error) {
+	r.clusterIDMu.Lock()
+	defer r.clusterIDMu.Unlock()
+
+	if r.memberTagID == "" {
+		id, err := r.NautobotClient.EnsureTag(ctx, ClusterMemberTag, "virtualization.virtualmachine")
+		if err != nil {
+			return "", err
+		}
+		r.memberTagID = id
+	}
+	return r.memberTagID, nil
+}
+
+// ensureClusterMember makes sure a virtual machine exists in the cluster for the node, creating
+// one tagged with ClusterMemberTag if needed.
+func (r *ReverseSyncReconciler) ensureClusterMember(ctx context.Context, nodeName string) error {
+	clusterID, err := r.getClusterID(ctx)
+	if err != nil {
+		return err
+	}
+	id, err := r.NautobotClient.GetClusterVirtualMachine(ctx, clusterID, nodeName, "")
+	if err != nil || id != "" {
+		return err
+	}
+	tagID, err := r.getMemberTagID(ctx)
+	if err != nil {
+		return err
+	}
+	return r.NautobotClient.CreateVirtualMachine(ctx, nodeName, clusterID, tagID)
+}
+
+// removeClusterMember removes the node's virtual machine from the cluster, if present and
+// created by the controller.
+func (r *ReverseSyncReconciler) removeClusterMember(ctx context.Context, nodeName string) error {
+	clusterID, err := r.getClusterID(ctx)
+	if err != nil {
+		return err
+	}
+	id, err := r.NautobotClient.GetClusterVirtualMachine(ctx, clusterID, nodeName, ClusterMemberTag)
+	if err != nil || id == "" {
+		return err
+	}
+	return r.NautobotClient.DeleteVirtualMachine(ctx, id)
+}
+
+// pruneClusterMembers periodically removes the virtual machines the controller created whose node
+// no longer exists, catching deletions that happened while the controller was not running.
+// Virtual machines without ClusterMemberTag are never removed.
+func (r *ReverseSyncReconciler) pruneClusterMembers(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("cluster-prune")
+
+	ticker := time.NewTicker(r.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		clusterID, err := r.getClusterID(ctx)
+		if err != nil {
+			logger.Error(err, "Failed to resolve virtualization cluster")
+			continue
+		}
+		members, err := r.NautobotClient.ListClusterVirtualMachines(ctx, clusterID, ClusterMemberTag)
+		if err != nil {
+			logger.Error(err, "Failed to list virtualization cluster members")
+			continue
+		}
+
+		var nodes corev1.NodeList
+		if err := r.List(ctx, &nodes); err != nil {
+			logger.Error(err, "Failed to list nodes")
+			continue
+		}
+		current := make(map[string]bool, len(nodes.Items))
+		for _, node := range nodes.Items {
+			current[node.Name] = true
+		}
+
+		for name, id := range members {
+			if current[name] {
+				continue
+			}
+			logger.Info("Removing virtual machine for departed node", "NodeName", name)
+			if err := r.NautobotClient.DeleteVirtualMachine(ctx, id); err != nil {
+				logger.Error(err, "Failed to remove virtual machine", "NodeName", name)
+			}
+		}
+	}
+}
+
+// syncNodeIPs makes sure every selected node address exists in Nautobot IPAM, assigned to the
+// designated interface, and that the device's primary IPs match the preferred addresses.
+func (r *ReverseSyncReconciler) syncNodeIPs(ctx context.Context, node *corev1.Node, deviceData *nautobot.DeviceData) error {
+	logger := log.FromContext(ctx)
+
+	addresses := nodeAddresses(node, r.AddressTypes)
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	interfaceID, err := r.NautobotClient.GetInterfaceID(ctx, deviceData.ID, r.InterfaceName)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{}
+	chosen := map[string]bool{}
+	for _, address := range addresses {
+		ipID, err := r.NautobotClient.EnsureInterfaceIPAddress(ctx, address, interfaceID)
+		if err != nil {
+			return err
+		}
+
+		// The first address of each family wins, since addresses are ordered by preference
+		field, current := "primary_ip4", deviceData.PrimaryIP4
+		if net.ParseIP(address).To4() == nil {
+			field, current = "primary_ip6", deviceData.PrimaryIP6
+		}
+		if chosen[field] {
+			continue
+		}
+		chosen[field] = true
+		if current != nil && current.ID == ipID {
+			continue
+		}
+		// A different primary IP already set in Nautobot is a conflict with what kubelet reports
+		if current != nil {
+			nautobotWon := r.ConflictPolicy.nautobotWins(false)
+			recordConflict(r.Recorder, node, field, address, current.Address, nautobotWon)
+			if nautobotWon {
+				continue
+			}
+		}
+		updates[field] = ipID
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	logger.Info("Updating device primary IPs in Nautobot", "NodeName", node.Name, "Device", deviceData.ID, "PrimaryIPs", updates)
+	return r.NautobotClient.UpdateDevice(ctx, deviceData.ID, updates)
+}
+
+// nodeAddresses returns the node's addresses of the given types, ordered by type preference.
+func nodeAddresses(node *corev1.Node, types []corev1.NodeAddressType) []string {
+	var addresses []string
+	for _, addressType := range types {
+		for _, address := range node.Status.Addresses {
+			if address.Type == addressType && net.ParseIP(address.Address) != nil {
+				addresses = append(addresses, address.Address)
+			}
+		}
+	}
+	return addresses
+}
+
+// nodeChanged reports whether an update of a node changes what is pushed to Nautobot: its
+// addresses, provider ID, device name annotation or the labels the lookup and the enabled syncs
+// read. Custom field templates may read anything, so with them every label and annotation
+// counts. Status heartbeats change none of these.
+func (r *ReverseSyncReconciler) nodeChanged(oldNode, newNode *corev1.Node) bool {
+	if !reflect.DeepEqual(oldNode.Status.Addresses, newNode.Status.Addresses) ||
+		oldNode.Spec.ProviderID != newNode.Spec.ProviderID ||
+		oldNode.Annotations[DeviceNameAnnotation] != newNode.Annotations[DeviceNameAnnotation] {
+		return true
+	}
+	if len(r.CustomFields) > 0 {
+		return !equalStringMaps(oldNode.Labels, newNode.Labels) || !equalStringMaps(oldNode.Annotations, newNode.Annotations)
+	}
+	relevant := func(key string) bool {
+		switch key {
+		case r.LookupKey.label, corev1.LabelOSStable, "node-role.kubernetes.io/control-plane", "node-role.kubernetes.io/master":
+			return true
+		}
+		for _, prefix := range r.LabelPrefixes {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, labels := range []map[string]string{oldNode.Labels, newNode.Labels} {
+		for key := range labels {
+			oldValue, oldOK := oldNode.Labels[key]
+			newValue, newOK := newNode.Labels[key]
+			// Role labels have empty values, so their presence counts
+			if relevant(key) && (oldOK != newOK || oldValue != newValue) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// predicate passes the creation and deletion of nodes and the updates nodeChanged reports
+func (r *ReverseSyncReconciler) predicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldNode, ok := e.ObjectOld.(*corev1.Node)
+			if !ok {
+				return true
+			}
+			newNode, ok := e.ObjectNew.(*corev1.Node)
+			if !ok {
+				return true
+			}
+			return r.nodeChanged(oldNode, newNode)
+		},
+	}
+}
+
+// SetupWithManager registers the reverse-sync controller with the manager
+func (r *ReverseSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.ClusterName != "" && r.PruneInterval > 0 && r.Shard.Index == 0 {
+		if err := mgr.Add(manager.RunnableFunc(r.pruneClusterMembers)); err != nil {
+			return err
+		}
+	}
+
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		Named("reverse-sync").
+		For(&corev1.Node{}, builder.WithPredicates(r.predicate())).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles})
+	if r.Shard.Sharded() {
+		bldr = bldr.WithEventFilter(r.Shard.Predicate())
+	}
+	return bldr.Complete(r)
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+func TestReverseSyncMarksDeletedNodeOffline(t *testing.T) {
+	tests := []struct {
+		version   string
+		device    string
+		wantPatch string
+	}{
+		{version: "1.6.8", device: `{"id":"d1","name":"worker-1","status":{"value":"active","label":"Active"}}`, wantPatch: `{"status":"offline"}`},
+		{version: "2.2.0", device: `{"id":"d1","name":"worker-1","status":{"id":"s1","name":"Active"}}`, wantPatch: `{"status":{"name":"Offline"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			var patches []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				switch {
+				case req.URL.Path == "/api/status/":
+					_, _ = w.Write([]byte(`{"nautobot-version":"` + tt.version + `"}`))
+				case req.Method == http.MethodPatch:
+					body, _ := io.ReadAll(req.Body)
+					patches = append(patches, req.URL.Path+" "+strings.TrimSpace(string(body)))
+					_, _ = w.Write([]byte(`{}`))
+				case req.URL.Path == "/api/dcim/devices/":
+					_, _ = w.Write([]byte(`{"count":1,"next":null,"results":[` + tt.device + `]}`))
+				default:
+					_, _ = w.Write([]byte(`{"count":0,"next":null,"results":[]}`))
+				}
+			}))
+			defer server.Close()
+			r := &ReverseSyncReconciler{
+				// The node is gone from the cluster
+				Client:         fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build(),
+				NautobotClient: nautobot.NewClient(server.URL, "token", nil),
+				OnNodeDelete:   NodeDeleteActionOffline,
+			}
+
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "worker-1"}}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			want := "/api/dcim/devices/d1/ " + tt.wantPatch
+			if len(patches) != 1 || patches[0] != want {
+				t.Errorf("patches = %q, want [%s]", patches, want)
+			}
+		})
+	}
+}
+
+func TestReverseSyncPredicate(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "worker-1",
+			Labels:      map[string]string{"node.example.com/pool": "a", "topology.kubernetes.io/zone": "ams1"},
+			Annotations: map[string]string{"example.com/note": "a"},
+		},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.1"}}},
+	}
+	tests := []struct {
+		name         string
+		customFields bool
+		change       func(node *corev1.Node)
+		want         bool
+	}{
+		{
+			name: "heartbeat",
+			change: func(node *corev1.Node) {
+				node.ResourceVersion = "2"
+				node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue, LastHeartbeatTime: metav1.Now()}}
+			},
+		},
+		{
+			name:   "address changed",
+			change: func(node *corev1.Node) { node.Status.Addresses[0].Address = "10.0.0.2" },
+			want:   true,
+		},
+		{
+			name:   "device name annotation set",
+			change: func(node *corev1.Node) { node.Annotations[DeviceNameAnnotation] = "server-1" },
+			want:   true,
+		},
+		{
+			name:   "role label added",
+			change: func(node *corev1.Node) { node.Labels["node-role.kubernetes.io/control-plane"] = "" },
+			want:   true,
+		},
+		{
+			name:   "prefixed label removed",
+			change: func(node *corev1.Node) { delete(node.Labels, "node.example.com/pool") },
+			want:   true,
+		},
+		{
+			name:   "other label changed",
+			change: func(node *corev1.Node) { node.Labels["topology.kubernetes.io/zone"] = "ams2" },
+		},
+		{
+			name:   "other annotation changed",
+			change: func(node *corev1.Node) { node.Annotations["example.com/note"] = "b" },
+		},
+		{
+			name:         "other label changed with custom fields",
+			customFields: true,
+			change:       func(node *corev1.Node) { node.Labels["topology.kubernetes.io/zone"] = "ams2" },
+			want:         true,
+		},
+		{
+			name:         "other annotation changed with custom fields",
+			customFields: true,
+			change:       func(node *corev1.Node) { node.Annotations["example.com/note"] = "b" },
+			want:         true,
+		},
+		{
+			name:         "heartbeat with custom fields",
+			customFields: true,
+			change:       func(node *corev1.Node) { node.ResourceVersion = "2" },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &ReverseSyncReconciler{SyncRoleTags: true, LabelPrefixes: []string{"node.example.com/"}}
+			if tt.customFields {
+				customFields, err := CompileCustomFields(map[string]string{"k8s_cluster": "{{ .ClusterName }}"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				r.CustomFields = customFields
+			}
+			updated := node.DeepCopy()
+			tt.change(updated)
+			if got := r.predicate().Update(event.UpdateEvent{ObjectOld: node, ObjectNew: updated}); got != tt.want {
+				t.Errorf("Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	r := &ReverseSyncReconciler{}
+	if !r.predicate().Create(event.CreateEvent{Object: node}) || !r.predicate().Delete(event.DeleteEvent{Object: node}) {
+		t.Error("creation or deletion of a node filtered")
+	}
+}
+
+func TestReverseSyncReadsDeviceStore(t *testing.T) {
+	var lookups int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/api/status/":
+			_, _ = w.Write([]byte(`{"nautobot-version":"1.6.8"}`))
+		case req.URL.Path == "/api/dcim/devices/":
+			lookups++
+			_, _ = w.Write([]byte(`{"count":0,"next":null,"results":[]}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1"}}
+	store := NewDeviceStore(nil, 0)
+	store.set([]*nautobot.DeviceData{{ID: "d1", Name: "worker-1", Tags: []nautobot.Ref{{ID: "t1", Name: workerRoleTag}}}}, time.Now())
+	r := &ReverseSyncReconciler{
+		Client:         fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(node).Build(),
+		NautobotClient: nautobot.NewClient(server.URL, "token", nil),
+		SyncRoleTags:   true,
+		DeviceStore:    store,
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "worker-1"}}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+	}
+	if lookups != 0 {
+		t.Errorf("device lookups = %d, want 0, the device is in the store", lookups)
+	}
+}
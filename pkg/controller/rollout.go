@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var rolloutAborted = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "nautobot_labeler_rollout_aborted",
+		Help: "Whether the rollout of label value changes was aborted after failures (1) or not (0).",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(rolloutAborted)
+}
+
+// Rollout paces the nodes whose existing label values change with the rollout of the
+// configuration: at most rollout.maxNodes per rollout.interval, and none once more than
+// rollout.maxErrors of them failed to update. A new configuration starts over.
+type Rollout struct {
+	mu sync.Mutex
+	// config is the configuration the state belongs to
+	config *Config
+	// start is the start of the current step, limit and admitted its nodes
+	start           time.Time
+	limit, admitted int
+	errors          int
+	aborted         bool
+}
+
+// NewRollout returns a Rollout without state
+func NewRollout() *Rollout {
+	return &Rollout{}
+}
+
+// Admit reports when a node may be relabeled: the zero time if it may be now, else the start of
+// the next step. countNodes counts the nodes percentages of rollout.maxNodes refer to. Without
+// rollout settings, or a Rollout, every node may be relabeled.
+func (r *Rollout) Admit(ctx context.Context, config *Config, countNodes func(context.Context) (int, error)) (time.Time, error) {
+	if r == nil || config.Rollout == nil {
+		return time.Time{}, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.config != config {
+		r.config, r.start, r.errors, r.aborted = config, time.Time{}, 0, false
+		rolloutAborted.Set(0)
+	}
+	now := time.Now()
+	if r.aborted {
+		return now.Add(config.Rollout.Interval.Duration), nil
+	}
+	if next := r.start.Add(config.Rollout.Interval.Duration); now.Before(next) {
+		if r.admitted >= r.limit {
+			return next, nil
+		}
+	} else {
+		nodes, err := countNodes(ctx)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to count nodes for the rollout: %w", err)
+		}
+		maxNodes := config.Rollout.MaxNodes
+		limit, err := intstr.GetScaledValueFromIntOrPercent(&maxNodes, nodes, true)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid rollout.maxNodes: %w", err)
+		}
+		r.start, r.limit, r.admitted = now, max(limit, 1), 0
+	}
+	r.admitted++
+	return time.Time{}, nil
+}
+
+// Done records the outcome of an admitted relabel, aborting the rollout when too many failed.
+// Conflicts are retried like before and do not count.
+func (r *Rollout) Done(config *Config, err error) {
+	if r == nil || config.Rollout == nil || err == nil || apierrors.IsConflict(err) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.config != config {
+		return
+	}
+	r.errors++
+	if r.errors > config.Rollout.MaxErrors {
+		r.aborted = true
+		rolloutAborted.Set(1)
+	}
+}
+
+// Aborted reports whether the rollout of a configuration was aborted
+func (r *Rollout) Aborted(config *Config) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.aborted && r.config == config
+}
+
+// relabels reports whether changes alter existing label values, not only add labels
+func relabels(changes []AuditRecord) bool {
+	for _, change := range changes {
+		if change.Kind == "label" && change.OldValue != "" && change.OldValue != change.NewValue {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var rulesResyncsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_rules_resyncs_total",
+		Help: "Number of resyncs of all nodes after config reloads, by trigger (sighup, file_change).",
+	},
+	[]string{"trigger"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(rulesResyncsTotal)
+}
+
+// RulesResync reconciles every node once the labeling rules were reloaded, so nodes that carry
+// all labels get the labels of the new rules right away rather than at their next resync
+type RulesResync struct {
+	Client client.Reader
+	// MetadataOnly lists only node metadata, sharing the reconciler's metadata-only cache
+	MetadataOnly bool
+	// Shard selects the nodes resynced by this replica
+	Shard Shard
+
+	triggers chan string
+	events   chan event.GenericEvent
+
+	mu sync.Mutex
+	// due are the nodes not reconciled since the last reload
+	due map[string]bool
+}
+
+// NewRulesResync returns a RulesResync reading nodes with the given client
+func NewRulesResync(reader client.Reader) *RulesResync {
+	return &RulesResync{
+		Client:   reader,
+		triggers: make(chan string, 1),
+		events:   make(chan event.GenericEvent),
+		due:      map[string]bool{},
+	}
+}
+
+// Source returns the source of the reconciles triggered by the resyncs
+func (r *RulesResync) Source() source.Source {
+	return source.Channel(r.events, &handler.EnqueueRequestForObject{})
+}
+
+// Trigger requests a resync of all nodes, e.g. as ConfigStore.OnRulesChange function. It does
+// not block; triggers arriving while a resync is pending are merged into it.
+func (r *RulesResync) Trigger(trigger string) {
+	select {
+	case r.triggers <- trigger:
+	default:
+	}
+}
+
+// Start resyncs all nodes at every trigger
+func (r *RulesResync) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case trigger := <-r.triggers:
+			r.resync(ctx, trigger)
+		}
+	}
+}
+
+// resync marks all nodes due and triggers a reconcile of every node
+func (r *RulesResync) resync(ctx context.Context, trigger string) {
+	logger := log.FromContext(ctx).WithName("rules-resync")
+
+	nodes, err := listNodeMetadata(ctx, r.Client, r.MetadataOnly)
+	if err != nil {
+		logger.Error(err, "Failed to list nodes, they get the reloaded rules at their next resync", "Trigger", trigger)
+		return
+	}
+	var names []string
+	for _, node := range nodes {
+		if r.Shard.Owns(node.Name) {
+			names = append(names, node.Name)
+		}
+	}
+	r.mu.Lock()
+	for _, name := range names {
+		r.due[name] = true
+	}
+	r.mu.Unlock()
+	rulesResyncsTotal.WithLabelValues(strings.ReplaceAll(strings.ToLower(trigger), " ", "_")).Inc()
+	logger.Info("Resyncing all nodes after config reload", "Trigger", trigger, "Nodes", len(names))
+
+	for _, name := range names {
+		node := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		select {
+		case r.events <- event.GenericEvent{Object: node}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Take reports and forgets whether a node is due for a check since the last reload. A nil
+// RulesResync has no due nodes.
+func (r *RulesResync) Take(nodeName string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	due := r.due[nodeName]
+	delete(r.due, nodeName)
+	return due
+}
@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// ServiceNowClient looks devices up in a ServiceNow CMDB table with the Table API, as a
+// DeviceSource for shops whose inventory is not in Nautobot (yet). Records map to devices like
+// this: sys_id, name and serial_number are the ID, name and serial, the site and rack fields the
+// site and rack, company the tenant, install_status the status, ip_address the primary IP and
+// fqdn its DNS name. All fields are available to templates as .CustomFields.
+type ServiceNowClient struct {
+	mu         sync.RWMutex
+	config     configv1alpha1.ServiceNowConfig
+	httpClient *http.Client
+}
+
+// NewServiceNowClient returns a new ServiceNowClient. tlsConfig, if set, configures the TLS
+// connections to ServiceNow.
+func NewServiceNowClient(config configv1alpha1.ServiceNowConfig, tlsConfig *tls.Config) *ServiceNowClient {
+	redact.Register(config.Password)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &ServiceNowClient{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport},
+	}
+}
+
+// SetConfig switches to a new instance, table or credentials, e.g. after a config reload
+func (c *ServiceNowClient) SetConfig(config configv1alpha1.ServiceNowConfig) {
+	redact.Register(config.Password)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config
+}
+
+// GetDeviceData looks up the record named like the short hostname of a node
+func (c *ServiceNowClient) GetDeviceData(ctx context.Context, nodeName string) (*nautobot.DeviceData, error) {
+	deviceData, err := c.getRecord(ctx, "name="+nautobot.ShortHostname(nodeName))
+	if errors.Is(err, nautobot.ErrDeviceNotFound) {
+		return nil, fmt.Errorf("%w for node: %s", nautobot.ErrDeviceNotFound, nodeName)
+	}
+	return deviceData, err
+}
+
+// GetDevice looks up the record with an exact name, or with an exact sys_id
+func (c *ServiceNowClient) GetDevice(ctx context.Context, nameOrID string) (*nautobot.DeviceData, error) {
+	deviceData, err := c.getRecord(ctx, "name="+nameOrID+"^ORsys_id="+nameOrID)
+	if errors.Is(err, nautobot.ErrDeviceNotFound) {
+		return nil, fmt.Errorf("%w named %s", nautobot.ErrDeviceNotFound, nameOrID)
+	}
+	return deviceData, err
+}
+
+// getRecord returns the first record matching an encoded query
+func (c *ServiceNowClient) getRecord(ctx context.Context, sysparmQuery string) (*nautobot.DeviceData, error) {
+	query := url.Values{
+		"sysparm_query":                  {sysparmQuery},
+		"sysparm_limit":                  {"1"},
+		"sysparm_display_value":          {"true"},
+		"sysparm_exclude_reference_link": {"true"},
+	}
+	var response struct {
+		Result []json.RawMessage `json:"result"`
+	}
+	path, err := c.get(ctx, query, &response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Result) == 0 {
+		return nil, nautobot.ErrDeviceNotFound
+	}
+	deviceData, err := c.parseRecord(response.Result[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ServiceNow response: %w", err)
+	}
+	deviceData.Query = path
+	return deviceData, nil
+}
+
+// Ping checks that the table can be read with the credentials
+func (c *ServiceNowClient) Ping(ctx context.Context) error {
+	_, err := c.get(ctx, url.Values{"sysparm_limit": {"1"}, "sysparm_fields": {"sys_id"}}, nil)
+	return err
+}
+
+// get reads records of the table and returns the path requested
+func (c *ServiceNowClient) get(ctx context.Context, query url.Values, out interface{}) (string, error) {
+	c.mu.RLock()
+	config := c.config
+	c.mu.RUnlock()
+
+	path := "/api/now/table/" + url.PathEscape(config.Table) + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(config.URL, "/")+path, nil)
+	if err != nil {
+		return path, fmt.Errorf("failed to create request to ServiceNow: %w", err)
+	}
+	req.SetBasicAuth(config.Username, config.Password)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return path, fmt.Errorf("failed to contact ServiceNow: %w", redact.Error(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return path, fmt.Errorf("ServiceNow returned status %d for GET %s", resp.StatusCode, path)
+	}
+	if out == nil {
+		return path, nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return path, fmt.Errorf("failed to decode ServiceNow response: %w", err)
+	}
+	return path, nil
+}
+
+// parseRecord converts a CMDB record, read as display values, into nautobot.DeviceData
+func (c *ServiceNowClient) parseRecord(raw json.RawMessage) (*nautobot.DeviceData, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	field := func(name string) string {
+		value, _ := fields[name].(string)
+		return value
+	}
+	if field("name") == "" {
+		return nil, errors.New("record without a name")
+	}
+
+	c.mu.RLock()
+	siteField, rackField := c.config.SiteField, c.config.RackField
+	c.mu.RUnlock()
+	deviceData := &nautobot.DeviceData{
+		ID:           field("sys_id"),
+		Name:         field("name"),
+		Serial:       field("serial_number"),
+		SiteName:     field(siteField),
+		RackName:     field(rackField),
+		TenantName:   field("company"),
+		Status:       strings.ToLower(field("install_status")),
+		CustomFields: fields,
+		Raw:          raw,
+	}
+	if ip := net.ParseIP(field("ip_address")); ip != nil {
+		address := &nautobot.IPAddress{Address: ip.String(), DNSName: field("fqdn")}
+		if ip.To4() != nil {
+			deviceData.PrimaryIP4 = address
+		} else {
+			deviceData.PrimaryIP6 = address
+		}
+	}
+	return deviceData, nil
+}
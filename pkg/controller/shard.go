@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Shard is the subset of nodes a replica owns when a fleet is split across Count replicas: the
+// nodes whose FNV-1a hash of the name modulo Count is Index. The zero Shard owns all nodes.
+type Shard struct {
+	Index int
+	Count int
+}
+
+// Sharded reports whether the fleet is split across replicas
+func (s Shard) Sharded() bool {
+	return s.Count > 1
+}
+
+// Owns reports whether the node with the given name belongs to the shard
+func (s Shard) Owns(nodeName string) bool {
+	if !s.Sharded() {
+		return true
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(nodeName))
+	return int(hash.Sum32()%uint32(s.Count)) == s.Index
+}
+
+// Filter returns the node names the shard owns
+func (s Shard) Filter(nodeNames []string) []string {
+	if !s.Sharded() {
+		return nodeNames
+	}
+	var owned []string
+	for _, nodeName := range nodeNames {
+		if s.Owns(nodeName) {
+			owned = append(owned, nodeName)
+		}
+	}
+	return owned
+}
+
+// Predicate passes the events of the nodes the shard owns
+func (s Shard) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return s.Owns(obj.GetName())
+	})
+}
+
+// Suffix is appended to the names of per-shard objects such as leader election leases, empty
+// without sharding
+func (s Shard) Suffix() string {
+	if !s.Sharded() {
+		return ""
+	}
+	return fmt.Sprintf("-shard-%d", s.Index)
+}
+
+// Validate checks that the shard is one of Count
+func (s Shard) Validate() error {
+	if s.Count < 1 {
+		return fmt.Errorf("--shard-count must be at least 1, got %d", s.Count)
+	}
+	if s.Index < 0 || s.Index >= s.Count {
+		return fmt.Errorf("--shard-index must be between 0 and --shard-count - 1, got %d", s.Index)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestShardOwns(t *testing.T) {
+	nodes := make([]string, 100)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("worker-%d", i)
+	}
+
+	tests := []struct {
+		name  string
+		count int
+	}{
+		{name: "unsharded", count: 0},
+		{name: "single shard", count: 1},
+		{name: "three shards", count: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every node is owned by exactly one shard
+			owners := map[string]int{}
+			shards := max(tt.count, 1)
+			for index := range shards {
+				shard := Shard{Index: index, Count: tt.count}
+				for _, node := range shard.Filter(nodes) {
+					if !shard.Owns(node) {
+						t.Errorf("Filter() of shard %d returned %s, which it does not own", index, node)
+					}
+					owners[node]++
+				}
+			}
+			for _, node := range nodes {
+				if owners[node] != 1 {
+					t.Errorf("%s is owned by %d shards, want 1", node, owners[node])
+				}
+			}
+		})
+	}
+}
+
+func TestShardSuffix(t *testing.T) {
+	tests := []struct {
+		shard Shard
+		want  string
+	}{
+		{shard: Shard{}, want: ""},
+		{shard: Shard{Index: 0, Count: 1}, want: ""},
+		{shard: Shard{Index: 2, Count: 3}, want: "-shard-2"},
+	}
+	for _, tt := range tests {
+		if got := tt.shard.Suffix(); got != tt.want {
+			t.Errorf("%+v.Suffix() = %q, want %q", tt.shard, got, tt.want)
+		}
+	}
+}
+
+func TestShardValidate(t *testing.T) {
+	tests := []struct {
+		shard   Shard
+		wantErr bool
+	}{
+		{shard: Shard{Index: 0, Count: 1}},
+		{shard: Shard{Index: 2, Count: 3}},
+		{shard: Shard{Index: 0, Count: 0}, wantErr: true},
+		{shard: Shard{Index: 3, Count: 3}, wantErr: true},
+		{shard: Shard{Index: -1, Count: 3}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := tt.shard.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() error = %v, wantErr %v", tt.shard, err, tt.wantErr)
+		}
+	}
+}
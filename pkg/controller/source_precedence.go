@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+var sourceConflictsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_source_conflicts_total",
+		Help: "Number of label values the device sources disagreed about under the error source conflict policy, by label.",
+	},
+	[]string{"label"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(sourceConflictsTotal)
+}
+
+// SourceConflict is a label the device sources disagree about under the error policy
+type SourceConflict struct {
+	Label string
+	// Values are the values of the sources, by source
+	Values map[configv1alpha1.DeviceSourceName]string
+}
+
+// sourceDevices returns the devices of a node in every source that has one, by source. device
+// is the device the lookup returned, from the static devices file or, if fromCloud, the cloud
+// provider topology when the inventory has none.
+func (r *NodeReconciler) sourceDevices(node *corev1.Node, device *nautobot.DeviceData, fromCloud bool) map[configv1alpha1.DeviceSourceName]*nautobot.DeviceData {
+	devices := map[configv1alpha1.DeviceSourceName]*nautobot.DeviceData{}
+	switch {
+	case fromCloud:
+		devices[configv1alpha1.DeviceSourceCloud] = device
+	case isStaticDevice(device):
+		devices[configv1alpha1.DeviceSourceStatic] = device
+	default:
+		devices[configv1alpha1.DeviceSourceNautobot] = device
+	}
+	if _, ok := devices[configv1alpha1.DeviceSourceStatic]; !ok {
+		if source, ok := r.deviceSource().(*FallbackSource); ok {
+			if static := source.Static.Lookup(node.Name); static != nil {
+				devices[configv1alpha1.DeviceSourceStatic] = static
+			}
+		}
+	}
+	if _, ok := devices[configv1alpha1.DeviceSourceCloud]; !ok {
+		if cloud := r.CloudFallback.device(node); cloud != nil {
+			devices[configv1alpha1.DeviceSourceCloud] = cloud
+		}
+	}
+	return devices
+}
+
+// MergeSources renders the mappings against the device of every source and picks the value of
+// each label by its source precedence. Labels the sources disagree about under the error policy
+// keep the node's value and are returned as conflicts.
+func MergeSources(config *Config, node *corev1.Node, devices map[configv1alpha1.DeviceSourceName]*nautobot.DeviceData, clusterName string) ([]mapping.Value, []SourceConflict, error) {
+	rendered := map[configv1alpha1.DeviceSourceName][]mapping.Value{}
+	for source, device := range devices {
+		values, err := mapping.Render(config.mappings, config.CompleteDevice(device), clusterName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to render the labels of the %s device: %w", source, err)
+		}
+		rendered[source] = values
+	}
+
+	desired := make([]mapping.Value, 0, len(config.mappings))
+	var conflicts []SourceConflict
+	for i, labelMapping := range config.mappings {
+		sources, policy := config.labelPrecedence(labelMapping.Label)
+		values := map[configv1alpha1.DeviceSourceName]string{}
+		value := ""
+		for _, source := range sources {
+			// Defaults stand in for missing data, which other sources may have
+			if sourceValues, ok := rendered[source]; ok && sourceValues[i].Value != "" && !sourceValues[i].Defaulted {
+				values[source] = sourceValues[i].Value
+				if value == "" {
+					value = sourceValues[i].Value
+				}
+			}
+		}
+		switch policy {
+		case configv1alpha1.SourceConflictNautobotWins:
+			if nautobotValue, ok := values[configv1alpha1.DeviceSourceNautobot]; ok {
+				value = nautobotValue
+			}
+		case configv1alpha1.SourceConflictError:
+			for _, other := range values {
+				if other != value {
+					conflicts = append(conflicts, SourceConflict{Label: labelMapping.Label, Values: values})
+					value = node.Labels[labelMapping.Label]
+					break
+				}
+			}
+		}
+		if value == "" && labelMapping.Default != "" {
+			desired = append(desired, mapping.Value{Key: labelMapping.Label, Value: labelMapping.Default, Defaulted: true})
+			continue
+		}
+		desired = append(desired, mapping.Value{Key: labelMapping.Label, Value: value})
+	}
+	return desired, conflicts, nil
+}
+
+// labelPrecedence returns the sources by precedence and the conflict policy of a label
+func (c *Config) labelPrecedence(label string) ([]configv1alpha1.DeviceSourceName, configv1alpha1.SourceConflictPolicy) {
+	precedence := c.SourcePrecedence
+	sources, policy := precedence.Sources, precedence.Conflict
+	if labelPrecedence, ok := precedence.Labels[label]; ok {
+		if labelPrecedence.Sources != nil {
+			sources = labelPrecedence.Sources
+		}
+		if labelPrecedence.Conflict != "" {
+			policy = labelPrecedence.Conflict
+		}
+	}
+	return sources, policy
+}
+
+// recordSourceConflict counts a source conflict and emits a Warning event on the node naming the
+// values of the sources
+func recordSourceConflict(recorder record.EventRecorder, node *corev1.Node, conflict SourceConflict) {
+	sourceConflictsTotal.WithLabelValues(conflict.Label).Inc()
+	if recorder == nil {
+		return
+	}
+	values := make([]string, 0, len(conflict.Values))
+	for source, value := range conflict.Values {
+		values = append(values, fmt.Sprintf("%s %q", source, value))
+	}
+	sort.Strings(values)
+	recorder.Eventf(node, corev1.EventTypeWarning, "SourceConflict",
+		"Device sources disagree about label %s (%s); keeping the node's value", conflict.Label, strings.Join(values, ", "))
+}
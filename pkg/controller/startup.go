@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// StartupPolicy decides how the controller starts while Nautobot is unreachable
+type StartupPolicy string
+
+const (
+	// StartupPolicyFailFast keeps the replica unready until Nautobot answers. Reconciles wait
+	// for it, and the process exits once the startup timeout (if any) has passed.
+	StartupPolicyFailFast StartupPolicy = "fail-fast"
+	// StartupPolicyDegraded becomes ready right away and does not tie readiness to Nautobot.
+	// Nodes keep the labels they already carry while Nautobot is retried in the background,
+	// and reconciles wait for it.
+	StartupPolicyDegraded StartupPolicy = "degraded"
+)
+
+// ParseStartupPolicy validates a startup policy name
+func ParseStartupPolicy(value string) (StartupPolicy, error) {
+	switch policy := StartupPolicy(value); policy {
+	case StartupPolicyFailFast, StartupPolicyDegraded:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown startup policy %q (expected fail-fast or degraded)", value)
+	}
+}
+
+// NautobotStartupGate waits for the first successful request to Nautobot, so reconciles are
+// held instead of failing one node at a time against an unreachable Nautobot.
+type NautobotStartupGate struct {
+	// Source is the device source waited for, usually Nautobot
+	Source DeviceSource
+	// Timeout, if positive, is how long to wait for Nautobot before failing the manager
+	Timeout time.Duration
+	// Permissions, if set, are checked once Nautobot answered, before the gate opens
+	Permissions *TokenPermissions
+
+	opened chan struct{}
+}
+
+// NewNautobotStartupGate returns a closed gate for the given client
+func NewNautobotStartupGate(source DeviceSource, timeout time.Duration) *NautobotStartupGate {
+	return &NautobotStartupGate{Source: source, Timeout: timeout, opened: make(chan struct{})}
+}
+
+// Start pings Nautobot with exponential backoff until it answers, then checks the token's
+// permissions and opens the gate
+func (g *NautobotStartupGate) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("startup")
+
+	var deadline <-chan time.Time
+	if g.Timeout > 0 {
+		timer := time.NewTimer(g.Timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	backoff := time.Second
+	for {
+		err := g.Source.Ping(ctx)
+		if err == nil {
+			if err := g.Permissions.Check(ctx); err != nil {
+				return err
+			}
+			logger.Info("Nautobot is reachable, starting to reconcile nodes")
+			close(g.opened)
+			return nil
+		}
+		logger.Error(err, "Waiting for Nautobot", "RetryIn", backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-deadline:
+			return fmt.Errorf("nautobot did not answer within the startup timeout of %v: %w", g.Timeout, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, 30*time.Second)
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Standbys wait too, so a new
+// leader does not start reconciling blindly.
+func (g *NautobotStartupGate) NeedLeaderElection() bool {
+	return false
+}
+
+// Wait blocks until the gate is open or the context is cancelled. A nil gate is always open.
+func (g *NautobotStartupGate) Wait(ctx context.Context) error {
+	if g == nil {
+		return nil
+	}
+	select {
+	case <-g.opened:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
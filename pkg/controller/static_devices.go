@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/yaml"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+var staticDeviceLookupsTotal = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_static_device_lookups_total",
+		Help: "Number of nodes without a device in the device source that were found in the static devices file.",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(staticDeviceLookupsTotal)
+}
+
+// staticDevice is an entry of a static devices file
+type staticDevice struct {
+	Site         string                 `json:"site,omitempty"`
+	Rack         string                 `json:"rack,omitempty"`
+	Region       string                 `json:"region,omitempty"`
+	Tenant       string                 `json:"tenant,omitempty"`
+	Status       string                 `json:"status,omitempty"`
+	CustomFields map[string]interface{} `json:"customFields,omitempty"`
+}
+
+// StaticDevices are the devices of hosts that are not modeled in the device source, read from
+// a YAML or CSV file keyed by node name
+type StaticDevices struct {
+	path    string
+	devices map[string]staticDevice
+}
+
+// LoadStaticDevices reads a static devices file. Files ending in .csv have a header row with a
+// name column and optional site, rack, region, tenant and status columns; further columns are
+// custom fields. Other files are YAML maps from node name to site, rack, region, tenant, status
+// and customFields.
+func LoadStaticDevices(path string) (*StaticDevices, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read static devices file: %w", err)
+	}
+	devices := map[string]staticDevice{}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		devices, err = parseStaticDevicesCSV(data)
+	} else {
+		err = yaml.UnmarshalStrict(data, &devices)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse static devices file %s: %w", path, err)
+	}
+	return &StaticDevices{path: path, devices: devices}, nil
+}
+
+// parseStaticDevicesCSV parses the CSV format of static devices files
+func parseStaticDevicesCSV(data []byte) (map[string]staticDevice, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return map[string]staticDevice{}, nil
+	}
+	header := rows[0]
+	nameColumn := -1
+	for i, column := range header {
+		if column == "name" {
+			nameColumn = i
+		}
+	}
+	if nameColumn < 0 {
+		return nil, errors.New("the header row has no name column")
+	}
+
+	devices := make(map[string]staticDevice, len(rows)-1)
+	for line, row := range rows[1:] {
+		name := row[nameColumn]
+		if name == "" {
+			return nil, fmt.Errorf("row %d has no name", line+2)
+		}
+		var device staticDevice
+		for i, value := range row {
+			switch header[i] {
+			case "name":
+			case "site":
+				device.Site = value
+			case "rack":
+				device.Rack = value
+			case "region":
+				device.Region = value
+			case "tenant":
+				device.Tenant = value
+			case "status":
+				device.Status = value
+			default:
+				if device.CustomFields == nil {
+					device.CustomFields = map[string]interface{}{}
+				}
+				device.CustomFields[header[i]] = value
+			}
+		}
+		devices[name] = device
+	}
+	return devices, nil
+}
+
+// staticDevicesQuery prefixes the Query of static devices
+const staticDevicesQuery = "static devices file "
+
+// isStaticDevice reports whether a device came from a static devices file
+func isStaticDevice(device *nautobot.DeviceData) bool {
+	return strings.HasPrefix(device.Query, staticDevicesQuery)
+}
+
+// Lookup returns the device of a node, matched first by its full name and then by its short
+// hostname, or nil
+func (s *StaticDevices) Lookup(nodeName string) *nautobot.DeviceData {
+	name := nodeName
+	device, ok := s.devices[name]
+	if !ok {
+		name = nautobot.ShortHostname(nodeName)
+		if device, ok = s.devices[name]; !ok {
+			return nil
+		}
+	}
+	raw, _ := json.Marshal(device)
+	return &nautobot.DeviceData{
+		Name:         name,
+		SiteName:     device.Site,
+		RackName:     device.Rack,
+		RegionName:   device.Region,
+		TenantName:   device.Tenant,
+		Status:       device.Status,
+		CustomFields: device.CustomFields,
+		Query:        staticDevicesQuery + s.path,
+		Raw:          raw,
+	}
+}
+
+// FallbackSource is a DeviceSource consulting static devices for the nodes that have no device
+// in Source. Only Source is pinged.
+type FallbackSource struct {
+	Source DeviceSource
+	Static *StaticDevices
+}
+
+// GetDeviceData implements DeviceSource
+func (f *FallbackSource) GetDeviceData(ctx context.Context, nodeName string) (*nautobot.DeviceData, error) {
+	deviceData, err := f.Source.GetDeviceData(ctx, nodeName)
+	if errors.Is(err, nautobot.ErrDeviceNotFound) {
+		if static := f.Static.Lookup(nodeName); static != nil {
+			staticDeviceLookupsTotal.Inc()
+			return static, nil
+		}
+	}
+	return deviceData, err
+}
+
+// GetDevice implements DeviceSource. Static devices are keyed by node name, so only Source is
+// consulted.
+func (f *FallbackSource) GetDevice(ctx context.Context, nameOrID string) (*nautobot.DeviceData, error) {
+	return f.Source.GetDevice(ctx, nameOrID)
+}
+
+// Ping implements DeviceSource
+func (f *FallbackSource) Ping(ctx context.Context) error {
+	return f.Source.Ping(ctx)
+}
@@ -0,0 +1,411 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+var nodesMissingInNautobot = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "nautobot_labeler_nodes_missing_in_nautobot",
+		Help: "Number of nodes for which no device could be found in Nautobot.",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(nodesMissingInNautobot)
+}
+
+// MissingNodes tracks nodes whose Nautobot lookup returned no device, so inventory gaps are
+// visible as a metric and a debug listing instead of only in logs. Missing nodes are looked up
+// again only after intervals.notFound, or when their device name annotation changes.
+type MissingNodes struct {
+	mu    sync.Mutex
+	nodes map[string]missingState
+}
+
+// missingState is what MissingNodes knows about a node
+type missingState struct {
+	since, next time.Time
+	// deviceName is the device name annotation the node was looked up with
+	deviceName string
+}
+
+// NewMissingNodes returns an empty MissingNodes tracker
+func NewMissingNodes() *MissingNodes {
+	return &MissingNodes{nodes: map[string]missingState{}}
+}
+
+// Add marks a node looked up with a device name annotation as missing in Nautobot until its
+// next lookup after backoff, keeping the time it was first seen missing. It reports whether the
+// node was newly missing.
+func (m *MissingNodes) Add(nodeName, deviceName string, backoff time.Duration) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.nodes[nodeName]
+	if !ok {
+		state.since = time.Now()
+	}
+	state.next, state.deviceName = time.Now().Add(backoff), deviceName
+	m.nodes[nodeName] = state
+	nodesMissingInNautobot.Set(float64(len(m.nodes)))
+	return !ok
+}
+
+// Remove clears a node, e.g. after it was found or deleted
+func (m *MissingNodes) Remove(nodeName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.nodes, nodeName)
+	nodesMissingInNautobot.Set(float64(len(m.nodes)))
+}
+
+// Contains reports whether a node is currently missing in Nautobot
+func (m *MissingNodes) Contains(nodeName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.nodes[nodeName]
+	return ok
+}
+
+// Backoff returns how long a missing node is not looked up again, zero for nodes that are due,
+// not missing or annotated with another device name since
+func (m *MissingNodes) Backoff(nodeName, deviceName string) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.nodes[nodeName]
+	if !ok || state.deviceName != deviceName {
+		return 0
+	}
+	return max(time.Until(state.next), 0)
+}
+
+// missingNode is the JSON representation of a missing node
+type missingNode struct {
+	Name      string    `json:"name"`
+	Since     time.Time `json:"since"`
+	NextCheck time.Time `json:"nextCheck"`
+}
+
+// List returns the missing nodes sorted by name
+func (m *MissingNodes) List() []missingNode {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nodes := make([]missingNode, 0, len(m.nodes))
+	for name, state := range m.nodes {
+		nodes = append(nodes, missingNode{Name: name, Since: state.since, NextCheck: state.next})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
+// ServeHTTP lists the missing nodes as JSON
+func (m *MissingNodes) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, m.List())
+}
+
+// NodeSyncRecord is what the controller knows about the last sync of a node
+type NodeSyncRecord struct {
+	Node string `json:"node"`
+	// Result is the outcome of the last reconcile (updated, unchanged, skipped, error)
+	Result string `json:"result"`
+	// Error is the error of the last reconcile, if any
+	Error string `json:"error,omitempty"`
+	// Time is when the last reconcile finished
+	Time time.Time `json:"time"`
+	// MatchStrategy describes how the node was matched to a device, e.g. the query used
+	MatchStrategy string `json:"matchStrategy,omitempty"`
+	// DesiredLabels is the label set derived from the Nautobot data
+	DesiredLabels map[string]string `json:"desiredLabels,omitempty"`
+	// NautobotResponse is the raw device object from the last successful lookup
+	NautobotResponse json.RawMessage `json:"nautobotResponse,omitempty"`
+	// LookupTime is when NautobotResponse was fetched
+	LookupTime *time.Time `json:"lookupTime,omitempty"`
+}
+
+// SyncRecords keeps the last sync record of every node for the debug API
+type SyncRecords struct {
+	// SkipResponses drops the raw Nautobot responses, which make up most of the records' size,
+	// when nothing serves them
+	SkipResponses bool
+
+	mu      sync.RWMutex
+	records map[string]NodeSyncRecord
+}
+
+// NewSyncRecords returns an empty SyncRecords store
+func NewSyncRecords() *SyncRecords {
+	return &SyncRecords{records: map[string]NodeSyncRecord{}}
+}
+
+// Record stores the outcome of a reconcile. Lookup details from an earlier reconcile are kept
+// when this one did not consult Nautobot.
+func (s *SyncRecords) Record(record NodeSyncRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.SkipResponses {
+		record.NautobotResponse = nil
+	}
+	if record.LookupTime == nil {
+		previous := s.records[record.Node]
+		record.MatchStrategy = previous.MatchStrategy
+		record.DesiredLabels = previous.DesiredLabels
+		record.NautobotResponse = previous.NautobotResponse
+		record.LookupTime = previous.LookupTime
+	}
+	s.records[record.Node] = record
+}
+
+// Delete forgets a node
+func (s *SyncRecords) Delete(nodeName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, nodeName)
+}
+
+// Get returns the last sync record of a node
+func (s *SyncRecords) Get(nodeName string) (NodeSyncRecord, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	record, ok := s.records[nodeName]
+	return record, ok
+}
+
+// ServeHTTP serves GET /debug/nodes/<name>
+func (s *SyncRecords) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(req.URL.Path, "/debug/nodes/")
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "expected /debug/nodes/<name>", http.StatusBadRequest)
+		return
+	}
+	record, ok := s.Get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("node %q has not been reconciled yet", name), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, record)
+}
+
+// clusterStatus is the JSON representation of the cluster sync summary
+type clusterStatus struct {
+	Nodes    int `json:"nodes"`
+	Synced   int `json:"synced"`
+	Pending  int `json:"pending"`
+	Failed   int `json:"failed"`
+	NotFound int `json:"notFound"`
+	// LastFullResyncTime is the oldest last reconcile among all nodes, i.e. every node has been
+	// synced since. It is unset while any node is pending.
+	LastFullResyncTime *time.Time `json:"lastFullResyncTime,omitempty"`
+	Nautobot           struct {
+		Healthy bool   `json:"healthy"`
+		Error   string `json:"error,omitempty"`
+		// TokenInUse is primary or secondary
+		TokenInUse string `json:"tokenInUse"`
+	} `json:"nautobot"`
+}
+
+// StatusHandler serves GET /status, a summary of the sync state of all nodes for dashboards
+// and smoke tests
+type StatusHandler struct {
+	// Client lists the nodes of the cluster
+	Client       client.Reader
+	SyncRecords  *SyncRecords
+	MissingNodes *MissingNodes
+	// HealthCheck reports whether Nautobot is reachable
+	HealthCheck *NautobotHealthCheck
+	// MetadataOnly lists only node metadata, sharing the reconciler's metadata-only cache
+	MetadataOnly bool
+	// Shard selects the nodes summarized, those reconciled by this replica
+	Shard Shard
+}
+
+// Summarize computes the sync summary of all nodes of the shard
+func (h *StatusHandler) Summarize(ctx context.Context) (clusterStatus, error) {
+	var status clusterStatus
+	names, err := h.nodeNames(ctx)
+	if err != nil {
+		return status, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	status.Nodes = len(names)
+	var oldest time.Time
+	for _, name := range names {
+		record, ok := h.SyncRecords.Get(name)
+		switch {
+		case !ok:
+			status.Pending++
+			continue
+		case record.Result != ResultError:
+			status.Synced++
+		case h.MissingNodes.Contains(name):
+			status.NotFound++
+		default:
+			status.Failed++
+		}
+		if oldest.IsZero() || record.Time.Before(oldest) {
+			oldest = record.Time
+		}
+	}
+	if status.Pending == 0 && !oldest.IsZero() {
+		status.LastFullResyncTime = &oldest
+	}
+
+	if err := h.HealthCheck.Check(nil); err != nil {
+		status.Nautobot.Error = redact.String(err.Error())
+	} else {
+		status.Nautobot.Healthy = true
+	}
+	if nautobotClient, ok := h.HealthCheck.Source.(*nautobot.Client); ok {
+		status.Nautobot.TokenInUse = nautobotClient.TokenInUse()
+	}
+	return status, nil
+}
+
+// nodeNames lists the names of all nodes of the shard
+func (h *StatusHandler) nodeNames(ctx context.Context) ([]string, error) {
+	names, err := listNodeNames(ctx, h.Client, h.MetadataOnly)
+	if err != nil {
+		return nil, err
+	}
+	return h.Shard.Filter(names), nil
+}
+
+// listNodeNames lists the names of all nodes, reading only their metadata if metadataOnly is set
+// so a metadata-only cache is shared
+func listNodeNames(ctx context.Context, reader client.Reader, metadataOnly bool) ([]string, error) {
+	var names []string
+	if metadataOnly {
+		var nodes metav1.PartialObjectMetadataList
+		nodes.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NodeList"))
+		if err := reader.List(ctx, &nodes); err != nil {
+			return nil, err
+		}
+		for _, node := range nodes.Items {
+			names = append(names, node.Name)
+		}
+		return names, nil
+	}
+
+	var nodes corev1.NodeList
+	if err := reader.List(ctx, &nodes); err != nil {
+		return nil, err
+	}
+	for _, node := range nodes.Items {
+		names = append(names, node.Name)
+	}
+	return names, nil
+}
+
+// listNodeMetadata lists the metadata of all nodes, with metadataOnly from a metadata-only cache
+func listNodeMetadata(ctx context.Context, reader client.Reader, metadataOnly bool) ([]metav1.ObjectMeta, error) {
+	var nodes []metav1.ObjectMeta
+	if metadataOnly {
+		var list metav1.PartialObjectMetadataList
+		list.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("NodeList"))
+		if err := reader.List(ctx, &list); err != nil {
+			return nil, err
+		}
+		for _, node := range list.Items {
+			nodes = append(nodes, node.ObjectMeta)
+		}
+		return nodes, nil
+	}
+
+	var list corev1.NodeList
+	if err := reader.List(ctx, &list); err != nil {
+		return nil, err
+	}
+	for _, node := range list.Items {
+		nodes = append(nodes, node.ObjectMeta)
+	}
+	return nodes, nil
+}
+
+// ServeHTTP implements http.Handler
+func (h *StatusHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	status, err := h.Summarize(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, status)
+}
+
+// reconcileError is a failed reconcile kept by RecentErrors
+type reconcileError struct {
+	Time  time.Time `json:"time"`
+	Node  string    `json:"node"`
+	Class string    `json:"class"`
+	Error string    `json:"error"`
+}
+
+// RecentErrors keeps the last reconcile errors in a ring buffer, so recent failures can be
+// inspected after the fact without a central log search.
+type RecentErrors struct {
+	mu      sync.Mutex
+	entries []reconcileError
+	next    int
+	full    bool
+}
+
+// NewRecentErrors returns a RecentErrors keeping the last size errors
+func NewRecentErrors(size int) *RecentErrors {
+	return &RecentErrors{entries: make([]reconcileError, size)}
+}
+
+// Add records a failed reconcile, dropping the oldest entry once the buffer is full
+func (e *RecentErrors) Add(nodeName string, err error) {
+	if e == nil || len(e.entries) == 0 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.entries[e.next] = reconcileError{Time: time.Now().UTC(), Node: nodeName, Class: errorClass(err), Error: redact.String(err.Error())}
+	e.next = (e.next + 1) % len(e.entries)
+	if e.next == 0 {
+		e.full = true
+	}
+}
+
+// List returns the recorded errors, newest first
+func (e *RecentErrors) List() []reconcileError {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	count := e.next
+	if e.full {
+		count = len(e.entries)
+	}
+	list := make([]reconcileError, 0, count)
+	for i := 1; i <= count; i++ {
+		list = append(list, e.entries[(e.next-i+len(e.entries))%len(e.entries)])
+	}
+	return list
+}
+
+// ServeHTTP lists the recent errors as JSON
+func (e *RecentErrors) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, e.List())
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/api/v1alpha1"
+)
+
+// StatusPublisher keeps the cluster-scoped NautobotLabelerStatus singleton up to date. It only
+// runs on the leader, which is the replica reconciling nodes.
+type StatusPublisher struct {
+	Client client.Client
+	// Summary computes the published state
+	Summary *StatusHandler
+	// Name is the name of the singleton object
+	Name string
+	// Interval is the time between two updates
+	Interval time.Duration
+}
+
+// Start implements manager.Runnable
+func (p *StatusPublisher) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("status-publisher")
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.publish(ctx); err != nil {
+			logger.Error(err, "Failed to publish controller status", "Name", p.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// publish creates the singleton if needed and replaces its status with the current summary
+func (p *StatusPublisher) publish(ctx context.Context) error {
+	summary, err := p.Summary.Summarize(ctx)
+	if err != nil {
+		return err
+	}
+
+	var status v1alpha1.NautobotLabelerStatus
+	if err := p.Client.Get(ctx, client.ObjectKey{Name: p.Name}, &status); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get status object: %w", err)
+		}
+		status = v1alpha1.NautobotLabelerStatus{ObjectMeta: metav1.ObjectMeta{Name: p.Name}}
+		if err := p.Client.Create(ctx, &status); err != nil {
+			return fmt.Errorf("failed to create status object: %w", err)
+		}
+	}
+
+	status.Status.Nodes = int32(summary.Nodes)
+	status.Status.Synced = int32(summary.Synced)
+	status.Status.Pending = int32(summary.Pending)
+	status.Status.Failed = int32(summary.Failed)
+	status.Status.NotFound = int32(summary.NotFound)
+	status.Status.LastResyncTime = nil
+	if summary.LastFullResyncTime != nil {
+		status.Status.LastResyncTime = &metav1.Time{Time: *summary.LastFullResyncTime}
+	}
+
+	reachable := metav1.Condition{Type: v1alpha1.ConditionNautobotReachable, Status: metav1.ConditionTrue, Reason: "Reachable"}
+	if !summary.Nautobot.Healthy {
+		reachable.Status, reachable.Reason, reachable.Message = metav1.ConditionFalse, "Unreachable", summary.Nautobot.Error
+	}
+	meta.SetStatusCondition(&status.Status.Conditions, reachable)
+
+	synced := metav1.Condition{Type: v1alpha1.ConditionAllNodesSynced, Status: metav1.ConditionTrue, Reason: "Synced"}
+	switch {
+	case summary.Pending > 0:
+		synced.Status, synced.Reason = metav1.ConditionFalse, "Pending"
+		synced.Message = fmt.Sprintf("%d of %d nodes have not been reconciled yet", summary.Pending, summary.Nodes)
+	case summary.Failed+summary.NotFound > 0:
+		synced.Status, synced.Reason = metav1.ConditionFalse, "Failing"
+		synced.Message = fmt.Sprintf("%d nodes failed to sync, %d have no Nautobot device", summary.Failed, summary.NotFound)
+	}
+	meta.SetStatusCondition(&status.Status.Conditions, synced)
+
+	if err := p.Client.Status().Update(ctx, &status); err != nil {
+		return fmt.Errorf("failed to update status object: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+var tokenPermissionMissing = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "nautobot_labeler_token_permission_missing",
+		Help: "Whether the startup check found the Nautobot token to lack a permission the configuration needs (1) or not (0), by permission.",
+	},
+	[]string{"permission"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(tokenPermissionMissing)
+}
+
+// PermissionCheckMode decides what permissions the Nautobot token lacks at startup do
+type PermissionCheckMode string
+
+const (
+	// PermissionCheckWarn logs the missing permissions and starts anyway
+	PermissionCheckWarn PermissionCheckMode = "warn"
+	// PermissionCheckFail fails the manager if a permission is missing
+	PermissionCheckFail PermissionCheckMode = "fail"
+	// PermissionCheckNone does not check permissions
+	PermissionCheckNone PermissionCheckMode = "none"
+)
+
+// ParsePermissionCheckMode validates a permission check mode name
+func ParsePermissionCheckMode(value string) (PermissionCheckMode, error) {
+	switch mode := PermissionCheckMode(value); mode {
+	case PermissionCheckWarn, PermissionCheckFail, PermissionCheckNone:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown permission check mode %q (expected warn, fail or none)", value)
+	}
+}
+
+// PermissionChecker checks permissions of the Nautobot token, implemented by nautobot.Client
+type PermissionChecker interface {
+	CheckPermission(ctx context.Context, permission nautobot.Permission) error
+}
+
+// TokenPermissions checks once Nautobot answered that its token has the permissions the
+// configuration needs, so a token without them fails clearly at startup instead of with a 403
+// per node
+type TokenPermissions struct {
+	Checker PermissionChecker
+	// Mode decides whether missing permissions fail the manager
+	Mode PermissionCheckMode
+	// Permissions are the permissions checked
+	Permissions []nautobot.Permission
+}
+
+// NautobotPermissions returns the permissions the labeling of a configuration reads Nautobot with
+func NautobotPermissions(config *Config) []nautobot.Permission {
+	var permissions []nautobot.Permission
+	view := func(path string) {
+		permissions = append(permissions, nautobot.Permission{Path: path, Action: nautobot.PermissionView})
+	}
+	if config.ServiceNow != nil {
+		// Devices come from ServiceNow, only virtual machines are looked up in Nautobot
+		if config.VirtualMachines != nil {
+			view("/api/virtualization/virtual-machines/")
+		}
+		return permissions
+	}
+	view("/api/dcim/devices/")
+	// Circuits terminate at the ancestor of the location type, looked up through the locations
+	if len(config.LocationTypes) > 0 || (config.CircuitProviders != nil && config.CircuitProviders.LocationType != "") {
+		view("/api/dcim/locations/")
+	}
+	if len(config.RackGroupLevels()) > 0 {
+		view("/api/dcim/racks/")
+		view("/api/dcim/rack-groups/")
+	}
+	if len(config.InventoryItemMappings()) > 0 {
+		view("/api/dcim/inventory-items/")
+	}
+	if config.PowerRedundancy != nil {
+		view("/api/dcim/power-feeds/")
+	}
+	if config.CircuitProviders != nil {
+		view("/api/circuits/circuits/")
+	}
+	if config.VirtualMachines != nil {
+		view("/api/virtualization/virtual-machines/")
+	}
+	if config.Lifecycle != nil && config.Lifecycle.DeviceLifecyclePlugin {
+		view("/api/plugins/nautobot-device-lifecycle-mgmt/hardware/")
+	}
+	return permissions
+}
+
+// Check checks the permissions, returning an error naming the missing ones in PermissionCheckFail
+// mode. Permissions that could not be checked, e.g. as Nautobot failed, are logged and pass. A
+// nil check passes.
+func (t *TokenPermissions) Check(ctx context.Context) error {
+	if t == nil || t.Mode == PermissionCheckNone {
+		return nil
+	}
+	logger := log.FromContext(ctx).WithName("startup")
+
+	var missing []string
+	for _, permission := range t.Permissions {
+		err := t.Checker.CheckPermission(ctx, permission)
+		switch {
+		case errors.Is(err, nautobot.ErrPermissionDenied):
+			tokenPermissionMissing.WithLabelValues(permission.String()).Set(1)
+			missing = append(missing, permission.String())
+			logger.Error(err, "The Nautobot token lacks a permission the configuration needs", "Permission", permission.String())
+		case err != nil:
+			logger.Error(err, "Failed to check a permission of the Nautobot token", "Permission", permission.String())
+		default:
+			tokenPermissionMissing.WithLabelValues(permission.String()).Set(0)
+		}
+	}
+	if len(missing) > 0 && t.Mode == PermissionCheckFail {
+		return fmt.Errorf("the Nautobot token lacks the permissions %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+)
+
+const (
+	siteLabel = "nautobot.io/site"
+	// maxConfigMapSize is the largest ConfigMap the API server stores
+	maxConfigMapSize = 1 << 20
+)
+
+var topologyMirrorNodes = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "nautobot_labeler_topology_mirror_nodes",
+		Help: "Number of nodes in the topology ConfigMap.",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(topologyMirrorNodes)
+}
+
+// TopologyMirror maintains a ConfigMap with a key per node holding the JSON object of its
+// topology labels: region, zone, rack, site and the labels under TopologyDomainLabelPrefix. It
+// lets workloads that may read a ConfigMap in their namespace, but not Node objects, place
+// themselves. Nodes without any of the labels map to an empty object, deleted nodes are
+// removed, and changes made to the ConfigMap by hand are reset at the next interval.
+type TopologyMirror struct {
+	// Client reads nodes from the cache and writes the ConfigMap
+	Client client.Client
+	// Reader reads the ConfigMap from the API server, as ConfigMaps are not cached
+	Reader    client.Reader
+	Namespace string
+	Name      string
+	// Interval is the time between two updates
+	Interval time.Duration
+	// MetadataOnly lists only node metadata, sharing the reconciler's metadata-only cache
+	MetadataOnly bool
+}
+
+// Start implements manager.Runnable
+func (t *TopologyMirror) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("topology-mirror")
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.apply(ctx); err != nil {
+			logger.Error(err, "Failed to maintain topology ConfigMap", "Namespace", t.Namespace, "Name", t.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// apply creates the ConfigMap if needed and resets its data if it differs from the nodes
+func (t *TopologyMirror) apply(ctx context.Context) error {
+	nodes, err := listNodeMetadata(ctx, t.Client, t.MetadataOnly)
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	data := make(map[string]string, len(nodes))
+	size := 0
+	for _, node := range nodes {
+		value, err := json.Marshal(topologyLabels(node.Labels))
+		if err != nil {
+			return fmt.Errorf("failed to encode topology of node %s: %w", node.Name, err)
+		}
+		data[node.Name] = string(value)
+		size += len(node.Name) + len(value)
+	}
+	if size > maxConfigMapSize {
+		return fmt.Errorf("topology of %d nodes is %d bytes, more than a ConfigMap holds", len(nodes), size)
+	}
+	topologyMirrorNodes.Set(float64(len(data)))
+
+	configMap := &corev1.ConfigMap{}
+	if err := t.Reader.Get(ctx, client.ObjectKey{Namespace: t.Namespace, Name: t.Name}, configMap); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get ConfigMap: %w", err)
+		}
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: t.Namespace,
+				Name:      t.Name,
+				Labels:    map[string]string{managedByLabel: "nautobot-node-labeler"},
+			},
+			Data: data,
+		}
+		if err := t.Client.Create(ctx, configMap); err != nil {
+			return fmt.Errorf("failed to create ConfigMap: %w", err)
+		}
+		return nil
+	}
+
+	if maps.Equal(configMap.Data, data) && configMap.Labels[managedByLabel] == "nautobot-node-labeler" {
+		return nil
+	}
+	original := configMap.DeepCopy()
+	if configMap.Labels == nil {
+		configMap.Labels = map[string]string{}
+	}
+	configMap.Labels[managedByLabel] = "nautobot-node-labeler"
+	configMap.Data = data
+	if err := t.Client.Patch(ctx, configMap, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("failed to update ConfigMap: %w", err)
+	}
+	return nil
+}
+
+// topologyLabels returns the topology labels among the labels of a node
+func topologyLabels(labels map[string]string) map[string]string {
+	topology := map[string]string{}
+	for key, value := range labels {
+		if key == regionLabel || key == zoneLabel || key == rackLabel || key == siteLabel ||
+			strings.HasPrefix(key, configv1alpha1.TopologyDomainLabelPrefix) {
+			topology[key] = value
+		}
+	}
+	return topology
+}
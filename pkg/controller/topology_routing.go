@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// topologyModeAnnotation enables topology-aware routing of a Service
+	topologyModeAnnotation = "service.kubernetes.io/topology-mode"
+	// topologyManagedAnnotation marks the Services whose topology mode the controller set
+	topologyManagedAnnotation = "nautobot.io/topology-mode-managed"
+)
+
+var topologyRoutingVerified = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "nautobot_labeler_topology_routing_verified",
+		Help: "Whether all nodes carry verified zone labels, so topology-aware routing is enabled (1) or not (0).",
+	},
+)
+
+func init() {
+	metrics.Registry.MustRegister(topologyRoutingVerified)
+}
+
+// TopologyRouting enables topology-aware routing of allow-listed Services once the zone labels
+// of all nodes are verified, and disables it again when they no longer are, so routing by zone
+// only ever relies on correct labels. A node's zone label is verified when it is set and was
+// not changed since the controller applied it. Services whose topology mode was set by others
+// are left alone.
+type TopologyRouting struct {
+	// Client reads nodes from the cache and patches Services
+	Client client.Client
+	// Reader lists Services from the API server, as they are not cached
+	Reader client.Reader
+	// Services are the allow-listed Services as namespace/name, or namespace/* for all Services
+	// of a namespace
+	Services []string
+	// Interval is the time between two checks
+	Interval time.Duration
+	// MetadataOnly lists only node metadata, sharing the reconciler's metadata-only cache
+	MetadataOnly bool
+
+	// verified is the result of the last check, logged when it changes
+	verified *bool
+}
+
+// Start implements manager.Runnable
+func (t *TopologyRouting) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("topology-routing")
+	ticker := time.NewTicker(t.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.update(ctx); err != nil {
+			logger.Error(err, "Failed to update topology-aware routing")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// update verifies the zone labels and sets or removes the topology mode of the Services
+func (t *TopologyRouting) update(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("topology-routing")
+	nodes, err := listNodeMetadata(ctx, t.Client, t.MetadataOnly)
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	verified, unverified := len(nodes) > 0, ""
+	for i := range nodes {
+		node := &corev1.Node{ObjectMeta: nodes[i]}
+		if node.Labels[zoneLabel] == "" || labelsChangedOutOfBand(node) {
+			verified, unverified = false, node.Name
+			break
+		}
+	}
+	if t.verified == nil || *t.verified != verified {
+		if verified {
+			logger.Info("Zone labels verified on all nodes, enabling topology-aware routing", "Nodes", len(nodes))
+		} else {
+			logger.Info("Zone labels not verified, disabling topology-aware routing", "NodeName", unverified)
+		}
+		t.verified = &verified
+	}
+	if verified {
+		topologyRoutingVerified.Set(1)
+	} else {
+		topologyRoutingVerified.Set(0)
+	}
+
+	var services corev1.ServiceList
+	if err := t.Reader.List(ctx, &services); err != nil {
+		return fmt.Errorf("failed to list Services: %w", err)
+	}
+	for i := range services.Items {
+		service := &services.Items[i]
+		managed := service.Annotations[topologyManagedAnnotation] == "true"
+		var patch client.Patch
+		switch {
+		case verified && t.allowed(service) && service.Annotations[topologyModeAnnotation] == "":
+			patch = client.MergeFrom(service.DeepCopy())
+			if service.Annotations == nil {
+				service.Annotations = map[string]string{}
+			}
+			service.Annotations[topologyModeAnnotation] = "Auto"
+			service.Annotations[topologyManagedAnnotation] = "true"
+		case managed && (!verified || !t.allowed(service)):
+			patch = client.MergeFrom(service.DeepCopy())
+			delete(service.Annotations, topologyModeAnnotation)
+			delete(service.Annotations, topologyManagedAnnotation)
+		default:
+			continue
+		}
+		if err := t.Client.Patch(ctx, service, patch); err != nil {
+			return fmt.Errorf("failed to update topology mode of Service %s/%s: %w", service.Namespace, service.Name, err)
+		}
+		logger.Info("Updated topology mode of Service", "Namespace", service.Namespace, "Service", service.Name,
+			"TopologyMode", service.Annotations[topologyModeAnnotation])
+	}
+	return nil
+}
+
+// allowed reports whether a Service is allow-listed
+func (t *TopologyRouting) allowed(service *corev1.Service) bool {
+	for _, entry := range t.Services {
+		namespace, name, _ := strings.Cut(entry, "/")
+		if namespace == service.Namespace && (name == "*" || name == service.Name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// tracer creates the controller's spans. It is a no-op until SetupTracing installs a provider.
+var tracer = otel.Tracer("github.com/your-org/k8s-nautobot-node-labeler")
+
+// SetupTracing installs an OTLP/gRPC trace exporter sending to endpoint. The standard
+// OTEL_EXPORTER_OTLP_* environment variables (headers, certificates, ...) are honored as well.
+// The returned function flushes and stops the exporter.
+func SetupTracing(ctx context.Context, endpoint string, insecure bool, samplingRatio float64) (func(context.Context) error, error) {
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName("nautobot-node-labeler"),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(samplingRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// startSpan starts a child span of the span in ctx, tagged with the node being processed
+func startSpan(ctx context.Context, name, nodeName string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attribute.String("k8s.node.name", nodeName)))
+}
+
+// endSpan records err (if any) on the span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(redact.Error(err))
+		span.SetStatus(codes.Error, redact.String(err.Error()))
+	}
+	span.End()
+}
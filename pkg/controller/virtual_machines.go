@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// LookupVirtualMachine returns the Nautobot virtual machine of a node without a device, named
+// like the device would be, with its hypervisor from the configured custom field. It returns an
+// error wrapping nautobot.ErrDeviceNotFound if there is none, or the configuration does not
+// match virtual machines.
+func LookupVirtualMachine(ctx context.Context, client nautobot.Interface, config *Config, node *corev1.Node, key LookupKey) (*nautobot.DeviceData, error) {
+	if config.VirtualMachines == nil || client == nil {
+		return nil, fmt.Errorf("%w for node: %s", nautobot.ErrDeviceNotFound, node.Name)
+	}
+	vm, err := client.GetVirtualMachine(ctx, key.Name(node))
+	if err != nil {
+		return nil, err
+	}
+	if customField := config.VirtualMachines.HostCustomField; customField != "" {
+		// Field also takes the name of hosts nested as objects
+		customFields, err := json.Marshal(vm.CustomFields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the custom fields of virtual machine %s: %w", vm.Name, err)
+		}
+		vm.Hypervisor = configv1alpha1.Field(customFields, customField)
+	}
+	return vm, nil
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+)
+
+// AllowZoneChangeAnnotation lets the zone label of a node change when set to "true", e.g. once
+// its zonal volumes were migrated
+const AllowZoneChangeAnnotation = "nautobot.io/allow-zone-change"
+
+var zoneChangesBlockedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_zone_changes_blocked_total",
+		Help: "Total number of zone label changes refused, by reason (zone_set, volumes).",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(zoneChangesBlockedTotal)
+}
+
+// GuardZoneLabel keeps the current zone label of a node when the desired labels would change
+// it, since local and zonal volumes pin workloads to the zone they were provisioned in. Adding a
+// zone is always allowed, as is replacing the default of a device without one, changing one only
+// with allow or the AllowZoneChangeAnnotation. It returns the refused value, empty if the zone
+// was not guarded.
+func GuardZoneLabel(node *corev1.Node, desired []mapping.Value, allow bool) string {
+	current := node.Labels[zoneLabel]
+	if current == "" || allow || node.Annotations[AllowZoneChangeAnnotation] == "true" {
+		return ""
+	}
+	// The zone of a device without one is the mapping's default, listed as missing
+	for _, missing := range strings.Split(node.Annotations[MissingLabelsAnnotation], ",") {
+		if missing == zoneLabel {
+			return ""
+		}
+	}
+	for i, label := range desired {
+		if label.Key == zoneLabel && label.Value != "" && label.Value != current {
+			desired[i].Value = current
+			return label.Value
+		}
+	}
+	return ""
+}
+
+// recordZoneChangeBlocked counts a refused zone change and emits a Warning event on the node
+// explaining how to allow it
+func recordZoneChangeBlocked(recorder record.EventRecorder, node *corev1.Node, refused string) {
+	zoneChangesBlockedTotal.WithLabelValues("zone_set").Inc()
+
+	if recorder != nil {
+		recorder.Eventf(node, corev1.EventTypeWarning, "ZoneChangeBlocked",
+			"Nautobot places the node in zone %q, but it is labeled %q and zonal volumes may be bound to it; annotate the node with %s=true to allow the change",
+			refused, node.Labels[zoneLabel], AllowZoneChangeAnnotation)
+	}
+}
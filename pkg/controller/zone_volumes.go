@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/mapping"
+)
+
+// ZoneVolumePolicy decides what happens to a zone change of a node whose pods use volumes bound
+// to the old zone
+type ZoneVolumePolicy string
+
+const (
+	// ZoneVolumePolicyBlock keeps the old zone
+	ZoneVolumePolicyBlock ZoneVolumePolicy = "block"
+	// ZoneVolumePolicyWarn changes the zone and reports the volumes
+	ZoneVolumePolicyWarn ZoneVolumePolicy = "warn"
+	// ZoneVolumePolicyOff changes the zone without looking at volumes
+	ZoneVolumePolicyOff ZoneVolumePolicy = "off"
+)
+
+// ParseZoneVolumePolicy validates a zone volume policy name
+func ParseZoneVolumePolicy(value string) (ZoneVolumePolicy, error) {
+	switch policy := ZoneVolumePolicy(value); policy {
+	case ZoneVolumePolicyBlock, ZoneVolumePolicyWarn, ZoneVolumePolicyOff:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown zone volume policy %q (expected block, warn or off)", value)
+	}
+}
+
+// maxReportedVolumes bounds the volumes named in an event
+const maxReportedVolumes = 5
+
+// ZoneVolumeCheck looks at the volumes of the pods on a node before its zone label changes, so
+// a wrong zone in Nautobot does not move a node away from the zonal volumes of its workloads.
+type ZoneVolumeCheck struct {
+	// Reader lists pods by node and reads claims and volumes, usually the uncached API reader
+	Reader client.Reader
+	// Policy is block or warn; off is represented by no ZoneVolumeCheck
+	Policy ZoneVolumePolicy
+}
+
+// Guard finds the volumes pinned to the current zone of a node when the desired labels change
+// it, keeping the current zone in desired with the block policy. It returns the desired zone
+// and the volumes, none if the zone does not change or no volume is pinned to it. A nil
+// ZoneVolumeCheck allows every change. Volumes that cannot be listed block the change too.
+func (z *ZoneVolumeCheck) Guard(ctx context.Context, node *corev1.Node, desired []mapping.Value) (string, []string, error) {
+	current := node.Labels[zoneLabel]
+	if z == nil || current == "" {
+		return "", nil, nil
+	}
+	for i, label := range desired {
+		if label.Key != zoneLabel || label.Value == "" || label.Value == current {
+			continue
+		}
+		volumes, err := z.pinnedVolumes(ctx, node.Name, current)
+		if err != nil || (len(volumes) > 0 && z.Policy != ZoneVolumePolicyWarn) {
+			desired[i].Value = current
+		}
+		return label.Value, volumes, err
+	}
+	return "", nil, nil
+}
+
+// pinnedVolumes returns the bound volumes of the pods on a node whose node affinity requires
+// zone, as namespace/claim (volume)
+func (z *ZoneVolumeCheck) pinnedVolumes(ctx context.Context, nodeName, zone string) ([]string, error) {
+	var pods corev1.PodList
+	if err := z.Reader.List(ctx, &pods, client.MatchingFields{"spec.nodeName": nodeName}); err != nil {
+		return nil, fmt.Errorf("failed to list pods of node %s: %w", nodeName, err)
+	}
+
+	var volumes []string
+	seen := map[types.NamespacedName]bool{}
+	for _, pod := range pods.Items {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim == nil {
+				continue
+			}
+			key := types.NamespacedName{Namespace: pod.Namespace, Name: volume.PersistentVolumeClaim.ClaimName}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			var claim corev1.PersistentVolumeClaim
+			if err := z.Reader.Get(ctx, key, &claim); err != nil {
+				return nil, fmt.Errorf("failed to get claim %s: %w", key, err)
+			}
+			if claim.Status.Phase != corev1.ClaimBound || claim.Spec.VolumeName == "" {
+				continue
+			}
+			var pv corev1.PersistentVolume
+			if err := z.Reader.Get(ctx, types.NamespacedName{Name: claim.Spec.VolumeName}, &pv); err != nil {
+				return nil, fmt.Errorf("failed to get volume %s: %w", claim.Spec.VolumeName, err)
+			}
+			if requiresZone(&pv, zone) {
+				volumes = append(volumes, fmt.Sprintf("%s (%s)", key, pv.Name))
+			}
+		}
+	}
+	sort.Strings(volumes)
+	return volumes, nil
+}
+
+// requiresZone reports whether the node affinity of a volume requires nodes in a zone
+func requiresZone(pv *corev1.PersistentVolume, zone string) bool {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return false
+	}
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expression := range term.MatchExpressions {
+			if (expression.Key == zoneLabel || expression.Key == corev1.LabelFailureDomainBetaZone) &&
+				expression.Operator == corev1.NodeSelectorOpIn && slices.Contains(expression.Values, zone) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// recordZoneVolumeConflict counts a zone change of a node with volumes pinned to its zone, if
+// blocked, and emits a Warning event on the node naming the volumes
+func recordZoneVolumeConflict(recorder record.EventRecorder, node *corev1.Node, zone string, volumes []string, blocked bool) {
+	outcome := "changing it anyway"
+	if blocked {
+		zoneChangesBlockedTotal.WithLabelValues("volumes").Inc()
+		outcome = "keeping the zone"
+	}
+
+	if recorder != nil {
+		named := volumes
+		if len(named) > maxReportedVolumes {
+			named = append(slices.Clip(named[:maxReportedVolumes]), fmt.Sprintf("%d more", len(volumes)-maxReportedVolumes))
+		}
+		recorder.Eventf(node, corev1.EventTypeWarning, "ZoneVolumeConflict",
+			"Nautobot moves the node from zone %q to %q, but volumes of its pods require %q: %s; %s",
+			node.Labels[zoneLabel], zone, node.Labels[zoneLabel], strings.Join(named, ", "), outcome)
+	}
+}
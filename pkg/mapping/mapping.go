@@ -0,0 +1,88 @@
+// Package mapping renders node labels from Nautobot devices with the label mappings of a
+// LabelerConfiguration, the value templates evaluated against every device
+package mapping
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	configv1alpha1 "github.com/your-org/k8s-nautobot-node-labeler/api/config/v1alpha1"
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// Mapping is a LabelMapping with its parsed template
+type Mapping struct {
+	// Label is the key of the label
+	Label string
+	// Template renders the value of the label from TemplateData
+	Template *template.Template
+	// Normalize, if set, normalizes the rendered value
+	Normalize *configv1alpha1.Normalization
+	// Default, if set, is the value when the rendered value is empty
+	Default string
+}
+
+// Compile parses the value templates of validated mappings
+func Compile(mappings []configv1alpha1.LabelMapping) ([]Mapping, error) {
+	compiled := make([]Mapping, 0, len(mappings))
+	for _, mapping := range mappings {
+		tmpl, err := template.New(mapping.Label).Option("missingkey=zero").Funcs(configv1alpha1.TemplateFuncs).Parse(mapping.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value template for label %q: %w", mapping.Label, err)
+		}
+		compiled = append(compiled, Mapping{Label: mapping.Label, Template: tmpl, Normalize: mapping.Normalize, Default: mapping.Default})
+	}
+	return compiled, nil
+}
+
+// TemplateData is what label value templates are evaluated against: the device fields plus the
+// name of the cluster
+type TemplateData struct {
+	*nautobot.DeviceData
+	ClusterName string
+}
+
+// Render evaluates the mappings against a device, returning the label values in mapping order.
+// Values are trimmed and normalized, and empty ones replaced by the default of their mapping; an
+// empty value means the label should not be applied.
+func Render(mappings []Mapping, device *nautobot.DeviceData, clusterName string) ([]Value, error) {
+	data := TemplateData{DeviceData: device, ClusterName: clusterName}
+	values := make([]Value, 0, len(mappings))
+	for _, mapping := range mappings {
+		rendered, err := RenderTemplate(mapping.Template, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render label %q: %w", mapping.Label, err)
+		}
+		values = append(values, mapping.value(mapping.Normalize.Apply(rendered)))
+	}
+	return values, nil
+}
+
+// RenderTemplate executes a template, trimming the result and treating missing values as empty
+func RenderTemplate(tmpl *template.Template, data interface{}) (string, error) {
+	var value strings.Builder
+	if err := tmpl.Execute(&value, data); err != nil {
+		return "", err
+	}
+	rendered := strings.TrimSpace(value.String())
+	if rendered == "<no value>" {
+		rendered = ""
+	}
+	return rendered, nil
+}
+
+// value returns the label value of a rendered value, the default if it is empty
+func (m Mapping) value(rendered string) Value {
+	if rendered == "" && m.Default != "" {
+		return Value{Key: m.Label, Value: m.Default, Defaulted: true}
+	}
+	return Value{Key: m.Label, Value: rendered}
+}
+
+// Value is a rendered label
+type Value struct {
+	Key, Value string
+	// Defaulted is set for the default value of a mapping, the device having no data for it
+	Defaulted bool
+}
@@ -0,0 +1,1025 @@
+package nautobot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// Ref is the nested object representation Nautobot uses for related objects
+type Ref struct {
+	ID      string `json:"id"`
+	Display string `json:"display"`
+	Name    string `json:"name"`
+}
+
+// IPAddress is the subset of a Nautobot IPAddress object we work with
+type IPAddress struct {
+	ID               string `json:"id"`
+	Address          string `json:"address"`
+	AssignedObjectID string `json:"assigned_object_id"`
+	DNSName          string `json:"dns_name"`
+}
+
+// APIError is returned when Nautobot answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	// Detail is the detail message of the response body, e.g. "Invalid token."
+	Detail string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Nautobot returned status %d for %s %s", e.StatusCode, e.Method, e.Path)
+}
+
+// SetEndpoint points the client at another Nautobot URL and token, e.g. after a config reload.
+// The client goes back to the primary token if it changed.
+func (c *Client) SetEndpoint(baseURL, authToken string) {
+	redact.Register(authToken)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed := c.authToken != authToken
+	if c.baseURL != baseURL {
+		c.majorVersion = 0
+	}
+	c.baseURL, c.authToken = baseURL, authToken
+	if changed {
+		c.setUseSecondary(false)
+	}
+}
+
+// SetSecondaryToken sets the token used when the primary one is rejected, so tokens can be
+// rotated without downtime
+func (c *Client) SetSecondaryToken(token string) {
+	redact.Register(token)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.secondaryToken = token
+	if token == "" {
+		c.setUseSecondary(false)
+	}
+}
+
+// SetDeviceFilters sets extra filters of every device query, by filter name of the device list,
+// so devices of the same name outside e.g. a location or tenant are never matched
+func (c *Client) SetDeviceFilters(filters map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deviceFilters = filters
+}
+
+// SetDepth sets the depth of the nested objects of device queries on Nautobot 2.x, so nested
+// objects such as rack groups and location parents come with the devices; 0 leaves it to
+// Nautobot. Location ancestors embedded this way are not looked up.
+func (c *Client) SetDepth(depth int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.depth = depth
+}
+
+// SetIncludeRelationships sets whether device queries include the relationships of devices,
+// filling DeviceData.Relationships
+func (c *Client) SetIncludeRelationships(include bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.includeRelationships = include
+}
+
+// SetIncludeLocations sets whether looked up devices get the location ancestry of their
+// location, filling DeviceData.Locations
+func (c *Client) SetIncludeLocations(include bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.includeLocations = include
+}
+
+// SetIncludeRackGroups sets whether looked up devices get the rack groups their rack is nested
+// in, filling DeviceData.RackGroups
+func (c *Client) SetIncludeRackGroups(include bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.includeRackGroups = include
+}
+
+// SetIncludeInventoryItems sets whether looked up devices get their inventory items, filling
+// DeviceData.InventoryItems
+func (c *Client) SetIncludeInventoryItems(include bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.includeInventoryItems = include
+}
+
+// SetIncludeHardwareNotices sets whether looked up devices get the hardware notice of their
+// device type from the Device Lifecycle Management plugin, filling DeviceData.HardwareNotice
+func (c *Client) SetIncludeHardwareNotices(include bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.includeHardwareNotices = include
+}
+
+// SetIncludePowerFeeds sets whether looked up devices get the power feeds of their rack,
+// filling DeviceData.PowerFeeds
+func (c *Client) SetIncludePowerFeeds(include bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.includePowerFeeds = include
+}
+
+// SetLogRequests sets whether every request to Nautobot is logged with its response status and
+// duration, for debugging. Credentials are redacted from the logged URLs and headers.
+func (c *Client) SetLogRequests(logRequests bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logRequests = logRequests
+}
+
+// logRequest logs a request and its response, nil if it failed, if requests are logged
+func (c *Client) logRequest(req *http.Request, resp *http.Response, duration time.Duration) {
+	c.mu.RLock()
+	logRequests := c.logRequests
+	c.mu.RUnlock()
+	if !logRequests {
+		return
+	}
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	log.Log.WithName("nautobot").Info("Nautobot request", "Method", req.Method, "URL", redact.String(req.URL.String()),
+		"Headers", redact.Header(req.Header), "Status", status, "Duration", duration)
+}
+
+// withDeviceFilters returns query with the device filters added, and the relationships included
+// if they should be
+func (c *Client) withDeviceFilters(query url.Values) url.Values {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	filtered := make(url.Values, len(query)+len(c.deviceFilters)+1)
+	for key, values := range query {
+		filtered[key] = values
+	}
+	for filter, value := range c.deviceFilters {
+		filtered.Set(filter, value)
+	}
+	if c.depth > 0 {
+		filtered.Set("depth", strconv.Itoa(c.depth))
+	}
+	if c.includeRelationships {
+		filtered.Set("include", "relationships")
+	}
+	return filtered
+}
+
+// setUseSecondary switches between the tokens. Callers hold mu.
+func (c *Client) setUseSecondary(useSecondary bool) {
+	c.useSecondary = useSecondary
+	if useSecondary {
+		nautobotTokenInUse.WithLabelValues("primary").Set(0)
+		nautobotTokenInUse.WithLabelValues("secondary").Set(1)
+	} else {
+		nautobotTokenInUse.WithLabelValues("primary").Set(1)
+		nautobotTokenInUse.WithLabelValues("secondary").Set(0)
+	}
+}
+
+// endpoint returns the current Nautobot URL and the token in use
+func (c *Client) endpoint() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.useSecondary {
+		return c.baseURL, c.secondaryToken
+	}
+	return c.baseURL, c.authToken
+}
+
+// TokenInUse returns which token the client currently uses: primary or secondary
+func (c *Client) TokenInUse() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.useSecondary {
+		return "secondary"
+	}
+	return "primary"
+}
+
+// invalidTokenDetail is the detail of Nautobot's 403 for an unknown or expired token
+const invalidTokenDetail = "Invalid token."
+
+// tokenRejected reports whether an error is Nautobot rejecting the token: a 401, or a 403 for
+// an invalid token, which is what Nautobot answers for revoked tokens
+func tokenRejected(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusUnauthorized ||
+		(apiErr.StatusCode == http.StatusForbidden && apiErr.Detail == invalidTokenDetail)
+}
+
+// switchToken moves to the other token after rejectedToken was rejected, reporting whether there
+// is another token to try
+func (c *Client) switchToken(rejectedToken string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.secondaryToken == "" || c.authToken == c.secondaryToken {
+		return false
+	}
+	current := c.authToken
+	if c.useSecondary {
+		current = c.secondaryToken
+	}
+	// Another request may have switched already
+	if current == rejectedToken {
+		c.setUseSecondary(!c.useSecondary)
+		inUse := "primary"
+		if c.useSecondary {
+			inUse = "secondary"
+		}
+		log.Log.WithName("nautobot").Info("Nautobot rejected the token, switched tokens", "TokenInUse", inUse)
+	}
+	return true
+}
+
+// doRequest sends an authenticated request to the Nautobot API. The body, if any, is encoded as
+// JSON and the response is decoded into out when out is non-nil. A request whose token is
+// rejected is retried once with the other token if a secondary token is configured.
+func (c *Client) doRequest(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode Nautobot request body: %w", err)
+		}
+	}
+
+	baseURL, authToken := c.endpoint()
+	err := c.send(ctx, method, baseURL, path, authToken, payload, out)
+	if tokenRejected(err) && c.switchToken(authToken) {
+		baseURL, authToken = c.endpoint()
+		err = c.send(ctx, method, baseURL, path, authToken, payload, out)
+	}
+	return err
+}
+
+// send performs a single request with the given token
+func (c *Client) send(ctx context.Context, method, baseURL, path, authToken string, payload []byte, out interface{}) error {
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request to Nautobot: %w", err)
+	}
+	req.Header.Set("Authorization", "Token "+authToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	// Device lists and bulk queries compress well; asking explicitly instead of relying on the
+	// transport's implicit gzip lets the transferred bytes be measured
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	started := time.Now()
+	resp, err := c.httpClient.Do(req)
+	c.logRequest(req, resp, time.Since(started))
+	if err != nil {
+		return fmt.Errorf("failed to contact Nautobot: %w", redact.Error(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Method: method, Path: path, Detail: errorDetail(resp)}
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	body := &countingReader{reader: resp.Body}
+	var decoded io.Reader = body
+	encoding := "identity"
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress Nautobot response: %w", err)
+		}
+		defer gzipReader.Close()
+		decoded, encoding = gzipReader, "gzip"
+	}
+	defer func() {
+		nautobotResponseBytesTotal.WithLabelValues(encoding).Add(float64(body.count))
+	}()
+	if err := json.NewDecoder(decoded).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse Nautobot response: %w", err)
+	}
+	return nil
+}
+
+// errorDetail returns the detail message of an error response, "" if its body has none
+func errorDetail(resp *http.Response) string {
+	var body io.Reader = io.LimitReader(resp.Body, 4096)
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gzipReader, err := gzip.NewReader(body)
+		if err != nil {
+			return ""
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	}
+	var detail struct {
+		Detail string `json:"detail"`
+	}
+	if err := json.NewDecoder(body).Decode(&detail); err != nil {
+		return ""
+	}
+	return detail.Detail
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+// Read implements io.Reader
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// DeviceURL returns the Nautobot UI URL of a device
+func (c *Client) DeviceURL(deviceID string) string {
+	baseURL, _ := c.endpoint()
+	return fmt.Sprintf("%s/dcim/devices/%s/", baseURL, deviceID)
+}
+
+// siteRegionTTL is how long the region of a site is cached
+const siteRegionTTL = 10 * time.Minute
+
+// siteRegionCache remembers the regions of sites, which rarely change
+type siteRegionCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedRegion
+}
+
+// cachedRegion is a cached region name with the time it was fetched
+type cachedRegion struct {
+	name    string
+	fetched time.Time
+}
+
+// GetSiteRegion returns the name of the region a site belongs to, or "" if it has none.
+// Regions are cached for siteRegionTTL.
+func (c *Client) GetSiteRegion(ctx context.Context, siteID string) (string, error) {
+	cache := &c.siteRegions
+	cache.mu.Lock()
+	entry, ok := cache.entries[siteID]
+	cache.mu.Unlock()
+	if ok && time.Since(entry.fetched) < siteRegionTTL {
+		return entry.name, nil
+	}
+
+	result, err := c.share(ctx, "site/"+siteID, func(ctx context.Context) (interface{}, error) {
+		var site struct {
+			Region *Ref `json:"region"`
+		}
+		if err := c.doRequest(ctx, http.MethodGet, "/api/dcim/sites/"+siteID+"/", nil, &site); err != nil {
+			return "", fmt.Errorf("failed to get site %s: %w", siteID, err)
+		}
+		if site.Region == nil {
+			return "", nil
+		}
+		if site.Region.Name != "" {
+			return site.Region.Name, nil
+		}
+		return site.Region.Display, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	region := result.(string)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.entries == nil {
+		cache.entries = map[string]cachedRegion{}
+	}
+	cache.entries[siteID] = cachedRegion{name: region, fetched: time.Now()}
+	return region, nil
+}
+
+// maxLocationDepth bounds the location ancestries walked, in case of a parent cycle
+const maxLocationDepth = 16
+
+// locationCache remembers the locations of location ancestries, which rarely change
+type locationCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedLocation
+}
+
+// cachedLocation is a cached location with the time it was fetched
+type cachedLocation struct {
+	name, locationType, parentID string
+	fetched                      time.Time
+}
+
+// GetLocationAncestry returns the names of a location and its ancestors keyed by location type,
+// e.g. {"Room": "R101", "Building": "B1", "Campus": "NYC"}. The nearest location of a type wins.
+// Locations are cached for siteRegionTTL.
+func (c *Client) GetLocationAncestry(ctx context.Context, locationID string) (map[string]string, error) {
+	ancestry := map[string]string{}
+	for depth := 0; locationID != "" && depth < maxLocationDepth; depth++ {
+		location, err := c.getLocation(ctx, locationID)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := ancestry[location.locationType]; !ok && location.locationType != "" {
+			ancestry[location.locationType] = location.name
+		}
+		locationID = location.parentID
+	}
+	return ancestry, nil
+}
+
+// embeddedLocation is a location nested in a device object, with its parent nested in turn if
+// the lookup depth reached it
+type embeddedLocation struct {
+	Ref
+	LocationType *Ref            `json:"location_type"`
+	Parent       json.RawMessage `json:"parent"`
+}
+
+// getDeviceLocationAncestry returns the location ancestry of a device from the locations nested
+// in its object, looking up the ancestors beyond the lookup depth
+func (c *Client) getDeviceLocationAncestry(ctx context.Context, deviceData *DeviceData) (map[string]string, error) {
+	var device struct {
+		Location json.RawMessage `json:"location"`
+		Cluster  *struct {
+			Location json.RawMessage `json:"location"`
+		} `json:"cluster"`
+	}
+	if err := json.Unmarshal(deviceData.Raw, &device); err != nil {
+		return c.GetLocationAncestry(ctx, deviceData.LocationID)
+	}
+	raw := device.Location
+	if deviceData.VirtualMachine && device.Cluster != nil {
+		raw = device.Cluster.Location
+	}
+
+	ancestry := map[string]string{}
+	locationID := deviceData.LocationID
+	for depth := 0; locationID != "" && depth < maxLocationDepth; depth++ {
+		var location embeddedLocation
+		if err := json.Unmarshal(raw, &location); err != nil || location.ID != locationID {
+			break
+		}
+		name := location.Name
+		if name == "" {
+			name = location.Display
+		}
+		var locationType string
+		if location.LocationType != nil {
+			locationType = location.LocationType.Name
+			if locationType == "" {
+				locationType = location.LocationType.Display
+			}
+		}
+		// Beyond the lookup depth locations and their types are references without names
+		if name == "" || (location.LocationType != nil && locationType == "") {
+			break
+		}
+		if _, ok := ancestry[locationType]; !ok && locationType != "" {
+			ancestry[locationType] = name
+		}
+		locationID, raw = "", location.Parent
+		var parent Ref
+		if json.Unmarshal(raw, &parent) == nil {
+			locationID = parent.ID
+		}
+	}
+	if locationID == "" {
+		return ancestry, nil
+	}
+	rest, err := c.GetLocationAncestry(ctx, locationID)
+	if err != nil {
+		return nil, err
+	}
+	for locationType, name := range rest {
+		if _, ok := ancestry[locationType]; !ok {
+			ancestry[locationType] = name
+		}
+	}
+	return ancestry, nil
+}
+
+// getLocation returns a location, from the cache if it was fetched within siteRegionTTL
+func (c *Client) getLocation(ctx context.Context, locationID string) (cachedLocation, error) {
+	cache := &c.locations
+	cache.mu.Lock()
+	entry, ok := cache.entries[locationID]
+	cache.mu.Unlock()
+	if ok && time.Since(entry.fetched) < siteRegionTTL {
+		return entry, nil
+	}
+
+	result, err := c.share(ctx, "location/"+locationID, func(ctx context.Context) (interface{}, error) {
+		var location struct {
+			Ref
+			// The names of nested objects take a depth of 1 in Nautobot 2.x
+			LocationType *Ref `json:"location_type"`
+			Parent       *Ref `json:"parent"`
+		}
+		if err := c.doRequest(ctx, http.MethodGet, "/api/dcim/locations/"+locationID+"/?depth=1", nil, &location); err != nil {
+			return cachedLocation{}, fmt.Errorf("failed to get location %s: %w", locationID, err)
+		}
+		entry := cachedLocation{name: location.Name}
+		if entry.name == "" {
+			entry.name = location.Display
+		}
+		if location.LocationType != nil {
+			entry.locationType = location.LocationType.Name
+			if entry.locationType == "" {
+				entry.locationType = location.LocationType.Display
+			}
+		}
+		if location.Parent != nil {
+			entry.parentID = location.Parent.ID
+		}
+		return entry, nil
+	})
+	if err != nil {
+		return cachedLocation{}, err
+	}
+	entry = result.(cachedLocation)
+	entry.fetched = time.Now()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.entries == nil {
+		cache.entries = map[string]cachedLocation{}
+	}
+	cache.entries[locationID] = entry
+	return entry, nil
+}
+
+// hardwareNoticeCache remembers the hardware notices of device types, which rarely change
+type hardwareNoticeCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedHardwareNotice
+}
+
+// cachedHardwareNotice is a cached hardware notice, nil for device types without one, with the
+// time it was fetched
+type cachedHardwareNotice struct {
+	notice  *HardwareNotice
+	fetched time.Time
+}
+
+// GetHardwareNotice returns the hardware notice of a device type from the Device Lifecycle
+// Management plugin, or nil if it has none. Notices are cached for siteRegionTTL.
+func (c *Client) GetHardwareNotice(ctx context.Context, deviceTypeID string) (*HardwareNotice, error) {
+	cache := &c.hardwareNotices
+	cache.mu.Lock()
+	entry, ok := cache.entries[deviceTypeID]
+	cache.mu.Unlock()
+	if ok && time.Since(entry.fetched) < siteRegionTTL {
+		return entry.notice, nil
+	}
+
+	result, err := c.share(ctx, "hardware-notice/"+deviceTypeID, func(ctx context.Context) (interface{}, error) {
+		query := url.Values{"device_type_id": {deviceTypeID}}
+		var list struct {
+			Results []HardwareNotice `json:"results"`
+		}
+		if err := c.doRequest(ctx, http.MethodGet, "/api/plugins/nautobot-device-lifecycle-mgmt/hardware/?"+query.Encode(), nil, &list); err != nil {
+			return (*HardwareNotice)(nil), fmt.Errorf("failed to get the hardware notice of device type %s: %w", deviceTypeID, err)
+		}
+		if len(list.Results) == 0 {
+			return (*HardwareNotice)(nil), nil
+		}
+		return &list.Results[0], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	notice := result.(*HardwareNotice)
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.entries == nil {
+		cache.entries = map[string]cachedHardwareNotice{}
+	}
+	cache.entries[deviceTypeID] = cachedHardwareNotice{notice: notice, fetched: time.Now()}
+	return notice, nil
+}
+
+// GetInventoryItems returns the inventory items of a device
+func (c *Client) GetInventoryItems(ctx context.Context, deviceID string) ([]InventoryItem, error) {
+	query := url.Values{"device_id": {deviceID}, "depth": {"1"}, "limit": {"1000"}}
+	raws, err := listAll[json.RawMessage](ctx, c, "/api/dcim/inventory-items/?"+query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the inventory items of device %s: %w", deviceID, err)
+	}
+	items := make([]InventoryItem, 0, len(raws))
+	for _, raw := range raws {
+		var result struct {
+			Name         string `json:"name"`
+			Manufacturer *Ref   `json:"manufacturer"`
+			PartID       string `json:"part_id"`
+			Tags         []Ref  `json:"tags"`
+		}
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse Nautobot response: %w", err)
+		}
+		item := InventoryItem{Name: result.Name, PartID: result.PartID, Raw: raw}
+		if result.Manufacturer != nil {
+			item.Manufacturer = result.Manufacturer.Name
+			if item.Manufacturer == "" {
+				item.Manufacturer = result.Manufacturer.Display
+			}
+		}
+		for _, tag := range result.Tags {
+			name := tag.Name
+			if name == "" {
+				name = tag.Display
+			}
+			item.Tags = append(item.Tags, name)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// GetPowerFeeds returns the power feeds of a rack. The nodes of a rack share one request.
+func (c *Client) GetPowerFeeds(ctx context.Context, rackID string) ([]PowerFeed, error) {
+	result, err := c.share(ctx, "power-feeds/"+rackID, func(ctx context.Context) (interface{}, error) {
+		query := url.Values{"rack_id": {rackID}, "limit": {"1000"}}
+		raws, err := listAll[json.RawMessage](ctx, c, "/api/dcim/power-feeds/?"+query.Encode())
+		if err != nil {
+			return []PowerFeed(nil), fmt.Errorf("failed to list the power feeds of rack %s: %w", rackID, err)
+		}
+		feeds := make([]PowerFeed, 0, len(raws))
+		for _, raw := range raws {
+			var result struct {
+				Name   string `json:"name"`
+				Status struct {
+					Value string `json:"value"`
+					// Name is the status of Nautobot 2.x, which dropped the value
+					Name string `json:"name"`
+				} `json:"status"`
+			}
+			if err := json.Unmarshal(raw, &result); err != nil {
+				return []PowerFeed(nil), fmt.Errorf("failed to parse Nautobot response: %w", err)
+			}
+			status := result.Status.Value
+			if status == "" {
+				status = strings.ToLower(result.Status.Name)
+			}
+			feeds = append(feeds, PowerFeed{Name: result.Name, Status: status})
+		}
+		return feeds, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]PowerFeed), nil
+}
+
+// GetInterfaceID returns the ID of the named interface on a device.
+func (c *Client) GetInterfaceID(ctx context.Context, deviceID, name string) (string, error) {
+	query := url.Values{"device_id": {deviceID}, "name": {name}}
+	var list struct {
+		Results []Ref `json:"results"`
+	}
+	if err := c.doRequest(ctx, http.MethodGet, "/api/dcim/interfaces/?"+query.Encode(), nil, &list); err != nil {
+		return "", err
+	}
+	if len(list.Results) == 0 {
+		return "", fmt.Errorf("no interface %q found in Nautobot for device %s", name, deviceID)
+	}
+	return list.Results[0].ID, nil
+}
+
+// EnsureInterfaceIPAddress makes sure an IPAddress object exists for address and is assigned to
+// the given interface, creating or assigning it as needed. It returns the IPAddress ID. Nautobot
+// 1.x assigns an address to one interface, so it is moved; 2.x assigns addresses through
+// ip-address-to-interface objects, of which an address may have several, so assignments to other
+// interfaces are kept.
+func (c *Client) EnsureInterfaceIPAddress(ctx context.Context, address, interfaceID string) (string, error) {
+	version, err := c.MajorVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{"address": {address}}
+	var list struct {
+		Results []IPAddress `json:"results"`
+	}
+	if err := c.doRequest(ctx, http.MethodGet, "/api/ipam/ip-addresses/?"+query.Encode(), nil, &list); err != nil {
+		return "", err
+	}
+
+	assignment := map[string]interface{}{
+		"assigned_object_type": "dcim.interface",
+		"assigned_object_id":   interfaceID,
+	}
+
+	if len(list.Results) == 0 {
+		body := map[string]interface{}{
+			"address": hostPrefix(address),
+			"status":  statusField(version, "Active"),
+		}
+		if version < 2 {
+			for k, v := range assignment {
+				body[k] = v
+			}
+		}
+		var created IPAddress
+		if err := c.doRequest(ctx, http.MethodPost, "/api/ipam/ip-addresses/", body, &created); err != nil {
+			return "", fmt.Errorf("failed to create IP address %s: %w", address, err)
+		}
+		if version >= 2 {
+			if err := c.assignIPAddressToInterface(ctx, created.ID, interfaceID); err != nil {
+				return "", fmt.Errorf("failed to assign IP address %s to interface: %w", address, err)
+			}
+		}
+		return created.ID, nil
+	}
+
+	existing := list.Results[0]
+	if version >= 2 {
+		if err := c.assignIPAddressToInterface(ctx, existing.ID, interfaceID); err != nil {
+			return "", fmt.Errorf("failed to assign IP address %s to interface: %w", address, err)
+		}
+		return existing.ID, nil
+	}
+	if existing.AssignedObjectID != interfaceID {
+		if err := c.doRequest(ctx, http.MethodPatch, "/api/ipam/ip-addresses/"+existing.ID+"/", assignment, nil); err != nil {
+			return "", fmt.Errorf("failed to assign IP address %s to interface: %w", address, err)
+		}
+	}
+	return existing.ID, nil
+}
+
+// assignIPAddressToInterface creates the Nautobot 2.x ip-address-to-interface object assigning an
+// IP address to an interface, unless it exists
+func (c *Client) assignIPAddressToInterface(ctx context.Context, ipAddressID, interfaceID string) error {
+	query := url.Values{"ip_address": {ipAddressID}, "interface": {interfaceID}}
+	var list struct {
+		Results []Ref `json:"results"`
+	}
+	if err := c.doRequest(ctx, http.MethodGet, "/api/ipam/ip-address-to-interface/?"+query.Encode(), nil, &list); err != nil {
+		return err
+	}
+	if len(list.Results) > 0 {
+		return nil
+	}
+	body := map[string]interface{}{"ip_address": ipAddressID, "interface": interfaceID}
+	return c.doRequest(ctx, http.MethodPost, "/api/ipam/ip-address-to-interface/", body, nil)
+}
+
+// UpdateDevice applies a partial update to a device.
+func (c *Client) UpdateDevice(ctx context.Context, deviceID string, fields map[string]interface{}) error {
+	if err := c.doRequest(ctx, http.MethodPatch, "/api/dcim/devices/"+deviceID+"/", fields, nil); err != nil {
+		return fmt.Errorf("failed to update device %s: %w", deviceID, err)
+	}
+	return nil
+}
+
+// UpdateDeviceStatus sets the status of a device, given by name, e.g. "Offline", in the form of
+// the Nautobot version
+func (c *Client) UpdateDeviceStatus(ctx context.Context, deviceID, status string) error {
+	version, err := c.MajorVersion(ctx)
+	if err != nil {
+		return err
+	}
+	return c.UpdateDevice(ctx, deviceID, map[string]interface{}{"status": statusField(version, status)})
+}
+
+// listResponse is the paginated list envelope returned by Nautobot
+type listResponse[T any] struct {
+	Next    string `json:"next"`
+	Results []T    `json:"results"`
+}
+
+// listAll fetches every page of a Nautobot list endpoint.
+func listAll[T any](ctx context.Context, c *Client, path string) ([]T, error) {
+	var all []T
+	for path != "" {
+		var page listResponse[T]
+		if err := c.doRequest(ctx, http.MethodGet, path, nil, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Results...)
+		baseURL, _ := c.endpoint()
+		next, err := nextPagePath(page.Next, baseURL)
+		if err != nil {
+			return nil, err
+		}
+		path = next
+	}
+	return all, nil
+}
+
+// nextPagePath returns the path of the absolute next page URL of a list relative to baseURL, ""
+// for the last page. Behind a reverse proxy the URL may have another scheme or host than
+// baseURL, so only its path and query are kept, without the path prefix of baseURL.
+func nextPagePath(next, baseURL string) (string, error) {
+	if next == "" {
+		return "", nil
+	}
+	nextURL, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse next page URL %q: %w", next, err)
+	}
+	path := nextURL.RequestURI()
+	if base, err := url.Parse(baseURL); err == nil {
+		if prefix := strings.TrimSuffix(base.Path, "/"); prefix != "" && strings.HasPrefix(path, prefix+"/") {
+			path = strings.TrimPrefix(path, prefix)
+		}
+	}
+	return path, nil
+}
+
+// ListDevices returns all devices of Nautobot, with the regions of their sites
+func (c *Client) ListDevices(ctx context.Context) ([]*DeviceData, error) {
+	path := "/api/dcim/devices/?" + c.withDeviceFilters(url.Values{"limit": {"1000"}}).Encode()
+	raws, err := listAll[json.RawMessage](ctx, c, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	devices := make([]*DeviceData, 0, len(raws))
+	for _, raw := range raws {
+		deviceData, err := ParseDevice(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Nautobot response: %w", err)
+		}
+		deviceData.Query = path
+		if err := c.completeDevice(ctx, deviceData); err != nil {
+			return nil, err
+		}
+		devices = append(devices, deviceData)
+	}
+	return devices, nil
+}
+
+// EnsureVirtualizationCluster returns the ID of the named virtualization cluster, creating it
+// (and its cluster type) if it does not exist yet.
+func (c *Client) EnsureVirtualizationCluster(ctx context.Context, name, typeName string) (string, error) {
+	version, err := c.MajorVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	clusters, err := listAll[Ref](ctx, c, "/api/virtualization/clusters/?"+url.Values{"name": {name}}.Encode())
+	if err != nil {
+		return "", err
+	}
+	if len(clusters) > 0 {
+		return clusters[0].ID, nil
+	}
+
+	types, err := listAll[Ref](ctx, c, "/api/virtualization/cluster-types/?"+url.Values{"name": {typeName}}.Encode())
+	if err != nil {
+		return "", err
+	}
+	var typeID string
+	if len(types) > 0 {
+		typeID = types[0].ID
+	} else {
+		var created Ref
+		if err := c.doRequest(ctx, http.MethodPost, "/api/virtualization/cluster-types/", map[string]interface{}{"name": typeName}, &created); err != nil {
+			return "", fmt.Errorf("failed to create cluster type %s: %w", typeName, err)
+		}
+		typeID = created.ID
+	}
+
+	// Nautobot 2.x renamed the type of clusters
+	typeField := "type"
+	if version >= 2 {
+		typeField = "cluster_type"
+	}
+	var created Ref
+	if err := c.doRequest(ctx, http.MethodPost, "/api/virtualization/clusters/", map[string]interface{}{"name": name, typeField: typeID}, &created); err != nil {
+		return "", fmt.Errorf("failed to create virtualization cluster %s: %w", name, err)
+	}
+	return created.ID, nil
+}
+
+// ListClusterVirtualMachines returns the virtual machines in a cluster with a tag, keyed by
+// name
+func (c *Client) ListClusterVirtualMachines(ctx context.Context, clusterID, tag string) (map[string]string, error) {
+	tagFilter, err := c.tagFilter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vms, err := listAll[Ref](ctx, c, "/api/virtualization/virtual-machines/?"+url.Values{"cluster_id": {clusterID}, tagFilter: {tag}, "limit": {"1000"}}.Encode())
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]string, len(vms))
+	for _, vm := range vms {
+		byName[vm.Name] = vm.ID
+	}
+	return byName, nil
+}
+
+// GetClusterVirtualMachine returns the ID of the named virtual machine in a cluster, "" if there
+// is none. With a tag, only a virtual machine with the tag is returned.
+func (c *Client) GetClusterVirtualMachine(ctx context.Context, clusterID, name, tag string) (string, error) {
+	query := url.Values{"cluster_id": {clusterID}, "name": {name}}
+	if tag != "" {
+		tagFilter, err := c.tagFilter(ctx)
+		if err != nil {
+			return "", err
+		}
+		query.Set(tagFilter, tag)
+	}
+	vms, err := listAll[Ref](ctx, c, "/api/virtualization/virtual-machines/?"+query.Encode())
+	if err != nil {
+		return "", fmt.Errorf("failed to look up virtual machine %s: %w", name, err)
+	}
+	if len(vms) == 0 {
+		return "", nil
+	}
+	return vms[0].ID, nil
+}
+
+// CreateVirtualMachine adds an active virtual machine with a tag to a cluster
+func (c *Client) CreateVirtualMachine(ctx context.Context, name, clusterID, tagID string) error {
+	version, err := c.MajorVersion(ctx)
+	if err != nil {
+		return err
+	}
+	body := map[string]interface{}{"name": name, "cluster": clusterID, "status": statusField(version, "Active"), "tags": []string{tagID}}
+	if err := c.doRequest(ctx, http.MethodPost, "/api/virtualization/virtual-machines/", body, nil); err != nil {
+		return fmt.Errorf("failed to create virtual machine %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteVirtualMachine removes a virtual machine.
+func (c *Client) DeleteVirtualMachine(ctx context.Context, id string) error {
+	if err := c.doRequest(ctx, http.MethodDelete, "/api/virtualization/virtual-machines/"+id+"/", nil, nil); err != nil {
+		return fmt.Errorf("failed to delete virtual machine %s: %w", id, err)
+	}
+	return nil
+}
+
+// EnsureTag returns the ID of the named tag, creating it for the content types, devices if none
+// are given, if it does not exist yet.
+func (c *Client) EnsureTag(ctx context.Context, name string, contentTypes ...string) (string, error) {
+	tags, err := listAll[Ref](ctx, c, "/api/extras/tags/?"+url.Values{"name": {name}}.Encode())
+	if err != nil {
+		return "", err
+	}
+	if len(tags) > 0 {
+		return tags[0].ID, nil
+	}
+
+	if len(contentTypes) == 0 {
+		contentTypes = []string{"dcim.device"}
+	}
+	body := map[string]interface{}{"name": name, "content_types": contentTypes}
+	var created Ref
+	if err := c.doRequest(ctx, http.MethodPost, "/api/extras/tags/", body, &created); err != nil {
+		return "", fmt.Errorf("failed to create tag %s: %w", name, err)
+	}
+	return created.ID, nil
+}
+
+// Ping checks that Nautobot is reachable and accepts our token.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.doRequest(ctx, http.MethodGet, "/api/status/", nil, nil)
+}
+
+// GetIPAddressDNSName returns the DNS name of an IP address
+func (c *Client) GetIPAddressDNSName(ctx context.Context, id string) (string, error) {
+	var address IPAddress
+	if err := c.doRequest(ctx, http.MethodGet, "/api/ipam/ip-addresses/"+id+"/", nil, &address); err != nil {
+		return "", fmt.Errorf("failed to get IP address %s: %w", id, err)
+	}
+	return address.DNSName, nil
+}
+
+// hostPrefix turns a bare IP into the host prefix notation Nautobot expects for IPAddress objects.
+func hostPrefix(address string) string {
+	if net.ParseIP(address).To4() != nil {
+		return fmt.Sprintf("%s/32", address)
+	}
+	return fmt.Sprintf("%s/128", address)
+}
@@ -0,0 +1,400 @@
+package nautobot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// tokenServer answers requests with the token valid as 200 and others with rejection
+func tokenServer(t *testing.T, valid string, rejection int, body string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Token "+valid {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(rejection)
+			_, _ = w.Write([]byte(body))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDoRequestSwitchesRejectedToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		rejection  int
+		body       string
+		wantSwitch bool
+	}{
+		{name: "401", rejection: http.StatusUnauthorized, body: `{"detail":"Authentication credentials were not provided."}`, wantSwitch: true},
+		{name: "403 invalid token", rejection: http.StatusForbidden, body: `{"detail":"Invalid token."}`, wantSwitch: true},
+		{name: "403 permission denied", rejection: http.StatusForbidden, body: `{"detail":"You do not have permission to perform this action."}`},
+		{name: "500", rejection: http.StatusInternalServerError, body: `oops`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := tokenServer(t, "new", tt.rejection, tt.body)
+			c := NewClient(server.URL, "old", nil)
+			c.SetSecondaryToken("new")
+
+			err := c.doRequest(context.Background(), http.MethodGet, "/api/status/", nil, &struct{}{})
+			if tt.wantSwitch {
+				if err != nil {
+					t.Fatalf("doRequest() error = %v, want retry with the secondary token", err)
+				}
+				if got := c.TokenInUse(); got != "secondary" {
+					t.Errorf("TokenInUse() = %q, want secondary", got)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("doRequest() succeeded, want the rejection")
+			}
+			if got := c.TokenInUse(); got != "primary" {
+				t.Errorf("TokenInUse() = %q, want primary", got)
+			}
+		})
+	}
+}
+
+func TestDoRequestErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantStatus int
+		wantDetail string
+		// wantDecodeErr expects the body to fail to decode
+		wantDecodeErr bool
+	}{
+		{name: "success", status: http.StatusOK, body: `{"name":"worker-1"}`},
+		{name: "not found", status: http.StatusNotFound, body: `{"detail":"Not found."}`, wantStatus: http.StatusNotFound, wantDetail: "Not found."},
+		{name: "server error without detail", status: http.StatusBadGateway, body: `<html>bad gateway</html>`, wantStatus: http.StatusBadGateway},
+		{name: "invalid JSON", status: http.StatusOK, body: `{"name":`, wantDecodeErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			c := NewClient(server.URL, "token", nil)
+
+			var result struct {
+				Name string `json:"name"`
+			}
+			err := c.doRequest(context.Background(), http.MethodGet, "/api/dcim/devices/d1/", nil, &result)
+			var apiErr *APIError
+			switch {
+			case tt.wantStatus != 0:
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("doRequest() error = %v, want an APIError", err)
+				}
+				if apiErr.StatusCode != tt.wantStatus || apiErr.Detail != tt.wantDetail {
+					t.Errorf("APIError = %d %q, want %d %q", apiErr.StatusCode, apiErr.Detail, tt.wantStatus, tt.wantDetail)
+				}
+			case tt.wantDecodeErr:
+				if err == nil || errors.As(err, &apiErr) {
+					t.Errorf("doRequest() error = %v, want a decoding error", err)
+				}
+			default:
+				if err != nil || result.Name != "worker-1" {
+					t.Errorf("doRequest() = %+v, %v, want worker-1", result, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSetEndpointKeepsSecondaryForSameToken(t *testing.T) {
+	server := tokenServer(t, "new", http.StatusForbidden, `{"detail":"Invalid token."}`)
+	c := NewClient(server.URL, "old", nil)
+	c.SetSecondaryToken("new")
+	if err := c.doRequest(context.Background(), http.MethodGet, "/api/status/", nil, nil); err != nil {
+		t.Fatalf("doRequest() error = %v", err)
+	}
+
+	// An unrelated reload keeps the token that works
+	c.SetEndpoint(server.URL, "old")
+	if got := c.TokenInUse(); got != "secondary" {
+		t.Errorf("TokenInUse() after reload with the same token = %q, want secondary", got)
+	}
+	// A new primary token is tried first
+	c.SetEndpoint(server.URL, "newer")
+	if got := c.TokenInUse(); got != "primary" {
+		t.Errorf("TokenInUse() after reload with a new token = %q, want primary", got)
+	}
+}
+
+func TestClusterVirtualMachineQueries(t *testing.T) {
+	tests := []struct {
+		version   string
+		wantQuery string
+	}{
+		{version: "1.6.8", wantQuery: "cluster_id=c1&name=worker-1&tag=nautobot-node-labeler"},
+		{version: "2.2.0", wantQuery: "cluster_id=c1&name=worker-1&tags=nautobot-node-labeler"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			var queries []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/api/status/" {
+					_, _ = w.Write([]byte(`{"nautobot-version":"` + tt.version + `"}`))
+					return
+				}
+				queries = append(queries, req.URL.RawQuery)
+				_, _ = w.Write([]byte(`{"count":1,"next":null,"results":[{"id":"vm-1","name":"worker-1"}]}`))
+			}))
+			defer server.Close()
+			c := NewClient(server.URL, "token", nil)
+
+			id, err := c.GetClusterVirtualMachine(context.Background(), "c1", "worker-1", "nautobot-node-labeler")
+			if err != nil {
+				t.Fatalf("GetClusterVirtualMachine() error = %v", err)
+			}
+			if id != "vm-1" {
+				t.Errorf("GetClusterVirtualMachine() = %q, want vm-1", id)
+			}
+			if len(queries) != 1 || queries[0] != tt.wantQuery {
+				t.Errorf("queries = %v, want [%s]", queries, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestNextPagePath(t *testing.T) {
+	tests := []struct {
+		name    string
+		next    string
+		baseURL string
+		want    string
+	}{
+		{name: "last page", next: "", baseURL: "https://nautobot.example.com", want: ""},
+		{name: "same host", next: "https://nautobot.example.com/api/dcim/devices/?limit=1000&offset=1000",
+			baseURL: "https://nautobot.example.com", want: "/api/dcim/devices/?limit=1000&offset=1000"},
+		{name: "proxy scheme", next: "http://nautobot.example.com/api/dcim/devices/?offset=1000",
+			baseURL: "https://nautobot.example.com", want: "/api/dcim/devices/?offset=1000"},
+		{name: "internal host", next: "http://nautobot-web.nautobot.svc:8080/api/dcim/devices/?offset=1000",
+			baseURL: "https://nautobot.example.com", want: "/api/dcim/devices/?offset=1000"},
+		{name: "path prefix", next: "http://10.0.0.1/nautobot/api/dcim/devices/?offset=1000",
+			baseURL: "https://example.com/nautobot/", want: "/api/dcim/devices/?offset=1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextPagePath(tt.next, tt.baseURL)
+			if err != nil {
+				t.Fatalf("nextPagePath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("nextPagePath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListAllFollowsNextPages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// The proxy in front of Nautobot rewrites the host of the next links
+		next := "http://nautobot.internal" + req.URL.Path + "?offset=2"
+		switch req.URL.Query().Get("offset") {
+		case "":
+			_, _ = w.Write([]byte(`{"next":"` + next + `","results":[{"id":"1"},{"id":"2"}]}`))
+		case "2":
+			_, _ = w.Write([]byte(`{"next":null,"results":[{"id":"3"}]}`))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+	c := NewClient(server.URL, "token", nil)
+
+	refs, err := listAll[Ref](context.Background(), c, "/api/dcim/devices/?limit=2")
+	if err != nil {
+		t.Fatalf("listAll() error = %v", err)
+	}
+	if len(refs) != 3 || refs[2].ID != "3" {
+		t.Errorf("listAll() = %v, want 3 objects", refs)
+	}
+}
+
+func TestEnsureInterfaceIPAddress(t *testing.T) {
+	tests := []struct {
+		name         string
+		version      string
+		existing     string
+		assigned     bool
+		wantRequests []string
+	}{
+		{
+			name:    "1.x new address",
+			version: "1.6.8",
+			wantRequests: []string{
+				"GET /api/ipam/ip-addresses/?address=10.0.0.1",
+				`POST /api/ipam/ip-addresses/ {"address":"10.0.0.1/32","assigned_object_id":"if-1","assigned_object_type":"dcim.interface","status":"active"}`,
+			},
+		},
+		{
+			name:     "1.x address on another interface",
+			version:  "1.6.8",
+			existing: `{"id":"ip-1","address":"10.0.0.1/32","assigned_object_id":"if-2"}`,
+			wantRequests: []string{
+				"GET /api/ipam/ip-addresses/?address=10.0.0.1",
+				`PATCH /api/ipam/ip-addresses/ip-1/ {"assigned_object_id":"if-1","assigned_object_type":"dcim.interface"}`,
+			},
+		},
+		{
+			name:    "2.x new address",
+			version: "2.2.0",
+			wantRequests: []string{
+				"GET /api/ipam/ip-addresses/?address=10.0.0.1",
+				`POST /api/ipam/ip-addresses/ {"address":"10.0.0.1/32","status":{"name":"Active"}}`,
+				"GET /api/ipam/ip-address-to-interface/?interface=if-1&ip_address=ip-1",
+				`POST /api/ipam/ip-address-to-interface/ {"interface":"if-1","ip_address":"ip-1"}`,
+			},
+		},
+		{
+			name:     "2.x assigned address",
+			version:  "2.2.0",
+			existing: `{"id":"ip-1","address":"10.0.0.1/32"}`,
+			assigned: true,
+			wantRequests: []string{
+				"GET /api/ipam/ip-addresses/?address=10.0.0.1",
+				"GET /api/ipam/ip-address-to-interface/?interface=if-1&ip_address=ip-1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/api/status/" {
+					_, _ = w.Write([]byte(`{"nautobot-version":"` + tt.version + `"}`))
+					return
+				}
+				request := req.Method + " " + req.URL.Path
+				if req.URL.RawQuery != "" {
+					request += "?" + req.URL.RawQuery
+				}
+				if body, _ := io.ReadAll(req.Body); len(body) > 0 {
+					request += " " + strings.TrimSpace(string(body))
+				}
+				requests = append(requests, request)
+				switch {
+				case req.Method == http.MethodPost && req.URL.Path == "/api/ipam/ip-addresses/":
+					_, _ = w.Write([]byte(`{"id":"ip-1"}`))
+				case req.URL.Path == "/api/ipam/ip-addresses/" && tt.existing != "":
+					_, _ = w.Write([]byte(`{"results":[` + tt.existing + `]}`))
+				case req.URL.Path == "/api/ipam/ip-address-to-interface/" && req.Method == http.MethodGet && tt.assigned:
+					_, _ = w.Write([]byte(`{"results":[{"id":"a-1"}]}`))
+				default:
+					_, _ = w.Write([]byte(`{"results":[]}`))
+				}
+			}))
+			defer server.Close()
+			c := NewClient(server.URL, "token", nil)
+
+			id, err := c.EnsureInterfaceIPAddress(context.Background(), "10.0.0.1", "if-1")
+			if err != nil {
+				t.Fatalf("EnsureInterfaceIPAddress() error = %v", err)
+			}
+			if id != "ip-1" {
+				t.Errorf("EnsureInterfaceIPAddress() = %q, want ip-1", id)
+			}
+			if !reflect.DeepEqual(requests, tt.wantRequests) {
+				t.Errorf("requests = %q, want %q", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// recordingServer answers /api/status/ with version and records the other requests as method,
+// path, query and body, answering them with respond
+func recordingServer(t *testing.T, version string, respond func(req *http.Request) string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/status/" {
+			_, _ = w.Write([]byte(`{"nautobot-version":"` + version + `"}`))
+			return
+		}
+		request := req.Method + " " + req.URL.Path
+		if req.URL.RawQuery != "" {
+			request += "?" + req.URL.RawQuery
+		}
+		if body, _ := io.ReadAll(req.Body); len(body) > 0 {
+			request += " " + strings.TrimSpace(string(body))
+		}
+		requests = append(requests, request)
+		_, _ = w.Write([]byte(respond(req)))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestEnsureVirtualizationCluster(t *testing.T) {
+	tests := []struct {
+		version    string
+		wantCreate string
+	}{
+		{version: "1.6.8", wantCreate: `POST /api/virtualization/clusters/ {"name":"prod","type":"t1"}`},
+		{version: "2.2.0", wantCreate: `POST /api/virtualization/clusters/ {"cluster_type":"t1","name":"prod"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			server, requests := recordingServer(t, tt.version, func(req *http.Request) string {
+				switch {
+				case req.URL.Path == "/api/virtualization/cluster-types/":
+					return `{"results":[{"id":"t1","name":"Kubernetes"}]}`
+				case req.Method == http.MethodPost:
+					return `{"id":"c1"}`
+				default:
+					return `{"results":[]}`
+				}
+			})
+			c := NewClient(server.URL, "token", nil)
+
+			id, err := c.EnsureVirtualizationCluster(context.Background(), "prod", "Kubernetes")
+			if err != nil {
+				t.Fatalf("EnsureVirtualizationCluster() error = %v", err)
+			}
+			if id != "c1" {
+				t.Errorf("EnsureVirtualizationCluster() = %q, want c1", id)
+			}
+			if got := (*requests)[len(*requests)-1]; got != tt.wantCreate {
+				t.Errorf("last request = %s, want %s", got, tt.wantCreate)
+			}
+		})
+	}
+}
+
+func TestCreateVirtualMachine(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "1.6.8", want: `POST /api/virtualization/virtual-machines/ {"cluster":"c1","name":"worker-1","status":"active","tags":["tag-1"]}`},
+		{version: "2.2.0", want: `POST /api/virtualization/virtual-machines/ {"cluster":"c1","name":"worker-1","status":{"name":"Active"},"tags":["tag-1"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			server, requests := recordingServer(t, tt.version, func(*http.Request) string { return `{"id":"vm-1"}` })
+			c := NewClient(server.URL, "token", nil)
+
+			if err := c.CreateVirtualMachine(context.Background(), "worker-1", "c1", "tag-1"); err != nil {
+				t.Fatalf("CreateVirtualMachine() error = %v", err)
+			}
+			if !reflect.DeepEqual(*requests, []string{tt.want}) {
+				t.Errorf("requests = %q, want %q", *requests, []string{tt.want})
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package nautobot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Circuit is a circuit of the circuits app terminating at a site or location
+type Circuit struct {
+	// CID is the circuit ID assigned by the provider
+	CID      string
+	Provider string
+	// Status is the status value of the circuit, e.g. "active" or "decommissioned"
+	Status string
+}
+
+// SetIncludeCircuits sets whether looked up devices get the circuits terminating at their site,
+// or in Nautobot 2.x at their location or its ancestor of locationType if set, filling
+// DeviceData.Circuits
+func (c *Client) SetIncludeCircuits(include bool, locationType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.includeCircuits = include
+	c.circuitLocationType = locationType
+}
+
+// getDeviceCircuits returns the circuits terminating at the site of a Nautobot 1.x device, or at
+// the location of a 2.x device or below it. With locationType the location is the nearest
+// ancestor of that type; devices without one have no circuits.
+func (c *Client) getDeviceCircuits(ctx context.Context, deviceData *DeviceData, locationType string) ([]Circuit, error) {
+	if deviceData.SiteID != "" {
+		return c.GetCircuits(ctx, url.Values{"site_id": {deviceData.SiteID}})
+	}
+	locationID := deviceData.LocationID
+	if locationType != "" {
+		var err error
+		if locationID, err = c.ancestorOfType(ctx, locationID, locationType); err != nil {
+			return nil, err
+		}
+	}
+	if locationID == "" {
+		return []Circuit{}, nil
+	}
+	// Providers and statuses come with their names at a depth of 1
+	return c.GetCircuits(ctx, url.Values{"location": {locationID}, "depth": {"1"}})
+}
+
+// ancestorOfType returns the ID of the nearest of a location and its ancestors of a location
+// type, "" if there is none. Locations are cached for siteRegionTTL.
+func (c *Client) ancestorOfType(ctx context.Context, locationID, locationType string) (string, error) {
+	for depth := 0; locationID != "" && depth < maxLocationDepth; depth++ {
+		location, err := c.getLocation(ctx, locationID)
+		if err != nil {
+			return "", err
+		}
+		if location.locationType == locationType {
+			return locationID, nil
+		}
+		locationID = location.parentID
+	}
+	return "", nil
+}
+
+// GetCircuits returns the circuits matching a circuits list filter, e.g. site_id. The nodes of
+// a site share one request.
+func (c *Client) GetCircuits(ctx context.Context, filter url.Values) ([]Circuit, error) {
+	query := url.Values{"limit": {"1000"}}
+	for key, values := range filter {
+		query[key] = values
+	}
+	result, err := c.share(ctx, "circuits/"+query.Encode(), func(ctx context.Context) (interface{}, error) {
+		raws, err := listAll[json.RawMessage](ctx, c, "/api/circuits/circuits/?"+query.Encode())
+		if err != nil {
+			return []Circuit(nil), fmt.Errorf("failed to list the circuits of %s: %w", filter.Encode(), err)
+		}
+		circuits := make([]Circuit, 0, len(raws))
+		for _, raw := range raws {
+			var result struct {
+				CID      string `json:"cid"`
+				Provider *Ref   `json:"provider"`
+				Status   struct {
+					Value string `json:"value"`
+					// Name is the status of Nautobot 2.x, which dropped the value
+					Name string `json:"name"`
+				} `json:"status"`
+			}
+			if err := json.Unmarshal(raw, &result); err != nil {
+				return []Circuit(nil), fmt.Errorf("failed to parse Nautobot response: %w", err)
+			}
+			circuit := Circuit{CID: result.CID, Status: result.Status.Value}
+			if circuit.Status == "" {
+				circuit.Status = strings.ToLower(result.Status.Name)
+			}
+			if result.Provider != nil {
+				circuit.Provider = result.Provider.Name
+				if circuit.Provider == "" {
+					circuit.Provider = result.Provider.Display
+				}
+			}
+			circuits = append(circuits, circuit)
+		}
+		return circuits, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]Circuit), nil
+}
@@ -0,0 +1,601 @@
+// Package nautobot is a client of the Nautobot REST and GraphQL APIs looking up the devices of
+// Kubernetes nodes, with a mock server for development and tests
+package nautobot
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/redact"
+)
+
+// Client is a simple client to query Nautobot for device or rack info.
+type Client struct {
+	mu         sync.RWMutex
+	baseURL    string
+	authToken  string
+	httpClient *http.Client
+
+	// secondaryToken, if set, is tried when authToken is rejected; useSecondary tracks which
+	// of the two is in use
+	secondaryToken string
+	useSecondary   bool
+	// deviceFilters are added to every device query
+	deviceFilters map[string]string
+	// depth is the depth of the nested objects of device queries, not sent if 0
+	depth int
+	// includeRelationships adds the relationships of devices to device queries
+	includeRelationships bool
+	// includeLocations fills the location ancestry of looked up devices
+	includeLocations bool
+	// includeRackGroups fills the rack groups of looked up devices
+	includeRackGroups bool
+	// includeInventoryItems fills the inventory items of looked up devices
+	includeInventoryItems bool
+	// includeHardwareNotices fills the hardware notices of looked up devices
+	includeHardwareNotices bool
+	// includePowerFeeds fills the rack power feeds of looked up devices
+	includePowerFeeds bool
+	// includeCircuits fills the circuits of the site or location of looked up devices, that of
+	// circuitLocationType if set
+	includeCircuits     bool
+	circuitLocationType string
+	// logRequests logs every request, with credentials redacted
+	logRequests bool
+	// majorVersion is the major version of Nautobot at baseURL, 0 until it was read
+	majorVersion int
+	// faults injects faults into the requests of httpClient
+	faults *faultTransport
+
+	// siteRegions caches the region of each site, which every device lookup needs
+	siteRegions siteRegionCache
+	// locations caches the locations of location ancestries
+	locations locationCache
+	// rackGroups caches the rack groups of racks and the parents of rack groups
+	rackGroups rackGroupCache
+	// hardwareNotices caches the hardware notices of device types
+	hardwareNotices hardwareNoticeCache
+	// lookups coalesces concurrent identical device and site lookups into one request
+	lookups singleflight.Group
+}
+
+// DeviceData represents the minimal data we care about from Nautobot
+type DeviceData struct {
+	ID       string
+	Name     string
+	Serial   string
+	SiteID   string
+	SiteName string
+	RackName string
+	// RackID is the rack of the device, if it has one
+	RackID string
+	// LocationID is the location of the device in Nautobot 2.x, or 1.x devices with one
+	LocationID string
+	// Locations holds the names of the device's location and its ancestors keyed by location
+	// type, e.g. "Building". Only lookups including location ancestry fill it.
+	Locations map[string]string
+	// RackGroups holds the names of the rack group of the device's rack and the groups containing
+	// it, nearest first, e.g. row, pod and room. Only lookups including rack groups fill it.
+	RackGroups []string
+	// RegionName is the region of the device's site, TenantName the device's tenant, if any
+	RegionName string
+	TenantName string
+	// PrimaryIP4 and PrimaryIP6 are the device's current primary addresses, if any
+	PrimaryIP4 *IPAddress
+	PrimaryIP6 *IPAddress
+	// Tags are the tags currently applied to the device
+	Tags []Ref
+	// Status is the device's status value (e.g. "active")
+	Status string
+	// CustomFields holds the device's custom field values keyed by field name
+	CustomFields map[string]interface{}
+	// VirtualMachine is set for virtual machines, whose VirtualizationCluster is the cluster they
+	// run in. Hypervisor is their host device, from a custom field the configuration names.
+	VirtualMachine        bool
+	VirtualizationCluster string
+	Hypervisor            string
+	// InventoryItems are the inventory items of the device, e.g. its GPUs. Only lookups including
+	// inventory items fill it, with an empty list for devices without any.
+	InventoryItems []InventoryItem
+	// HardwareNotice is the hardware notice of the device's type in the Device Lifecycle
+	// Management plugin. Only lookups including hardware notices fill it, nil without a notice.
+	HardwareNotice *HardwareNotice
+	// PowerFeeds are the power feeds of the device's rack. Only lookups including power feeds
+	// fill it.
+	PowerFeeds []PowerFeed
+	// Circuits are the circuits terminating at the device's site or location. Only lookups
+	// including circuits fill it, with an empty list for sites without any.
+	Circuits []Circuit
+	// CircuitProviders are the sorted names of the providers of the device's circuits in the
+	// statuses the labeler configuration counts, which fills it from Circuits
+	CircuitProviders []string
+	// Inventory summarizes the inventory items of each configured kind of hardware, e.g. GPUs or
+	// NICs, by name. The labeler configuration fills it from InventoryItems.
+	Inventory map[string]*InventorySummary
+	// Relationships holds the sorted names of the objects related to the device, keyed by
+	// relationship. Only lookups including relationships fill it.
+	Relationships map[string][]string
+
+	// Query is the Nautobot query that matched the device
+	Query string
+	// Raw is the device object exactly as returned by Nautobot
+	Raw json.RawMessage
+}
+
+// InventoryItem is an inventory item of a device
+type InventoryItem struct {
+	Name         string
+	Manufacturer string
+	PartID       string
+	Tags         []string
+	// Raw is the inventory item object exactly as returned by Nautobot
+	Raw json.RawMessage
+}
+
+// HardwareNotice is the end of life notice of a device type in the Device Lifecycle Management
+// plugin, with dates as YYYY-MM-DD and "" where unset
+type HardwareNotice struct {
+	EndOfSale             string `json:"end_of_sale"`
+	EndOfSupport          string `json:"end_of_support"`
+	EndOfSoftwareReleases string `json:"end_of_sw_releases"`
+	EndOfSecurityPatches  string `json:"end_of_security_patches"`
+}
+
+// PowerFeed is a power feed of a rack
+type PowerFeed struct {
+	Name string
+	// Status is the status value of the feed, e.g. "active" or "failed"
+	Status string
+}
+
+// InventorySummary summarizes the inventory items of a kind of hardware of a device
+type InventorySummary struct {
+	// Model is the model of the items, "" if they are of several models or there are none
+	Model string
+	Count int
+	// Fields are the values of further fields the items share, by label suffix
+	Fields map[string]string
+}
+
+// Define the response structure to match the Nautobot API response
+type deviceResponse struct {
+	Results []json.RawMessage `json:"results"`
+}
+
+// deviceResult is a single device of a deviceResponse
+type deviceResult struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Serial string `json:"serial"`
+	Site   struct {
+		ID      string `json:"id"`
+		Display string `json:"display"`
+		Name    string `json:"name"`
+	} `json:"site"`
+	// Location replaces the site in Nautobot 2.x
+	Location *Ref `json:"location"`
+	Rack     struct {
+		ID      string `json:"id"`
+		Display string `json:"display"`
+		Name    string `json:"name"`
+	} `json:"rack"`
+	Tenant     *Ref       `json:"tenant"`
+	PrimaryIP4 *IPAddress `json:"primary_ip4"`
+	PrimaryIP6 *IPAddress `json:"primary_ip6"`
+	Tags       []Ref      `json:"tags"`
+	Status     struct {
+		Value string `json:"value"`
+		// Name is the status of Nautobot 2.x, which dropped the value
+		Name string `json:"name"`
+	} `json:"status"`
+	CustomFields map[string]interface{} `json:"custom_fields"`
+	// Relationships are only included on request
+	Relationships map[string]relationshipResult `json:"relationships"`
+}
+
+// relationshipResult is a relationship of a deviceResult. Nautobot nests the related objects
+// under the side of the relationship opposite the device, or under peer for symmetric ones.
+type relationshipResult struct {
+	Source      *relationshipSide `json:"source"`
+	Destination *relationshipSide `json:"destination"`
+	Peer        *relationshipSide `json:"peer"`
+}
+
+// relationshipSide is a side of a relationshipResult
+type relationshipSide struct {
+	Objects []Ref `json:"objects"`
+}
+
+// nautobotMaxIdleConns is how many idle connections to Nautobot are kept for reuse
+const nautobotMaxIdleConns = 64
+
+// ErrDeviceNotFound is returned when Nautobot has no device matching a node
+var ErrDeviceNotFound = errors.New("no device found in Nautobot")
+
+// NewClient returns a new Client. tlsConfig, if set, configures the TLS
+// connections to Nautobot.
+func NewClient(baseURL, authToken string, tlsConfig *tls.Config) *Client {
+	redact.Register(authToken)
+	nautobotTokenInUse.WithLabelValues("primary").Set(1)
+	faults := &faultTransport{}
+	return &Client{
+		baseURL:    baseURL,
+		authToken:  authToken,
+		httpClient: newHTTPClient(tlsConfig, faults),
+		faults:     faults,
+	}
+}
+
+// newHTTPClient returns the instrumented HTTP client requests to Nautobot are sent with,
+// through faults unless nil
+func newHTTPClient(tlsConfig *tls.Config, faults *faultTransport) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Parallel reconciles would otherwise reopen connections beyond the default of two idle
+	// connections per host
+	transport.MaxIdleConnsPerHost = nautobotMaxIdleConns
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	var next http.RoundTripper = transport
+	if faults != nil {
+		faults.next = transport
+		next = faults
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &instrumentedTransport{next: next},
+	}
+}
+
+// GetDeviceData queries Nautobot for a device's site and rack.
+// In real usage, you'd likely query by a more reliable key, e.g., a device ID or an annotation.
+func (c *Client) GetDeviceData(ctx context.Context, nodeName string) (*DeviceData, error) {
+	// Extract the hostname part (before the first dot) to query Nautobot
+	hostname := ShortHostname(nodeName)
+
+	deviceData, err := c.getSharedDevice(ctx, DeviceFilter(hostname))
+	if errors.Is(err, ErrDeviceNotFound) {
+		return nil, fmt.Errorf("%w for node: %s", ErrDeviceNotFound, nodeName)
+	}
+	return deviceData, err
+}
+
+// GetDevice looks a device up by its exact name, or by its ID if nameOrID is a UUID, for nodes
+// whose names do not match their devices
+func (c *Client) GetDevice(ctx context.Context, nameOrID string) (*DeviceData, error) {
+	deviceData, err := c.getSharedDevice(ctx, DeviceFilter(nameOrID))
+	if errors.Is(err, ErrDeviceNotFound) {
+		return nil, fmt.Errorf("%w named %s", ErrDeviceNotFound, nameOrID)
+	}
+	return deviceData, err
+}
+
+// getSharedDevice looks up the device matching filter. Concurrent lookups of the same device,
+// e.g. queued events for one node or several nodes of one chassis, share a single request.
+func (c *Client) getSharedDevice(ctx context.Context, filter url.Values) (*DeviceData, error) {
+	query := c.withDeviceFilters(filter).Encode()
+	result, err := c.share(ctx, "device/"+query, func(ctx context.Context) (interface{}, error) {
+		return c.getDevice(ctx, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Callers get their own copy of the shared result
+	deviceData := *result.(*DeviceData)
+	return &deviceData, nil
+}
+
+// DeviceFilter returns the query parameters of the device list selecting a device by exact
+// name, or by ID if nameOrID is a UUID
+func DeviceFilter(nameOrID string) url.Values {
+	if IsDeviceID(nameOrID) {
+		return url.Values{"id": {nameOrID}}
+	}
+	return url.Values{"name": {nameOrID}}
+}
+
+// IsDeviceID reports whether s is a device ID, a UUID in its canonical form
+func IsDeviceID(s string) bool {
+	_, err := uuid.Parse(s)
+	return err == nil && len(s) == 36
+}
+
+// share runs fn once for concurrent callers with the same key. fn runs with the context of the
+// caller that started it; the others stop waiting when their own context ends and start over
+// when that caller's context ended instead of theirs.
+func (c *Client) share(ctx context.Context, key string, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	for {
+		results := c.lookups.DoChan(key, func() (interface{}, error) {
+			result, err := fn(ctx)
+			if err != nil && ctx.Err() != nil {
+				return nil, &abandonedCall{err: err}
+			}
+			return result, err
+		})
+		var result singleflight.Result
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case result = <-results:
+		}
+		if result.Shared {
+			nautobotLookupsSharedTotal.Inc()
+		}
+		var abandoned *abandonedCall
+		if errors.As(result.Err, &abandoned) {
+			if ctx.Err() == nil {
+				continue
+			}
+			return nil, abandoned.err
+		}
+		return result.Val, result.Err
+	}
+}
+
+// abandonedCall is the error of a shared call whose context ended
+type abandonedCall struct {
+	err error
+}
+
+func (e *abandonedCall) Error() string {
+	return e.err.Error()
+}
+
+// getDevice looks up the first device matching an encoded query of the device list
+func (c *Client) getDevice(ctx context.Context, query string) (*DeviceData, error) {
+	// Example: GET /api/dcim/devices/?name=<hostname>
+	// This is an example endpoint — adjust to your actual Nautobot configuration/URL scheme.
+	path := "/api/dcim/devices/?" + query
+
+	var deviceResponse deviceResponse
+	if err := c.doRequest(ctx, http.MethodGet, path, nil, &deviceResponse); err != nil {
+		return nil, err
+	}
+
+	if len(deviceResponse.Results) == 0 {
+		return nil, ErrDeviceNotFound
+	}
+
+	deviceData, err := ParseDevice(deviceResponse.Results[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Nautobot response: %w", err)
+	}
+	deviceData.Query = path
+	if err := c.completeDevice(ctx, deviceData); err != nil {
+		return nil, err
+	}
+	return deviceData, nil
+}
+
+// GetVirtualMachine looks up the virtual machine of a node by its short hostname, for nodes
+// without a device. Virtual machines have no rack; their site is that of Nautobot 1.x, or the
+// location of their cluster in Nautobot 2.x.
+func (c *Client) GetVirtualMachine(ctx context.Context, nodeName string) (*DeviceData, error) {
+	c.mu.RLock()
+	depth := max(c.depth, 1)
+	c.mu.RUnlock()
+	query := url.Values{"name": {ShortHostname(nodeName)}, "depth": {strconv.Itoa(depth)}}.Encode()
+	result, err := c.share(ctx, "virtual-machine/"+query, func(ctx context.Context) (interface{}, error) {
+		return c.getVirtualMachine(ctx, query)
+	})
+	if errors.Is(err, ErrDeviceNotFound) {
+		return nil, fmt.Errorf("%w, nor a virtual machine, for node: %s", ErrDeviceNotFound, nodeName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	deviceData := *result.(*DeviceData)
+	return &deviceData, nil
+}
+
+// getVirtualMachine looks up the first virtual machine matching an encoded query of the virtual
+// machine list
+func (c *Client) getVirtualMachine(ctx context.Context, query string) (*DeviceData, error) {
+	path := "/api/virtualization/virtual-machines/?" + query
+	var response deviceResponse
+	if err := c.doRequest(ctx, http.MethodGet, path, nil, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Results) == 0 {
+		return nil, ErrDeviceNotFound
+	}
+
+	raw := response.Results[0]
+	deviceData, err := ParseDevice(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Nautobot response: %w", err)
+	}
+	var vm struct {
+		Cluster *struct {
+			Ref
+			Location *Ref `json:"location"`
+		} `json:"cluster"`
+	}
+	if err := json.Unmarshal(raw, &vm); err != nil {
+		return nil, fmt.Errorf("failed to parse Nautobot response: %w", err)
+	}
+	deviceData.VirtualMachine = true
+	deviceData.Query = path
+	if cluster := vm.Cluster; cluster != nil {
+		deviceData.VirtualizationCluster = cluster.Name
+		if deviceData.VirtualizationCluster == "" {
+			deviceData.VirtualizationCluster = cluster.Display
+		}
+		if deviceData.SiteName == "" && cluster.Location != nil && cluster.Location.Name != "" {
+			deviceData.SiteName, deviceData.LocationID = cluster.Location.Name, cluster.Location.ID
+		} else if deviceData.SiteName == "" && cluster.Location != nil && cluster.Location.ID != "" {
+			location, err := c.getLocation(ctx, cluster.Location.ID)
+			if err != nil {
+				return nil, err
+			}
+			deviceData.SiteName, deviceData.LocationID = location.name, cluster.Location.ID
+		}
+	}
+	if err := c.completeDevice(ctx, deviceData); err != nil {
+		return nil, err
+	}
+	return deviceData, nil
+}
+
+// completeDevice adds the data of a parsed device that takes further requests: the region of
+// its site and, if requested, its location ancestry, rack groups, inventory items, hardware
+// notice, rack power feeds and circuits
+func (c *Client) completeDevice(ctx context.Context, deviceData *DeviceData) error {
+	var err error
+	if deviceData.SiteID != "" {
+		if deviceData.RegionName, err = c.GetSiteRegion(ctx, deviceData.SiteID); err != nil {
+			return err
+		}
+	}
+	c.mu.RLock()
+	includeLocations, includeInventoryItems, includeHardwareNotices := c.includeLocations, c.includeInventoryItems, c.includeHardwareNotices
+	includePowerFeeds, includeRackGroups := c.includePowerFeeds, c.includeRackGroups
+	includeCircuits, circuitLocationType := c.includeCircuits, c.circuitLocationType
+	c.mu.RUnlock()
+	if includeLocations && deviceData.LocationID != "" {
+		if deviceData.Locations, err = c.getDeviceLocationAncestry(ctx, deviceData); err != nil {
+			return err
+		}
+	}
+	if includeRackGroups && deviceData.RackID != "" {
+		if deviceData.RackGroups, err = c.getDeviceRackGroups(ctx, deviceData); err != nil {
+			return err
+		}
+	}
+	if includeInventoryItems && deviceData.ID != "" {
+		if deviceData.InventoryItems, err = c.GetInventoryItems(ctx, deviceData.ID); err != nil {
+			return err
+		}
+	}
+	if includeHardwareNotices {
+		if deviceTypeID := deviceTypeID(deviceData.Raw); deviceTypeID != "" {
+			if deviceData.HardwareNotice, err = c.GetHardwareNotice(ctx, deviceTypeID); err != nil {
+				return err
+			}
+		}
+	}
+	if includePowerFeeds && deviceData.RackID != "" {
+		if deviceData.PowerFeeds, err = c.GetPowerFeeds(ctx, deviceData.RackID); err != nil {
+			return err
+		}
+	}
+	if includeCircuits {
+		if deviceData.Circuits, err = c.getDeviceCircuits(ctx, deviceData, circuitLocationType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deviceTypeID returns the ID of the device type of a device object, "" if it has none
+func deviceTypeID(raw json.RawMessage) string {
+	var device struct {
+		DeviceType *Ref `json:"device_type"`
+	}
+	if err := json.Unmarshal(raw, &device); err != nil || device.DeviceType == nil {
+		return ""
+	}
+	return device.DeviceType.ID
+}
+
+// ShortHostname returns the part of a node name before the first dot, the name of its device
+func ShortHostname(nodeName string) string {
+	if dotIndex := strings.Index(nodeName, "."); dotIndex > 0 {
+		return nodeName[:dotIndex]
+	}
+	return nodeName
+}
+
+// ParseDevice converts a Nautobot device object into DeviceData
+func ParseDevice(raw json.RawMessage) (*DeviceData, error) {
+	var device deviceResult
+	if err := json.Unmarshal(raw, &device); err != nil {
+		return nil, err
+	}
+
+	siteName := device.Site.Name
+	// If name isn't available, fall back to display
+	if siteName == "" {
+		siteName = device.Site.Display
+	}
+	if siteName == "" && device.Location != nil {
+		siteName = device.Location.Name
+	}
+
+	rackName := device.Rack.Name
+	// If name isn't available, fall back to display
+	if rackName == "" {
+		rackName = device.Rack.Display
+	}
+
+	status := device.Status.Value
+	if status == "" {
+		status = strings.ToLower(device.Status.Name)
+	}
+
+	var locationID string
+	if device.Location != nil {
+		locationID = device.Location.ID
+	}
+
+	var tenantName string
+	if device.Tenant != nil {
+		tenantName = device.Tenant.Name
+	}
+
+	var relationships map[string][]string
+	for key, relationship := range device.Relationships {
+		var names []string
+		for _, side := range []*relationshipSide{relationship.Source, relationship.Destination, relationship.Peer} {
+			if side == nil {
+				continue
+			}
+			for _, object := range side.Objects {
+				name := object.Name
+				if name == "" {
+					name = object.Display
+				}
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		if relationships == nil {
+			relationships = map[string][]string{}
+		}
+		relationships[key] = names
+	}
+
+	return &DeviceData{
+		ID:         device.ID,
+		Name:       device.Name,
+		Serial:     device.Serial,
+		SiteID:     device.Site.ID,
+		SiteName:   siteName,
+		RackName:   rackName,
+		RackID:     device.Rack.ID,
+		LocationID: locationID,
+		TenantName: tenantName,
+		PrimaryIP4: device.PrimaryIP4,
+		PrimaryIP6: device.PrimaryIP6,
+		Tags:       device.Tags,
+		Status:     status,
+
+		CustomFields:  device.CustomFields,
+		Relationships: relationships,
+
+		Raw: raw,
+	}, nil
+}
@@ -0,0 +1,176 @@
+package nautobot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Fake is an in-memory Interface for tests. Devices are looked up by the short hostname of
+// nodes, like Client does, or by exact name or ID; Err, if set, fails every call.
+type Fake struct {
+	mu sync.Mutex
+	// Devices are the devices keyed by name
+	Devices map[string]*DeviceData
+	// VirtualMachines are the virtual machines keyed by name
+	VirtualMachines map[string]*DeviceData
+	// DNSNames are the DNS names of IP addresses keyed by IP address ID
+	DNSNames map[string]string
+	// Err is returned by every call instead of a result
+	Err error
+	// Calls counts the calls made per method name
+	Calls map[string]int
+}
+
+// NewFake returns a Fake serving the given devices
+func NewFake(devices ...*DeviceData) *Fake {
+	f := &Fake{Devices: map[string]*DeviceData{}, DNSNames: map[string]string{}}
+	for _, device := range devices {
+		f.Devices[device.Name] = device
+	}
+	return f
+}
+
+// SetDevice adds or replaces a device
+func (f *Fake) SetDevice(device *DeviceData) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Devices == nil {
+		f.Devices = map[string]*DeviceData{}
+	}
+	f.Devices[device.Name] = device
+}
+
+// DeleteDevice removes the device of the given name
+func (f *Fake) DeleteDevice(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.Devices, name)
+}
+
+// SetErr makes every following call fail with err, or succeed again if err is nil
+func (f *Fake) SetErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Err = err
+}
+
+// CallCount returns how often a method was called
+func (f *Fake) CallCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Calls[method]
+}
+
+// call records a call of method and returns the error it fails with, if any. Callers hold mu.
+func (f *Fake) call(ctx context.Context, method string) error {
+	if f.Calls == nil {
+		f.Calls = map[string]int{}
+	}
+	f.Calls[method]++
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.Err
+}
+
+// GetDeviceData implements Interface
+func (f *Fake) GetDeviceData(ctx context.Context, nodeName string) (*DeviceData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "GetDeviceData"); err != nil {
+		return nil, err
+	}
+	device, ok := f.Devices[ShortHostname(nodeName)]
+	if !ok {
+		return nil, fmt.Errorf("%w for node: %s", ErrDeviceNotFound, nodeName)
+	}
+	deviceData := *device
+	return &deviceData, nil
+}
+
+// GetDevice implements Interface
+func (f *Fake) GetDevice(ctx context.Context, nameOrID string) (*DeviceData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "GetDevice"); err != nil {
+		return nil, err
+	}
+	for _, device := range f.Devices {
+		if device.Name == nameOrID || (device.ID != "" && device.ID == nameOrID) {
+			deviceData := *device
+			return &deviceData, nil
+		}
+	}
+	return nil, fmt.Errorf("%w named %s", ErrDeviceNotFound, nameOrID)
+}
+
+// GetDevicesData implements Interface
+func (f *Fake) GetDevicesData(ctx context.Context, names []string) (map[string]*DeviceData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "GetDevicesData"); err != nil {
+		return nil, err
+	}
+	devices := make(map[string]*DeviceData, len(names))
+	for _, name := range names {
+		if device, ok := f.Devices[name]; ok {
+			deviceData := *device
+			devices[name] = &deviceData
+		}
+	}
+	return devices, nil
+}
+
+// GetVirtualMachine implements Interface
+func (f *Fake) GetVirtualMachine(ctx context.Context, nodeName string) (*DeviceData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "GetVirtualMachine"); err != nil {
+		return nil, err
+	}
+	vm, ok := f.VirtualMachines[ShortHostname(nodeName)]
+	if !ok {
+		return nil, fmt.Errorf("%w, nor a virtual machine, for node: %s", ErrDeviceNotFound, nodeName)
+	}
+	deviceData := *vm
+	deviceData.VirtualMachine = true
+	return &deviceData, nil
+}
+
+// ListDevices implements Interface
+func (f *Fake) ListDevices(ctx context.Context) ([]*DeviceData, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "ListDevices"); err != nil {
+		return nil, err
+	}
+	devices := make([]*DeviceData, 0, len(f.Devices))
+	for _, device := range f.Devices {
+		deviceData := *device
+		devices = append(devices, &deviceData)
+	}
+	return devices, nil
+}
+
+// GetIPAddressDNSName implements Interface
+func (f *Fake) GetIPAddressDNSName(ctx context.Context, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.call(ctx, "GetIPAddressDNSName"); err != nil {
+		return "", err
+	}
+	return f.DNSNames[id], nil
+}
+
+// DeviceURL implements Interface
+func (f *Fake) DeviceURL(deviceID string) string {
+	return fmt.Sprintf("https://nautobot.invalid/dcim/devices/%s/", deviceID)
+}
+
+// Ping implements Interface
+func (f *Fake) Ping(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.call(ctx, "Ping")
+}
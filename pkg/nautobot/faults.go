@@ -0,0 +1,119 @@
+package nautobot
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var nautobotFaultsInjectedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "nautobot_labeler_nautobot_faults_injected_total",
+		Help: "Number of faults injected into Nautobot API requests, by fault (latency, error, not_found).",
+	},
+	[]string{"fault"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(nautobotFaultsInjectedTotal)
+}
+
+// FaultInjection makes random requests to Nautobot slow or fail, so backoff, retries and
+// alerts can be tested against a healthy Nautobot, e.g. in staging. Rates are fractions of
+// the requests between 0 and 1.
+type FaultInjection struct {
+	// Latency delays the requests drawn at LatencyRate
+	Latency     time.Duration
+	LatencyRate float64
+	// ErrorRate is the rate of requests answered with 503 Service Unavailable
+	ErrorRate float64
+	// NotFoundRate is the rate of device and virtual machine lookups answered with no results
+	NotFoundRate float64
+}
+
+// Enabled reports whether any fault is injected
+func (f FaultInjection) Enabled() bool {
+	return (f.Latency > 0 && f.LatencyRate > 0) || f.ErrorRate > 0 || f.NotFoundRate > 0
+}
+
+// Validate checks that the rates are fractions and a latency rate comes with a latency
+func (f FaultInjection) Validate() error {
+	for name, rate := range map[string]float64{"latency": f.LatencyRate, "error": f.ErrorRate, "not found": f.NotFoundRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s fault rate %v is not between 0 and 1", name, rate)
+		}
+	}
+	if f.Latency < 0 {
+		return fmt.Errorf("fault latency %s is negative", f.Latency)
+	}
+	if f.LatencyRate > 0 && f.Latency == 0 {
+		return fmt.Errorf("fault latency rate %v is set without a latency", f.LatencyRate)
+	}
+	return nil
+}
+
+// SetFaultInjection injects faults into the following requests, replacing the previous ones.
+// The injected faults show in the request metrics like real ones.
+func (c *Client) SetFaultInjection(faults FaultInjection) {
+	if !faults.Enabled() {
+		c.faults.faults.Store(nil)
+		return
+	}
+	c.faults.faults.Store(&faults)
+}
+
+// faultTransport injects faults into requests before sending them to next
+type faultTransport struct {
+	next   http.RoundTripper
+	faults atomic.Pointer[FaultInjection]
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	faults := t.faults.Load()
+	if faults == nil {
+		return t.next.RoundTrip(req)
+	}
+	if rand.Float64() < faults.LatencyRate {
+		nautobotFaultsInjectedTotal.WithLabelValues("latency").Inc()
+		timer := time.NewTimer(faults.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+	if rand.Float64() < faults.ErrorRate {
+		nautobotFaultsInjectedTotal.WithLabelValues("error").Inc()
+		return injectedResponse(req, http.StatusServiceUnavailable, `{"detail":"Injected fault."}`), nil
+	}
+	if req.Method == http.MethodGet && (req.URL.Path == "/api/dcim/devices/" || req.URL.Path == "/api/virtualization/virtual-machines/") &&
+		rand.Float64() < faults.NotFoundRate {
+		nautobotFaultsInjectedTotal.WithLabelValues("not_found").Inc()
+		return injectedResponse(req, http.StatusOK, `{"count":0,"next":null,"previous":null,"results":[]}`), nil
+	}
+	return t.next.RoundTrip(req)
+}
+
+// injectedResponse returns a JSON response to req that was never sent
+func injectedResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
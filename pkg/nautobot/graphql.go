@@ -0,0 +1,167 @@
+package nautobot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// devicesQueryV1 fetches everything the label mappings can refer to for a set of device names
+// from Nautobot 1.x. The %s is replaced with the arguments of the device filters.
+const devicesQueryV1 = `query ($names: [String]) {
+  devices(name: $names%s) {
+    id
+    name
+    serial
+    site { id name region { name } }
+    rack { name }
+    tenant { name }
+    status { slug }
+    primary_ip4 { id address dns_name }
+    primary_ip6 { id address dns_name }
+    tags { id name }
+    _custom_field_data
+  }
+}`
+
+// devicesQueryV2 is devicesQueryV1 for Nautobot 2.x, which replaced sites and regions with
+// locations and the slugs of statuses with their names
+const devicesQueryV2 = `query ($names: [String]) {
+  devices(name: $names%s) {
+    id
+    name
+    serial
+    location { id name }
+    rack { name }
+    tenant { name }
+    status { name }
+    primary_ip4 { id address dns_name }
+    primary_ip6 { id address dns_name }
+    tags { id name }
+    _custom_field_data
+  }
+}`
+
+// graphQLDevice is a device as returned by devicesQueryV1 or devicesQueryV2
+type graphQLDevice struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Serial string `json:"serial"`
+	Site   *struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Region *Ref   `json:"region"`
+	} `json:"site"`
+	Location *Ref `json:"location"`
+	Rack     *Ref `json:"rack"`
+	Tenant   *Ref `json:"tenant"`
+	Status   *struct {
+		Slug string `json:"slug"`
+		Name string `json:"name"`
+	} `json:"status"`
+	PrimaryIP4   *IPAddress             `json:"primary_ip4"`
+	PrimaryIP6   *IPAddress             `json:"primary_ip6"`
+	Tags         []Ref                  `json:"tags"`
+	CustomFields map[string]interface{} `json:"_custom_field_data"`
+}
+
+// graphQLResponse is the envelope of a Nautobot GraphQL answer
+type graphQLResponse struct {
+	Data struct {
+		Devices []json.RawMessage `json:"devices"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// deviceFilterArguments returns the device filters as GraphQL arguments, sorted by name. JSON
+// strings are valid GraphQL strings; single values are coerced into lists where needed.
+func (c *Client) deviceFilterArguments() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	filters := make([]string, 0, len(c.deviceFilters))
+	for filter := range c.deviceFilters {
+		filters = append(filters, filter)
+	}
+	sort.Strings(filters)
+	var arguments strings.Builder
+	for _, filter := range filters {
+		value, _ := json.Marshal(c.deviceFilters[filter])
+		fmt.Fprintf(&arguments, ", %s: %s", filter, value)
+	}
+	return arguments.String()
+}
+
+// GetDevicesData looks up many devices by name with a single GraphQL query, in the schema of the
+// Nautobot version. The result is keyed by device name; names without a device are left out.
+func (c *Client) GetDevicesData(ctx context.Context, names []string) (map[string]*DeviceData, error) {
+	version, err := c.MajorVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := devicesQueryV1
+	if version >= 2 {
+		query = devicesQueryV2
+	}
+	body := map[string]interface{}{
+		"query":     fmt.Sprintf(query, c.deviceFilterArguments()),
+		"variables": map[string]interface{}{"names": names},
+	}
+	var response graphQLResponse
+	if err := c.doRequest(ctx, http.MethodPost, "/api/graphql/", body, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Errors) > 0 {
+		var messages []string
+		for _, graphQLErr := range response.Errors {
+			messages = append(messages, graphQLErr.Message)
+		}
+		return nil, fmt.Errorf("nautobot GraphQL query failed: %s", strings.Join(messages, "; "))
+	}
+
+	devices := make(map[string]*DeviceData, len(response.Data.Devices))
+	for _, raw := range response.Data.Devices {
+		var device graphQLDevice
+		if err := json.Unmarshal(raw, &device); err != nil {
+			return nil, fmt.Errorf("failed to parse Nautobot GraphQL response: %w", err)
+		}
+		data := &DeviceData{
+			ID:           device.ID,
+			Name:         device.Name,
+			Serial:       device.Serial,
+			PrimaryIP4:   device.PrimaryIP4,
+			PrimaryIP6:   device.PrimaryIP6,
+			Tags:         device.Tags,
+			CustomFields: device.CustomFields,
+			Query:        fmt.Sprintf("graphql devices(name: %q)", device.Name),
+			Raw:          raw,
+		}
+		if device.Site != nil {
+			data.SiteID, data.SiteName = device.Site.ID, device.Site.Name
+			if device.Site.Region != nil {
+				data.RegionName = device.Site.Region.Name
+			}
+		}
+		if device.Location != nil {
+			data.SiteName, data.LocationID = device.Location.Name, device.Location.ID
+		}
+		if device.Rack != nil {
+			data.RackName = device.Rack.Name
+		}
+		if device.Tenant != nil {
+			data.TenantName = device.Tenant.Name
+		}
+		if device.Status != nil {
+			data.Status = device.Status.Slug
+			if data.Status == "" {
+				data.Status = strings.ToLower(device.Status.Name)
+			}
+		}
+		devices[device.Name] = data
+	}
+	return devices, nil
+}
@@ -0,0 +1,71 @@
+package nautobot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetDevicesDataQueryByVersion(t *testing.T) {
+	tests := []struct {
+		version    string
+		device     string
+		wantFields []string
+		wantSite   string
+		wantStatus string
+	}{
+		{
+			version:    "1.6.8",
+			device:     `{"id":"d1","name":"worker-1","site":{"id":"s1","name":"ams1","region":{"name":"eu"}},"status":{"slug":"active"}}`,
+			wantFields: []string{"site { id name region { name } }", "status { slug }"},
+			wantSite:   "ams1",
+			wantStatus: "active",
+		},
+		{
+			version:    "2.2.0",
+			device:     `{"id":"d1","name":"worker-1","location":{"id":"l1","name":"ams1"},"status":{"name":"Active"}}`,
+			wantFields: []string{"location { id name }", "status { name }"},
+			wantSite:   "ams1",
+			wantStatus: "active",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			var query string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/api/status/" {
+					_, _ = w.Write([]byte(`{"nautobot-version":"` + tt.version + `"}`))
+					return
+				}
+				var body struct {
+					Query string `json:"query"`
+				}
+				_ = json.NewDecoder(req.Body).Decode(&body)
+				query = body.Query
+				_, _ = w.Write([]byte(`{"data":{"devices":[` + tt.device + `]}}`))
+			}))
+			defer server.Close()
+			c := NewClient(server.URL, "token", nil)
+
+			devices, err := c.GetDevicesData(context.Background(), []string{"worker-1"})
+			if err != nil {
+				t.Fatalf("GetDevicesData() error = %v", err)
+			}
+			for _, field := range tt.wantFields {
+				if !strings.Contains(query, field) {
+					t.Errorf("query %q does not select %q", query, field)
+				}
+			}
+			device := devices["worker-1"]
+			if device == nil {
+				t.Fatalf("GetDevicesData() = %v, want worker-1", devices)
+			}
+			if device.SiteName != tt.wantSite || device.Status != tt.wantStatus {
+				t.Errorf("site, status = %q, %q, want %q, %q", device.SiteName, device.Status, tt.wantSite, tt.wantStatus)
+			}
+		})
+	}
+}
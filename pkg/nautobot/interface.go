@@ -0,0 +1,30 @@
+package nautobot
+
+import "context"
+
+// Interface is the read side of Client the controller looks devices up with. Controllers
+// embedding the package depend on it rather than on *Client, so they can be tested with a Fake.
+type Interface interface {
+	// GetDeviceData returns the device of a node, or an error wrapping ErrDeviceNotFound
+	GetDeviceData(ctx context.Context, nodeName string) (*DeviceData, error)
+	// GetDevice returns the device with an exact name or ID, or an error wrapping ErrDeviceNotFound
+	GetDevice(ctx context.Context, nameOrID string) (*DeviceData, error)
+	// GetDevicesData looks up many devices by name, leaving out names without a device
+	GetDevicesData(ctx context.Context, names []string) (map[string]*DeviceData, error)
+	// GetVirtualMachine returns the virtual machine of a node, or an error wrapping
+	// ErrDeviceNotFound
+	GetVirtualMachine(ctx context.Context, nodeName string) (*DeviceData, error)
+	// ListDevices returns all devices
+	ListDevices(ctx context.Context) ([]*DeviceData, error)
+	// GetIPAddressDNSName returns the DNS name of an IP address
+	GetIPAddressDNSName(ctx context.Context, id string) (string, error)
+	// DeviceURL returns the UI URL of a device
+	DeviceURL(deviceID string) string
+	// Ping checks that Nautobot is reachable and accepts the token
+	Ping(ctx context.Context) error
+}
+
+var (
+	_ Interface = &Client{}
+	_ Interface = &Fake{}
+)
@@ -0,0 +1,94 @@
+package nautobot
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	nautobotRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_nautobot_requests_total",
+			Help: "Number of requests sent to the Nautobot API, by method, endpoint and status code.",
+		},
+		[]string{"method", "endpoint", "code"},
+	)
+
+	nautobotRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "nautobot_labeler_nautobot_request_duration_seconds",
+			Help:    "Latency of Nautobot API requests in seconds, by method and endpoint.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 12),
+		},
+		[]string{"method", "endpoint"},
+	)
+
+	nautobotResponseBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_nautobot_response_bytes_total",
+			Help: "Bytes of Nautobot API response bodies as transferred, by content encoding (gzip, identity).",
+		},
+		[]string{"encoding"},
+	)
+
+	nautobotLookupsSharedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "nautobot_labeler_nautobot_lookups_shared_total",
+			Help: "Number of device and site lookups that shared one in-flight Nautobot request with concurrent identical lookups.",
+		},
+	)
+
+	nautobotTokenInUse = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "nautobot_labeler_nautobot_token_in_use",
+			Help: "1 for the Nautobot token (primary or secondary) currently used, 0 for the other.",
+		},
+		[]string{"token"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		nautobotRequestsTotal,
+		nautobotRequestDuration,
+		nautobotResponseBytesTotal,
+		nautobotLookupsSharedTotal,
+		nautobotTokenInUse,
+	)
+}
+
+// uuidPattern matches object IDs in Nautobot API paths
+var uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+
+// endpointLabel reduces a request path to a low-cardinality endpoint name, e.g.
+// /api/dcim/devices/<uuid>/ becomes /api/dcim/devices/{id}/.
+func endpointLabel(path string) string {
+	return uuidPattern.ReplaceAllString(path, "{id}")
+}
+
+// instrumentedTransport records request counts and latencies for every Nautobot API call.
+type instrumentedTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := endpointLabel(req.URL.Path)
+	started := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+
+	nautobotRequestDuration.WithLabelValues(req.Method, endpoint).Observe(time.Since(started).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	nautobotRequestsTotal.WithLabelValues(req.Method, endpoint, code).Inc()
+
+	return resp, err
+}
@@ -0,0 +1,394 @@
+package nautobot
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// MockToken is the token the controller uses to talk to the mock Nautobot
+const MockToken = "mock-token"
+
+// MockFixtures is the format of a --mock-nautobot fixtures file: device, site, virtual machine,
+// inventory item and power feed objects shaped like those of the Nautobot API. A saved /api/dcim/devices/ response can be used as is, its
+// results are served as devices.
+type MockFixtures struct {
+	Devices []map[string]interface{} `json:"devices"`
+	Sites   []map[string]interface{} `json:"sites"`
+	// VirtualMachines are served as is, with their cluster nested like at depth 1
+	VirtualMachines []map[string]interface{} `json:"virtualMachines"`
+	// InventoryItems reference their device by ID, like {device: {id: node-1}}
+	InventoryItems []map[string]interface{} `json:"inventoryItems"`
+	// PowerFeeds reference their rack by ID, like {rack: {id: rack-1}, status: {value: failed}}
+	PowerFeeds []map[string]interface{} `json:"powerFeeds"`
+
+	Results  []map[string]interface{} `json:"results"`
+	Count    int                      `json:"count"`
+	Next     *string                  `json:"next"`
+	Previous *string                  `json:"previous"`
+}
+
+// MockServer serves canned devices and sites from a fixtures file with the parts of the
+// Nautobot API the controller reads, so it can be run end-to-end without a real Nautobot, e.g.
+// in kind clusters and demos. Device updates are kept in memory.
+type MockServer struct {
+	// Latency delays every response, to mimic a remote Nautobot
+	Latency time.Duration
+
+	requests atomic.Int64
+
+	mu              sync.Mutex
+	devices         []map[string]interface{}
+	sites           map[string]map[string]interface{}
+	virtualMachines []map[string]interface{}
+	inventoryItems  []map[string]interface{}
+	powerFeeds      []map[string]interface{}
+}
+
+// LoadMockServer reads a YAML or JSON fixtures file. Objects without an id get their name as
+// ID.
+func LoadMockServer(path string) (*MockServer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mock Nautobot fixtures: %w", err)
+	}
+	var fixtures MockFixtures
+	if err := yaml.UnmarshalStrict(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse mock Nautobot fixtures %s: %w", path, err)
+	}
+	return NewMockServer(fixtures), nil
+}
+
+// NewMockServer returns a mock serving the given fixtures
+func NewMockServer(fixtures MockFixtures) *MockServer {
+	m := &MockServer{
+		devices:         append(fixtures.Devices, fixtures.Results...),
+		sites:           map[string]map[string]interface{}{},
+		virtualMachines: fixtures.VirtualMachines,
+		inventoryItems:  fixtures.InventoryItems,
+		powerFeeds:      fixtures.PowerFeeds,
+	}
+	for _, device := range m.devices {
+		if _, ok := device["id"]; !ok {
+			device["id"] = device["name"]
+		}
+	}
+	for _, site := range fixtures.Sites {
+		if _, ok := site["id"]; !ok {
+			site["id"] = site["name"]
+		}
+		m.sites[fmt.Sprint(site["id"])] = site
+	}
+	// Sites only referenced by devices are served without a region
+	for _, device := range m.devices {
+		if site, ok := device["site"].(map[string]interface{}); ok && site["id"] != nil {
+			if _, ok := m.sites[fmt.Sprint(site["id"])]; !ok {
+				m.sites[fmt.Sprint(site["id"])] = site
+			}
+		}
+	}
+	return m
+}
+
+// Requests returns the number of requests served so far
+func (m *MockServer) Requests() int64 {
+	return m.requests.Load()
+}
+
+// SetDevice adds a device, or replaces the device of the same name. A device without an id gets
+// its name as ID.
+func (m *MockServer) SetDevice(device map[string]interface{}) {
+	if _, ok := device["id"]; !ok {
+		device["id"] = device["name"]
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.devices = setMockObject(m.devices, device)
+	if site, ok := device["site"].(map[string]interface{}); ok && site["id"] != nil {
+		if _, ok := m.sites[fmt.Sprint(site["id"])]; !ok {
+			m.sites[fmt.Sprint(site["id"])] = site
+		}
+	}
+}
+
+// DeleteDevice removes the device of the given name
+func (m *MockServer) DeleteDevice(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.devices = deleteMockObject(m.devices, name)
+}
+
+// Device returns a copy of the device of the given name as served, including updates, or nil
+func (m *MockServer) Device(name string) map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, device := range m.devices {
+		if device["name"] == name {
+			// Copied through JSON, which also gives the device the types clients decode
+			data, err := json.Marshal(device)
+			if err != nil {
+				return nil
+			}
+			var copied map[string]interface{}
+			if err := json.Unmarshal(data, &copied); err != nil {
+				return nil
+			}
+			return copied
+		}
+	}
+	return nil
+}
+
+// SetVirtualMachine adds a virtual machine, or replaces the virtual machine of the same name
+func (m *MockServer) SetVirtualMachine(vm map[string]interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.virtualMachines = setMockObject(m.virtualMachines, vm)
+}
+
+// DeleteVirtualMachine removes the virtual machine of the given name
+func (m *MockServer) DeleteVirtualMachine(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.virtualMachines = deleteMockObject(m.virtualMachines, name)
+}
+
+// setMockObject replaces the object of the same name in objects, or appends it
+func setMockObject(objects []map[string]interface{}, object map[string]interface{}) []map[string]interface{} {
+	for i, existing := range objects {
+		if existing["name"] == object["name"] {
+			objects[i] = object
+			return objects
+		}
+	}
+	return append(objects, object)
+}
+
+// deleteMockObject removes the objects of a name from objects
+func deleteMockObject(objects []map[string]interface{}, name string) []map[string]interface{} {
+	kept := objects[:0]
+	for _, object := range objects {
+		if object["name"] != name {
+			kept = append(kept, object)
+		}
+	}
+	return kept
+}
+
+// Start serves the mock API on a random loopback port and returns its base URL. The server
+// runs until the process exits.
+func (m *MockServer) Start() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to start mock Nautobot: %w", err)
+	}
+	go func() {
+		_ = http.Serve(listener, m)
+	}()
+	return "http://" + listener.Addr().String(), nil
+}
+
+// ServeHTTP implements the Nautobot endpoints used to look devices, their inventory items and
+// virtual machines up, plus device updates and the GraphQL device query
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	m.requests.Add(1)
+	time.Sleep(m.Latency)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Like Nautobot behind a compressing proxy, clients asking for it get gzip
+	if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
+		gzipWriter := gzip.NewWriter(w)
+		defer gzipWriter.Close()
+		w = gzipResponseWriter{ResponseWriter: w, writer: gzipWriter}
+	}
+
+	path := req.URL.Path
+	switch {
+	case path == "/api/status/" && req.Method == http.MethodGet:
+		writeJSON(w, map[string]string{"nautobot-version": "mock"})
+	case path == "/api/dcim/devices/" && req.Method == http.MethodGet:
+		name, id := req.URL.Query().Get("name"), req.URL.Query().Get("id")
+		results := []map[string]interface{}{}
+		for _, device := range m.devices {
+			if (name == "" || device["name"] == name) && (id == "" || device["id"] == id) {
+				results = append(results, device)
+			}
+		}
+		writeJSON(w, map[string]interface{}{"count": len(results), "next": nil, "results": results})
+	case strings.HasPrefix(path, "/api/dcim/devices/"):
+		device := m.device(strings.Trim(strings.TrimPrefix(path, "/api/dcim/devices/"), "/"))
+		switch {
+		case device == nil:
+			mockNotFound(w)
+		case req.Method == http.MethodGet:
+			writeJSON(w, device)
+		case req.Method == http.MethodPatch:
+			var fields map[string]interface{}
+			if err := json.NewDecoder(req.Body).Decode(&fields); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			updateMockDevice(device, fields)
+			writeJSON(w, device)
+		default:
+			http.Error(w, "method not supported by the mock Nautobot", http.StatusMethodNotAllowed)
+		}
+	case path == "/api/virtualization/virtual-machines/" && req.Method == http.MethodGet:
+		name := req.URL.Query().Get("name")
+		results := []map[string]interface{}{}
+		for _, vm := range m.virtualMachines {
+			if name == "" || vm["name"] == name {
+				results = append(results, vm)
+			}
+		}
+		writeJSON(w, map[string]interface{}{"count": len(results), "next": nil, "results": results})
+	case path == "/api/dcim/inventory-items/" && req.Method == http.MethodGet:
+		deviceID := req.URL.Query().Get("device_id")
+		results := []map[string]interface{}{}
+		for _, item := range m.inventoryItems {
+			if device, ok := item["device"].(map[string]interface{}); ok && fmt.Sprint(device["id"]) == deviceID {
+				results = append(results, item)
+			}
+		}
+		writeJSON(w, map[string]interface{}{"count": len(results), "next": nil, "results": results})
+	case path == "/api/dcim/power-feeds/" && req.Method == http.MethodGet:
+		rackID := req.URL.Query().Get("rack_id")
+		results := []map[string]interface{}{}
+		for _, feed := range m.powerFeeds {
+			if rack, ok := feed["rack"].(map[string]interface{}); ok && fmt.Sprint(rack["id"]) == rackID {
+				results = append(results, feed)
+			}
+		}
+		writeJSON(w, map[string]interface{}{"count": len(results), "next": nil, "results": results})
+	case path == "/api/graphql/" && req.Method == http.MethodPost:
+		// Only the device query of the bulk resync is supported
+		var query struct {
+			Variables struct {
+				Names []string `json:"names"`
+			} `json:"variables"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		devices := []map[string]interface{}{}
+		for _, name := range query.Variables.Names {
+			for _, device := range m.devices {
+				if device["name"] == name {
+					devices = append(devices, m.graphQLDevice(device))
+				}
+			}
+		}
+		writeJSON(w, map[string]interface{}{"data": map[string]interface{}{"devices": devices}})
+	case strings.HasPrefix(path, "/api/ipam/ip-addresses/") && req.Method == http.MethodGet:
+		address := m.ipAddress(strings.Trim(strings.TrimPrefix(path, "/api/ipam/ip-addresses/"), "/"))
+		if address == nil {
+			mockNotFound(w)
+			return
+		}
+		writeJSON(w, address)
+	case strings.HasPrefix(path, "/api/dcim/sites/") && req.Method == http.MethodGet:
+		site, ok := m.sites[strings.Trim(strings.TrimPrefix(path, "/api/dcim/sites/"), "/")]
+		if !ok {
+			mockNotFound(w)
+			return
+		}
+		writeJSON(w, site)
+	default:
+		http.Error(w, "endpoint not supported by the mock Nautobot", http.StatusNotImplemented)
+	}
+}
+
+// gzipResponseWriter compresses a response body
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer io.Writer
+}
+
+// Write implements http.ResponseWriter
+func (w gzipResponseWriter) Write(p []byte) (int, error) {
+	return w.writer.Write(p)
+}
+
+// device returns the device with the given ID, or nil
+func (m *MockServer) device(id string) map[string]interface{} {
+	for _, device := range m.devices {
+		if fmt.Sprint(device["id"]) == id {
+			return device
+		}
+	}
+	return nil
+}
+
+// ipAddress returns the primary IP address of a device with the given ID, or nil
+func (m *MockServer) ipAddress(id string) map[string]interface{} {
+	for _, device := range m.devices {
+		for _, key := range []string{"primary_ip4", "primary_ip6"} {
+			if address, ok := device[key].(map[string]interface{}); ok && fmt.Sprint(address["id"]) == id {
+				return address
+			}
+		}
+	}
+	return nil
+}
+
+// graphQLDevice converts a REST device object into the shape of devicesQuery results
+func (m *MockServer) graphQLDevice(device map[string]interface{}) map[string]interface{} {
+	converted := map[string]interface{}{}
+	for _, key := range []string{"id", "name", "serial", "rack", "tenant", "primary_ip4", "primary_ip6", "tags"} {
+		converted[key] = device[key]
+	}
+	converted["_custom_field_data"] = device["custom_fields"]
+	if status, ok := device["status"].(map[string]interface{}); ok {
+		converted["status"] = map[string]interface{}{"slug": status["value"]}
+	}
+	if site, ok := device["site"].(map[string]interface{}); ok {
+		withRegion := map[string]interface{}{"id": site["id"], "name": site["name"]}
+		if known, ok := m.sites[fmt.Sprint(site["id"])]; ok {
+			withRegion["region"] = known["region"]
+		}
+		converted["site"] = withRegion
+	}
+	return converted
+}
+
+// updateMockDevice applies a PATCH to a device. Custom fields are merged like Nautobot does.
+func updateMockDevice(device, fields map[string]interface{}) {
+	for key, value := range fields {
+		updates, isMap := value.(map[string]interface{})
+		existing, hasMap := device[key].(map[string]interface{})
+		if key == "custom_fields" && isMap && hasMap {
+			for name, fieldValue := range updates {
+				existing[name] = fieldValue
+			}
+			continue
+		}
+		device[key] = value
+	}
+}
+
+// mockNotFound answers like Nautobot does for unknown objects
+func mockNotFound(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	_, _ = w.Write([]byte(`{"detail":"Not found."}` + "\n"))
+}
+
+// writeJSON encodes v as the indented JSON response body
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(v)
+}
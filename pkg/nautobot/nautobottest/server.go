@@ -0,0 +1,193 @@
+// Package nautobottest provides a fake Nautobot server for integration tests of code using the
+// nautobot client or the controller, e.g. in operators embedding them. It serves the endpoints of
+// nautobot.MockServer over httptest, with devices and virtual machines changed by the test and
+// injected failures.
+package nautobottest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/your-org/k8s-nautobot-node-labeler/pkg/nautobot"
+)
+
+// Token is the API token the fake accepts
+const Token = nautobot.MockToken
+
+// Failure makes requests fail
+type Failure struct {
+	// Path is the prefix of the paths of the failing requests, e.g. /api/dcim/devices/; all
+	// requests fail if empty
+	Path string
+	// Status is the HTTP status the requests fail with; the connection is closed without an
+	// answer if 0
+	Status int
+	// Times is how many requests fail before the failure heals; requests fail until
+	// ClearFailures if 0
+	Times int
+}
+
+// Request is a request the server received
+type Request struct {
+	Method string
+	// Path is the request path without the query, e.g. /api/dcim/devices/
+	Path  string
+	Query string
+}
+
+// Server is a fake Nautobot listening on a loopback port
+type Server struct {
+	// Mock serves the requests that do not fail
+	Mock *nautobot.MockServer
+
+	server *httptest.Server
+
+	mu       sync.Mutex
+	latency  time.Duration
+	failures []*Failure
+	requests []Request
+}
+
+// NewServer starts a fake Nautobot serving fixtures. Close it when done.
+func NewServer(fixtures nautobot.MockFixtures) *Server {
+	s := &Server{Mock: nautobot.NewMockServer(fixtures)}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL returns the base URL of the server, e.g. http://127.0.0.1:41234
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Client returns a Nautobot client of the server
+func (s *Server) Client() *nautobot.Client {
+	return nautobot.NewClient(s.server.URL, Token, nil)
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// SetDevice adds a device shaped like the objects of the Nautobot API, e.g.
+// {name: worker-1, site: {id: fra1, name: fra1}, rack: {id: r1, name: r1}}, or replaces the
+// device of the same name
+func (s *Server) SetDevice(device map[string]interface{}) {
+	s.Mock.SetDevice(device)
+}
+
+// DeleteDevice removes the device of the given name
+func (s *Server) DeleteDevice(name string) {
+	s.Mock.DeleteDevice(name)
+}
+
+// Device returns the device of the given name with the updates it received, or nil
+func (s *Server) Device(name string) map[string]interface{} {
+	return s.Mock.Device(name)
+}
+
+// SetVirtualMachine adds a virtual machine, or replaces the virtual machine of the same name
+func (s *Server) SetVirtualMachine(vm map[string]interface{}) {
+	s.Mock.SetVirtualMachine(vm)
+}
+
+// DeleteVirtualMachine removes the virtual machine of the given name
+func (s *Server) DeleteVirtualMachine(name string) {
+	s.Mock.DeleteVirtualMachine(name)
+}
+
+// SetLatency delays every following response by latency
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// InjectFailure makes the following requests matching failure fail. Of several failures
+// matching a request, the one injected first applies.
+func (s *Server) InjectFailure(failure Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure)
+}
+
+// ClearFailures heals all injected failures
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+// Requests returns the requests received so far, oldest first
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestCount returns the number of requests received so far with a path starting with prefix
+func (s *Server) RequestCount(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, request := range s.requests {
+		if strings.HasPrefix(request.Path, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// serveHTTP records a request and fails it if a failure matches, or lets the mock answer
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Authorization") != "Token "+Token {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"detail":"Invalid token."}` + "\n"))
+		return
+	}
+
+	latency, failure := s.record(req)
+	time.Sleep(latency)
+	switch {
+	case failure == nil:
+		s.Mock.ServeHTTP(w, req)
+	case failure.Status == 0:
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "connection cannot be closed", http.StatusInternalServerError)
+			return
+		}
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			_ = conn.Close()
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(failure.Status)
+		_, _ = w.Write([]byte(`{"detail":"Injected failure."}` + "\n"))
+	}
+}
+
+// record records a request and returns the latency and failure, if any, it gets
+func (s *Server) record(req *http.Request) (time.Duration, *Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, Request{Method: req.Method, Path: req.URL.Path, Query: req.URL.RawQuery})
+	for i, failure := range s.failures {
+		if !strings.HasPrefix(req.URL.Path, failure.Path) {
+			continue
+		}
+		if failure.Times > 0 {
+			failure.Times--
+			if failure.Times == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return s.latency, failure
+	}
+	return s.latency, nil
+}
@@ -0,0 +1,81 @@
+package nautobot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrPermissionDenied is wrapped by the errors of permission checks of permissions the token
+// lacks
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Actions of permissions
+const (
+	PermissionView   = "view"
+	PermissionAdd    = "add"
+	PermissionChange = "change"
+)
+
+// Permission is an action on the objects of an API endpoint
+type Permission struct {
+	// Path is the list endpoint of the objects, e.g. /api/dcim/devices/
+	Path string
+	// Action is PermissionView, PermissionAdd or PermissionChange
+	Action string
+}
+
+// String returns the permission as action and path, e.g. "view /api/dcim/devices/"
+func (p Permission) String() string {
+	return p.Action + " " + p.Path
+}
+
+// CheckPermission checks that the token has a permission without changing anything, returning
+// an error wrapping ErrPermissionDenied if it lacks it. View permissions are checked by listing
+// an object; add and change permissions by the methods Nautobot's OPTIONS metadata of the list,
+// or of its first object for change, allows the token. Change permissions of endpoints without
+// any object the token can see cannot be checked and pass.
+func (c *Client) CheckPermission(ctx context.Context, permission Permission) error {
+	var list struct {
+		Results []Ref `json:"results"`
+	}
+	if err := c.doRequest(ctx, http.MethodGet, permission.Path+"?limit=1", nil, &list); err != nil {
+		return permissionError(permission, err)
+	}
+
+	var method, path string
+	switch permission.Action {
+	case PermissionView:
+		return nil
+	case PermissionAdd:
+		method, path = http.MethodPost, permission.Path
+	case PermissionChange:
+		if len(list.Results) == 0 {
+			return nil
+		}
+		method, path = http.MethodPut, permission.Path+list.Results[0].ID+"/"
+	default:
+		return fmt.Errorf("unknown action of permission %s", permission)
+	}
+	var metadata struct {
+		Actions map[string]json.RawMessage `json:"actions"`
+	}
+	if err := c.doRequest(ctx, http.MethodOptions, path, nil, &metadata); err != nil {
+		return permissionError(permission, err)
+	}
+	if _, ok := metadata.Actions[method]; !ok {
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, permission)
+	}
+	return nil
+}
+
+// permissionError returns the error of a failed request of a permission check
+func permissionError(permission Permission, err error) error {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, permission)
+	}
+	return fmt.Errorf("failed to check permission %s: %w", permission, err)
+}
@@ -0,0 +1,171 @@
+package nautobot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxRackGroupLevels bounds the rack groups walked up from a rack, in case of a parent cycle
+const maxRackGroupLevels = 16
+
+// rackGroupCache remembers the rack groups of racks and the parents of rack groups, which rarely
+// change, keyed by rack/<id> and rack-group/<id>
+type rackGroupCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedRackGroup
+}
+
+// cachedRackGroup is a cached rack group, or the rack group of a cached rack as parentID, with
+// the time it was fetched
+type cachedRackGroup struct {
+	name, parentID string
+	fetched        time.Time
+}
+
+// embeddedRackGroup is a rack group nested in a rack object, with its parent nested in turn if
+// the lookup depth reached it
+type embeddedRackGroup struct {
+	Ref
+	Parent json.RawMessage `json:"parent"`
+}
+
+// rackGroupField returns the rack group nested in a rack object, rack_group in Nautobot 2.x and
+// group in 1.x, or nil if the object has neither, e.g. a rack nested as a reference
+func rackGroupField(rack json.RawMessage) json.RawMessage {
+	var fields struct {
+		RackGroup json.RawMessage `json:"rack_group"`
+		Group     json.RawMessage `json:"group"`
+	}
+	if err := json.Unmarshal(rack, &fields); err != nil {
+		return nil
+	}
+	if len(fields.RackGroup) > 0 {
+		return fields.RackGroup
+	}
+	return fields.Group
+}
+
+// getDeviceRackGroups returns the names of the rack groups of a device's rack, nearest first,
+// from the groups nested in its object, looking up the rack and the groups beyond the lookup
+// depth
+func (c *Client) getDeviceRackGroups(ctx context.Context, deviceData *DeviceData) ([]string, error) {
+	var device struct {
+		Rack json.RawMessage `json:"rack"`
+	}
+	_ = json.Unmarshal(deviceData.Raw, &device)
+	raw := rackGroupField(device.Rack)
+	var groupID string
+	if raw == nil {
+		var err error
+		if groupID, err = c.getRackGroupID(ctx, deviceData.RackID); err != nil {
+			return nil, err
+		}
+	} else {
+		var group Ref
+		if err := json.Unmarshal(raw, &group); err == nil {
+			groupID = group.ID
+		}
+	}
+
+	var groups []string
+	for level := 0; groupID != "" && level < maxRackGroupLevels; level++ {
+		var group embeddedRackGroup
+		name := ""
+		if err := json.Unmarshal(raw, &group); err == nil && group.ID == groupID {
+			name = group.Name
+			if name == "" {
+				name = group.Display
+			}
+		}
+		// Beyond the lookup depth rack groups are references without names
+		if name == "" {
+			entry, err := c.getRackGroup(ctx, groupID)
+			if err != nil {
+				return nil, err
+			}
+			groups = append(groups, entry.name)
+			groupID, raw = entry.parentID, nil
+			continue
+		}
+		groups = append(groups, name)
+		groupID, raw = "", group.Parent
+		var parent Ref
+		if err := json.Unmarshal(raw, &parent); err == nil {
+			groupID = parent.ID
+		}
+	}
+	return groups, nil
+}
+
+// getRackGroupID returns the ID of the rack group of a rack, "" if it has none. Racks are
+// cached for siteRegionTTL.
+func (c *Client) getRackGroupID(ctx context.Context, rackID string) (string, error) {
+	entry, err := c.getCachedRackGroup(ctx, "rack/"+rackID, func(ctx context.Context) (cachedRackGroup, error) {
+		var rack json.RawMessage
+		if err := c.doRequest(ctx, http.MethodGet, "/api/dcim/racks/"+rackID+"/", nil, &rack); err != nil {
+			return cachedRackGroup{}, fmt.Errorf("failed to get rack %s: %w", rackID, err)
+		}
+		var group Ref
+		if raw := rackGroupField(rack); raw != nil {
+			_ = json.Unmarshal(raw, &group)
+		}
+		return cachedRackGroup{parentID: group.ID}, nil
+	})
+	return entry.parentID, err
+}
+
+// getRackGroup returns a rack group with the ID of its parent. Rack groups are cached for
+// siteRegionTTL.
+func (c *Client) getRackGroup(ctx context.Context, groupID string) (cachedRackGroup, error) {
+	return c.getCachedRackGroup(ctx, "rack-group/"+groupID, func(ctx context.Context) (cachedRackGroup, error) {
+		var group struct {
+			Ref
+			// The names of nested objects take a depth of 1 in Nautobot 2.x
+			Parent *Ref `json:"parent"`
+		}
+		if err := c.doRequest(ctx, http.MethodGet, "/api/dcim/rack-groups/"+groupID+"/?depth=1", nil, &group); err != nil {
+			return cachedRackGroup{}, fmt.Errorf("failed to get rack group %s: %w", groupID, err)
+		}
+		entry := cachedRackGroup{name: group.Name}
+		if entry.name == "" {
+			entry.name = group.Display
+		}
+		if group.Parent != nil {
+			entry.parentID = group.Parent.ID
+		}
+		return entry, nil
+	})
+}
+
+// getCachedRackGroup returns the cache entry of key if it was fetched within siteRegionTTL, or
+// else fetches and caches it, sharing concurrent fetches
+func (c *Client) getCachedRackGroup(ctx context.Context, key string, fetch func(context.Context) (cachedRackGroup, error)) (cachedRackGroup, error) {
+	cache := &c.rackGroups
+	cache.mu.Lock()
+	entry, ok := cache.entries[key]
+	cache.mu.Unlock()
+	if ok && time.Since(entry.fetched) < siteRegionTTL {
+		return entry, nil
+	}
+
+	result, err := c.share(ctx, key, func(ctx context.Context) (interface{}, error) {
+		return fetch(ctx)
+	})
+	if err != nil {
+		return cachedRackGroup{}, err
+	}
+	entry = result.(cachedRackGroup)
+	entry.fetched = time.Now()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.entries == nil {
+		cache.entries = map[string]cachedRackGroup{}
+	}
+	cache.entries[key] = entry
+	return entry, nil
+}
@@ -0,0 +1,58 @@
+package nautobot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MajorVersion returns the major version of Nautobot, e.g. 2, read from /api/status/ once per
+// URL. Versions that do not parse, like that of the mock, count as 1, whose objects the mock
+// serves.
+func (c *Client) MajorVersion(ctx context.Context) (int, error) {
+	c.mu.RLock()
+	version := c.majorVersion
+	c.mu.RUnlock()
+	if version != 0 {
+		return version, nil
+	}
+
+	var status struct {
+		Version string `json:"nautobot-version"`
+	}
+	if err := c.doRequest(ctx, http.MethodGet, "/api/status/", nil, &status); err != nil {
+		return 0, fmt.Errorf("failed to get the Nautobot version: %w", err)
+	}
+	version, err := strconv.Atoi(strings.SplitN(status.Version, ".", 2)[0])
+	if err != nil || version < 1 {
+		version = 1
+	}
+	c.mu.Lock()
+	c.majorVersion = version
+	c.mu.Unlock()
+	return version, nil
+}
+
+// tagFilter returns the name of the tag filter of object lists, which Nautobot 2.x renamed
+func (c *Client) tagFilter(ctx context.Context) (string, error) {
+	version, err := c.MajorVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	if version >= 2 {
+		return "tags", nil
+	}
+	return "tag", nil
+}
+
+// statusField returns the value of the status field of objects written to Nautobot, e.g. for
+// "Active": its slug, "active", on 1.x and a reference by name on 2.x, where statuses are related
+// objects
+func statusField(version int, name string) interface{} {
+	if version >= 2 {
+		return map[string]interface{}{"name": name}
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,176 @@
+package plugin
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// codec encodes the messages in the protobuf wire format of mapping.proto, so plugins can use
+// code generated from it while the controller needs no generated code. codec_test.go checks it
+// against messages of the mapping.proto descriptor; keep both in sync with the file.
+type codec struct{}
+
+// Name implements encoding.Codec. It is the content subtype of protobuf, which the messages are.
+func (codec) Name() string {
+	return "proto"
+}
+
+// Marshal implements encoding.Codec
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	var b []byte
+	switch m := v.(type) {
+	case *MapRequest:
+		b = appendString(b, 1, m.NodeName)
+		b = appendBytes(b, 2, m.Node)
+		b = appendBytes(b, 3, m.Device)
+		b = appendString(b, 4, m.ClusterName)
+		b = appendStringMap(b, 5, m.Labels)
+	case *MapResponse:
+		b = appendStringMap(b, 1, m.Labels)
+		for _, taint := range m.Taints {
+			var entry []byte
+			entry = appendString(entry, 1, taint.Key)
+			entry = appendString(entry, 2, taint.Value)
+			entry = appendString(entry, 3, taint.Effect)
+			b = protowire.AppendTag(b, 2, protowire.BytesType)
+			b = protowire.AppendBytes(b, entry)
+		}
+	default:
+		return nil, fmt.Errorf("cannot encode %T as a mapping plugin message", v)
+	}
+	return b, nil
+}
+
+// Unmarshal implements encoding.Codec
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	switch m := v.(type) {
+	case *MapRequest:
+		return consumeFields(data, func(num protowire.Number, value []byte) error {
+			switch num {
+			case 1:
+				m.NodeName = string(value)
+			case 2:
+				m.Node = append([]byte(nil), value...)
+			case 3:
+				m.Device = append([]byte(nil), value...)
+			case 4:
+				m.ClusterName = string(value)
+			case 5:
+				return consumeMapEntry(value, &m.Labels)
+			}
+			return nil
+		})
+	case *MapResponse:
+		return consumeFields(data, func(num protowire.Number, value []byte) error {
+			switch num {
+			case 1:
+				return consumeMapEntry(value, &m.Labels)
+			case 2:
+				var taint Taint
+				err := consumeFields(value, func(num protowire.Number, value []byte) error {
+					switch num {
+					case 1:
+						taint.Key = string(value)
+					case 2:
+						taint.Value = string(value)
+					case 3:
+						taint.Effect = string(value)
+					}
+					return nil
+				})
+				m.Taints = append(m.Taints, taint)
+				return err
+			}
+			return nil
+		})
+	}
+	return fmt.Errorf("cannot decode %T as a mapping plugin message", v)
+}
+
+// appendString appends a string field, omitted when empty like in proto3
+func appendString(b []byte, num protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// appendBytes appends a bytes field, omitted when empty like in proto3
+func appendBytes(b []byte, num protowire.Number, value []byte) []byte {
+	if len(value) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+// appendStringMap appends a map<string, string> field as its entries, sorted by key
+func appendStringMap(b []byte, num protowire.Number, values map[string]string) []byte {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry []byte
+		entry = appendString(entry, 1, key)
+		entry = appendString(entry, 2, values[key])
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+// consumeFields calls fn with the number and value of every length-delimited field, skipping
+// fields of other wire types, which the messages do not use
+func consumeFields(data []byte, fn func(num protowire.Number, value []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		value, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := fn(num, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumeMapEntry adds a map<string, string> entry to values
+func consumeMapEntry(entry []byte, values *map[string]string) error {
+	var key, value string
+	err := consumeFields(entry, func(num protowire.Number, field []byte) error {
+		switch num {
+		case 1:
+			key = string(field)
+		case 2:
+			value = string(field)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if *values == nil {
+		*values = map[string]string{}
+	}
+	(*values)[key] = value
+	return nil
+}
@@ -0,0 +1,382 @@
+package plugin
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// mappingProto is the descriptor of mapping.proto as protoc compiles it. Messages built from
+// it are encoded by the protobuf runtime like those of code generated from mapping.proto, the
+// reference the codec is checked against. It is built once, as messages of different
+// descriptors never compare equal.
+func mappingProto(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+	mappingProtoOnce.Do(func() {
+		mappingProtoFile, mappingProtoErr = buildMappingProto()
+	})
+	if mappingProtoErr != nil {
+		t.Fatalf("invalid descriptor: %v", mappingProtoErr)
+	}
+	return mappingProtoFile
+}
+
+var (
+	mappingProtoOnce sync.Once
+	mappingProtoFile protoreflect.FileDescriptor
+	mappingProtoErr  error
+)
+
+// buildMappingProto compiles the descriptor of mapping.proto
+func buildMappingProto() (protoreflect.FileDescriptor, error) {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		f := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Type: typ.Enum(), Label: label.Enum()}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	const (
+		str     = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		bytes   = descriptorpb.FieldDescriptorProto_TYPE_BYTES
+		message = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+		pkg     = ".nautobot.labeler.plugin.v1alpha1."
+	)
+	labelsEntry := &descriptorpb.DescriptorProto{
+		Name:    proto.String("LabelsEntry"),
+		Field:   []*descriptorpb.FieldDescriptorProto{field("key", 1, str, "", false), field("value", 2, str, "", false)},
+		Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("mapping.proto"),
+		Package: proto.String("nautobot.labeler.plugin.v1alpha1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("MapRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("node_name", 1, str, "", false),
+					field("node", 2, bytes, "", false),
+					field("device", 3, bytes, "", false),
+					field("cluster_name", 4, str, "", false),
+					field("labels", 5, message, pkg+"MapRequest.LabelsEntry", true),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{labelsEntry},
+			},
+			{
+				Name: proto.String("Taint"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, str, "", false),
+					field("value", 2, str, "", false),
+					field("effect", 3, str, "", false),
+				},
+			},
+			{
+				Name: proto.String("MapResponse"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("labels", 1, message, pkg+"MapResponse.LabelsEntry", true),
+					field("taints", 2, message, pkg+"Taint", true),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{proto.Clone(labelsEntry).(*descriptorpb.DescriptorProto)},
+			},
+		},
+	}
+	return protodesc.NewFile(file, nil)
+}
+
+// protoFieldPattern matches the field declarations of mapping.proto, e.g. "repeated Taint taints = 2;"
+var protoFieldPattern = regexp.MustCompile(`^\s*(?:repeated\s+)?([\w<>, ]+?)\s+(\w+)\s*=\s*(\d+);`)
+
+func TestMappingProtoDescriptor(t *testing.T) {
+	data, err := os.ReadFile("mapping.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The fields declared per message, as "type name = number"
+	declared := map[string][]string{}
+	var message string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "message ") {
+			message = strings.Fields(line)[1]
+			continue
+		}
+		if match := protoFieldPattern.FindStringSubmatch(line); match != nil && message != "" {
+			declared[message] = append(declared[message], strings.ReplaceAll(match[1], " ", "")+" "+match[2]+" = "+match[3])
+		}
+	}
+
+	messages := mappingProto(t).Messages()
+	if len(declared) != messages.Len() {
+		t.Errorf("mapping.proto declares %d messages, the descriptor has %d", len(declared), messages.Len())
+	}
+	for i := 0; i < messages.Len(); i++ {
+		descriptor := messages.Get(i)
+		var got []string
+		fields := descriptor.Fields()
+		for j := 0; j < fields.Len(); j++ {
+			f := fields.Get(j)
+			typ := f.Kind().String()
+			switch {
+			case f.IsMap():
+				typ = "map<" + f.MapKey().Kind().String() + "," + f.MapValue().Kind().String() + ">"
+			case f.Kind() == protoreflect.MessageKind:
+				typ = string(f.Message().Name())
+			}
+			got = append(got, typ+" "+string(f.Name())+" = "+strconv.Itoa(int(f.Number())))
+		}
+		want := declared[string(descriptor.Name())]
+		sort.Strings(got)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: descriptor fields %v, mapping.proto declares %v", descriptor.Name(), got, want)
+		}
+	}
+}
+
+// newMessage returns an empty message of mapping.proto
+func newMessage(t *testing.T, name protoreflect.Name) *dynamicpb.Message {
+	t.Helper()
+	descriptor := mappingProto(t).Messages().ByName(name)
+	if descriptor == nil {
+		t.Fatalf("mapping.proto has no message %s", name)
+	}
+	return dynamicpb.NewMessage(descriptor)
+}
+
+// setStringMap sets a map<string, string> field of m
+func setStringMap(m *dynamicpb.Message, name protoreflect.Name, values map[string]string) {
+	field := m.Descriptor().Fields().ByName(name)
+	entries := m.Mutable(field).Map()
+	for key, value := range values {
+		entries.Set(protoreflect.ValueOfString(key).MapKey(), protoreflect.ValueOfString(value))
+	}
+}
+
+// getStringMap returns a map<string, string> field of m, nil when it has no entries
+func getStringMap(m *dynamicpb.Message, name protoreflect.Name) map[string]string {
+	var values map[string]string
+	m.Get(m.Descriptor().Fields().ByName(name)).Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+		if values == nil {
+			values = map[string]string{}
+		}
+		values[key.String()] = value.String()
+		return true
+	})
+	return values
+}
+
+// requestMessage builds the mapping.proto message of request
+func requestMessage(t *testing.T, request *MapRequest) *dynamicpb.Message {
+	m := newMessage(t, "MapRequest")
+	fields := m.Descriptor().Fields()
+	m.Set(fields.ByName("node_name"), protoreflect.ValueOfString(request.NodeName))
+	m.Set(fields.ByName("node"), protoreflect.ValueOfBytes(request.Node))
+	m.Set(fields.ByName("device"), protoreflect.ValueOfBytes(request.Device))
+	m.Set(fields.ByName("cluster_name"), protoreflect.ValueOfString(request.ClusterName))
+	setStringMap(m, "labels", request.Labels)
+	return m
+}
+
+// responseMessage builds the mapping.proto message of response
+func responseMessage(t *testing.T, response *MapResponse) *dynamicpb.Message {
+	m := newMessage(t, "MapResponse")
+	setStringMap(m, "labels", response.Labels)
+	taints := m.Mutable(m.Descriptor().Fields().ByName("taints")).List()
+	for _, taint := range response.Taints {
+		entry := taints.NewElement().Message()
+		fields := entry.Descriptor().Fields()
+		entry.Set(fields.ByName("key"), protoreflect.ValueOfString(taint.Key))
+		entry.Set(fields.ByName("value"), protoreflect.ValueOfString(taint.Value))
+		entry.Set(fields.ByName("effect"), protoreflect.ValueOfString(taint.Effect))
+		taints.Append(protoreflect.ValueOfMessage(entry))
+	}
+	return m
+}
+
+// appendUnknownFields appends fields of every wire type with numbers mapping.proto does not use,
+// like a plugin built from a newer mapping.proto sends them
+func appendUnknownFields(b []byte) []byte {
+	b = protowire.AppendTag(b, 100, protowire.VarintType)
+	b = protowire.AppendVarint(b, 42)
+	b = protowire.AppendTag(b, 101, protowire.BytesType)
+	b = protowire.AppendString(b, "unknown")
+	b = protowire.AppendTag(b, 102, protowire.Fixed32Type)
+	b = protowire.AppendFixed32(b, 7)
+	b = protowire.AppendTag(b, 103, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, 7)
+}
+
+func TestCodecMapRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		request MapRequest
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "all fields",
+			request: MapRequest{
+				NodeName:    "node-1",
+				Node:        []byte(`{"metadata":{"name":"node-1"}}`),
+				Device:      []byte(`{"id":"1","name":"node-1"}`),
+				ClusterName: "cluster-1",
+				Labels: map[string]string{
+					"nautobot.io/rack": "rack-1",
+					"nautobot.io/site": "site-1",
+					"nautobot.io/role": "",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The codec encodes the request like the generated code does
+			data, err := codec{}.Marshal(&tt.request)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			decoded := newMessage(t, "MapRequest")
+			if err := proto.Unmarshal(data, decoded); err != nil {
+				t.Fatalf("proto.Unmarshal() error = %v", err)
+			}
+			if want := requestMessage(t, &tt.request); !proto.Equal(decoded, want) {
+				t.Errorf("Marshal() decodes to %v, want %v", decoded, want)
+			}
+			if labels := getStringMap(decoded, "labels"); !reflect.DeepEqual(labels, tt.request.Labels) {
+				t.Errorf("Marshal() labels = %v, want %v", labels, tt.request.Labels)
+			}
+
+			// and decodes the request encoded by the generated code, ignoring unknown fields
+			data, err = proto.Marshal(requestMessage(t, &tt.request))
+			if err != nil {
+				t.Fatalf("proto.Marshal() error = %v", err)
+			}
+			var request MapRequest
+			if err := (codec{}).Unmarshal(appendUnknownFields(data), &request); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(request, tt.request) {
+				t.Errorf("Unmarshal() = %+v, want %+v", request, tt.request)
+			}
+		})
+	}
+}
+
+func TestCodecMapResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response MapResponse
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "labels and taints",
+			response: MapResponse{
+				Labels: map[string]string{
+					"nautobot.io/rack":  "rack-1",
+					"nautobot.io/empty": "",
+				},
+				Taints: []Taint{
+					{Key: "nautobot.io/maintenance", Value: "true", Effect: "NoSchedule"},
+					{Key: "nautobot.io/offline", Effect: "NoExecute"},
+					{Key: "nautobot.io/maintenance", Value: "true", Effect: "NoSchedule"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := codec{}.Marshal(&tt.response)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			decoded := newMessage(t, "MapResponse")
+			if err := proto.Unmarshal(data, decoded); err != nil {
+				t.Fatalf("proto.Unmarshal() error = %v", err)
+			}
+			if want := responseMessage(t, &tt.response); !proto.Equal(decoded, want) {
+				t.Errorf("Marshal() decodes to %v, want %v", decoded, want)
+			}
+
+			data, err = proto.Marshal(responseMessage(t, &tt.response))
+			if err != nil {
+				t.Fatalf("proto.Marshal() error = %v", err)
+			}
+			var response MapResponse
+			if err := (codec{}).Unmarshal(appendUnknownFields(data), &response); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			if !reflect.DeepEqual(response, tt.response) {
+				t.Errorf("Unmarshal() = %+v, want %+v", response, tt.response)
+			}
+		})
+	}
+}
+
+func TestCodecUnknownFieldsInEntries(t *testing.T) {
+	// Unknown fields inside map entries and taints are ignored too
+	var entry []byte
+	entry = appendString(entry, 1, "nautobot.io/rack")
+	entry = appendUnknownFields(entry)
+	entry = appendString(entry, 2, "rack-1")
+	var taint []byte
+	taint = appendUnknownFields(taint)
+	taint = appendString(taint, 1, "nautobot.io/offline")
+	taint = appendString(taint, 3, "NoExecute")
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendBytes(data, entry)
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendBytes(data, taint)
+
+	// The protobuf runtime accepts the message as well
+	if err := proto.Unmarshal(data, newMessage(t, "MapResponse")); err != nil {
+		t.Fatalf("proto.Unmarshal() error = %v", err)
+	}
+	var response MapResponse
+	if err := (codec{}).Unmarshal(data, &response); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	want := MapResponse{
+		Labels: map[string]string{"nautobot.io/rack": "rack-1"},
+		Taints: []Taint{{Key: "nautobot.io/offline", Effect: "NoExecute"}},
+	}
+	if !reflect.DeepEqual(response, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", response, want)
+	}
+}
+
+func TestCodecErrors(t *testing.T) {
+	if _, err := (codec{}).Marshal(&Taint{}); err == nil {
+		t.Error("Marshal() of a Taint succeeded")
+	}
+	if err := (codec{}).Unmarshal(nil, &Taint{}); err == nil {
+		t.Error("Unmarshal() into a Taint succeeded")
+	}
+	// A truncated length-delimited field
+	data := protowire.AppendTag(nil, 1, protowire.BytesType)
+	data = protowire.AppendVarint(data, 10)
+	if err := (codec{}).Unmarshal(data, &MapRequest{}); err == nil {
+		t.Error("Unmarshal() of a truncated message succeeded")
+	}
+}
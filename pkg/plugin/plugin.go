@@ -0,0 +1,106 @@
+// Package plugin implements the gRPC protocol of out-of-process mapping plugins, which compute
+// the labels and taints of nodes from their Nautobot devices with logic of their own. The
+// protocol is defined in mapping.proto; plugins can be written in any language from it, or in Go
+// with NewServer.
+package plugin
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc"
+)
+
+// mapMethod is the full name of the Map RPC
+const mapMethod = "/nautobot.labeler.plugin.v1alpha1.MappingPlugin/Map"
+
+// MapRequest is what the controller sends for a node
+type MapRequest struct {
+	NodeName string
+	// Node is the Node object, JSON encoded
+	Node []byte
+	// Device is the device object exactly as returned by Nautobot, JSON encoded
+	Device      []byte
+	ClusterName string
+	// Labels are the labels rendered by the configured mappings
+	Labels map[string]string
+}
+
+// Taint is a node taint wanted by a plugin
+type Taint struct {
+	Key    string
+	Value  string
+	Effect string
+}
+
+// MapResponse is the desired state of a node computed by a plugin
+type MapResponse struct {
+	// Labels are the desired labels, replacing the rendered ones
+	Labels map[string]string
+	// Taints are the desired taints set by the controller
+	Taints []Taint
+}
+
+// Mapper computes the desired labels and taints of a node
+type Mapper interface {
+	Map(ctx context.Context, req *MapRequest) (*MapResponse, error)
+}
+
+// Client calls a mapping plugin
+type Client struct {
+	conn *grpc.ClientConn
+}
+
+// NewClient returns a client of the plugin at target, e.g. unix:///run/plugin/plugin.sock or
+// localhost:9500. The connection is established on first use.
+func NewClient(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.NewClient(target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create mapping plugin client: %w", err)
+	}
+	return &Client{conn: conn}, nil
+}
+
+// Map implements Mapper
+func (c *Client) Map(ctx context.Context, req *MapRequest) (*MapResponse, error) {
+	resp := &MapResponse{}
+	if err := c.conn.Invoke(ctx, mapMethod, req, resp, grpc.ForceCodec(codec{})); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Close closes the connection to the plugin
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// NewServer returns a gRPC server serving mapper as a mapping plugin
+func NewServer(mapper Mapper, opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(append(opts, grpc.ForceServerCodec(codec{}))...)
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "nautobot.labeler.plugin.v1alpha1.MappingPlugin",
+		HandlerType: (*Mapper)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Map",
+			Handler:    mapHandler,
+		}},
+		Metadata: "mapping.proto",
+	}, mapper)
+	return server
+}
+
+// mapHandler decodes a Map call and passes it to the Mapper
+func mapHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &MapRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(Mapper).Map(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: mapMethod}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(Mapper).Map(ctx, req.(*MapRequest))
+	})
+}
@@ -0,0 +1,34 @@
+package redact
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Recorder returns an EventRecorder redacting the messages of the events it records with
+// recorder
+func Recorder(recorder record.EventRecorder) record.EventRecorder {
+	return &redactingRecorder{recorder: recorder}
+}
+
+// redactingRecorder is a record.EventRecorder redacting event messages
+type redactingRecorder struct {
+	recorder record.EventRecorder
+}
+
+// Event implements record.EventRecorder
+func (r *redactingRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	r.recorder.Event(object, eventtype, reason, String(message))
+}
+
+// Eventf implements record.EventRecorder
+func (r *redactingRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.recorder.Event(object, eventtype, reason, String(fmt.Sprintf(messageFmt, args...)))
+}
+
+// AnnotatedEventf implements record.EventRecorder
+func (r *redactingRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", String(fmt.Sprintf(messageFmt, args...)))
+}
@@ -0,0 +1,129 @@
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Core returns a zap core redacting the messages and the fields of the entries it writes to core:
+// strings, errors and fmt.Stringers, and the JSON of reflected values, e.g. of zap.Any, and of
+// object and array marshalers. Sample the returned core rather than core, as samplers
+// write entries without checking the core they wrap.
+func Core(core zapcore.Core) zapcore.Core {
+	return &redactingCore{Core: core}
+}
+
+// redactingCore is a zapcore.Core redacting entries
+type redactingCore struct {
+	zapcore.Core
+}
+
+// With implements zapcore.Core
+func (c *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: c.Core.With(redactFields(fields))}
+}
+
+// Check implements zapcore.Core
+func (c *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+// Write implements zapcore.Core
+func (c *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = String(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+// redactFields returns fields with their string, error, fmt.Stringer, reflected, object and
+// array values redacted
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case zapcore.StringType:
+			field.String = String(field.String)
+		case zapcore.ByteStringType:
+			if value, ok := field.Interface.([]byte); ok {
+				field.Interface = []byte(String(string(value)))
+			}
+		case zapcore.ErrorType:
+			if err, ok := field.Interface.(error); ok {
+				field.Interface = Error(err)
+			}
+		case zapcore.StringerType:
+			if stringer, ok := field.Interface.(fmt.Stringer); ok {
+				field.Interface = redactedStringer{stringer: stringer}
+			}
+		case zapcore.ReflectType, zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.InlineMarshalerType:
+			field = redactStructured(field)
+		}
+		redacted[i] = field
+	}
+	return redacted
+}
+
+// redactStructured returns a field of a reflected value or a marshaler as its redacted JSON.
+// Values that fail to encode are replaced with the error, inline marshalers keep their own key.
+func redactStructured(field zapcore.Field) zapcore.Field {
+	encoder := zapcore.NewMapObjectEncoder()
+	var value interface{}
+	var err error
+	switch marshaler := field.Interface.(type) {
+	case zapcore.ObjectMarshaler:
+		err = marshaler.MarshalLogObject(encoder)
+		value = encoder.Fields
+	case zapcore.ArrayMarshaler:
+		err = encoder.AddArray(field.Key, marshaler)
+		value = encoder.Fields[field.Key]
+	default:
+		value = field.Interface
+	}
+	if err != nil {
+		return zap.String(field.Key+"Error", String(err.Error()))
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return zap.String(field.Key+"Error", String(err.Error()))
+	}
+	redacted := String(string(data))
+	if !json.Valid([]byte(redacted)) {
+		// A redaction crossed the syntax of the JSON, keep it as text
+		return zap.String(field.Key, redacted)
+	}
+	if field.Type == zapcore.InlineMarshalerType {
+		return zap.Inline(rawObject(redacted))
+	}
+	return zap.Reflect(field.Key, json.RawMessage(redacted))
+}
+
+// rawObject is an inline marshaler adding the fields of a redacted JSON object
+type rawObject string
+
+// MarshalLogObject implements zapcore.ObjectMarshaler
+func (o rawObject) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(o), &fields); err != nil {
+		return err
+	}
+	for key, value := range fields {
+		if err := encoder.AddReflected(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactedStringer is a fmt.Stringer whose string is redacted
+type redactedStringer struct {
+	stringer fmt.Stringer
+}
+
+func (s redactedStringer) String() string {
+	return String(s.stringer.String())
+}
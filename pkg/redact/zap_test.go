@@ -0,0 +1,65 @@
+package redact
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestCore(t *testing.T) {
+	Register("s3cr3t-token")
+
+	tests := []struct {
+		name  string
+		field zap.Field
+		want  string
+	}{
+		{name: "string", field: zap.String("value", "token s3cr3t-token"), want: `"value":"token [REDACTED]"`},
+		{name: "byte string", field: zap.ByteString("value", []byte("token s3cr3t-token")), want: `"value":"token [REDACTED]"`},
+		{name: "error", field: zap.Error(errors.New("invalid token s3cr3t-token")), want: `"error":"invalid token [REDACTED]"`},
+		{name: "stringer", field: zap.Stringer("value", stringer("token s3cr3t-token")), want: `"value":"token [REDACTED]"`},
+		{name: "any map", field: zap.Any("value", map[string]string{"token": "s3cr3t-token"}), want: `"value":{"token":"[REDACTED]"}`},
+		{name: "reflected struct", field: zap.Reflect("value", struct{ Token string }{Token: "s3cr3t-token"}), want: `"value":{"Token":"[REDACTED]"}`},
+		{name: "object", field: zap.Object("value", object{"token": "s3cr3t-token"}), want: `"value":{"token":"[REDACTED]"}`},
+		{name: "array", field: zap.Strings("value", []string{"s3cr3t-token", "ams1"}), want: `"value":["[REDACTED]","ams1"]`},
+		{name: "inline", field: zap.Inline(object{"token": "s3cr3t-token"}), want: `"token":"[REDACTED]"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+			logger := zap.New(Core(zapcore.NewCore(encoder, zapcore.AddSync(&out), zapcore.DebugLevel)))
+
+			logger.Info("using s3cr3t-token", tt.field)
+			logger.With(tt.field).Info("with")
+			got := out.String()
+			if strings.Contains(got, "s3cr3t-token") {
+				t.Errorf("logged the secret: %s", got)
+			}
+			if strings.Count(got, tt.want) != 2 {
+				t.Errorf("logged %s, want %s in both entries", got, tt.want)
+			}
+		})
+	}
+}
+
+// stringer is a fmt.Stringer returning itself
+type stringer string
+
+func (s stringer) String() string {
+	return string(s)
+}
+
+// object is a zapcore.ObjectMarshaler adding its strings
+type object map[string]string
+
+func (o object) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	for key, value := range o {
+		encoder.AddString(key, value)
+	}
+	return nil
+}